@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"myapp/internal/config"
+)
+
+// runConfig runs the config subcommand:
+//
+//	config print [-redacted] [-config file] [-<key> value ...]
+//	config reference
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: config print [-redacted] | config reference")
+	}
+
+	switch args[0] {
+	case "print":
+		fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+		redacted := fs.Bool("redacted", false, "Replace secrets with [REDACTED]")
+		loader := config.NewLoader(fs)
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		cfg, err := loader.Load()
+		if err != nil {
+			return err
+		}
+		return config.Print(os.Stdout, cfg, *redacted)
+	case "reference":
+		return config.Reference(os.Stdout)
+	default:
+		return fmt.Errorf("unknown config command %q, want print or reference", args[0])
+	}
+}
@@ -20,50 +20,81 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
-	"time"
 
 	_ "myapp/docs" // docs is generated by Swag CLI
 	"myapp/internal/bootstrap"
 	"myapp/internal/config"
+	"myapp/internal/database"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfig(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Load configuration
-	cfg, err := config.Load()
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ExitOnError)
+	loader := config.NewLoader(fs)
+	_ = fs.Parse(os.Args[1:])
+	cfg, err := loader.Load()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
-	log.Printf("Configuration loaded: %+v", cfg)
 
 	// Create app instance
 	app, err := bootstrap.NewApp(cfg)
 	if err != nil {
 		log.Fatalf("Failed to create app: %v", err)
 	}
+
+	// Log through slog at the configured level; the standard logger is
+	// routed there too
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: app.LogLevel})))
+	log.Printf("Configuration loaded for the %s environment", cfg.Env)
 	log.Println("App instance created successfully")
 
-	// Close database connection when the application exits
+	// Close database connections when the application exits
 	defer func() {
-		sqlDB, err := app.Database.DB()
-		if err != nil {
-			log.Printf("Failed to get underlying *sql.DB: %v", err)
-			return
-		}
-		if err := sqlDB.Close(); err != nil {
-			log.Printf("Failed to close database connection: %v", err)
+		if err := database.Close(app.Database); err != nil {
+			log.Printf("Failed to close database connections: %v", err)
 		}
 	}()
 
 	// Create server
 	srv := &http.Server{
-		Addr:    cfg.Server.Address,
-		Handler: app.Router,
+		Addr:              cfg.Server.Address,
+		Handler:           app.Router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
 	}
+	// Open event streams would otherwise hold up graceful shutdown
+	srv.RegisterOnShutdown(app.Events.Close)
+
+	// Start the outbox dispatcher and webhook delivery workers
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	workersDone := make(chan struct{})
+	go func() {
+		defer close(workersDone)
+		app.RunBackground(workerCtx)
+	}()
+
+	// Apply reloadable settings on SIGHUP and configuration file changes
+	watcher := config.NewWatcher(loader, cfg)
+	watcher.OnReload(app.Apply)
+	go watcher.Run(workerCtx)
 
 	// Start server in a goroutine
 	go func() {
@@ -80,7 +111,7 @@ func main() {
 	log.Println("Shutting down server...")
 
 	// Create context with timeout for shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	// Shutdown server
@@ -88,5 +119,9 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Let in-flight events and webhook deliveries finish
+	stopWorkers()
+	<-workersDone
+
 	log.Println("Server exited properly")
 }
@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"myapp/internal/model"
+)
+
+// Change is the value of a field before and after an action
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Diff compares the JSON representations of before and after and returns the
+// fields that differ, keyed by JSON name. A nil before or after records a
+// creation or deletion, with every field appearing on one side only.
+func Diff(before, after interface{}) (model.JSONText, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+	for name, value := range b {
+		if other, ok := a[name]; !ok || !reflect.DeepEqual(value, other) {
+			changes[name] = Change{Before: value, After: a[name]}
+		}
+	}
+	for name, value := range a {
+		if _, ok := b[name]; !ok {
+			changes[name] = Change{After: value}
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return model.NewJSONText(changes)
+}
+
+// fields decodes the JSON object representation of v
+func fields(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
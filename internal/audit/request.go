@@ -0,0 +1,26 @@
+// Package audit provides the request metadata and change diffs recorded in
+// the audit log.
+package audit
+
+import "context"
+
+// Request describes the HTTP request an audited action was made in
+type Request struct {
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+type requestKey struct{}
+
+// WithRequest returns a copy of ctx carrying req
+func WithRequest(ctx context.Context, req Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+// RequestFromContext returns the request stored in ctx, or a zero Request
+// for work that does not originate from an HTTP request
+func RequestFromContext(ctx context.Context) Request {
+	req, _ := ctx.Value(requestKey{}).(Request)
+	return req
+}
@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"myapp/internal/repository"
+)
+
+const (
+	// DefaultSequenceInterval is how often the sequencer links new entries
+	DefaultSequenceInterval = time.Second
+
+	// sequenceBatch is the number of entries linked per transaction
+	sequenceBatch = 500
+)
+
+// Sequencer links recorded entries into the audit log's hash chain in the
+// background. Every instance runs one; the repository lets only one of them
+// append links at a time, so audited transactions never wait on the chain.
+type Sequencer struct {
+	repo     repository.AuditRepository
+	interval time.Duration
+}
+
+// NewSequencer creates a Sequencer linking entries every interval, or every
+// DefaultSequenceInterval if interval is not positive
+func NewSequencer(repo repository.AuditRepository, interval time.Duration) *Sequencer {
+	if interval <= 0 {
+		interval = DefaultSequenceInterval
+	}
+	return &Sequencer{repo: repo, interval: interval}
+}
+
+// Run links entries until ctx is cancelled
+func (s *Sequencer) Run(ctx context.Context) {
+	for {
+		n, err := s.repo.ChainPending(ctx, sequenceBatch)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("audit: failed to chain entries", "error", err)
+		}
+
+		// Keep draining while batches come back full
+		if err == nil && n == sequenceBatch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
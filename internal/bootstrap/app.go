@@ -1,17 +1,34 @@
 package bootstrap
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 
+	"myapp/internal/audit"
+	"myapp/internal/cache"
 	"myapp/internal/config"
+	"myapp/internal/database"
+	"myapp/internal/events"
+	"myapp/internal/feature"
 	"myapp/internal/handler"
+	"myapp/internal/idempotency"
+	"myapp/internal/middleware"
+	"myapp/internal/outbox"
 	"myapp/internal/pkg/jwt"
+	"myapp/internal/ratelimit"
 	"myapp/internal/repository"
 	"myapp/internal/router"
 	"myapp/internal/service"
+	"myapp/internal/storage"
+	"myapp/internal/validation"
+	"myapp/internal/webhook"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
@@ -20,51 +37,308 @@ type App struct {
 	Config   *config.Config
 	Database *gorm.DB
 	Router   http.Handler
+	Events   *events.Bus
+	Outbox   *outbox.Dispatcher
+	Fanout   *outbox.Fanout
+	Audit    *audit.Sequencer
+	Webhooks *webhook.Worker
+	// LogLevel is the level of the application's logger, set from log.level
+	LogLevel *slog.LevelVar
+	// Features are the feature flags, for services and handlers to query
+	Features *feature.Flags
+
+	// Reloadable settings, updated by Apply
+	validator           *validation.Engine
+	limiter             *ratelimit.Limiter
+	registrationEnabled atomic.Bool
+}
+
+// Apply applies the reloadable settings of cfg to the running application.
+// It is called with the initial configuration and after every reload.
+func (a *App) Apply(cfg *config.Config) {
+	var level slog.Level
+	// Validate has checked the level name
+	_ = level.UnmarshalText([]byte(cfg.Log.Level))
+	a.LogLevel.Set(level)
+
+	a.Features.Set(cfg.Features)
+	a.validator.SetConfig(&cfg.Validation)
+	a.limiter.SetLimit(ratelimit.Limit{
+		Requests: cfg.RateLimit.Requests,
+		Window:   cfg.RateLimit.Window,
+	})
+	a.registrationEnabled.Store(cfg.Auth.RegistrationEnabled)
+}
+
+// RunBackground runs the background workers until ctx is cancelled and
+// waits for them to finish their in-flight work
+func (a *App) RunBackground(ctx context.Context) {
+	var wg sync.WaitGroup
+	workers := []func(context.Context){a.Outbox.Run, a.Webhooks.Run, a.Audit.Run}
+	if a.Fanout != nil {
+		workers = append(workers, a.Fanout.Run)
+	}
+	for _, run := range workers {
+		wg.Add(1)
+		go func(run func(context.Context)) {
+			defer wg.Done()
+			run(ctx)
+		}(run)
+	}
+	wg.Wait()
 }
 
 // NewApp creates a new App instance
 func NewApp(cfg *config.Config) (*App, error) {
+	app := &App{
+		Config:    cfg,
+		LogLevel:  new(slog.LevelVar),
+		Features:  feature.New(nil),
+		validator: validation.NewEngine(&cfg.Validation),
+		limiter:   ratelimit.NewLimiter(ratelimit.Limit{}),
+	}
+	app.Apply(cfg)
+
 	// Setup database connection
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.Name,
-		cfg.Database.SSLMode,
-	)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := database.Open(databaseConfig(cfg.Database))
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
 	}
 
-	// Initialize repositories
+	// Initialize repositories. Users are looked up on every authenticated
+	// request, so they are cached.
 	repos := repository.NewRepositories(db)
+	userCache := newUserCache(cfg.Cache)
+	if userCache != nil {
+		repos.User = userCache.Wrap(repos.User)
+	}
 
 	// Initialize JWT service with config
 	jwtService := jwt.NewServiceWithConfig(jwt.ServiceConfig{
-		SecretKey:     cfg.JWT.Secret,
+		SecretKey:     cfg.JWT.Secret.Value(),
 		AccessExpiry:  cfg.JWT.AccessExpiresIn,
 		RefreshExpiry: cfg.JWT.RefreshExpiresIn,
 	})
 
+	// Initialize domain event dispatch. Services record events in the outbox
+	// through the unit of work. Once committed, every instance pushes them to
+	// its connected clients through the bus, and one instance queues them
+	// for registered webhooks. SQLite has no notifications, so events are
+	// pushed to the clients of the instance that dispatches them, which
+	// suits the single instance SQLite is meant for.
+	bus := events.NewBus(cfg.Events.ReplaySize, cfg.Events.ReplayTTL)
+	dispatcher := outbox.NewDispatcher(repos.Outbox, outbox.Config{
+		BatchSize:    cfg.Outbox.BatchSize,
+		PollInterval: cfg.Outbox.PollInterval,
+		Timeout:      cfg.Outbox.Timeout,
+		MaxAttempts:  cfg.Outbox.MaxAttempts,
+		BackoffBase:  cfg.Outbox.BackoffBase,
+		BackoffMax:   cfg.Outbox.BackoffMax,
+		Retention:    cfg.Outbox.Retention,
+	})
+	dispatcher.Subscribe(outbox.AllEvents, "webhooks", webhook.NewEnqueuer(repos.Webhook).Handle)
+	var fanout *outbox.Fanout
+	if database.IsSQLite(db) {
+		dispatcher.Subscribe(outbox.AllEvents, "stream", outbox.Publish(bus))
+	} else {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		fanout = outbox.NewFanout(sqlDB, repos.Outbox, bus, bus.Reset)
+	}
+	uow := repository.NewUnitOfWork(db, dispatcher.Notify)
+	if userCache != nil {
+		uow = userCache.UnitOfWork(uow)
+	}
+
+	webhookWorker := webhook.NewWorker(repos.Webhook, nil, webhook.Config{
+		Workers:      cfg.Webhook.Workers,
+		BatchSize:    cfg.Webhook.BatchSize,
+		Timeout:      cfg.Webhook.Timeout,
+		PollInterval: cfg.Webhook.PollInterval,
+		MaxAttempts:  cfg.Webhook.MaxAttempts,
+		BackoffBase:  cfg.Webhook.BackoffBase,
+		BackoffMax:   cfg.Webhook.BackoffMax,
+	})
+
 	// Initialize services
-	authService := service.NewAuthService(repos.User, jwtService)
-	todoService := service.NewTodoService(repos.Todo)
+	authService := service.NewAuthService(repos.User, repos.Workspace, jwtService, uow, service.AuthConfig{
+		BcryptCost:          cfg.Auth.BcryptCost,
+		RegistrationEnabled: app.registrationEnabled.Load,
+	})
+	todoService := service.NewTodoService(repos.Todo, repos.User, uow)
+	webhookService := service.NewWebhookService(repos.Webhook)
+	auditService := service.NewAuditService(repos.Audit)
+	commentService := service.NewCommentService(repos.Comment, repos.Todo, repos.User, repos.Audit, uow)
+	calendarService := service.NewCalendarService(repos.User, repos.Todo, uow)
+	workspaceService := service.NewWorkspaceService(repos.Workspace, repos.User, uow)
+
+	// Initialize attachment storage
+	blobStore, err := newBlobStore(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	urlSecret := cfg.Attachments.URLSecret.Value()
+	if urlSecret == "" {
+		urlSecret = cfg.JWT.Secret.Value()
+	}
+	attachmentService := service.NewAttachmentService(repos.Attachment, repos.Todo, blobStore, service.AttachmentConfig{
+		MaxSize:      int64(cfg.Attachments.MaxSize),
+		AllowedTypes: cfg.Attachments.AllowedTypes,
+		URLSecret:    []byte(urlSecret),
+		URLTTL:       cfg.Attachments.URLTTL,
+	})
 
 	// Initialize handlers
-	h := &handler.Handler{
-		UserHandler: handler.NewUserHandler(authService),
-		TodoHandler: handler.NewTodoHandler(todoService),
+	h := handler.New(authService, todoService, webhookService, auditService, commentService, calendarService, workspaceService, app.validator, handler.NewStreamHandler(bus, cfg.Stream.HeartbeatInterval), handler.NewAttachmentHandler(attachmentService, int64(cfg.Attachments.MaxSize)), handler.NewFeatureHandler(app.Features))
+
+	// Initialize idempotency key storage
+	var idempotencyStore idempotency.Store
+	switch cfg.Idempotency.Store {
+	case "memory":
+		idempotencyStore = idempotency.NewMemoryStore()
+	case "postgres":
+		idempotencyStore = idempotency.NewPostgresStore(db)
+	default:
+		return nil, fmt.Errorf("unknown idempotency store %q", cfg.Idempotency.Store)
+	}
+	idempotencySecret := cfg.Idempotency.Secret.Value()
+	if idempotencySecret == "" {
+		idempotencySecret = cfg.JWT.Secret.Value()
+	}
+	idempotencyStore, err = idempotency.NewEncryptedStore(idempotencyStore, []byte(idempotencySecret))
+	if err != nil {
+		return nil, err
 	}
 
 	// Setup router
-	r := router.New(h, authService)
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	app.Router = router.New(h, authService, middleware.ClientIP(trustedProxies), middleware.Compress(int(cfg.Compression.MinSize), cfg.Compression.Types),
+		middleware.Idempotency(idempotencyStore, cfg.Idempotency.TTL, int64(cfg.Idempotency.MaxBody)), middleware.RateLimit(app.limiter),
+		middleware.ReadYourWrites(database.NewStickiness(cfg.Database.ReplicaStickiness), jwtService))
+
+	app.Database = db
+	app.Events = bus
+	app.Outbox = dispatcher
+	app.Fanout = fanout
+	app.Audit = audit.NewSequencer(repos.Audit, cfg.Audit.ChainInterval)
+	app.Webhooks = webhookWorker
+	return app, nil
+}
+
+// databaseConfig returns the connection settings of the primary and the
+// replicas, which share its credentials and database
+func databaseConfig(cfg config.Database) database.Config {
+	if cfg.Driver == database.SQLite {
+		return database.Config{
+			Driver:          database.SQLite,
+			DSN:             cfg.Path,
+			MaxOpenConns:    cfg.MaxOpenConns,
+			MaxIdleConns:    cfg.MaxIdleConns,
+			ConnMaxLifetime: cfg.ConnMaxLifetime,
+			ConnMaxIdleTime: cfg.ConnMaxIdleTime,
+		}
+	}
+
+	dsn := func(host, port string) string {
+		settings := []string{
+			"host=" + dsnValue(host),
+			"port=" + dsnValue(port),
+			"user=" + dsnValue(cfg.User),
+			"password=" + dsnValue(cfg.Password.Value()),
+			"dbname=" + dsnValue(cfg.Name),
+			"sslmode=" + dsnValue(cfg.SSLMode),
+		}
+		if cfg.StatementTimeout > 0 {
+			settings = append(settings, fmt.Sprintf("statement_timeout=%d", cfg.StatementTimeout.Milliseconds()))
+		}
+		return strings.Join(settings, " ")
+	}
 
-	return &App{
-		Config:   cfg,
-		Database: db,
-		Router:   r,
-	}, nil
+	replicas := make([]string, len(cfg.Replicas))
+	for i, replica := range cfg.Replicas {
+		host, port, err := net.SplitHostPort(replica)
+		if err != nil {
+			// Validate has checked that it is a bare host
+			host, port = replica, cfg.Port
+		}
+		replicas[i] = dsn(host, port)
+	}
+
+	return database.Config{
+		Driver:          database.Postgres,
+		DSN:             dsn(cfg.Host, cfg.Port),
+		ReplicaDSNs:     replicas,
+		MaxOpenConns:    cfg.MaxOpenConns,
+		MaxIdleConns:    cfg.MaxIdleConns,
+		ConnMaxLifetime: cfg.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.ConnMaxIdleTime,
+		ConnectRetries:  cfg.ConnectRetries,
+		ConnectBackoff:  cfg.ConnectBackoff,
+	}
+}
+
+// dsnQuoter escapes the characters that end a quoted DSN value
+var dsnQuoter = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// dsnValue quotes a value of a keyword/value DSN, so that values with
+// spaces, quotes or backslashes, such as passwords, cannot break out of
+// their setting
+func dsnValue(v string) string {
+	return "'" + dsnQuoter.Replace(v) + "'"
+}
+
+// newUserCache creates the user cache selected by the cache driver, or
+// returns nil if caching is off
+func newUserCache(cfg config.Cache) *repository.UserCache {
+	switch cfg.Driver {
+	case "memory":
+		return repository.NewUserCache(cache.NewLRU(cfg.Size), cfg.TTL)
+	case "redis":
+		redis := cache.NewRedis(cache.RedisConfig{
+			Address:  cfg.Redis.Address,
+			Password: cfg.Redis.Password.Value(),
+			DB:       cfg.Redis.DB,
+			Prefix:   cfg.Redis.Prefix,
+			Timeout:  cfg.Redis.Timeout,
+			PoolSize: cfg.Redis.PoolSize,
+		})
+		// The cache is an optimization, so an unreachable server only
+		// slows requests down
+		if err := redis.Ping(context.Background()); err != nil {
+			slog.Warn("cache: redis is unreachable, users are read from the database until it is back", "error", err)
+		}
+		return repository.NewUserCache(redis, cfg.TTL)
+	default:
+		return nil
+	}
+}
+
+// newBlobStore creates the blob store selected by the storage driver
+func newBlobStore(cfg config.Storage) (storage.BlobStore, error) {
+	switch cfg.Driver {
+	case "local":
+		store, err := storage.NewLocalStore(cfg.LocalDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create blob directory: %w", err)
+		}
+		return store, nil
+	case "s3":
+		return storage.NewS3Store(storage.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey.Value(),
+			Region:    cfg.S3.Region,
+			UseSSL:    cfg.S3.UseSSL,
+		})
+	case "memory":
+		return storage.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
 }
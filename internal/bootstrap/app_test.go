@@ -0,0 +1,45 @@
+package bootstrap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"myapp/internal/config"
+)
+
+func TestDatabaseConfigQuotesValues(t *testing.T) {
+	cfg := config.Database{
+		Host:             "db.internal",
+		Port:             "5432",
+		User:             "app user",
+		Password:         config.Secret(`p@ss word' sslmode=disable \x`),
+		Name:             "todos",
+		SSLMode:          "verify-full",
+		StatementTimeout: 5 * time.Second,
+		Replicas:         []string{"replica.internal:6432"},
+	}
+
+	dbConfig := databaseConfig(cfg)
+	for _, dsn := range append([]string{dbConfig.DSN}, dbConfig.ReplicaDSNs...) {
+		parsed, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			t.Fatalf("ParseConfig(%q): %v", dsn, err)
+		}
+		if parsed.User != cfg.User || parsed.Password != cfg.Password.Value() || parsed.Database != cfg.Name {
+			t.Errorf("parsed user %q, password %q, database %q; want %q, %q, %q",
+				parsed.User, parsed.Password, parsed.Database, cfg.User, cfg.Password.Value(), cfg.Name)
+		}
+		// The password cannot turn off TLS
+		if parsed.TLSConfig == nil {
+			t.Errorf("DSN %q connects without TLS", dsn)
+		}
+		if parsed.RuntimeParams["statement_timeout"] != "5000" {
+			t.Errorf("statement_timeout = %q, want 5000", parsed.RuntimeParams["statement_timeout"])
+		}
+	}
+	if parsed, _ := pgx.ParseConfig(dbConfig.ReplicaDSNs[0]); parsed == nil || parsed.Host != "replica.internal" || parsed.Port != 6432 {
+		t.Errorf("replica DSN %q does not connect to replica.internal:6432", dbConfig.ReplicaDSNs[0])
+	}
+}
@@ -0,0 +1,27 @@
+// Package cache stores short-lived copies of data that is expensive to load,
+// either in process, in an LRU, or in a server that speaks the Redis
+// protocol, so that several instances share it.
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMiss is returned by Get for keys that are not cached
+var ErrMiss = errors.New("cache: miss")
+
+// Cache is a key-value store whose entries expire
+type Cache interface {
+	// Get returns the value of key, or ErrMiss if it is not cached or has
+	// expired
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Add stores value under key for ttl unless key is cached, and reports
+	// whether it did
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Delete removes keys; missing keys are ignored
+	Delete(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestLRU returns an LRU whose clock is advanced by the returned function
+func newTestLRU(size int) (*LRU, func(time.Duration)) {
+	c := NewLRU(size)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	return c, func(d time.Duration) { now = now.Add(d) }
+}
+
+// assertValue checks that key holds want, or is a miss if want is empty
+func assertValue(t *testing.T, c Cache, key, want string) {
+	t.Helper()
+	got, err := c.Get(context.Background(), key)
+	if want == "" {
+		if !errors.Is(err, ErrMiss) {
+			t.Fatalf("Get(%q) = %q, %v; want ErrMiss", key, got, err)
+		}
+		return
+	}
+	if err != nil || string(got) != want {
+		t.Fatalf("Get(%q) = %q, %v; want %q", key, got, err, want)
+	}
+}
+
+func TestLRUSetGetDelete(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestLRU(10)
+
+	assertValue(t, c, "a", "")
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+	assertValue(t, c, "a", "1")
+
+	c.Set(ctx, "a", []byte("3"), time.Minute)
+	assertValue(t, c, "a", "3")
+
+	if err := c.Delete(ctx, "a", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	assertValue(t, c, "a", "")
+	assertValue(t, c, "b", "2")
+}
+
+func TestLRUExpires(t *testing.T) {
+	ctx := context.Background()
+	c, advance := newTestLRU(10)
+
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	advance(time.Minute - time.Nanosecond)
+	assertValue(t, c, "a", "1")
+	advance(time.Nanosecond)
+	assertValue(t, c, "a", "")
+
+	// Setting a key again extends it
+	c.Set(ctx, "b", []byte("1"), time.Minute)
+	advance(30 * time.Second)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+	advance(45 * time.Second)
+	assertValue(t, c, "b", "2")
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestLRU(2)
+
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+	assertValue(t, c, "a", "1")
+	c.Set(ctx, "c", []byte("3"), time.Minute)
+
+	assertValue(t, c, "b", "")
+	assertValue(t, c, "a", "1")
+	assertValue(t, c, "c", "3")
+
+	// Updating a key uses it too
+	c.Set(ctx, "a", []byte("4"), time.Minute)
+	c.Set(ctx, "d", []byte("5"), time.Minute)
+	assertValue(t, c, "c", "")
+	assertValue(t, c, "a", "4")
+}
+
+func TestLRUAdd(t *testing.T) {
+	ctx := context.Background()
+	c, advance := newTestLRU(10)
+
+	if added, err := c.Add(ctx, "a", []byte("1"), time.Minute); !added || err != nil {
+		t.Fatalf("Add of a missing key = %v, %v; want true", added, err)
+	}
+	if added, err := c.Add(ctx, "a", []byte("2"), time.Minute); added || err != nil {
+		t.Fatalf("Add of a cached key = %v, %v; want false", added, err)
+	}
+	assertValue(t, c, "a", "1")
+
+	advance(time.Minute)
+	if added, err := c.Add(ctx, "a", []byte("3"), time.Minute); !added || err != nil {
+		t.Fatalf("Add of an expired key = %v, %v; want true", added, err)
+	}
+	assertValue(t, c, "a", "3")
+}
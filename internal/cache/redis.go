@@ -0,0 +1,262 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RedisConfig configures a Redis cache
+type RedisConfig struct {
+	// Address is the host:port of the server
+	Address string
+	// Password authenticates with AUTH if set
+	Password string
+	// DB is the database selected with SELECT
+	DB int
+	// Prefix is prepended to every key, to share a server with other
+	// applications
+	Prefix string
+	// Timeout bounds connecting and every command
+	Timeout time.Duration
+	// PoolSize is the number of idle connections kept open
+	PoolSize int
+}
+
+// Redis is a Cache stored in a server that speaks the Redis protocol, such
+// as Redis, Valkey or KeyDB. Only GET, SET with PX and NX, DEL, AUTH,
+// SELECT and PING are used. It is safe for concurrent use.
+type Redis struct {
+	config RedisConfig
+	idle   chan *redisConn
+}
+
+// redisError is an error reply from the server. The connection that
+// received it is still usable.
+type redisError string
+
+func (e redisError) Error() string {
+	return "cache: redis: " + string(e)
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// NewRedis creates a Redis cache. Connections are opened on first use.
+func NewRedis(config RedisConfig) *Redis {
+	return &Redis{
+		config: config,
+		idle:   make(chan *redisConn, max(config.PoolSize, 1)),
+	}
+}
+
+// Ping checks that the server is reachable
+func (c *Redis) Ping(ctx context.Context) error {
+	_, err := c.do(ctx, "PING")
+	return err
+}
+
+// Get implements Cache
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := c.do(ctx, "GET", c.config.Prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, ErrMiss
+	}
+	return value, nil
+}
+
+// Set implements Cache
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ms := max(ttl.Milliseconds(), 1)
+	_, err := c.do(ctx, "SET", c.config.Prefix+key, string(value), "PX", strconv.FormatInt(ms, 10))
+	return err
+}
+
+// Add implements Cache
+func (c *Redis) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	ms := max(ttl.Milliseconds(), 1)
+	reply, err := c.do(ctx, "SET", c.config.Prefix+key, string(value), "PX", strconv.FormatInt(ms, 10), "NX")
+	if err != nil {
+		return false, err
+	}
+	// The reply is OK if the key was set and nil if it exists
+	return reply != nil, nil
+}
+
+// Delete implements Cache
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := make([]string, 0, len(keys)+1)
+	args = append(args, "DEL")
+	for _, key := range keys {
+		args = append(args, c.config.Prefix+key)
+	}
+	_, err := c.do(ctx, args...)
+	return err
+}
+
+// Close closes the idle connections
+func (c *Redis) Close() error {
+	var errs []error
+	for {
+		select {
+		case conn := <-c.idle:
+			errs = append(errs, conn.conn.Close())
+		default:
+			return errors.Join(errs...)
+		}
+	}
+}
+
+// do runs a command on a pooled connection and returns its reply: nil, a
+// string, an int64, a []byte or a []interface{}
+func (c *Redis) do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(c.deadline(ctx), args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		conn.conn.Close()
+		return nil, err
+	}
+	c.put(conn)
+	return reply, err
+}
+
+// get takes an idle connection or opens a new one
+func (c *Redis) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-c.idle:
+		return conn, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: c.config.Timeout}
+	nc, err := dialer.DialContext(ctx, "tcp", c.config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("cache: redis: %w", err)
+	}
+	conn := &redisConn{conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	deadline := c.deadline(ctx)
+	if c.config.Password != "" {
+		if _, err := conn.do(deadline, "AUTH", c.config.Password); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	if c.config.DB != 0 {
+		if _, err := conn.do(deadline, "SELECT", strconv.Itoa(c.config.DB)); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// put returns a connection to the pool, closing it if the pool is full
+func (c *Redis) put(conn *redisConn) {
+	select {
+	case c.idle <- conn:
+	default:
+		conn.conn.Close()
+	}
+}
+
+// deadline is when a command started now has to finish
+func (c *Redis) deadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(c.config.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		return d
+	}
+	return deadline
+}
+
+// do sends a command and reads its reply
+func (c *redisConn) do(deadline time.Time, args ...string) (interface{}, error) {
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, fmt.Errorf("cache: redis: %w", err)
+	}
+	return c.read()
+}
+
+// read reads one reply in RESP2
+func (c *redisConn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("cache: redis: %w", err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("cache: redis: malformed reply")
+	}
+	kind, text := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return text, nil
+	case '-':
+		return nil, redisError(text)
+	case ':':
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, errors.New("cache: redis: malformed integer reply")
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, errors.New("cache: redis: malformed bulk reply")
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, fmt.Errorf("cache: redis: %w", err)
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, errors.New("cache: redis: malformed array reply")
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			// An error element is part of the reply, not a failure
+			var replyErr redisError
+			if items[i], err = c.read(); err != nil && !errors.As(err, &replyErr) {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("cache: redis: unexpected reply type %q", kind)
+	}
+}
@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestRedis starts an in-process Redis server and returns a client of
+// it with a password, database and prefix
+func newTestRedis(t *testing.T) (*Redis, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	c := NewRedis(RedisConfig{
+		Address:  server.Addr(),
+		Password: "secret",
+		DB:       2,
+		Prefix:   "myapp:",
+		Timeout:  time.Second,
+		PoolSize: 2,
+	})
+	t.Cleanup(func() { c.Close() })
+	return c, server
+}
+
+func TestRedisSetGetDelete(t *testing.T) {
+	ctx := context.Background()
+	c, server := newTestRedis(t)
+
+	assertValue(t, c, "a", "")
+	// Values are binary safe
+	value := "line 1\r\nline 2\x00"
+	if err := c.Set(ctx, "a", []byte(value), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	assertValue(t, c, "a", value)
+
+	// Keys are prefixed, in the configured database, and expire
+	if got, err := server.DB(2).Get("myapp:a"); err != nil || got != value {
+		t.Fatalf("stored value = %q, %v; want %q", got, err, value)
+	}
+	if ttl := server.DB(2).TTL("myapp:a"); ttl != time.Minute {
+		t.Fatalf("TTL = %v, want 1m", ttl)
+	}
+
+	if err := c.Set(ctx, "b", []byte("2"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, "a", "b", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	assertValue(t, c, "a", "")
+	assertValue(t, c, "b", "")
+}
+
+func TestRedisExpires(t *testing.T) {
+	ctx := context.Background()
+	c, server := newTestRedis(t)
+
+	if err := c.Set(ctx, "a", []byte("1"), 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(time.Second)
+	assertValue(t, c, "a", "1")
+	server.FastForward(time.Second)
+	assertValue(t, c, "a", "")
+}
+
+func TestRedisAdd(t *testing.T) {
+	ctx := context.Background()
+	c, server := newTestRedis(t)
+
+	if added, err := c.Add(ctx, "a", []byte("1"), time.Minute); !added || err != nil {
+		t.Fatalf("Add of a missing key = %v, %v; want true", added, err)
+	}
+	if added, err := c.Add(ctx, "a", []byte("2"), time.Minute); added || err != nil {
+		t.Fatalf("Add of a cached key = %v, %v; want false", added, err)
+	}
+	assertValue(t, c, "a", "1")
+
+	server.FastForward(time.Minute)
+	if added, err := c.Add(ctx, "a", []byte("3"), time.Minute); !added || err != nil {
+		t.Fatalf("Add of an expired key = %v, %v; want true", added, err)
+	}
+	assertValue(t, c, "a", "3")
+}
+
+func TestRedisWrongPassword(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	c := NewRedis(RedisConfig{Address: server.Addr(), Password: "wrong", Timeout: time.Second})
+	defer c.Close()
+
+	var replyErr redisError
+	if err := c.Ping(context.Background()); !errors.As(err, &replyErr) {
+		t.Fatalf("Ping = %v, want an error reply", err)
+	}
+}
+
+func TestRedisErrorReplyKeepsConnection(t *testing.T) {
+	ctx := context.Background()
+	c, server := newTestRedis(t)
+	server.Select(2)
+	server.Lpush("myapp:list", "x")
+
+	var replyErr redisError
+	if _, err := c.Get(ctx, "list"); !errors.As(err, &replyErr) {
+		t.Fatalf("Get of a list = %v, want an error reply", err)
+	}
+	if err := c.Set(ctx, "a", []byte("1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if n := server.TotalConnectionCount(); n != 1 {
+		t.Fatalf("opened %d connections, want the first one reused", n)
+	}
+}
+
+func TestRedisReconnects(t *testing.T) {
+	ctx := context.Background()
+	c, server := newTestRedis(t)
+
+	if err := c.Set(ctx, "a", []byte("1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	server.Restart()
+
+	// The first command may fail on the pooled connection the server
+	// closed. That connection is dropped, so the next command connects again.
+	_ = c.Ping(ctx)
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("Ping after reconnecting = %v", err)
+	}
+	assertValue(t, c, "a", "1")
+}
+
+func TestRedisTimeout(t *testing.T) {
+	// A server that accepts connections but never replies
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	c := NewRedis(RedisConfig{Address: listener.Addr().String(), Timeout: 50 * time.Millisecond})
+	defer c.Close()
+	start := time.Now()
+	if err := c.Ping(context.Background()); err == nil {
+		t.Fatal("Ping of a silent server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Ping took %v, want it bounded by the timeout", elapsed)
+	}
+}
+
+func TestRedisReadReplies(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  string
+		err   string
+	}{
+		{"+OK\r\n", "OK", ""},
+		{"-ERR unknown command\r\n", "", "cache: redis: ERR unknown command"},
+		{":42\r\n", "42", ""},
+		{"$5\r\nhello\r\n", "[104 101 108 108 111]", ""},
+		{"$0\r\n\r\n", "[]", ""},
+		{"$-1\r\n", "<nil>", ""},
+		{"*3\r\n$1\r\na\r\n:1\r\n-ERR element\r\n", "[[97] 1 <nil>]", ""},
+		{"*-1\r\n", "<nil>", ""},
+		{"*0\r\n", "[]", ""},
+		{"+OK\n", "", "cache: redis: malformed reply"},
+		{":x\r\n", "", "cache: redis: malformed integer reply"},
+		{"$x\r\n", "", "cache: redis: malformed bulk reply"},
+		{"*x\r\n", "", "cache: redis: malformed array reply"},
+		{"%1\r\n", "", `cache: redis: unexpected reply type '%'`},
+		{"$5\r\nhel", "", "cache: redis: unexpected EOF"},
+		{"*2\r\n:1\r\n", "", "cache: redis: EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.TrimSpace(tt.reply), func(t *testing.T) {
+			conn := &redisConn{r: bufio.NewReader(strings.NewReader(tt.reply))}
+			reply, err := conn.read()
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("read = %v, %v; want error %q", reply, err, tt.err)
+				}
+				return
+			}
+			if err != nil || fmt.Sprint(reply) != tt.want {
+				t.Fatalf("read = %v, %v; want %s", reply, err, tt.want)
+			}
+		})
+	}
+}
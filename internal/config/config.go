@@ -1,79 +1,334 @@
+// Package config loads the application configuration. Every setting has a
+// key, such as server.address, and is resolved from, in increasing order of
+// precedence: the defaults in Default, a YAML or TOML file, an environment
+// variable and a command line flag named after the key.
+//
+// Secrets are held as Secret, which never prints its value. Each secret can
+// also be read from the file named by its environment variable with a _FILE
+// suffix, or be given as a reference such as vault:myapp/database#password
+// that a SecretProvider resolves.
+//
+// Keys tagged reload:"true" can change while the server runs: a Watcher
+// reloads them on SIGHUP or when the configuration file changes. All other
+// keys only take effect on restart.
 package config
 
 import (
-	"os"
 	"time"
 
-	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
+
+	"myapp/internal/feature"
+	"myapp/internal/validation"
+)
+
+// Environments
+const (
+	EnvDevelopment = "development"
+	EnvTest        = "test"
+	EnvProduction  = "production"
 )
 
+// insecureJWTSecret is the JWT secret used when none is configured. It is
+// public, so Validate rejects it outside development.
+const insecureJWTSecret = "insecure-development-secret"
+
 // Server holds server configuration
 type Server struct {
-	Address string
+	Address           string        `key:"address" env:"SERVER_ADDRESS" doc:"Address the HTTP server listens on"`
+	ReadHeaderTimeout time.Duration `key:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" doc:"Time allowed to read request headers"`
+	ReadTimeout       time.Duration `key:"read_timeout" env:"SERVER_READ_TIMEOUT" doc:"Time allowed to read a whole request, including uploads; 0 disables the limit"`
+	WriteTimeout      time.Duration `key:"write_timeout" env:"SERVER_WRITE_TIMEOUT" doc:"Time allowed to write a response; 0 disables the limit, which event streams and exports rely on"`
+	IdleTimeout       time.Duration `key:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" doc:"How long idle keep-alive connections are kept open"`
+	ShutdownTimeout   time.Duration `key:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" doc:"How long graceful shutdown waits for in-flight requests"`
+	TrustedProxies    []string      `key:"trusted_proxies" env:"SERVER_TRUSTED_PROXIES" doc:"Addresses or CIDR ranges of reverse proxies whose X-Forwarded-For and X-Real-IP headers are trusted for the client IP; requests from anywhere else are attributed to their peer address"`
+}
+
+// Compression holds response compression configuration
+type Compression struct {
+	MinSize ByteSize `key:"min_size" env:"COMPRESSION_MIN_SIZE" doc:"Smallest response body compressed, such as 1KB"`
+	Types   []string `key:"types" env:"COMPRESSION_TYPES" doc:"Media types compressed with brotli or gzip, as negotiated with the client, such as application/json or text/*; empty disables compression"`
 }
 
 // Database holds database configuration
 type Database struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	Name     string
-	SSLMode  string
+	Driver            string        `key:"driver" env:"DB_DRIVER" doc:"Database the data is stored in: postgres or sqlite"`
+	Path              string        `key:"path" env:"DB_PATH" doc:"SQLite database file, created with its schema if missing; used by the sqlite driver"`
+	Host              string        `key:"host" env:"DB_HOST" doc:"PostgreSQL host"`
+	Port              string        `key:"port" env:"DB_PORT" doc:"PostgreSQL port"`
+	User              string        `key:"user" env:"DB_USER" doc:"PostgreSQL user"`
+	Password          Secret        `key:"password" env:"DB_PASSWORD" doc:"PostgreSQL password"`
+	Name              string        `key:"name" env:"DB_NAME" doc:"PostgreSQL database name"`
+	SSLMode           string        `key:"sslmode" env:"DB_SSLMODE" doc:"PostgreSQL sslmode: disable, allow, prefer, require, verify-ca or verify-full"`
+	MaxOpenConns      int           `key:"max_open_conns" env:"DB_MAX_OPEN_CONNS" doc:"Maximum number of open connections; 0 means unlimited"`
+	MaxIdleConns      int           `key:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" doc:"Maximum number of idle connections kept in the pool"`
+	ConnMaxLifetime   time.Duration `key:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" doc:"Maximum time a connection is reused; 0 means forever"`
+	ConnMaxIdleTime   time.Duration `key:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" doc:"Maximum time a connection stays idle; 0 means forever"`
+	ConnectRetries    int           `key:"connect_retries" env:"DB_CONNECT_RETRIES" doc:"How many times connecting is retried at startup before giving up"`
+	ConnectBackoff    time.Duration `key:"connect_backoff" env:"DB_CONNECT_BACKOFF" doc:"Wait before the first connection retry; it doubles after every attempt, up to 30s"`
+	StatementTimeout  time.Duration `key:"statement_timeout" env:"DB_STATEMENT_TIMEOUT" doc:"Time after which PostgreSQL cancels a statement; 0 disables the limit"`
+	Replicas          []string      `key:"replicas" env:"DB_REPLICAS" doc:"Read replicas as host or host:port, sharing the user, password, name and sslmode of the primary; read-only queries are spread across them"`
+	ReplicaStickiness time.Duration `key:"replica_stickiness" env:"DB_REPLICA_STICKINESS" doc:"How long a user's reads stay on the primary after they write, so that they see their changes despite replication lag"`
 }
 
 // JWT holds JWT configuration
 type JWT struct {
-	Secret           string
-	AccessExpiresIn  time.Duration
-	RefreshExpiresIn time.Duration
+	Secret           Secret        `key:"secret" env:"JWT_SECRET" doc:"Key signing access and refresh tokens; must be set outside development"`
+	AccessExpiresIn  time.Duration `key:"access_expires_in" env:"JWT_ACCESS_EXPIRES_IN" doc:"Lifetime of access tokens"`
+	RefreshExpiresIn time.Duration `key:"refresh_expires_in" env:"JWT_REFRESH_EXPIRES_IN" doc:"Lifetime of refresh tokens"`
+}
+
+// Auth holds registration and password handling configuration
+type Auth struct {
+	RegistrationEnabled bool `key:"registration_enabled" env:"AUTH_REGISTRATION_ENABLED" reload:"true" doc:"Whether new users may register"`
+	BcryptCost          int  `key:"bcrypt_cost" env:"AUTH_BCRYPT_COST" doc:"bcrypt cost of stored password hashes, between 4 and 31"`
+}
+
+// Log holds logging configuration
+type Log struct {
+	Level string `key:"level" env:"LOG_LEVEL" reload:"true" doc:"Lowest level logged: debug, info, warn or error. Requests are logged at info."`
+}
+
+// RateLimit holds API rate limit configuration. Each user, or each IP
+// address for anonymous requests, may burst up to Requests and is then
+// refilled at Requests per Window.
+type RateLimit struct {
+	Requests int           `key:"requests" env:"RATE_LIMIT_REQUESTS" reload:"true" doc:"Requests allowed per window and client; 0 disables rate limiting"`
+	Window   time.Duration `key:"window" env:"RATE_LIMIT_WINDOW" reload:"true" doc:"Window the request allowance refills over"`
+}
+
+// Audit holds audit log configuration
+type Audit struct {
+	ChainInterval time.Duration `key:"chain_interval" env:"AUDIT_CHAIN_INTERVAL" doc:"How often recorded entries are linked into the audit log's hash chain"`
+}
+
+// Idempotency holds Idempotency-Key configuration
+type Idempotency struct {
+	Store   string        `key:"store" env:"IDEMPOTENCY_STORE" doc:"Where idempotency keys are kept: postgres, meaning the application database whatever its driver, or memory"`
+	TTL     time.Duration `key:"ttl" env:"IDEMPOTENCY_TTL" doc:"How long a key's response is replayed"`
+	Secret  Secret        `key:"secret" env:"IDEMPOTENCY_SECRET" doc:"Key encrypting stored responses, which may hold tokens; defaults to the JWT secret"`
+	MaxBody ByteSize      `key:"max_body" env:"IDEMPOTENCY_MAX_BODY" doc:"Largest request body accepted with an Idempotency-Key, as the whole body is fingerprinted first"`
+}
+
+// Cache holds configuration of the cache of users looked up on every
+// authenticated request
+type Cache struct {
+	Driver string        `key:"driver" env:"CACHE_DRIVER" doc:"Where users are cached: memory, redis or none"`
+	TTL    time.Duration `key:"ttl" env:"CACHE_TTL" doc:"How long a user stays cached; with the memory driver, changes made through other instances show after at most this long"`
+	Size   int           `key:"size" env:"CACHE_SIZE" doc:"Maximum number of users cached by the memory driver"`
+	Redis  Redis         `key:"redis"`
+}
+
+// Redis holds configuration of the redis cache driver
+type Redis struct {
+	Address  string        `key:"address" env:"REDIS_ADDR" doc:"Redis host:port"`
+	Password Secret        `key:"password" env:"REDIS_PASSWORD" doc:"Redis password"`
+	DB       int           `key:"db" env:"REDIS_DB" doc:"Redis database number"`
+	Prefix   string        `key:"prefix" env:"REDIS_PREFIX" doc:"Prefix of every key, to share the server with other applications"`
+	Timeout  time.Duration `key:"timeout" env:"REDIS_TIMEOUT" doc:"Time allowed to connect or run a command"`
+	PoolSize int           `key:"pool_size" env:"REDIS_POOL_SIZE" doc:"Number of idle connections kept open"`
+}
+
+// Events holds in-process event bus configuration
+type Events struct {
+	ReplaySize int           `key:"replay_size" env:"EVENTS_REPLAY_SIZE" doc:"Number of recent events kept per user for reconnecting streams"`
+	ReplayTTL  time.Duration `key:"replay_ttl" env:"EVENTS_REPLAY_TTL" doc:"How long a user's recent events are kept after the latest one; streams reconnecting later must resynchronise"`
+}
+
+// Stream holds real-time event stream configuration
+type Stream struct {
+	HeartbeatInterval time.Duration `key:"heartbeat_interval" env:"STREAM_HEARTBEAT_INTERVAL" doc:"How often idle SSE and WebSocket streams are pinged"`
+}
+
+// Webhook holds webhook delivery configuration
+type Webhook struct {
+	Workers      int           `key:"workers" env:"WEBHOOK_WORKERS" doc:"Number of deliveries sent concurrently"`
+	BatchSize    int           `key:"batch_size" env:"WEBHOOK_BATCH_SIZE" doc:"Number of due deliveries claimed per poll"`
+	Timeout      time.Duration `key:"timeout" env:"WEBHOOK_TIMEOUT" doc:"Time allowed for each delivery request"`
+	PollInterval time.Duration `key:"poll_interval" env:"WEBHOOK_POLL_INTERVAL" doc:"How often idle workers check for due deliveries"`
+	MaxAttempts  int           `key:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" doc:"Attempts before a delivery is given up"`
+	BackoffBase  time.Duration `key:"backoff_base" env:"WEBHOOK_BACKOFF_BASE" doc:"Delay before the first retry; it doubles on every further retry"`
+	BackoffMax   time.Duration `key:"backoff_max" env:"WEBHOOK_BACKOFF_MAX" doc:"Longest delay between retries"`
+}
+
+// Outbox holds domain event dispatcher configuration
+type Outbox struct {
+	BatchSize    int           `key:"batch_size" env:"OUTBOX_BATCH_SIZE" doc:"Number of events claimed per poll"`
+	PollInterval time.Duration `key:"poll_interval" env:"OUTBOX_POLL_INTERVAL" doc:"How often the dispatcher checks for events committed by other instances"`
+	Timeout      time.Duration `key:"timeout" env:"OUTBOX_TIMEOUT" doc:"Time allowed for the handlers of one event"`
+	MaxAttempts  int           `key:"max_attempts" env:"OUTBOX_MAX_ATTEMPTS" doc:"Attempts before an event is marked dead"`
+	BackoffBase  time.Duration `key:"backoff_base" env:"OUTBOX_BACKOFF_BASE" doc:"Delay before the first retry; it doubles on every further retry"`
+	BackoffMax   time.Duration `key:"backoff_max" env:"OUTBOX_BACKOFF_MAX" doc:"Longest delay between retries"`
+	Retention    time.Duration `key:"retention" env:"OUTBOX_RETENTION" doc:"How long dispatched events are kept"`
+}
+
+// Storage holds blob storage configuration
+type Storage struct {
+	Driver   string `key:"driver" env:"STORAGE_DRIVER" doc:"Blob storage backend: local, s3 or memory"`
+	LocalDir string `key:"local_dir" env:"STORAGE_LOCAL_DIR" doc:"Directory blobs are written to by the local driver"`
+	S3       S3     `key:"s3"`
+}
+
+// S3 holds the connection settings of an S3-compatible service such as
+// AWS S3 or MinIO
+type S3 struct {
+	Endpoint  string `key:"endpoint" env:"S3_ENDPOINT" doc:"S3 endpoint, as host:port"`
+	Bucket    string `key:"bucket" env:"S3_BUCKET" doc:"S3 bucket"`
+	AccessKey string `key:"access_key" env:"S3_ACCESS_KEY" doc:"S3 access key ID"`
+	SecretKey Secret `key:"secret_key" env:"S3_SECRET_KEY" doc:"S3 secret access key"`
+	Region    string `key:"region" env:"S3_REGION" doc:"S3 region"`
+	UseSSL    bool   `key:"use_ssl" env:"S3_USE_SSL" doc:"Connect to S3 over TLS"`
+}
+
+// Attachments holds todo attachment configuration
+type Attachments struct {
+	MaxSize      ByteSize      `key:"max_size" env:"ATTACHMENT_MAX_SIZE" doc:"Largest accepted file, such as 512KB or 10MB"`
+	AllowedTypes []string      `key:"allowed_types" env:"ATTACHMENT_ALLOWED_TYPES" doc:"Media types accepted for upload"`
+	URLSecret    Secret        `key:"url_secret" env:"ATTACHMENT_URL_SECRET" doc:"Key signing download links; defaults to the JWT secret"`
+	URLTTL       time.Duration `key:"url_ttl" env:"ATTACHMENT_URL_TTL" doc:"How long a signed download link stays valid"`
 }
 
 // Config holds all application configuration
 type Config struct {
-	Server   Server
-	Database Database
-	JWT      JWT
+	Env         string            `key:"env" env:"APP_ENV" doc:"Environment: development, test or production. Insecure defaults are only accepted in development."`
+	Server      Server            `key:"server"`
+	Compression Compression       `key:"compression"`
+	Database    Database          `key:"database"`
+	JWT         JWT               `key:"jwt"`
+	Auth        Auth              `key:"auth"`
+	Log         Log               `key:"log"`
+	RateLimit   RateLimit         `key:"rate_limit"`
+	Audit       Audit             `key:"audit"`
+	Idempotency Idempotency       `key:"idempotency"`
+	Cache       Cache             `key:"cache"`
+	Events      Events            `key:"events"`
+	Stream      Stream            `key:"stream"`
+	Webhook     Webhook           `key:"webhook"`
+	Outbox      Outbox            `key:"outbox"`
+	Storage     Storage           `key:"storage"`
+	Attachments Attachments       `key:"attachments"`
+	Vault       Vault             `key:"vault"`
+	Validation  validation.Config `key:"validation" reload:"true"`
+	// Features are the feature flags by name. They can only be set in the
+	// configuration file, under features.<name>.
+	Features map[string]feature.Flag `key:"features" reload:"true"`
 }
 
-// Load loads configuration from environment variables
-func Load() (*Config, error) {
-	// Load .env file if it exists
-	_ = godotenv.Load()
-
+// Default returns the default configuration, which is meant for local
+// development
+func Default() *Config {
 	return &Config{
+		Env: EnvDevelopment,
 		Server: Server{
-			Address: getEnv("SERVER_ADDRESS", ":8080"),
+			Address:           ":8080",
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       time.Minute,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   10 * time.Second,
+		},
+		Compression: Compression{
+			MinSize: 1 << 10,
+			Types: []string{
+				"application/json", "application/x-ndjson", "application/javascript", "image/svg+xml",
+				"text/html", "text/css", "text/plain", "text/csv", "text/calendar",
+			},
 		},
 		Database: Database{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", "postgres"),
-			Name:     getEnv("DB_NAME", "myapp"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+			Driver:            "postgres",
+			Path:              "myapp.db",
+			Host:              "localhost",
+			Port:              "5432",
+			User:              "postgres",
+			Password:          "postgres",
+			Name:              "myapp",
+			SSLMode:           "disable",
+			MaxOpenConns:      25,
+			MaxIdleConns:      10,
+			ConnMaxLifetime:   30 * time.Minute,
+			ConnMaxIdleTime:   5 * time.Minute,
+			ConnectRetries:    5,
+			ConnectBackoff:    time.Second,
+			StatementTimeout:  30 * time.Second,
+			ReplicaStickiness: 5 * time.Second,
 		},
 		JWT: JWT{
-			Secret:           getEnv("JWT_SECRET", "your-secret-key"),
-			AccessExpiresIn:  time.Duration(getEnvAsInt("JWT_ACCESS_EXPIRES_IN", 24)) * time.Hour,
-			RefreshExpiresIn: time.Duration(getEnvAsInt("JWT_REFRESH_EXPIRES_IN", 7*24)) * time.Hour,
+			Secret:           insecureJWTSecret,
+			AccessExpiresIn:  24 * time.Hour,
+			RefreshExpiresIn: 7 * 24 * time.Hour,
 		},
-	}, nil
-}
-
-// getEnv retrieves environment variables with fallback values
-func getEnv(key, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
-	}
-	return fallback
-}
-
-// getEnvAsInt retrieves environment variables as integers with fallback values
-func getEnvAsInt(key string, fallback int) int {
-	valueStr := getEnv(key, "")
-	if value, err := time.ParseDuration(valueStr); err == nil {
-		return int(value.Hours())
+		Auth: Auth{
+			RegistrationEnabled: true,
+			BcryptCost:          bcrypt.DefaultCost,
+		},
+		Log: Log{
+			Level: "info",
+		},
+		RateLimit: RateLimit{
+			Requests: 600,
+			Window:   time.Minute,
+		},
+		Audit: Audit{
+			ChainInterval: time.Second,
+		},
+		Idempotency: Idempotency{
+			Store:   "postgres",
+			TTL:     24 * time.Hour,
+			MaxBody: 32 << 20,
+		},
+		Cache: Cache{
+			Driver: "memory",
+			TTL:    time.Minute,
+			Size:   10000,
+			Redis: Redis{
+				Address:  "localhost:6379",
+				Prefix:   "myapp:",
+				Timeout:  time.Second,
+				PoolSize: 10,
+			},
+		},
+		Events: Events{
+			ReplaySize: 256,
+			ReplayTTL:  10 * time.Minute,
+		},
+		Stream: Stream{
+			HeartbeatInterval: 15 * time.Second,
+		},
+		Webhook: Webhook{
+			Workers:      4,
+			BatchSize:    32,
+			Timeout:      10 * time.Second,
+			PollInterval: time.Second,
+			MaxAttempts:  8,
+			BackoffBase:  30 * time.Second,
+			BackoffMax:   6 * time.Hour,
+		},
+		Outbox: Outbox{
+			BatchSize:    100,
+			PollInterval: time.Second,
+			Timeout:      30 * time.Second,
+			MaxAttempts:  10,
+			BackoffBase:  time.Second,
+			BackoffMax:   10 * time.Minute,
+			Retention:    7 * 24 * time.Hour,
+		},
+		Storage: Storage{
+			Driver:   "local",
+			LocalDir: "./data/blobs",
+			S3: S3{
+				Endpoint: "localhost:9000",
+				Bucket:   "myapp",
+			},
+		},
+		Attachments: Attachments{
+			MaxSize:      10 << 20,
+			AllowedTypes: []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain"},
+			URLTTL:       5 * time.Minute,
+		},
+		Vault: Vault{
+			Mount:   "secret",
+			Timeout: 10 * time.Second,
+		},
+		Validation: *validation.DefaultConfig(),
+		Features:   map[string]feature.Flag{},
 	}
-	return fallback
 }
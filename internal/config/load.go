@@ -0,0 +1,461 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+
+	"myapp/internal/feature"
+)
+
+// FileEnv is the environment variable naming the configuration file when no
+// -config flag is given
+const FileEnv = "CONFIG_FILE"
+
+// Loader loads the configuration from all sources. Its flags are registered
+// on a flag.FlagSet, which must be parsed before calling Load.
+type Loader struct {
+	file      string
+	flags     []setting
+	providers map[string]SecretProvider
+	// dotenv holds the variables set from the .env file
+	dotenv map[string]bool
+}
+
+// setting is a value for a key from a source other than the defaults
+type setting struct {
+	key, value string
+}
+
+// NewLoader creates a Loader and registers its flags on fs: -config, naming
+// the configuration file, and one flag per key, such as -server.address
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{}
+	fs.StringVar(&l.file, "config", "", fmt.Sprintf("Configuration file, in YAML or TOML (env %s)", FileEnv))
+	for _, f := range fields(Default()) {
+		key := f.key
+		fs.Func(key, f.doc, func(value string) error {
+			l.flags = append(l.flags, setting{key: key, value: value})
+			return nil
+		})
+	}
+	return l
+}
+
+// RegisterProvider makes provider resolve secret references with the given
+// scheme, as in <scheme>:<name>. The env and file schemes are built in, and
+// vault is available once vault.address is set; registering one of them
+// replaces it.
+func (l *Loader) RegisterProvider(scheme string, provider SecretProvider) {
+	if l.providers == nil {
+		l.providers = make(map[string]SecretProvider)
+	}
+	l.providers[scheme] = provider
+}
+
+// Load resolves the configuration from the defaults, the configuration
+// file, the environment (including a .env file, if present) and the parsed
+// flags, resolves secret references, and validates the result
+func (l *Loader) Load() (*Config, error) {
+	l.loadDotenv()
+
+	cfg := Default()
+	all := fields(cfg)
+	index := make(map[string]field, len(all))
+	for _, f := range all {
+		index[f.key] = f
+	}
+
+	if path := l.File(); path != "" {
+		if err := loadFile(path, cfg, index); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range all {
+		// An empty variable, as in "KEY=" in .env, only clears strings
+		if value, ok := os.LookupEnv(f.env); ok && (value != "" || f.value.Kind() == reflect.String) {
+			if err := f.set(value); err != nil {
+				return nil, fmt.Errorf("config: environment variable %s: %w", f.env, err)
+			}
+		}
+		if f.secret {
+			if err := setFromFileEnv(f); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, s := range l.flags {
+		if err := index[s.key].set(s.value); err != nil {
+			return nil, fmt.Errorf("config: flag -%s: %w", s.key, err)
+		}
+	}
+
+	if err := l.resolveSecrets(cfg, all); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadDotenv sets the variables of the .env file, if there is one, that are
+// not set in the environment of the process. As Load reads the file again
+// on every call, changes to it are picked up on reload: variables it set
+// before are updated, or unset if removed from the file, while the process
+// environment still takes precedence.
+func (l *Loader) loadDotenv() {
+	values, err := godotenv.Read()
+	if err != nil {
+		values = nil
+	}
+	if l.dotenv == nil {
+		l.dotenv = make(map[string]bool)
+	}
+	for name := range l.dotenv {
+		if _, ok := values[name]; !ok {
+			os.Unsetenv(name)
+			delete(l.dotenv, name)
+		}
+	}
+	for name, value := range values {
+		if _, set := os.LookupEnv(name); set && !l.dotenv[name] {
+			continue
+		}
+		os.Setenv(name, value)
+		l.dotenv[name] = true
+	}
+}
+
+// setFromFileEnv reads a secret from the file named by its _FILE
+// environment variable, as Docker and Kubernetes secrets are mounted
+func setFromFileEnv(f field) error {
+	fileEnv := f.env + "_FILE"
+	path, ok := os.LookupEnv(fileEnv)
+	if !ok || path == "" {
+		return nil
+	}
+	if value := os.Getenv(f.env); value != "" {
+		return fmt.Errorf("config: set either %s or %s, not both", f.env, fileEnv)
+	}
+	secret, err := readSecretFile(path)
+	if err != nil {
+		return fmt.Errorf("config: environment variable %s: %w", fileEnv, err)
+	}
+	f.value.SetString(secret)
+	return nil
+}
+
+// File returns the path of the configuration file: the -config flag, or
+// else the CONFIG_FILE environment variable. It is empty if there is none.
+func (l *Loader) File() string {
+	if l.file != "" {
+		return l.file
+	}
+	return os.Getenv(FileEnv)
+}
+
+// loadFile applies a YAML or TOML file, chosen by extension. Keys the
+// configuration does not have are rejected, so that typos do not go
+// unnoticed.
+func loadFile(path string, cfg *Config, index map[string]field) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	var doc map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		doc, err = parseTOML(data)
+	default:
+		return fmt.Errorf("config: %s: unsupported file type %q, want .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+
+	if value, ok := doc["features"]; ok {
+		features, err := decodeFeatures(value)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", path, err)
+		}
+		cfg.Features = features
+		delete(doc, "features")
+	}
+
+	return walkFile(doc, "", func(key string, value interface{}) error {
+		f, ok := index[key]
+		if !ok {
+			return fmt.Errorf("config: %s: unknown key %q", path, key)
+		}
+		if err := f.setFileValue(value); err != nil {
+			return fmt.Errorf("config: %s: %s: %w", path, key, err)
+		}
+		return nil
+	})
+}
+
+// walkFile calls fn with the dotted key and value of every leaf of a
+// decoded file
+func walkFile(doc map[string]interface{}, prefix string, fn func(key string, value interface{}) error) error {
+	names := make([]string, 0, len(doc))
+	for name := range doc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key, value := prefix+name, doc[name]
+		if table, ok := value.(map[string]interface{}); ok {
+			if err := walkFile(table, key+".", fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field is a configuration key: a leaf field of Config
+type field struct {
+	key string
+	env string
+	doc string
+	// secret marks keys of type Secret
+	secret bool
+	// reload marks keys that a Watcher applies without a restart
+	reload bool
+	value  reflect.Value
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	byteSizeType = reflect.TypeOf(ByteSize(0))
+	secretType   = reflect.TypeOf(Secret(""))
+)
+
+// fields returns the keys of cfg in declaration order. Nested structs
+// contribute their fields with the struct's key as a prefix and inherit its
+// reload tag. Feature flags are not keys; they are handled separately.
+func fields(cfg *Config) []field {
+	var out []field
+	var walk func(v reflect.Value, prefix string, reload bool)
+	walk = func(v reflect.Value, prefix string, reload bool) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			key, ok := sf.Tag.Lookup("key")
+			if !ok {
+				continue
+			}
+			fieldReload := reload || sf.Tag.Get("reload") == "true"
+			switch sf.Type.Kind() {
+			case reflect.Struct:
+				walk(v.Field(i), prefix+key+".", fieldReload)
+				continue
+			case reflect.Map:
+				continue
+			}
+			out = append(out, field{
+				key:    prefix + key,
+				env:    sf.Tag.Get("env"),
+				doc:    sf.Tag.Get("doc"),
+				secret: sf.Type == secretType,
+				reload: fieldReload,
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "", false)
+	return out
+}
+
+// set parses a value given as text, as in environment variables and flags.
+// Lists are comma-separated.
+func (f field) set(text string) error {
+	v := f.value
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(strings.TrimSpace(text))
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, want a number with a unit such as 30s, 5m or 24h", text)
+		}
+		v.SetInt(int64(d))
+	case v.Type() == byteSizeType:
+		size, err := ParseByteSize(text)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(size))
+	case v.Kind() == reflect.String:
+		v.SetString(text)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", text)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", text)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// setFileValue sets a value decoded from a configuration file, which may be
+// typed. Durations must be strings with a unit, so that a bare number is
+// never silently read as nanoseconds.
+func (f field) setFileValue(value interface{}) error {
+	v := f.value
+	switch value := value.(type) {
+	case string:
+		if v.Kind() == reflect.Slice {
+			return errors.New("must be a list")
+		}
+		return f.set(value)
+	case bool:
+		if v.Kind() != reflect.Bool {
+			return fmt.Errorf("must be of type %s", typeName(v.Type()))
+		}
+		v.SetBool(value)
+	case int, int64:
+		n := reflect.ValueOf(value).Int()
+		if v.Type() == durationType {
+			return fmt.Errorf("duration %d needs a unit, such as \"%ds\"", n, n)
+		}
+		if v.Kind() != reflect.Int && v.Type() != byteSizeType {
+			return fmt.Errorf("must be of type %s", typeName(v.Type()))
+		}
+		v.SetInt(n)
+	case []interface{}:
+		if v.Kind() != reflect.Slice {
+			return fmt.Errorf("must be of type %s", typeName(v.Type()))
+		}
+		list := make([]string, 0, len(value))
+		for _, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return errors.New("must be a list of strings")
+			}
+			list = append(list, s)
+		}
+		v.Set(reflect.ValueOf(list))
+	case nil:
+		// An empty value, such as "key:" in YAML, keeps the default
+	default:
+		return fmt.Errorf("must be of type %s", typeName(v.Type()))
+	}
+	return nil
+}
+
+// decodeFeatures decodes the features table of a configuration file
+func decodeFeatures(value interface{}) (map[string]feature.Flag, error) {
+	features := map[string]feature.Flag{}
+	if value == nil {
+		return features, nil
+	}
+	table, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("features must be a table of flags")
+	}
+
+	for name, value := range table {
+		settings, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("features.%s must be a table", name)
+		}
+		var flag feature.Flag
+		for key, value := range settings {
+			var ok bool
+			switch key {
+			case "enabled":
+				flag.Enabled, ok = value.(bool)
+			case "percentage":
+				var n int64
+				n, ok = fileInt(value)
+				flag.Percentage = int(n)
+			case "users":
+				var list []interface{}
+				list, ok = value.([]interface{})
+				for _, item := range list {
+					id, isInt := fileInt(item)
+					if !isInt || id <= 0 {
+						return nil, fmt.Errorf("features.%s.users must be a list of user IDs", name)
+					}
+					flag.Users = append(flag.Users, uint(id))
+				}
+			default:
+				return nil, fmt.Errorf("unknown key %q", "features."+name+"."+key)
+			}
+			if !ok {
+				return nil, fmt.Errorf("features.%s.%s has the wrong type", name, key)
+			}
+		}
+		features[name] = flag
+	}
+	return features, nil
+}
+
+// fileInt converts an integer decoded from YAML or TOML
+func fileInt(value interface{}) (int64, bool) {
+	switch n := value.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// typeName describes the type of a key in error messages and the reference
+func typeName(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "duration"
+	case t == byteSizeType:
+		return "size"
+	case t == secretType:
+		return "secret"
+	case t.Kind() == reflect.Int:
+		return "integer"
+	case t.Kind() == reflect.Bool:
+		return "boolean"
+	case t.Kind() == reflect.Slice:
+		return "list"
+	default:
+		return "string"
+	}
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// load loads the configuration with the given configuration file content,
+// named name, and flags
+func load(t *testing.T, name, content string, args ...string) (*Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := NewLoader(fs)
+	if name != "" {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		args = append([]string{"-config", path}, args...)
+	}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return loader.Load()
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	const file = "log:\n  level: warn\nrate_limit:\n  requests: 10\n  window: 2m\n"
+	tests := []struct {
+		name  string
+		file  string
+		env   map[string]string
+		args  []string
+		level string
+		reqs  int
+	}{
+		{"defaults", "", nil, nil, "info", 600},
+		{"file over defaults", file, nil, nil, "warn", 10},
+		{"env over file", file, map[string]string{"LOG_LEVEL": "error"}, nil, "error", 10},
+		{"flags over env", file, map[string]string{"LOG_LEVEL": "error", "RATE_LIMIT_REQUESTS": "20"}, []string{"-log.level", "debug"}, "debug", 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			name := ""
+			if tt.file != "" {
+				name = "config.yaml"
+			}
+			cfg, err := load(t, name, tt.file, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Log.Level != tt.level || cfg.RateLimit.Requests != tt.reqs {
+				t.Fatalf("log.level = %q, rate_limit.requests = %d; want %q, %d", cfg.Log.Level, cfg.RateLimit.Requests, tt.level, tt.reqs)
+			}
+		})
+	}
+}
+
+func TestLoadDurations(t *testing.T) {
+	tests := []struct {
+		name, file, content string
+		env                 string
+		want                time.Duration
+		wantErr             string
+	}{
+		{"yaml", "config.yaml", "idempotency:\n  ttl: 30m\n", "", 30 * time.Minute, ""},
+		{"yaml quoted", "config.yaml", "idempotency:\n  ttl: \"1h30m\"\n", "", 90 * time.Minute, ""},
+		{"toml", "config.toml", "[idempotency]\nttl = \"30m\"\n", "", 30 * time.Minute, ""},
+		{"env", "", "", "30m", 30 * time.Minute, ""},
+		{"bare number in file", "config.yaml", "idempotency:\n  ttl: 30\n", "", 0, "needs a unit"},
+		{"bare number in env", "", "", "30", 0, "invalid duration"},
+		{"unknown unit", "config.yaml", "idempotency:\n  ttl: 30min\n", "", 0, "invalid duration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("IDEMPOTENCY_TTL", tt.env)
+			}
+			cfg, err := load(t, tt.file, tt.content)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Idempotency.TTL != tt.want {
+				t.Fatalf("idempotency.ttl = %v, want %v", cfg.Idempotency.TTL, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	if _, err := load(t, "config.yaml", "log:\n  levle: warn\n"); err == nil || !strings.Contains(err.Error(), `unknown key "log.levle"`) {
+		t.Fatalf("Load error = %v, want the unknown key reported", err)
+	}
+}
+
+func TestValidateInsecureDefaults(t *testing.T) {
+	strong := Secret(strings.Repeat("k", minJWTSecretLength))
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr []string
+	}{
+		{"development accepts defaults", func(c *Config) {}, nil},
+		{"production rejects defaults", func(c *Config) { c.Env = EnvProduction },
+			[]string{"jwt.secret is a published default", "database.password is the default"}},
+		{"test rejects defaults", func(c *Config) { c.Env = EnvTest },
+			[]string{"jwt.secret is a published default"}},
+		{"short secret", func(c *Config) {
+			c.Env, c.JWT.Secret, c.Database.Password = EnvProduction, "short", "db"
+		}, []string{"jwt.secret must be at least"}},
+		{"short URL secret", func(c *Config) {
+			c.Env, c.JWT.Secret, c.Database.Password, c.Attachments.URLSecret = EnvProduction, strong, "db", "short"
+		}, []string{"attachments.url_secret must be at least"}},
+		{"s3 without keys", func(c *Config) {
+			c.Env, c.JWT.Secret, c.Database.Password = EnvProduction, strong, "db"
+			c.Storage.Driver, c.Storage.S3.Endpoint, c.Storage.S3.Bucket = "s3", "https://s3.example.com", "todos"
+		}, []string{"storage.s3.access_key and storage.s3.secret_key must be set"}},
+		{"production with secrets", func(c *Config) {
+			c.Env, c.JWT.Secret, c.Database.Password = EnvProduction, strong, "db"
+		}, nil},
+		{"sqlite needs no database password", func(c *Config) {
+			c.Env, c.JWT.Secret, c.Database.Driver = EnvProduction, strong, "sqlite"
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want errors %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, want an error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrint(t *testing.T) {
+	t.Setenv("JWT_SECRET", "printed-secret")
+	cfg, err := load(t, "config.yaml", "features:\n  beta:\n    enabled: true\n    percentage: 25\n    users: [7]\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var redactedOut bytes.Buffer
+	if err := Print(&redactedOut, cfg, true); err != nil {
+		t.Fatal(err)
+	}
+	out := redactedOut.String()
+	if strings.Contains(out, "printed-secret") || !strings.Contains(out, "secret: '"+redacted+"'") {
+		t.Fatalf("redacted output does not hide jwt.secret:\n%s", out)
+	}
+	if !strings.Contains(out, `password: ""`) {
+		t.Errorf("redacted output hides an empty secret, want it left empty:\n%s", out)
+	}
+
+	// Unredacted output is a file Load reads back to the same configuration
+	var full bytes.Buffer
+	if err := Print(&full, cfg, false); err != nil {
+		t.Fatal(err)
+	}
+	os.Unsetenv("JWT_SECRET")
+	reloaded, err := load(t, "printed.yaml", full.String())
+	if err != nil {
+		t.Fatalf("loading printed configuration: %v\n%s", err, full.String())
+	}
+	var again bytes.Buffer
+	if err := Print(&again, reloaded, false); err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != full.String() {
+		t.Fatalf("printed configuration changed when loaded back:\n%s\nwant:\n%s", again.String(), full.String())
+	}
+}
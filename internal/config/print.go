@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"myapp/internal/feature"
+)
+
+// redacted replaces secret values in printed configuration
+const redacted = "[REDACTED]"
+
+// Print writes the configuration to w as a YAML file that Load accepts. If
+// redact is set, secrets that are set are replaced with [REDACTED].
+func Print(w io.Writer, cfg *Config, redact bool) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range fields(cfg) {
+		parts := strings.Split(f.key, ".")
+		parent := root
+		for _, name := range parts[:len(parts)-1] {
+			parent = mappingChild(parent, name)
+		}
+
+		value := f.node()
+		if redact && f.secret && f.text() != "" {
+			value = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: redacted}
+		}
+		parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: parts[len(parts)-1]}, value)
+	}
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "features"}, featuresNode(cfg.Features))
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Reference writes a Markdown reference of every key with its environment
+// variable, type, default and description
+func Reference(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Configuration reference\n\n")
+	b.WriteString("<!-- Generated by `make config-docs`; do not edit. -->\n\n")
+	b.WriteString("Every setting is resolved from, in increasing order of precedence: the default below, ")
+	b.WriteString("the configuration file named by `-config` or `" + FileEnv + "` (YAML or TOML, with nested tables for dotted keys), ")
+	b.WriteString("the environment variable, and the command line flag `-<key>`. ")
+	b.WriteString("Durations take a unit, such as `30s`, `5m` or `24h`; sizes take `B`, `KB`, `MB` or `GB`; ")
+	b.WriteString("lists are comma-separated in environment variables and flags.\n\n")
+	b.WriteString("Outside the `development` environment, startup fails on insecure defaults such as the built-in JWT secret.\n\n")
+	b.WriteString("Secrets can also be read from the file named by their environment variable with a `_FILE` suffix, ")
+	b.WriteString("as Docker and Kubernetes secrets are mounted, or be given as a reference that a secret provider resolves: ")
+	b.WriteString("`env:NAME` reads another environment variable, `file:/path` reads a file, and `vault:<path>#<key>` reads ")
+	b.WriteString("a key of a KV version 2 secret from the Vault server configured under `vault`. Secrets are never printed.\n\n")
+	b.WriteString("Keys marked as reloadable take effect without a restart when the configuration file changes ")
+	b.WriteString("or the server receives SIGHUP. A reload that fails validation is logged and ignored.\n\n")
+	b.WriteString("| Key | Environment variable | Type | Default | Reloadable | Description |\n")
+	b.WriteString("|-----|----------------------|------|---------|------------|-------------|\n")
+	for _, f := range fields(Default()) {
+		def := f.text()
+		if f.secret && def != "" {
+			def = redacted
+		}
+		if def != "" {
+			def = "`" + strings.ReplaceAll(def, "|", `\|`) + "`"
+		}
+		reload := "no"
+		if f.reload {
+			reload = "yes"
+		}
+		env := "`" + f.env + "`"
+		if f.secret {
+			env += ", `" + f.env + "_FILE`"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s | %s |\n", f.key, env, typeName(f.value.Type()), def, reload, f.doc)
+	}
+	for _, row := range featureReference {
+		fmt.Fprintf(&b, "| `features.<name>.%s` | | %s | %s | yes | %s |\n", row[0], row[1], row[2], row[3])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// featureReference documents the settings of a feature flag, which are
+// not fields of Config: key, type, default and description
+var featureReference = [][4]string{
+	{"enabled", "boolean", "`false`", "Switches the feature on; feature flags can only be set in the configuration file"},
+	{"percentage", "integer", "`0`", "Share of users, from 0 to 100, the feature is on for"},
+	{"users", "list", "", "IDs of users the feature is always on for"},
+}
+
+// featuresNode returns the feature flags as a YAML mapping, by name
+func featuresNode(features map[string]feature.Flag) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, name := range sortedKeys(features) {
+		flag := features[name]
+		users := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, id := range flag.Users {
+			users.Content = append(users.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatUint(uint64(id), 10)})
+		}
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: name},
+			&yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Value: "enabled"},
+				{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(flag.Enabled)},
+				{Kind: yaml.ScalarNode, Value: "percentage"},
+				{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(flag.Percentage)},
+				{Kind: yaml.ScalarNode, Value: "users"},
+				users,
+			}},
+		)
+	}
+	return node
+}
+
+// mappingChild returns the mapping stored under name in a mapping node,
+// adding it if missing
+func mappingChild(parent *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == name {
+			return parent.Content[i+1]
+		}
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, child)
+	return child
+}
+
+// node returns the value of the key as a YAML node
+func (f field) node() *yaml.Node {
+	v := f.value
+	switch {
+	case v.Kind() == reflect.Slice:
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for i := 0; i < v.Len(); i++ {
+			seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v.Index(i).String()})
+		}
+		return seq
+	case v.Type() == durationType || v.Type() == byteSizeType || v.Kind() == reflect.String:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: f.text()}
+	case v.Kind() == reflect.Bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: f.text()}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: f.text()}
+	}
+}
+
+// text formats the value of the key the way set parses it
+func (f field) text() string {
+	v := f.value
+	switch {
+	case v.Type() == durationType:
+		return formatDuration(time.Duration(v.Int()))
+	case v.Type() == byteSizeType:
+		return ByteSize(v.Int()).String()
+	case v.Kind() == reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case v.Kind() == reflect.Int:
+		return strconv.FormatInt(v.Int(), 10)
+	case v.Kind() == reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = v.Index(i).String()
+		}
+		return strings.Join(items, ",")
+	default:
+		return v.String()
+	}
+}
+
+// formatDuration formats a duration without zero trailing units, as 24h
+// rather than 24h0m0s
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Secret is a configuration value that must not be disclosed, such as a
+// password or signing key. It formats, marshals and logs as [REDACTED];
+// Value returns the secret itself.
+type Secret string
+
+// Value returns the secret
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// String redacts the secret, unless it is empty
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// GoString redacts the secret in %#v output
+func (s Secret) GoString() string {
+	return fmt.Sprintf("config.Secret(%q)", s.String())
+}
+
+// MarshalJSON redacts the secret
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// MarshalText redacts the secret, for encoders such as log/slog
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ErrSecretNotFound is returned by a SecretProvider that has no secret by
+// the requested name
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretProvider looks up secrets by name. A secret setting whose value is
+// a reference of the form <scheme>:<name>, such as file:/run/secrets/db or
+// vault:myapp/database#password, is replaced with the secret the provider
+// registered for the scheme returns for the name.
+type SecretProvider interface {
+	Secret(ctx context.Context, name string) (string, error)
+}
+
+// EnvProvider reads secrets from environment variables, as in env:DB_PASS
+type EnvProvider struct{}
+
+// Secret returns the value of the environment variable name
+func (EnvProvider) Secret(ctx context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s: %w", name, ErrSecretNotFound)
+	}
+	return value, nil
+}
+
+// FileProvider reads secrets from files, such as Docker and Kubernetes
+// secrets, as in file:/run/secrets/db_password. Relative names are resolved
+// against Dir. A single trailing newline is removed.
+type FileProvider struct {
+	Dir string
+}
+
+// Secret returns the content of the file name
+func (p FileProvider) Secret(ctx context.Context, name string) (string, error) {
+	path := name
+	if !filepath.IsAbs(path) && p.Dir != "" {
+		path = filepath.Join(p.Dir, path)
+	}
+	return readSecretFile(path)
+}
+
+// readSecretFile reads a secret file, dropping the trailing newline that
+// editors and echo add
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("file %s: %w", path, ErrSecretNotFound)
+	}
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
+
+// resolveSecrets replaces secret references in cfg with the secrets they
+// name. vault.token is resolved first, since the Vault provider needs it.
+func (l *Loader) resolveSecrets(cfg *Config, all []field) error {
+	providers := map[string]SecretProvider{
+		"env":  EnvProvider{},
+		"file": FileProvider{},
+	}
+	for scheme, provider := range l.providers {
+		providers[scheme] = provider
+	}
+
+	resolve := func(f field) error {
+		value := f.value.String()
+		scheme, name, ok := strings.Cut(value, ":")
+		if !ok {
+			return nil
+		}
+		provider, ok := providers[scheme]
+		if !ok && scheme == "vault" {
+			switch {
+			case f.key == "vault.token":
+				return fmt.Errorf("config: %s cannot be read from Vault itself", f.key)
+			case cfg.Vault.Address == "":
+				return fmt.Errorf("config: %s: vault.address must be set to resolve vault references", f.key)
+			}
+			provider = NewVaultProvider(cfg.Vault, nil)
+			providers[scheme], ok = provider, true
+		}
+		if !ok {
+			// Not a reference, just a secret containing a colon
+			return nil
+		}
+
+		secret, err := provider.Secret(context.Background(), name)
+		if err != nil {
+			return fmt.Errorf("config: %s: %s secret: %w", f.key, scheme, err)
+		}
+		f.value.SetString(secret)
+		return nil
+	}
+
+	for _, f := range all {
+		if f.key == "vault.token" {
+			if err := resolve(f); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range all {
+		if f.secret && f.key != "vault.token" {
+			if err := resolve(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
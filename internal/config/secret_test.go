@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretRedaction(t *testing.T) {
+	tests := []struct {
+		secret Secret
+		want   string
+	}{
+		{"hunter2", redacted},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.secret.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+		if got := fmt.Sprintf("%v %s %+v", tt.secret, tt.secret, tt.secret); got != strings.Repeat(tt.want+" ", 2)+tt.want {
+			t.Errorf("formatted = %q, want %q three times", got, tt.want)
+		}
+		if got := fmt.Sprintf("%#v", tt.secret); got != fmt.Sprintf("config.Secret(%q)", tt.want) {
+			t.Errorf("%%#v = %s, want it redacted", got)
+		}
+		data, err := json.Marshal(struct{ Password Secret }{tt.secret})
+		if err != nil || string(data) != fmt.Sprintf(`{"Password":%q}`, tt.want) {
+			t.Errorf("MarshalJSON = %s, %v; want %q", data, err, tt.want)
+		}
+		text, err := tt.secret.MarshalText()
+		if err != nil || string(text) != tt.want {
+			t.Errorf("MarshalText = %s, %v; want %q", text, err, tt.want)
+		}
+		if tt.secret.Value() != string(tt.secret) {
+			t.Errorf("Value() = %q, want the secret", tt.secret.Value())
+		}
+	}
+}
+
+func TestLoadSecretFromFileEnv(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{"file", map[string]string{"DB_PASSWORD_FILE": write("plain", "s3cret")}, "s3cret", ""},
+		{"trailing newline", map[string]string{"DB_PASSWORD_FILE": write("newline", "s3cret\n")}, "s3cret", ""},
+		{"trailing CRLF", map[string]string{"DB_PASSWORD_FILE": write("crlf", "s3cret\r\n")}, "s3cret", ""},
+		{"inner newline kept", map[string]string{"DB_PASSWORD_FILE": write("lines", "s3\ncret\n")}, "s3\ncret", ""},
+		{"empty file variable", map[string]string{"DB_PASSWORD": "direct", "DB_PASSWORD_FILE": ""}, "direct", ""},
+		{"both set", map[string]string{"DB_PASSWORD": "direct", "DB_PASSWORD_FILE": write("both", "s3cret")}, "", "set either DB_PASSWORD or DB_PASSWORD_FILE, not both"},
+		{"missing file", map[string]string{"DB_PASSWORD_FILE": filepath.Join(dir, "missing")}, "", "DB_PASSWORD_FILE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, err := NewLoader(flag.NewFlagSet("test", flag.ContinueOnError)).Load()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.Database.Password.Value(); got != tt.want {
+				t.Fatalf("database.password = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
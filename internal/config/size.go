@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a number of bytes, written as a plain number or with one of
+// the suffixes B, KB, MB and GB, such as 512KB or 10MB. The multiples are
+// binary: 1KB is 1024 bytes.
+type ByteSize int64
+
+// byteUnits are the accepted size suffixes, longest first
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseByteSize parses a byte size such as 10MB
+func ParseByteSize(text string) (ByteSize, error) {
+	value := strings.ToUpper(strings.TrimSpace(text))
+	unit := int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value, unit = strings.TrimSpace(strings.TrimSuffix(value, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, want a number of bytes or a number with KB, MB or GB", text)
+	}
+	return ByteSize(n * unit), nil
+}
+
+// String formats the size with the largest suffix that divides it exactly
+func (b ByteSize) String() string {
+	for _, u := range byteUnits {
+		if b != 0 && int64(b)%u.size == 0 {
+			return fmt.Sprintf("%d%s", int64(b)/u.size, u.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", int64(b))
+}
@@ -0,0 +1,360 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseTOML decodes the subset of TOML that configuration files need:
+// tables, dotted keys, basic and literal strings, integers, floats,
+// booleans, arrays and inline tables. Multi-line strings, dates and arrays
+// of tables are rejected.
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	p := &tomlParser{data: string(data), line: 1}
+	root := make(map[string]interface{})
+	defined := make(map[string]bool)
+	current := root
+
+	for {
+		p.skipSpace(true)
+		if p.eof() {
+			return root, nil
+		}
+
+		if p.peek() == '[' {
+			p.pos++
+			if p.peek() == '[' {
+				return nil, p.errorf("arrays of tables are not supported")
+			}
+			p.skipSpace(false)
+			path, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpace(false)
+			if p.peek() != ']' {
+				return nil, p.errorf("expected ] after table name")
+			}
+			p.pos++
+			name := strings.Join(path, ".")
+			if defined[name] {
+				return nil, p.errorf("table [%s] defined twice", name)
+			}
+			defined[name] = true
+			if current, err = p.table(root, path); err != nil {
+				return nil, err
+			}
+		} else {
+			path, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpace(false)
+			if p.peek() != '=' {
+				return nil, p.errorf("expected = after key")
+			}
+			p.pos++
+			p.skipSpace(false)
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.set(current, path, value); err != nil {
+				return nil, err
+			}
+		}
+
+		p.skipSpace(false)
+		p.skipComment()
+		if !p.eof() && p.peek() != '\n' && p.peek() != '\r' {
+			return nil, p.errorf("expected end of line")
+		}
+	}
+}
+
+type tomlParser struct {
+	data string
+	pos  int
+	line int
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.data[p.pos]
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips blanks and, if newlines is set, line breaks and comments
+func (p *tomlParser) skipSpace(newlines bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t':
+			p.pos++
+		case newlines && c == '\n':
+			p.pos++
+			p.line++
+		case newlines && c == '\r':
+			p.pos++
+		case newlines && c == '#':
+			p.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// key parses a possibly dotted key of bare or quoted parts
+func (p *tomlParser) key() ([]string, error) {
+	var path []string
+	for {
+		var part string
+		switch p.peek() {
+		case '"', '\'':
+			s, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			part = s.(string)
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("expected a key")
+			}
+			part = p.data[start:p.pos]
+		}
+		path = append(path, part)
+
+		p.skipSpace(false)
+		if p.peek() != '.' {
+			return path, nil
+		}
+		p.pos++
+		p.skipSpace(false)
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// table returns the table at path, creating missing tables
+func (p *tomlParser) table(root map[string]interface{}, path []string) (map[string]interface{}, error) {
+	t := root
+	for _, name := range path {
+		switch next := t[name].(type) {
+		case nil:
+			created := make(map[string]interface{})
+			t[name] = created
+			t = created
+		case map[string]interface{}:
+			t = next
+		default:
+			return nil, p.errorf("key %q is not a table", name)
+		}
+	}
+	return t, nil
+}
+
+// set stores a value at a dotted key path below t
+func (p *tomlParser) set(t map[string]interface{}, path []string, value interface{}) error {
+	t, err := p.table(t, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	name := path[len(path)-1]
+	if _, ok := t[name]; ok {
+		return p.errorf("key %q defined twice", strings.Join(path, "."))
+	}
+	t[name] = value
+	return nil
+}
+
+func (p *tomlParser) value() (interface{}, error) {
+	switch c := p.peek(); {
+	case strings.HasPrefix(p.data[p.pos:], `"""`) || strings.HasPrefix(p.data[p.pos:], "'''"):
+		return nil, p.errorf("multi-line strings are not supported")
+	case c == '"':
+		return p.basicString()
+	case c == '\'':
+		p.pos++
+		end := strings.IndexAny(p.data[p.pos:], "'\n")
+		if end < 0 || p.data[p.pos+end] != '\'' {
+			return nil, p.errorf("unterminated string")
+		}
+		s := p.data[p.pos : p.pos+end]
+		p.pos += end + 1
+		return s, nil
+	case c == '[':
+		return p.array()
+	case c == '{':
+		return p.inlineTable()
+	default:
+		start := p.pos
+		for !p.eof() && strings.IndexByte(" \t\r\n,]}#", p.peek()) < 0 {
+			p.pos++
+		}
+		word := p.data[start:p.pos]
+		switch {
+		case word == "true":
+			return true, nil
+		case word == "false":
+			return false, nil
+		case word == "":
+			return nil, p.errorf("expected a value")
+		}
+		digits := strings.ReplaceAll(word, "_", "")
+		if n, err := strconv.ParseInt(digits, 0, 64); err == nil {
+			return n, nil
+		}
+		if f, err := strconv.ParseFloat(digits, 64); err == nil {
+			return f, nil
+		}
+		return nil, p.errorf("invalid value %q", word)
+	}
+}
+
+// basicString parses a double-quoted string with escapes
+func (p *tomlParser) basicString() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		p.pos++
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if p.eof() {
+				return "", p.errorf("unterminated string")
+			}
+			e := p.peek()
+			p.pos++
+			switch e {
+			case '"', '\\':
+				b.WriteByte(e)
+			case 'b':
+				b.WriteByte('\b')
+			case 't':
+				b.WriteByte('\t')
+			case 'n':
+				b.WriteByte('\n')
+			case 'f':
+				b.WriteByte('\f')
+			case 'r':
+				b.WriteByte('\r')
+			case 'u', 'U':
+				n := 4
+				if e == 'U' {
+					n = 8
+				}
+				if p.pos+n > len(p.data) {
+					return "", p.errorf("invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(p.data[p.pos:p.pos+n], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(r)) {
+					return "", p.errorf("invalid unicode escape")
+				}
+				b.WriteRune(rune(r))
+				p.pos += n
+			default:
+				return "", p.errorf("invalid escape \\%c", e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// array parses an array, which may span several lines
+func (p *tomlParser) array() ([]interface{}, error) {
+	p.pos++
+	list := []interface{}{}
+	for {
+		p.skipSpace(true)
+		if p.peek() == ']' {
+			p.pos++
+			return list, nil
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+
+		p.skipSpace(true)
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("expected , or ] in array")
+		}
+	}
+}
+
+// inlineTable parses a table written as { key = value, ... } on one line
+func (p *tomlParser) inlineTable() (map[string]interface{}, error) {
+	p.pos++
+	t := make(map[string]interface{})
+	p.skipSpace(false)
+	if p.peek() == '}' {
+		p.pos++
+		return t, nil
+	}
+	for {
+		p.skipSpace(false)
+		path, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace(false)
+		if p.peek() != '=' {
+			return nil, p.errorf("expected = after key")
+		}
+		p.pos++
+		p.skipSpace(false)
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.set(t, path, value); err != nil {
+			return nil, err
+		}
+
+		p.skipSpace(false)
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return t, nil
+		default:
+			return nil, p.errorf("expected , or } in inline table")
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minJWTSecretLength is the shortest JWT secret accepted outside
+// development: 256 bits, matching the HS256 signing key size
+const minJWTSecretLength = 32
+
+// knownInsecureSecrets are JWT secrets that have shipped in defaults and
+// examples and so must never be used outside development
+var knownInsecureSecrets = map[string]bool{
+	insecureJWTSecret: true,
+	"your-secret-key": true,
+	"your-secret-key-change-this-in-production": true,
+}
+
+var logLevels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+
+var sslModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true,
+}
+
+// Validate checks the configuration and reports every problem found. Outside
+// development it also rejects insecure defaults, such as the built-in JWT
+// secret.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.check(c.Env == EnvDevelopment || c.Env == EnvTest || c.Env == EnvProduction,
+		"env must be one of %s, %s or %s, got %q", EnvDevelopment, EnvTest, EnvProduction, c.Env)
+
+	v.check(c.Server.Address != "", "server.address must be set")
+	v.check(c.Server.ReadHeaderTimeout >= 0, "server.read_header_timeout must not be negative")
+	v.check(c.Server.ReadTimeout >= 0, "server.read_timeout must not be negative")
+	v.check(c.Server.WriteTimeout >= 0, "server.write_timeout must not be negative")
+	v.check(c.Server.IdleTimeout >= 0, "server.idle_timeout must not be negative")
+	v.check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	for _, proxy := range c.Server.TrustedProxies {
+		v.check(validAddressRange(proxy), "server.trusted_proxies must contain IP addresses or CIDR ranges, got %q", proxy)
+	}
+
+	v.check(c.Compression.MinSize >= 0, "compression.min_size must not be negative")
+	for _, t := range c.Compression.Types {
+		v.check(validMediaRange(t), "compression.types must contain media types such as application/json or text/*, got %q", t)
+	}
+
+	switch c.Database.Driver {
+	case "postgres":
+		v.check(c.Database.Host != "", "database.host must be set")
+		v.check(c.Database.Name != "", "database.name must be set")
+		port, err := strconv.Atoi(c.Database.Port)
+		v.check(err == nil && port > 0 && port < 1<<16, "database.port must be a port number, got %q", c.Database.Port)
+		v.check(sslModes[c.Database.SSLMode], "database.sslmode %q is not a PostgreSQL sslmode", c.Database.SSLMode)
+	case "sqlite":
+		v.check(c.Database.Path != "", "database.path must be set for the sqlite driver")
+		v.check(len(c.Database.Replicas) == 0, "database.replicas are not supported by the sqlite driver")
+	default:
+		v.check(false, "database.driver must be postgres or sqlite, got %q", c.Database.Driver)
+	}
+	v.check(c.Database.MaxOpenConns >= 0, "database.max_open_conns must not be negative")
+	v.check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns must not be negative")
+	v.check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+	v.check(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time must not be negative")
+	v.check(c.Database.ConnectRetries >= 0, "database.connect_retries must not be negative")
+	v.check(c.Database.ConnectRetries == 0 || c.Database.ConnectBackoff > 0, "database.connect_backoff must be positive")
+	v.check(c.Database.StatementTimeout >= 0, "database.statement_timeout must not be negative")
+	v.check(c.Database.ReplicaStickiness >= 0, "database.replica_stickiness must not be negative")
+	for _, replica := range c.Database.Replicas {
+		v.check(validHostPort(replica), "database.replicas must contain hosts or host:port pairs, got %q", replica)
+	}
+
+	v.check(c.JWT.Secret != "", "jwt.secret must be set")
+	v.check(c.JWT.AccessExpiresIn > 0, "jwt.access_expires_in must be positive")
+	v.check(c.JWT.RefreshExpiresIn > 0, "jwt.refresh_expires_in must be positive")
+
+	v.check(c.Auth.BcryptCost >= bcrypt.MinCost && c.Auth.BcryptCost <= bcrypt.MaxCost,
+		"auth.bcrypt_cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+
+	v.check(logLevels[c.Log.Level], "log.level must be debug, info, warn or error, got %q", c.Log.Level)
+
+	v.check(c.RateLimit.Requests >= 0, "rate_limit.requests must not be negative")
+	v.check(c.RateLimit.Requests == 0 || c.RateLimit.Window > 0, "rate_limit.window must be positive")
+
+	v.check(c.Audit.ChainInterval > 0, "audit.chain_interval must be positive")
+
+	v.check(c.Idempotency.Store == "postgres" || c.Idempotency.Store == "memory",
+		"idempotency.store must be postgres or memory, got %q", c.Idempotency.Store)
+	v.check(c.Idempotency.TTL > 0, "idempotency.ttl must be positive")
+	v.check(c.Idempotency.MaxBody > 0, "idempotency.max_body must be positive")
+
+	switch c.Cache.Driver {
+	case "memory":
+		v.check(c.Cache.Size > 0, "cache.size must be positive for the memory driver")
+	case "redis":
+		v.check(c.Cache.Redis.Address != "", "cache.redis.address must be set for the redis driver")
+		v.check(c.Cache.Redis.DB >= 0, "cache.redis.db must not be negative")
+		v.check(c.Cache.Redis.Timeout > 0, "cache.redis.timeout must be positive")
+		v.check(c.Cache.Redis.PoolSize >= 0, "cache.redis.pool_size must not be negative")
+	case "none":
+	default:
+		v.check(false, "cache.driver must be memory, redis or none, got %q", c.Cache.Driver)
+	}
+	v.check(c.Cache.Driver == "none" || c.Cache.TTL > 0, "cache.ttl must be positive")
+
+	v.check(c.Events.ReplaySize > 0, "events.replay_size must be positive")
+	v.check(c.Events.ReplayTTL > 0, "events.replay_ttl must be positive")
+	v.check(c.Stream.HeartbeatInterval > 0, "stream.heartbeat_interval must be positive")
+
+	v.check(c.Webhook.Workers > 0, "webhook.workers must be positive")
+	v.check(c.Webhook.BatchSize > 0, "webhook.batch_size must be positive")
+	v.check(c.Webhook.Timeout > 0, "webhook.timeout must be positive")
+	v.check(c.Webhook.PollInterval > 0, "webhook.poll_interval must be positive")
+	v.check(c.Webhook.MaxAttempts > 0, "webhook.max_attempts must be positive")
+	v.check(c.Webhook.BackoffBase > 0, "webhook.backoff_base must be positive")
+	v.check(c.Webhook.BackoffMax >= c.Webhook.BackoffBase, "webhook.backoff_max must not be less than webhook.backoff_base")
+
+	v.check(c.Outbox.BatchSize > 0, "outbox.batch_size must be positive")
+	v.check(c.Outbox.PollInterval > 0, "outbox.poll_interval must be positive")
+	v.check(c.Outbox.Timeout > 0, "outbox.timeout must be positive")
+	v.check(c.Outbox.MaxAttempts > 0, "outbox.max_attempts must be positive")
+	v.check(c.Outbox.BackoffBase > 0, "outbox.backoff_base must be positive")
+	v.check(c.Outbox.BackoffMax >= c.Outbox.BackoffBase, "outbox.backoff_max must not be less than outbox.backoff_base")
+	v.check(c.Outbox.Retention > 0, "outbox.retention must be positive")
+
+	switch c.Storage.Driver {
+	case "local":
+		v.check(c.Storage.LocalDir != "", "storage.local_dir must be set for the local driver")
+	case "s3":
+		v.check(c.Storage.S3.Endpoint != "", "storage.s3.endpoint must be set for the s3 driver")
+		v.check(c.Storage.S3.Bucket != "", "storage.s3.bucket must be set for the s3 driver")
+	case "memory":
+	default:
+		v.check(false, "storage.driver must be local, s3 or memory, got %q", c.Storage.Driver)
+	}
+
+	v.check(c.Vault.Timeout > 0, "vault.timeout must be positive")
+
+	v.check(c.Attachments.MaxSize > 0, "attachments.max_size must be positive")
+	v.check(c.Attachments.URLTTL > 0, "attachments.url_ttl must be positive")
+
+	password, username, name := c.Validation.Password, c.Validation.Username, c.Validation.Name
+	v.check(password.MinLength > 0, "validation.password.min_length must be positive")
+	v.check(username.MinLength > 0, "validation.username.min_length must be positive")
+	v.check(username.MaxLength >= username.MinLength, "validation.username.max_length must not be less than validation.username.min_length")
+	v.check(username.AllowedChars != "", "validation.username.allowed_chars must be set")
+	v.check(name.MinLength > 0, "validation.name.min_length must be positive")
+	v.check(name.MaxLength >= name.MinLength, "validation.name.max_length must not be less than validation.name.min_length")
+	v.check(name.AllowedChars != "", "validation.name.allowed_chars must be set")
+	v.check(name.MaxConsecutive > 0, "validation.name.max_consecutive must be positive")
+
+	for _, name := range sortedKeys(c.Features) {
+		flag := c.Features[name]
+		v.check(name != "", "features must not contain an empty name")
+		v.check(flag.Percentage >= 0 && flag.Percentage <= 100, "features.%s.percentage must be between 0 and 100", name)
+	}
+
+	if c.Env != EnvDevelopment {
+		v.check(!knownInsecureSecrets[c.JWT.Secret.Value()], "jwt.secret is a published default and must be replaced in %s", c.Env)
+		v.check(len(c.JWT.Secret) >= minJWTSecretLength, "jwt.secret must be at least %d bytes in %s", minJWTSecretLength, c.Env)
+		v.check(c.Database.Driver != "postgres" || c.Database.Password != "postgres", "database.password is the default and must be replaced in %s", c.Env)
+		if c.Attachments.URLSecret != "" {
+			v.check(len(c.Attachments.URLSecret) >= minJWTSecretLength, "attachments.url_secret must be at least %d bytes in %s", minJWTSecretLength, c.Env)
+		}
+		if c.Storage.Driver == "s3" {
+			v.check(c.Storage.S3.AccessKey != "" && c.Storage.S3.SecretKey != "", "storage.s3.access_key and storage.s3.secret_key must be set in %s", c.Env)
+		}
+	}
+
+	if len(v.errs) > 0 {
+		return fmt.Errorf("config: invalid configuration:\n%w", errors.Join(v.errs...))
+	}
+	return nil
+}
+
+// validHostPort reports whether s is a host, or a host and port number
+func validHostPort(s string) bool {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return s != "" && !strings.ContainsAny(s, ":/ ")
+	}
+	n, err := strconv.Atoi(port)
+	return host != "" && err == nil && n > 0 && n < 1<<16
+}
+
+// validMediaRange reports whether s is a lower case media type without
+// parameters, such as text/plain, or a range of them, such as text/*
+func validMediaRange(s string) bool {
+	if prefix, ok := strings.CutSuffix(s, "/*"); ok {
+		s = prefix + "/any"
+	}
+	mediaType, params, err := mime.ParseMediaType(s)
+	return err == nil && len(params) == 0 && mediaType == s
+}
+
+// validAddressRange reports whether s is an IP address, such as 10.0.0.1,
+// or a CIDR range, such as 10.0.0.0/8
+func validAddressRange(s string) bool {
+	if _, err := netip.ParsePrefix(s); err == nil {
+		return true
+	}
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
+
+// validator collects validation failures
+type validator struct {
+	errs []error
+}
+
+// check records a failure unless ok
+func (v *validator) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.errs = append(v.errs, fmt.Errorf(format, args...))
+	}
+}
+
+// sortedKeys returns the keys of a map in order, so that errors and output
+// are deterministic
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Vault holds the connection settings of a HashiCorp Vault compatible
+// server, used to resolve vault:<path>#<key> secret references
+type Vault struct {
+	Address   string        `key:"address" env:"VAULT_ADDR" doc:"Vault server URL, such as https://vault.example.com:8200; required for vault: references"`
+	Token     Secret        `key:"token" env:"VAULT_TOKEN" doc:"Vault token used to read secrets"`
+	Mount     string        `key:"mount" env:"VAULT_MOUNT" doc:"Mount path of the KV version 2 secrets engine"`
+	Namespace string        `key:"namespace" env:"VAULT_NAMESPACE" doc:"Vault Enterprise namespace, if any"`
+	Timeout   time.Duration `key:"timeout" env:"VAULT_TIMEOUT" doc:"Time allowed for each Vault request"`
+}
+
+// VaultProvider reads secrets from the KV version 2 secrets engine of a
+// Vault compatible server. Names have the form <path>#<key>, such as
+// myapp/database#password, which reads the key password of the secret at
+// <mount>/data/myapp/database.
+type VaultProvider struct {
+	config Vault
+	client *http.Client
+}
+
+// NewVaultProvider creates a VaultProvider. A nil client uses a client with
+// the configured timeout.
+func NewVaultProvider(config Vault, client *http.Client) *VaultProvider {
+	if config.Mount == "" {
+		config.Mount = "secret"
+	}
+	if client == nil {
+		client = &http.Client{Timeout: config.Timeout}
+	}
+	return &VaultProvider{config: config, client: client}
+}
+
+// vaultResponse is the part of a KV version 2 read response that holds the
+// secret's keys
+type vaultResponse struct {
+	Data struct {
+		Data map[string]interface{} `json:"data"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// Secret reads the key of the secret at path, given as <path>#<key>
+func (p *VaultProvider) Secret(ctx context.Context, name string) (string, error) {
+	path, key, ok := strings.Cut(name, "#")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("invalid reference %q, want <path>#<key>", name)
+	}
+
+	endpoint, err := url.JoinPath(p.config.Address, "v1", p.config.Mount, "data", path)
+	if err != nil {
+		return "", fmt.Errorf("invalid vault.address: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", p.config.Token.Value())
+	if p.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.config.Namespace)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body vaultResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("decoding response for %s: %w", path, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("%s: %w", path, ErrSecretNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("reading %s: %s %s", path, resp.Status, strings.Join(body.Errors, "; "))
+	}
+
+	value, ok := body.Data.Data[key]
+	if !ok {
+		return "", fmt.Errorf("%s#%s: %w", path, key, ErrSecretNotFound)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s#%s is not a string", path, key)
+	}
+	return s, nil
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// vaultStub serves the KV version 2 secret myapp/database under the mount
+// kv to requests with the token root in the namespace team
+func vaultStub(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Header.Get("X-Vault-Token") != "root" || r.Header.Get("X-Vault-Namespace") != "team":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+		case r.URL.Path == "/v1/kv/data/myapp/database":
+			w.Write([]byte(`{"data":{"data":{"password":"s3cret","port":5432}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVaultProviderSecret(t *testing.T) {
+	server := vaultStub(t)
+	tests := []struct {
+		name     string
+		token    Secret
+		ref      string
+		want     string
+		notFound bool
+		wantErr  string
+	}{
+		{"found", "root", "myapp/database#password", "s3cret", false, ""},
+		{"missing key", "root", "myapp/database#user", "", true, ""},
+		{"missing secret", "root", "myapp/cache#password", "", true, ""},
+		{"not a string", "root", "myapp/database#port", "", false, "is not a string"},
+		{"no key", "root", "myapp/database", "", false, "invalid reference"},
+		{"denied", "wrong", "myapp/database#password", "", false, "permission denied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewVaultProvider(Vault{Address: server.URL, Token: tt.token, Mount: "kv", Namespace: "team"}, server.Client())
+			got, err := provider.Secret(context.Background(), tt.ref)
+			switch {
+			case tt.notFound:
+				if !errors.Is(err, ErrSecretNotFound) {
+					t.Fatalf("Secret(%q) error = %v, want ErrSecretNotFound", tt.ref, err)
+				}
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Secret(%q) error = %v, want one containing %q", tt.ref, err, tt.wantErr)
+				}
+			case err != nil || got != tt.want:
+				t.Fatalf("Secret(%q) = %q, %v; want %q", tt.ref, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadResolvesVaultReferences(t *testing.T) {
+	server := vaultStub(t)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "env:TEST_VAULT_TOKEN")
+	t.Setenv("TEST_VAULT_TOKEN", "root")
+	t.Setenv("VAULT_MOUNT", "kv")
+	t.Setenv("VAULT_NAMESPACE", "team")
+	t.Setenv("DB_PASSWORD", "vault:myapp/database#password")
+
+	cfg, err := NewLoader(flag.NewFlagSet("test", flag.ContinueOnError)).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Password.Value() != "s3cret" {
+		t.Fatalf("database.password = %q, want the secret read from Vault", cfg.Database.Password.Value())
+	}
+
+	t.Setenv("DB_PASSWORD", "vault:myapp/cache#password")
+	if _, err := NewLoader(flag.NewFlagSet("test", flag.ContinueOnError)).Load(); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("Load with a missing Vault secret: %v, want ErrSecretNotFound", err)
+	}
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// watchInterval is how often a Watcher checks the configuration file for
+// changes
+const watchInterval = 2 * time.Second
+
+// Watcher holds the current configuration and reloads it on SIGHUP or when
+// the configuration file changes. A reloaded configuration is validated
+// like the initial one; only its reloadable keys are applied, and an invalid
+// one is rejected as a whole.
+type Watcher struct {
+	loader  *Loader
+	current atomic.Pointer[Config]
+
+	mu        sync.Mutex
+	callbacks []func(*Config)
+}
+
+// NewWatcher creates a Watcher for a configuration loaded by loader
+func NewWatcher(loader *Loader, cfg *Config) *Watcher {
+	w := &Watcher{loader: loader}
+	w.current.Store(cfg)
+	return w
+}
+
+// Current returns the current configuration. It must not be modified.
+func (w *Watcher) Current() *Config {
+	return w.current.Load()
+}
+
+// OnReload registers fn to be called with the new configuration after
+// every successful reload
+func (w *Watcher) OnReload(fn func(*Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callbacks = append(w.callbacks, fn)
+}
+
+// Reload loads the configuration again, including the .env file, and swaps
+// in its reloadable keys. Changes to other keys are logged and otherwise
+// ignored until restart.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	loaded, err := w.loader.Load()
+	if err != nil {
+		return err
+	}
+
+	next := *w.current.Load()
+	nextFields, loadedFields := fields(&next), fields(loaded)
+	for i, f := range nextFields {
+		src := loadedFields[i]
+		switch {
+		case f.reload:
+			f.value.Set(src.value)
+		case !reflect.DeepEqual(f.value.Interface(), src.value.Interface()):
+			slog.Warn("config: change needs a restart to take effect", "key", f.key)
+		}
+	}
+	next.Features = loaded.Features
+
+	w.current.Store(&next)
+	for _, fn := range w.callbacks {
+		fn(&next)
+	}
+	return nil
+}
+
+// Run reloads the configuration on SIGHUP and whenever the configuration
+// file is modified, until ctx is cancelled. Failed reloads are logged and
+// leave the current configuration in place.
+func (w *Watcher) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	path := w.loader.File()
+	last := fileVersion(path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("config: reloading on SIGHUP")
+		case <-ticker.C:
+			version := fileVersion(path)
+			if version == last {
+				continue
+			}
+			last = version
+			slog.Info("config: reloading changed file", "path", path)
+		}
+
+		if err := w.Reload(); err != nil {
+			slog.Error("config: reload failed, keeping the current configuration", "error", err)
+		}
+	}
+}
+
+// fileVersion identifies the content of a file by size and modification
+// time. It is empty if there is no file.
+func fileVersion(path string) string {
+	if path == "" {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%d", info.ModTime(), info.Size())
+}
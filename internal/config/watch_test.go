@@ -0,0 +1,134 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newWatcher loads the configuration file at path and watches it
+func newWatcher(t *testing.T, path string) *Watcher {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := NewLoader(fs)
+	if err := fs.Parse([]string{"-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewWatcher(loader, cfg)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherReloadSwapsReloadableKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "server:\n  address: \":8080\"\nlog:\n  level: info\n")
+	w := newWatcher(t, path)
+	before := w.Current()
+
+	var notified *Config
+	w.OnReload(func(cfg *Config) { notified = cfg })
+
+	writeFile(t, path, "server:\n  address: \":9090\"\nlog:\n  level: debug\nfeatures:\n  beta:\n    enabled: true\n")
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := w.Current()
+	if cfg.Log.Level != "debug" {
+		t.Errorf("log.level = %q after reload, want debug", cfg.Log.Level)
+	}
+	if cfg.Server.Address != ":8080" {
+		t.Errorf("server.address = %q after reload, want it kept until restart", cfg.Server.Address)
+	}
+	if !cfg.Features["beta"].Enabled {
+		t.Errorf("features = %v after reload, want beta enabled", cfg.Features)
+	}
+	if notified != cfg {
+		t.Error("OnReload callback not called with the new configuration")
+	}
+	if before.Log.Level != "info" {
+		t.Errorf("previous configuration modified: log.level = %q", before.Log.Level)
+	}
+}
+
+func TestWatcherRejectsInvalidReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "log:\n  level: warn\n")
+	w := newWatcher(t, path)
+	before := w.Current()
+
+	called := false
+	w.OnReload(func(*Config) { called = true })
+
+	for _, content := range []string{
+		"log:\n  level: verbose\n",
+		"log:\n  level: debug\nrate_limit:\n  requests: -1\n",
+		"log:\n  level: [debug\n",
+	} {
+		writeFile(t, path, content)
+		if err := w.Reload(); err == nil {
+			t.Errorf("Reload of %q succeeded, want it rejected", content)
+		}
+	}
+	if w.Current() != before || w.Current().Log.Level != "warn" {
+		t.Errorf("configuration changed by a rejected reload: log.level = %q", w.Current().Log.Level)
+	}
+	if called {
+		t.Error("OnReload callback called for a rejected reload")
+	}
+}
+
+func TestWatcherReloadReadsDotenv(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	// Variables the test sets are restored afterwards, including those the
+	// .env file sets
+	t.Setenv("LOG_LEVEL", "")
+	os.Unsetenv("LOG_LEVEL")
+	t.Setenv("RATE_LIMIT_REQUESTS", "7")
+
+	writeFile(t, ".env", "LOG_LEVEL=warn\nRATE_LIMIT_REQUESTS=1\n")
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "")
+	w := newWatcher(t, path)
+	if cfg := w.Current(); cfg.Log.Level != "warn" || cfg.RateLimit.Requests != 7 {
+		t.Fatalf("log.level = %q, rate_limit.requests = %d; want warn from .env and 7 from the environment", cfg.Log.Level, cfg.RateLimit.Requests)
+	}
+
+	writeFile(t, ".env", "LOG_LEVEL=error\nRATE_LIMIT_REQUESTS=2\n")
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := w.Current(); cfg.Log.Level != "error" || cfg.RateLimit.Requests != 7 {
+		t.Fatalf("after editing .env: log.level = %q, rate_limit.requests = %d; want error and 7", cfg.Log.Level, cfg.RateLimit.Requests)
+	}
+
+	writeFile(t, ".env", "# LOG_LEVEL removed\n")
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if level := w.Current().Log.Level; level != Default().Log.Level {
+		t.Fatalf("after removing LOG_LEVEL from .env: log.level = %q, want the default %q", level, Default().Log.Level)
+	}
+	if _, ok := os.LookupEnv("LOG_LEVEL"); ok || os.Getenv("RATE_LIMIT_REQUESTS") != "7" {
+		t.Fatal("environment not restored to the variables set outside .env")
+	}
+}
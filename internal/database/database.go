@@ -0,0 +1,228 @@
+// Package database opens the database connection pools of the
+// application: the primary, retried with backoff while it starts up, and
+// optional read replicas that read-only queries are routed to. The primary
+// is a PostgreSQL server, or a SQLite file for running without one.
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Drivers supported by Open
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// sqliteMigrations are the SQLite counterparts of internal/db/migrations,
+// numbered alike. The first creates the schema as of the migration SQLite
+// support was added in.
+//
+//go:embed sqlite/*.up.sql
+var sqliteMigrations embed.FS
+
+// sqliteParams are the connection settings of SQLite databases:
+// enforcing foreign keys, waiting for the write lock rather than failing,
+// and taking it when a transaction begins, so that transactions that read
+// before writing cannot deadlock. Times are written in a format that sorts
+// and compares as text.
+const sqliteParams = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate&_time_format=sqlite"
+
+// maxConnectBackoff caps the wait between connection attempts
+const maxConnectBackoff = 30 * time.Second
+
+// Config configures the connection pools
+type Config struct {
+	// Driver is Postgres or SQLite; empty means Postgres
+	Driver string
+	// DSN is the data source name of the primary, or the path of the
+	// database file for SQLite
+	DSN string
+	// ReplicaDSNs are the data source names of the read replicas, if any.
+	// SQLite does not support replicas.
+	ReplicaDSNs []string
+
+	// Pool settings, applied to the primary and every replica
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ConnectRetries is how many times connecting is retried before Open
+	// gives up
+	ConnectRetries int
+	// ConnectBackoff is the wait before the first retry. It doubles after
+	// every attempt, up to 30 seconds.
+	ConnectBackoff time.Duration
+}
+
+// Open connects to the primary and the replicas. Connections that fail,
+// such as while the database is still starting, are retried with
+// exponential backoff. Read-only queries are routed to the replicas, see
+// ReadOnly. A SQLite database is created if it does not exist, and its
+// schema brought up to date.
+func Open(cfg Config) (*gorm.DB, error) {
+	db, _, err := connect(cfg, cfg.DSN, "primary")
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Driver == SQLite {
+		if len(cfg.ReplicaDSNs) > 0 {
+			closeAll(db, nil)
+			return nil, errors.New("database: SQLite does not support replicas")
+		}
+		if err := migrateSQLite(db); err != nil {
+			closeAll(db, nil)
+			return nil, fmt.Errorf("failed to migrate the SQLite schema: %w", err)
+		}
+		return db, nil
+	}
+	if len(cfg.ReplicaDSNs) == 0 {
+		return db, nil
+	}
+
+	replicas := make([]*sql.DB, 0, len(cfg.ReplicaDSNs))
+	for i, dsn := range cfg.ReplicaDSNs {
+		_, replica, err := connect(cfg, dsn, fmt.Sprintf("replica %d", i+1))
+		if err != nil {
+			closeAll(db, replicas)
+			return nil, err
+		}
+		replicas = append(replicas, replica)
+	}
+	if err := db.Use(newRouter(replicas)); err != nil {
+		closeAll(db, replicas)
+		return nil, fmt.Errorf("failed to register replica routing: %w", err)
+	}
+	return db, nil
+}
+
+// Close closes the connection pools of a database opened by Open
+func Close(db *gorm.DB) error {
+	var errs []error
+	if plugin, ok := db.Config.Plugins[routerName]; ok {
+		for _, replica := range plugin.(*router).replicas {
+			errs = append(errs, replica.Close())
+		}
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	return errors.Join(append(errs, sqlDB.Close())...)
+}
+
+// connect opens a connection pool, retrying as configured
+func connect(cfg Config, dsn, name string) (*gorm.DB, *sql.DB, error) {
+	backoff := cfg.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		db, err := gorm.Open(dialector(cfg.Driver, dsn), &gorm.Config{TranslateError: true})
+		if err == nil {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to get underlying *sql.DB: %w", err)
+			}
+			sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+			sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+			sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+			sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+			return db, sqlDB, nil
+		}
+		if attempt >= cfg.ConnectRetries {
+			return nil, nil, fmt.Errorf("failed to connect to the %s database: %w", name, err)
+		}
+
+		slog.Warn("database: connection failed, retrying",
+			"database", name, "attempt", attempt+1, "retry_in", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff = min(2*backoff, maxConnectBackoff)
+	}
+}
+
+// dialector returns the GORM dialector of a driver
+func dialector(driver, dsn string) gorm.Dialector {
+	if driver == SQLite {
+		return sqlite.Open(dsn + "?" + sqliteParams)
+	}
+	return postgres.Open(dsn)
+}
+
+// migrateSQLite applies the SQLite migrations numbered after the version
+// recorded in schema_version, each in a transaction of its own
+func migrateSQLite(db *gorm.DB) error {
+	if err := db.Exec("CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)").Error; err != nil {
+		return err
+	}
+	names, err := fs.Glob(sqliteMigrations, "sqlite/*.up.sql")
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		version, err := migrationVersion(name)
+		if err != nil {
+			return err
+		}
+		script, err := sqliteMigrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		// Transactions take the write lock when they begin, so instances
+		// starting at the same time apply each migration once
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var current int
+			if err := tx.Raw("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&current).Error; err != nil {
+				return err
+			}
+			if version <= current {
+				return nil
+			}
+			if err := tx.Exec(string(script)).Error; err != nil {
+				return err
+			}
+			return tx.Exec("INSERT INTO schema_version (version) VALUES (?)", version).Error
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", path.Base(name), err)
+		}
+	}
+	return nil
+}
+
+// migrationVersion returns the number a migration file name starts with
+func migrationVersion(name string) (int, error) {
+	number, _, _ := strings.Cut(path.Base(name), "_")
+	version, err := strconv.Atoi(number)
+	if err != nil {
+		return 0, fmt.Errorf("migration %s is not numbered", name)
+	}
+	return version, nil
+}
+
+// IsSQLite reports whether db is a SQLite database, for the few queries
+// that differ from PostgreSQL
+func IsSQLite(db *gorm.DB) bool {
+	return db.Dialector.Name() == SQLite
+}
+
+// closeAll closes the pools opened so far when Open fails
+func closeAll(db *gorm.DB, replicas []*sql.DB) {
+	for _, replica := range replicas {
+		replica.Close()
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"database/sql"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// routerName is the name the replica router is registered under
+	routerName = "database:replicas"
+	// readOnlyKey marks a statement as safe to run on a replica
+	readOnlyKey = "database:read_only"
+)
+
+// ReadOnly is a scope that marks a query as read-only, so that it may run on
+// a read replica:
+//
+//	db.WithContext(ctx).Scopes(database.ReadOnly).First(&user, id)
+//
+// The query still runs on the primary inside a transaction, with a locking
+// clause, with a context from Primary, and in a session that has written to
+// or sticks to the primary (see WithSession). Without replicas the mark has no effect. Replicas may
+// lag behind the primary, so only reads that tolerate slightly stale data
+// should be marked.
+func ReadOnly(db *gorm.DB) *gorm.DB {
+	return db.Set(readOnlyKey, true)
+}
+
+// router is a GORM plugin that sends read-only queries to the replicas in
+// turn, and records writes in the session of their context
+type router struct {
+	replicas []*sql.DB
+	next     atomic.Uint64
+}
+
+func newRouter(replicas []*sql.DB) *router {
+	return &router{replicas: replicas}
+}
+
+// Name implements gorm.Plugin
+func (r *router) Name() string {
+	return routerName
+}
+
+// Initialize implements gorm.Plugin
+func (r *router) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Query().Before("gorm:query").Register("database:route_read", r.route); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("database:route_read", r.route); err != nil {
+		return err
+	}
+	if err := callbacks.Create().After("gorm:create").Register("database:record_write", recordWrite); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("database:record_write", recordWrite); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().After("gorm:delete").Register("database:record_write", recordWrite); err != nil {
+		return err
+	}
+	return callbacks.Raw().After("gorm:raw").Register("database:record_write", recordWrite)
+}
+
+// route switches a read-only query to the next replica
+func (r *router) route(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	if readOnly, _ := db.Get(readOnlyKey); readOnly != true {
+		return
+	}
+	if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		return
+	}
+	if _, locking := db.Statement.Clauses[clause.Locking{}.Name()]; locking {
+		return
+	}
+	if onPrimary(db.Statement.Context) {
+		return
+	}
+	db.Statement.ConnPool = r.replicas[(r.next.Add(1)-1)%uint64(len(r.replicas))]
+}
+
+// recordWrite marks the session of a write's context, so that the reads
+// that follow it go to the primary
+func recordWrite(db *gorm.DB) {
+	if db.Error == nil {
+		markWrite(db.Statement.Context)
+	}
+}
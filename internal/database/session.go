@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// session tracks whether the work done with a context has written to the
+// primary, or has to read from it for another reason
+type session struct {
+	primary atomic.Bool
+	wrote   atomic.Bool
+	// onWrite, if set, is called on each write
+	onWrite func()
+}
+
+type contextKey struct{}
+
+// primaryKey marks a context whose reads go to the primary
+type primaryKey struct{}
+
+// WithSession returns a copy of ctx with a new session. Once a query run
+// with the session's context has written, its read-only queries stop going
+// to the replicas, so that the session sees its own writes. If primary is
+// set, they go to the primary from the start.
+func WithSession(ctx context.Context, primary bool) context.Context {
+	return withSession(ctx, primary, nil)
+}
+
+// withSession is WithSession with a function called on each write
+func withSession(ctx context.Context, primary bool, onWrite func()) context.Context {
+	s := &session{onWrite: onWrite}
+	s.primary.Store(primary)
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// Wrote reports whether a write has been made in the session of ctx
+func Wrote(ctx context.Context) bool {
+	s, ok := ctx.Value(contextKey{}).(*session)
+	return ok && s.wrote.Load()
+}
+
+// markWrite records a write in the session of ctx, if any
+func markWrite(ctx context.Context) {
+	if s, ok := ctx.Value(contextKey{}).(*session); ok {
+		s.wrote.Store(true)
+		if s.onWrite != nil {
+			s.onWrite()
+		}
+	}
+}
+
+// Primary returns a copy of ctx whose read-only queries go to the primary.
+// Reads that a write depends on, such as the version a conditional update
+// checks, use it so that they do not see a replica's stale state.
+func Primary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// onPrimary reports whether the read-only queries of ctx go to the primary,
+// because of Primary or of its session
+func onPrimary(ctx context.Context) bool {
+	if ctx.Value(primaryKey{}) == true {
+		return true
+	}
+	s, ok := ctx.Value(contextKey{}).(*session)
+	return ok && (s.primary.Load() || s.wrote.Load())
+}
+
+// Stickiness remembers who wrote recently, so that their next sessions read
+// from the primary until the replicas have caught up. It is safe for
+// concurrent use.
+type Stickiness struct {
+	mu        sync.Mutex
+	window    time.Duration
+	writes    map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewStickiness creates a Stickiness that keeps a writer on the primary for
+// window after their last write. A zero window only keeps sessions that
+// wrote on the primary.
+func NewStickiness(window time.Duration) *Stickiness {
+	return &Stickiness{
+		window: window,
+		writes: make(map[string]time.Time),
+	}
+}
+
+// Begin returns a copy of ctx with a new session for key, which reads from
+// the primary if key wrote within the window. The writes of the session
+// are recorded for key as they are made, so that the next sessions of key
+// read from the primary even if they start before this one ends. An empty
+// key identifies no one.
+func (s *Stickiness) Begin(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return WithSession(ctx, false)
+	}
+	s.mu.Lock()
+	last, ok := s.writes[key]
+	s.mu.Unlock()
+	return withSession(ctx, ok && time.Since(last) < s.window, func() { s.record(key) })
+}
+
+// record records a write for key
+func (s *Stickiness) record(key string) {
+	if s.window <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	s.writes[key] = now
+}
+
+// sweep drops writes older than the window. It runs at most once per window.
+func (s *Stickiness) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.window {
+		return
+	}
+	s.lastSweep = now
+	for key, last := range s.writes {
+		if now.Sub(last) >= s.window {
+			delete(s.writes, key)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStickinessRecordsWritesAsTheyAreMade(t *testing.T) {
+	stickiness := NewStickiness(time.Minute)
+
+	writing := stickiness.Begin(context.Background(), "user:1")
+	if onPrimary(writing) {
+		t.Fatal("a session of a user who never wrote reads from the primary")
+	}
+	markWrite(writing)
+	if !onPrimary(writing) {
+		t.Fatal("a session that wrote reads from the replicas")
+	}
+
+	// The next session starts before the one that wrote ends
+	if !onPrimary(stickiness.Begin(context.Background(), "user:1")) {
+		t.Error("a session of a user who just wrote reads from the replicas")
+	}
+	if onPrimary(stickiness.Begin(context.Background(), "user:2")) {
+		t.Error("a session of another user reads from the primary")
+	}
+	anonymous := stickiness.Begin(context.Background(), "")
+	markWrite(anonymous)
+	if onPrimary(stickiness.Begin(context.Background(), "")) {
+		t.Error("a write without a key made later anonymous sessions read from the primary")
+	}
+}
+
+func TestStickinessWithoutWindow(t *testing.T) {
+	stickiness := NewStickiness(0)
+	markWrite(stickiness.Begin(context.Background(), "user:1"))
+	if onPrimary(stickiness.Begin(context.Background(), "user:1")) {
+		t.Error("a session reads from the primary without a stickiness window")
+	}
+}
+
+func TestPrimary(t *testing.T) {
+	ctx := WithSession(context.Background(), false)
+	if onPrimary(ctx) {
+		t.Fatal("a new session reads from the primary")
+	}
+	if !onPrimary(Primary(ctx)) {
+		t.Error("a context from Primary reads from the replicas")
+	}
+	if !onPrimary(Primary(context.Background())) {
+		t.Error("a context from Primary without a session reads from the replicas")
+	}
+}
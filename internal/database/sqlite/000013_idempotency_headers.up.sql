@@ -0,0 +1,3 @@
+-- Keep the headers of stored responses, such as ETag and Location, so that
+-- replays carry them
+ALTER TABLE idempotency_keys ADD COLUMN headers TEXT;
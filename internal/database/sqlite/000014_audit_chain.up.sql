@@ -0,0 +1,29 @@
+-- Chain audit entries in a separate append-only table written by a single
+-- sequencer, so that recording an entry does not serialize the transactions
+-- of every audited action. Entries are content-hashed when recorded and
+-- queued in audit_pending until the sequencer links them.
+CREATE TABLE IF NOT EXISTS audit_chain (
+    seq INTEGER PRIMARY KEY AUTOINCREMENT,
+    entry_id BIGINT NOT NULL UNIQUE REFERENCES audit_log(id),
+    prev_hash VARCHAR(64) NOT NULL,
+    hash VARCHAR(64) NOT NULL
+);
+
+CREATE TRIGGER IF NOT EXISTS audit_chain_no_update BEFORE UPDATE ON audit_chain
+BEGIN
+    SELECT RAISE(ABORT, 'audit_chain is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_chain_no_delete BEFORE DELETE ON audit_chain
+BEGIN
+    SELECT RAISE(ABORT, 'audit_chain is append-only');
+END;
+
+CREATE TABLE IF NOT EXISTS audit_pending (
+    entry_id BIGINT PRIMARY KEY REFERENCES audit_log(id)
+);
+
+-- Entries recorded before the sequencer are chained in the order of their IDs
+INSERT OR IGNORE INTO audit_pending (entry_id)
+SELECT id FROM audit_log
+WHERE id NOT IN (SELECT entry_id FROM audit_chain);
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// sqliteOnlyTables stand in for PostgreSQL features SQLite lacks
+var sqliteOnlyTables = []string{"todo_change_seq"}
+
+var (
+	sqlComment     = regexp.MustCompile(`--[^\n]*`)
+	dollarQuoted   = regexp.MustCompile(`(?s)\$\$.*?\$\$`)
+	createTable    = regexp.MustCompile(`(?is)^CREATE TABLE (?:IF NOT EXISTS )?(\w+)\s*\((.*)\)$`)
+	alterTable     = regexp.MustCompile(`(?is)^ALTER TABLE (?:IF EXISTS )?(\w+)\s+(.*)$`)
+	dropTable      = regexp.MustCompile(`(?is)^DROP TABLE (?:IF EXISTS )?(\w+)`)
+	addColumn      = regexp.MustCompile(`(?is)^ADD (?:COLUMN )?(?:IF NOT EXISTS )?(\w+)`)
+	dropColumn     = regexp.MustCompile(`(?is)^DROP (?:COLUMN )?(?:IF EXISTS )?(\w+)`)
+	renameColumn   = regexp.MustCompile(`(?is)^RENAME (?:COLUMN )?(\w+) TO (\w+)`)
+	tableConstrain = regexp.MustCompile(`(?i)^(PRIMARY|UNIQUE|CONSTRAINT|FOREIGN|CHECK)\b`)
+)
+
+// schemaColumns returns the columns of every table the scripts create, in
+// the order they are applied, by reading their CREATE TABLE and ALTER TABLE
+// statements
+func schemaColumns(t *testing.T, scripts []string) map[string][]string {
+	t.Helper()
+	tables := make(map[string][]string)
+	for _, script := range scripts {
+		script = dollarQuoted.ReplaceAllString(sqlComment.ReplaceAllString(script, ""), "''")
+		for _, stmt := range strings.Split(script, ";") {
+			stmt = strings.TrimSpace(stmt)
+			if m := createTable.FindStringSubmatch(stmt); m != nil {
+				if _, ok := tables[m[1]]; ok {
+					continue
+				}
+				var columns []string
+				for _, def := range splitTopLevel(m[2]) {
+					if def != "" && !tableConstrain.MatchString(def) {
+						columns = append(columns, strings.Fields(def)[0])
+					}
+				}
+				tables[m[1]] = columns
+			} else if m := alterTable.FindStringSubmatch(stmt); m != nil {
+				for _, action := range splitTopLevel(m[2]) {
+					columns := tables[m[1]]
+					if c := addColumn.FindStringSubmatch(action); c != nil && !slices.Contains(columns, c[1]) {
+						tables[m[1]] = append(columns, c[1])
+					} else if c := dropColumn.FindStringSubmatch(action); c != nil {
+						tables[m[1]] = slices.DeleteFunc(columns, func(name string) bool { return name == c[1] })
+					} else if c := renameColumn.FindStringSubmatch(action); c != nil {
+						if i := slices.Index(columns, c[1]); i >= 0 {
+							columns[i] = c[2]
+						}
+					}
+				}
+			} else if m := dropTable.FindStringSubmatch(stmt); m != nil {
+				delete(tables, m[1])
+			}
+		}
+	}
+	for table, columns := range tables {
+		slices.Sort(columns)
+		tables[table] = columns
+	}
+	return tables
+}
+
+// splitTopLevel splits s at the commas outside parentheses
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// readMigrations returns the version and content of the migrations in fsys
+// matching pattern, in version order
+func readMigrations(t *testing.T, fsys fs.FS, pattern string) ([]int, []string) {
+	t.Helper()
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions := make([]int, len(names))
+	scripts := make([]string, len(names))
+	for i, name := range names {
+		if versions[i], err = migrationVersion(name); err != nil {
+			t.Fatal(err)
+		}
+		script, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scripts[i] = string(script)
+	}
+	return versions, scripts
+}
+
+func TestSQLiteMigrationsMatchPostgres(t *testing.T) {
+	pgVersions, pgScripts := readMigrations(t, os.DirFS(filepath.Join("..", "db", "migrations")), "*.up.sql")
+	sqliteVersions, sqliteScripts := readMigrations(t, sqliteMigrations, "sqlite/*.up.sql")
+
+	// Every migration after the first SQLite one has a counterpart
+	first := slices.Index(pgVersions, sqliteVersions[0])
+	if first < 0 || !slices.Equal(pgVersions[first:], sqliteVersions) {
+		t.Fatalf("SQLite migrations %v, want one for each of %v", sqliteVersions, pgVersions[max(first, 0):])
+	}
+
+	pg := schemaColumns(t, pgScripts)
+	sqlite := schemaColumns(t, sqliteScripts)
+	for _, table := range sqliteOnlyTables {
+		delete(sqlite, table)
+	}
+	for table, columns := range pg {
+		if got := sqlite[table]; !slices.Equal(got, columns) {
+			t.Errorf("SQLite table %s has columns %v, want %v", table, got, columns)
+		}
+	}
+	for table := range sqlite {
+		if _, ok := pg[table]; !ok {
+			t.Errorf("SQLite table %s is not in the PostgreSQL schema", table)
+		}
+	}
+}
+
+func TestOpenSQLiteMigratesOnce(t *testing.T) {
+	versions, _ := readMigrations(t, sqliteMigrations, "sqlite/*.up.sql")
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	for i := 0; i < 2; i++ {
+		db, err := Open(Config{Driver: SQLite, DSN: path})
+		if err != nil {
+			t.Fatalf("opening the database a %s time: %v", []string{"first", "second"}[i], err)
+		}
+		var recorded []int
+		err = db.Raw("SELECT version FROM schema_version ORDER BY version").Scan(&recorded).Error
+		Close(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(recorded) != fmt.Sprint(versions) {
+			t.Fatalf("recorded versions %v, want %v", recorded, versions)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+-- Create idempotency keys table
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key VARCHAR(320) PRIMARY KEY,
+    fingerprint VARCHAR(64) NOT NULL,
+    completed BOOLEAN NOT NULL DEFAULT FALSE,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    content_type VARCHAR(255),
+    body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
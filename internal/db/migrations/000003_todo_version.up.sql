@@ -0,0 +1,2 @@
+-- Add optimistic concurrency version to todos
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,13 @@
+-- Track the order in which todos change for the sync change feed
+CREATE SEQUENCE IF NOT EXISTS todo_change_seq;
+
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS change_seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS created_seq BIGINT NOT NULL DEFAULT 0;
+
+-- Number existing todos so that a first sync picks them up
+UPDATE todos SET change_seq = nextval('todo_change_seq') WHERE change_seq = 0;
+UPDATE todos SET created_seq = change_seq WHERE created_seq = 0;
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_todos_deleted_at ON todos(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_todos_user_id_change_seq ON todos(user_id, change_seq);
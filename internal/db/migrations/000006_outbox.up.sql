@@ -0,0 +1,22 @@
+-- Create outbox table for domain events written alongside entity changes
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id VARCHAR(64) NOT NULL UNIQUE,
+    type VARCHAR(64) NOT NULL,
+    user_id INTEGER NOT NULL,
+    payload TEXT NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    available_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_outbox_due ON outbox(available_at, id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_outbox_processed_at ON outbox(processed_at) WHERE status = 'processed';
+
+-- Webhook deliveries are created once per event, however often it is dispatched
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_event ON webhook_deliveries(webhook_id, event_id);
@@ -0,0 +1,11 @@
+-- Add due dates and recurrence to todos
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS due_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS recurrence VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS recurrence_start TIMESTAMP WITH TIME ZONE;
+
+-- Add the time zone recurring todos are scheduled in to users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_todos_due_at ON todos(user_id, due_at) WHERE deleted_at IS NULL;
@@ -0,0 +1,5 @@
+-- Add the hash of the calendar feed token to users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS calendar_token_hash VARCHAR(64);
+
+-- Create indexes
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_calendar_token_hash ON users(calendar_token_hash);
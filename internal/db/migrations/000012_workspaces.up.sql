@@ -0,0 +1,48 @@
+-- Create workspaces table
+CREATE TABLE IF NOT EXISTS workspaces (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_by INTEGER NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create workspace members table
+CREATE TABLE IF NOT EXISTS workspace_members (
+    workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'member',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+-- Give every existing user a personal workspace they own
+INSERT INTO workspaces (name, created_by)
+SELECT u.username || '''s workspace', u.id
+FROM users u
+WHERE NOT EXISTS (SELECT 1 FROM workspaces w WHERE w.created_by = u.id);
+
+INSERT INTO workspace_members (workspace_id, user_id, role)
+SELECT w.id, w.created_by, 'owner'
+FROM workspaces w
+ON CONFLICT DO NOTHING;
+
+-- Move existing todos into their owner's personal workspace
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS workspace_id INTEGER REFERENCES workspaces(id) ON DELETE CASCADE;
+
+UPDATE todos t
+SET workspace_id = (SELECT MIN(w.id) FROM workspaces w WHERE w.created_by = t.user_id)
+WHERE t.workspace_id IS NULL;
+
+ALTER TABLE todos ALTER COLUMN workspace_id SET NOT NULL;
+
+-- Keep calendar feeds on the workspace they were issued in
+ALTER TABLE users ADD COLUMN IF NOT EXISTS calendar_workspace_id INTEGER REFERENCES workspaces(id) ON DELETE SET NULL;
+
+UPDATE users u
+SET calendar_workspace_id = (SELECT MIN(w.id) FROM workspaces w WHERE w.created_by = u.id)
+WHERE u.calendar_token_hash IS NOT NULL AND u.calendar_workspace_id IS NULL;
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_todos_workspace_id ON todos(workspace_id, user_id);
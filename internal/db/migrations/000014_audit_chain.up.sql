@@ -0,0 +1,30 @@
+-- Chain audit entries in a separate append-only table written by a single
+-- sequencer, so that recording an entry does not serialize the transactions
+-- of every audited action. Entries are content-hashed when recorded and
+-- queued in audit_pending until the sequencer links them.
+CREATE TABLE IF NOT EXISTS audit_chain (
+    seq BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL UNIQUE REFERENCES audit_log(id),
+    prev_hash VARCHAR(64) NOT NULL,
+    hash VARCHAR(64) NOT NULL
+);
+
+DROP TRIGGER IF EXISTS audit_chain_append_only ON audit_chain;
+CREATE TRIGGER audit_chain_append_only
+    BEFORE UPDATE OR DELETE ON audit_chain
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+DROP TRIGGER IF EXISTS audit_chain_no_truncate ON audit_chain;
+CREATE TRIGGER audit_chain_no_truncate
+    BEFORE TRUNCATE ON audit_chain
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TABLE IF NOT EXISTS audit_pending (
+    entry_id BIGINT PRIMARY KEY REFERENCES audit_log(id)
+);
+
+-- Entries recorded before the sequencer are chained in the order of their IDs
+INSERT INTO audit_pending (entry_id)
+SELECT id FROM audit_log
+WHERE id NOT IN (SELECT entry_id FROM audit_chain)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,295 @@
+// Package events provides an in-process publish/subscribe bus for pushing
+// domain events to connected clients.
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultReplaySize is the number of recent events kept per user for
+	// resuming a stream
+	DefaultReplaySize = 256
+
+	// DefaultReplayTTL is how long a user's replay buffer is kept after
+	// their last event
+	DefaultReplayTTL = 10 * time.Minute
+
+	// subscriberBuffer is the number of undelivered events a subscriber may
+	// fall behind by before it is disconnected
+	subscriberBuffer = 64
+)
+
+// Event types published for todos
+const (
+	TodoCreated = "todo.created"
+	TodoUpdated = "todo.updated"
+	TodoDeleted = "todo.deleted"
+
+	// TodoCompleted is published in addition to TodoUpdated when an update
+	// marks a todo as completed
+	TodoCompleted = "todo.completed"
+)
+
+// Event types published for comments on todos
+const (
+	CommentCreated = "comment.created"
+	CommentUpdated = "comment.updated"
+	CommentDeleted = "comment.deleted"
+)
+
+// Event types published for users
+const (
+	UserRegistered = "user.registered"
+)
+
+// Event is a change delivered to a user's subscribers. ID is unique within
+// a Bus and increases with every event.
+type Event struct {
+	ID     string      `json:"id,omitempty"`
+	Type   string      `json:"type"`
+	UserID uint        `json:"-"`
+	Data   interface{} `json:"data"`
+	Time   time.Time   `json:"time"`
+
+	seq uint64
+}
+
+// Bus is an in-process event bus that fans events out to the subscribers
+// of the user they belong to and keeps a bounded replay buffer per user.
+// Buffers of users without events for the replay TTL are evicted, so that
+// memory does not grow with every user who ever had an event.
+type Bus struct {
+	mu          sync.Mutex
+	epoch       string
+	seq         uint64
+	replaySize  int
+	replayTTL   time.Duration
+	replay      map[uint]*replayBuffer
+	subscribers map[uint]map[*Subscription]struct{}
+	// evicted is the sequence number of the latest event evicted with a
+	// buffer; events up to it may be missing for users without one
+	evicted   uint64
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// replayBuffer holds a user's recent events
+type replayBuffer struct {
+	events []Event
+	// dropped is the sequence number of the latest event dropped from
+	// events, which a stream cannot resume before
+	dropped uint64
+	// lastPublished is when the latest event was added
+	lastPublished time.Time
+}
+
+// NewBus creates a new Bus keeping replaySize events per user for resuming,
+// for replayTTL after the user's latest event
+func NewBus(replaySize int, replayTTL time.Duration) *Bus {
+	if replaySize <= 0 {
+		replaySize = DefaultReplaySize
+	}
+	if replayTTL <= 0 {
+		replayTTL = DefaultReplayTTL
+	}
+	return &Bus{
+		// The epoch makes IDs from a previous process unrecognisable, so a
+		// client resuming across a restart is told to resynchronise
+		epoch:       newEpoch(time.Now()),
+		replaySize:  replaySize,
+		replayTTL:   replayTTL,
+		replay:      make(map[uint]*replayBuffer),
+		subscribers: make(map[uint]map[*Subscription]struct{}),
+		now:         time.Now,
+	}
+}
+
+// Publish implements Publisher. Subscribers that cannot keep up are
+// disconnected rather than blocking the publisher.
+func (b *Bus) Publish(userID uint, eventType string, data interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.sweep(now)
+
+	b.seq++
+	event := Event{
+		ID:     fmt.Sprintf("%s-%d", b.epoch, b.seq),
+		Type:   eventType,
+		UserID: userID,
+		Data:   data,
+		Time:   now,
+		seq:    b.seq,
+	}
+
+	buffer := b.replay[userID]
+	if buffer == nil {
+		// Events of the user may have been evicted before
+		buffer = &replayBuffer{dropped: b.evicted}
+		b.replay[userID] = buffer
+	}
+	buffer.events = append(buffer.events, event)
+	if len(buffer.events) > b.replaySize {
+		buffer.dropped = buffer.events[len(buffer.events)-b.replaySize-1].seq
+		buffer.events = buffer.events[len(buffer.events)-b.replaySize:]
+	}
+	buffer.lastPublished = now
+
+	for sub := range b.subscribers[userID] {
+		select {
+		case sub.events <- event:
+		default:
+			b.remove(sub)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for a user's events. If lastEventID is
+// set, buffered events published after it are returned for replay; resumed
+// is false when lastEventID is unknown or has already left the buffer, in
+// which case the client has missed events and must resynchronise.
+func (b *Bus) Subscribe(userID uint, lastEventID string) (sub *Subscription, replay []Event, resumed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{
+		bus:    b,
+		userID: userID,
+		events: make(chan Event, subscriberBuffer),
+		done:   make(chan struct{}),
+	}
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	b.subscribers[userID][sub] = struct{}{}
+
+	if lastEventID == "" {
+		return sub, nil, true
+	}
+
+	// Resuming is only possible if no event after lastEventID has been
+	// dropped from the user's buffer or evicted with it
+	seq, ok := b.parseID(lastEventID)
+	if !ok {
+		return sub, nil, false
+	}
+	buffer := b.replay[userID]
+	if buffer == nil {
+		return sub, nil, seq >= b.evicted
+	}
+	if seq < buffer.dropped {
+		return sub, nil, false
+	}
+	for _, event := range buffer.events {
+		if event.seq > seq {
+			replay = append(replay, event)
+		}
+	}
+	return sub, replay, true
+}
+
+// sweep evicts the buffers of users without events for the replay TTL, at
+// most once per tenth of it; the caller must hold b.mu
+func (b *Bus) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < b.replayTTL/10 {
+		return
+	}
+	b.lastSweep = now
+	for userID, buffer := range b.replay {
+		if now.Sub(buffer.lastPublished) >= b.replayTTL {
+			b.evicted = max(b.evicted, buffer.events[len(buffer.events)-1].seq)
+			delete(b.replay, userID)
+		}
+	}
+}
+
+// Reset forgets every buffered event and ends every subscription, for when
+// events may have been missed. Clients reconnecting with an earlier event ID
+// are told to resynchronise.
+func (b *Bus) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.epoch = newEpoch(b.now())
+	b.replay = make(map[uint]*replayBuffer)
+	b.evicted = 0
+	for _, subs := range b.subscribers {
+		for sub := range subs {
+			b.remove(sub)
+		}
+	}
+}
+
+// Close ends every subscription, letting open streams finish during a
+// graceful shutdown
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, subs := range b.subscribers {
+		for sub := range subs {
+			b.remove(sub)
+		}
+	}
+}
+
+// newEpoch returns an epoch for the event IDs issued from now on
+func newEpoch(now time.Time) string {
+	return strconv.FormatInt(now.UnixNano(), 36)
+}
+
+// parseID extracts the sequence number from an event ID issued by this bus
+func (b *Bus) parseID(id string) (uint64, bool) {
+	epoch, seqStr, ok := strings.Cut(id, "-")
+	if !ok || epoch != b.epoch {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil || seq > b.seq {
+		return 0, false
+	}
+	return seq, true
+}
+
+// remove unregisters a subscriber; the caller must hold b.mu
+func (b *Bus) remove(sub *Subscription) {
+	subs := b.subscribers[sub.userID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subscribers, sub.userID)
+	}
+	close(sub.done)
+}
+
+// Subscription receives a user's events until it is closed
+type Subscription struct {
+	bus    *Bus
+	userID uint
+	events chan Event
+	done   chan struct{}
+}
+
+// Events returns the channel events are delivered on
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Done is closed when the subscription ends, either because Close was
+// called or because the subscriber fell too far behind
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close unsubscribes; it is safe to call more than once
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s)
+}
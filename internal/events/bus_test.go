@@ -0,0 +1,127 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+// testBus returns a Bus whose clock is advanced by the returned function
+func testBus(replaySize int, replayTTL time.Duration) (*Bus, func(time.Duration)) {
+	b := NewBus(replaySize, replayTTL)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }
+}
+
+func lastID(t *testing.T, b *Bus, userID uint) string {
+	t.Helper()
+	buffer := b.replay[userID]
+	if buffer == nil || len(buffer.events) == 0 {
+		t.Fatalf("no buffered events for user %d", userID)
+	}
+	return buffer.events[len(buffer.events)-1].ID
+}
+
+func TestBusResumesFromBuffer(t *testing.T) {
+	b, _ := testBus(2, time.Minute)
+
+	b.Publish(1, TodoCreated, nil)
+	id := lastID(t, b, 1)
+	b.Publish(1, TodoUpdated, nil)
+
+	sub, replay, resumed := b.Subscribe(1, id)
+	defer sub.Close()
+	if !resumed || len(replay) != 1 || replay[0].Type != TodoUpdated {
+		t.Fatalf("Subscribe = %v, %v; want the update replayed", replay, resumed)
+	}
+
+	// A third event drops the first, so resuming from it misses the second
+	b.Publish(1, TodoDeleted, nil)
+	b.Publish(1, TodoDeleted, nil)
+	if _, _, resumed := b.Subscribe(1, id); resumed {
+		t.Fatal("resumed past a dropped event")
+	}
+}
+
+func TestBusEvictsIdleBuffers(t *testing.T) {
+	b, advance := testBus(8, time.Minute)
+
+	b.Publish(1, TodoCreated, nil)
+	first := lastID(t, b, 1)
+	b.Publish(1, TodoUpdated, nil)
+	b.Publish(2, TodoCreated, nil)
+	other := lastID(t, b, 2)
+
+	advance(30 * time.Second)
+	b.Publish(2, TodoUpdated, nil)
+	advance(45 * time.Second)
+	b.Publish(3, TodoCreated, nil)
+
+	if _, ok := b.replay[1]; ok {
+		t.Fatal("idle buffer of user 1 was kept")
+	}
+	if _, ok := b.replay[2]; !ok {
+		t.Fatal("buffer of user 2 was evicted before its TTL")
+	}
+
+	// User 1 missed an event that was evicted and must resynchronise
+	if _, _, resumed := b.Subscribe(1, first); resumed {
+		t.Fatal("resumed past an evicted event")
+	}
+	// User 2 still resumes from the buffer
+	if _, replay, resumed := b.Subscribe(2, other); !resumed || len(replay) != 1 {
+		t.Fatalf("Subscribe(2) = %v, %v; want one event replayed", replay, resumed)
+	}
+
+	// Once user 1 has events again, resuming from before the eviction still
+	// fails, while resuming from the new events works
+	b.Publish(1, TodoCreated, nil)
+	latest := lastID(t, b, 1)
+	b.Publish(1, TodoUpdated, nil)
+	if _, _, resumed := b.Subscribe(1, first); resumed {
+		t.Fatal("resumed past an evicted event after new events")
+	}
+	if _, replay, resumed := b.Subscribe(1, latest); !resumed || len(replay) != 1 {
+		t.Fatalf("Subscribe(1) = %v, %v; want one event replayed", replay, resumed)
+	}
+}
+
+func TestBusResumesWithoutBufferWhenNothingWasEvicted(t *testing.T) {
+	b, advance := testBus(8, time.Minute)
+
+	b.Publish(1, TodoCreated, nil)
+	id := lastID(t, b, 1)
+	advance(2 * time.Minute)
+	b.Publish(2, TodoCreated, nil)
+
+	// User 1's buffer is gone, but its last event is the one the client saw
+	if _, replay, resumed := b.Subscribe(1, id); !resumed || len(replay) != 0 {
+		t.Fatalf("Subscribe = %v, %v; want resumed with nothing to replay", replay, resumed)
+	}
+}
+
+func TestBusResetMakesClientsResynchronise(t *testing.T) {
+	b, advance := testBus(8, time.Minute)
+
+	b.Publish(1, TodoCreated, nil)
+	id := lastID(t, b, 1)
+	sub, _, _ := b.Subscribe(1, "")
+
+	advance(time.Millisecond)
+	b.Reset()
+	select {
+	case <-sub.Done():
+	default:
+		t.Fatal("subscription survived the reset")
+	}
+	if _, _, resumed := b.Subscribe(1, id); resumed {
+		t.Fatal("resumed from an event issued before the reset")
+	}
+
+	b.Publish(1, TodoCreated, nil)
+	latest := lastID(t, b, 1)
+	b.Publish(1, TodoUpdated, nil)
+	if _, replay, resumed := b.Subscribe(1, latest); !resumed || len(replay) != 1 {
+		t.Fatalf("Subscribe = %v, %v; want one event replayed", replay, resumed)
+	}
+}
@@ -0,0 +1,35 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DomainEvent records a change to an entity. Services write domain events to
+// the outbox in the same transaction as the change, and the outbox
+// dispatcher delivers them to in-process handlers once committed.
+type DomainEvent struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	UserID     uint            `json:"user_id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// NewDomainEvent creates a DomainEvent with a new ID and data encoded as
+// its JSON payload
+func NewDomainEvent(eventType string, userID uint, data interface{}) (DomainEvent, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return DomainEvent{}, err
+	}
+	return DomainEvent{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		UserID:     userID,
+		OccurredAt: time.Now(),
+		Payload:    payload,
+	}, nil
+}
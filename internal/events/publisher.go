@@ -0,0 +1,6 @@
+package events
+
+// Publisher publishes events for a user
+type Publisher interface {
+	Publish(userID uint, eventType string, data interface{})
+}
@@ -0,0 +1,89 @@
+// Package feature evaluates feature flags. A flag is rolled out to a
+// percentage of users and to listed users, and can be switched off entirely.
+// Flags are replaced as a whole when the configuration is reloaded.
+package feature
+
+import (
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"sync/atomic"
+)
+
+// Flag is the rollout of one feature
+type Flag struct {
+	// Enabled switches the feature on; a disabled flag is off for everyone,
+	// including listed users
+	Enabled bool `json:"enabled"`
+	// Percentage is the share of users, from 0 to 100, the feature is on for.
+	// Users are assigned by a stable hash, so raising the percentage only
+	// adds users.
+	Percentage int `json:"percentage"`
+	// Users are the IDs of users the feature is always on for
+	Users []uint `json:"users"`
+}
+
+// On reports whether the flag is on for a user. Anonymous callers, with
+// user ID 0, only see fully rolled out features.
+func (f Flag) On(name string, userID uint) bool {
+	switch {
+	case !f.Enabled:
+		return false
+	case f.Percentage >= 100:
+		return true
+	case userID == 0:
+		return false
+	case slices.Contains(f.Users, userID):
+		return true
+	default:
+		return bucket(name, userID) < f.Percentage
+	}
+}
+
+// bucket assigns a user to one of 100 buckets. The flag name is part of the
+// hash so that each flag reaches a different subset of users first.
+func bucket(name string, userID uint) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatUint(uint64(userID), 10)))
+	return int(h.Sum32() % 100)
+}
+
+// Flags holds the current set of flags. It is safe for concurrent use.
+type Flags struct {
+	flags atomic.Pointer[map[string]Flag]
+}
+
+// New creates a Flags holding flags
+func New(flags map[string]Flag) *Flags {
+	f := &Flags{}
+	f.Set(flags)
+	return f
+}
+
+// Set replaces all flags
+func (f *Flags) Set(flags map[string]Flag) {
+	copied := make(map[string]Flag, len(flags))
+	for name, flag := range flags {
+		copied[name] = flag
+	}
+	f.flags.Store(&copied)
+}
+
+// Enabled reports whether a feature is on for a user. Unknown features are
+// off.
+func (f *Flags) Enabled(name string, userID uint) bool {
+	flag, ok := (*f.flags.Load())[name]
+	return ok && flag.On(name, userID)
+}
+
+// For evaluates every flag for a user
+func (f *Flags) For(userID uint) map[string]bool {
+	flags := *f.flags.Load()
+	out := make(map[string]bool, len(flags))
+	for name, flag := range flags {
+		out[name] = flag.On(name, userID)
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package feature
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFlagOn(t *testing.T) {
+	tests := []struct {
+		name   string
+		flag   Flag
+		userID uint
+		want   bool
+	}{
+		{"disabled", Flag{Percentage: 100, Users: []uint{1}}, 1, false},
+		{"fully rolled out", Flag{Enabled: true, Percentage: 100}, 1, true},
+		{"fully rolled out to anonymous", Flag{Enabled: true, Percentage: 100}, 0, true},
+		{"partly rolled out to anonymous", Flag{Enabled: true, Percentage: 99}, 0, false},
+		{"listed user", Flag{Enabled: true, Users: []uint{1, 2}}, 2, true},
+		{"unlisted user", Flag{Enabled: true, Users: []uint{1, 2}}, 3, false},
+		{"no rollout", Flag{Enabled: true}, 1, false},
+	}
+	for _, tt := range tests {
+		if got := tt.flag.On("beta", tt.userID); got != tt.want {
+			t.Errorf("%s: On(beta, %d) = %v, want %v", tt.name, tt.userID, got, tt.want)
+		}
+	}
+}
+
+func TestFlagPercentageRollout(t *testing.T) {
+	const users = 10000
+	previous := make(map[uint]bool)
+	for _, percentage := range []int{0, 1, 10, 25, 50, 90, 100} {
+		flag := Flag{Enabled: true, Percentage: percentage}
+		on := 0
+		for id := uint(1); id <= users; id++ {
+			got := flag.On("beta", id)
+			if got != flag.On("beta", id) {
+				t.Fatalf("On(beta, %d) at %d%% changed between calls", id, percentage)
+			}
+			// Raising the percentage only adds users
+			if previous[id] && !got {
+				t.Fatalf("user %d lost the feature when the rollout was raised to %d%%", id, percentage)
+			}
+			previous[id] = got
+			if got {
+				on++
+			}
+		}
+		if want := users * percentage / 100; on < want-users/50 || on > want+users/50 {
+			t.Errorf("%d%% rollout reached %d of %d users, want about %d", percentage, on, users, want)
+		}
+	}
+}
+
+func TestFlagRolloutDiffersByName(t *testing.T) {
+	flag := Flag{Enabled: true, Percentage: 50}
+	same := 0
+	for id := uint(1); id <= 1000; id++ {
+		if flag.On("beta", id) == flag.On("search", id) {
+			same++
+		}
+	}
+	if same == 1000 {
+		t.Fatal("two flags at the same percentage reach the same users")
+	}
+}
+
+func TestFlags(t *testing.T) {
+	input := map[string]Flag{"beta": {Enabled: true, Users: []uint{1}}}
+	flags := New(input)
+
+	// The flags are copied
+	input["beta"] = Flag{}
+	if !flags.Enabled("beta", 1) {
+		t.Fatal("Enabled(beta, 1) = false, want true")
+	}
+	if flags.Enabled("unknown", 1) {
+		t.Fatal("an unknown feature is enabled")
+	}
+
+	flags.Set(map[string]Flag{"search": {Enabled: true, Percentage: 100}})
+	got := fmt.Sprint(flags.For(1))
+	if want := "map[search:true]"; got != want {
+		t.Fatalf("For(1) = %s after Set, want %s", got, want)
+	}
+}
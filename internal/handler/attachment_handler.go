@@ -0,0 +1,317 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"myapp/internal/middleware"
+	"myapp/internal/model"
+	"myapp/internal/service"
+	"myapp/internal/storage"
+)
+
+// multipartOverhead is the room left in upload request bodies for multipart
+// boundaries, part headers and small form fields besides the file
+const multipartOverhead = 64 << 10
+
+// AttachmentHandler handles HTTP requests for todo attachment operations
+type AttachmentHandler struct {
+	attachmentService service.AttachmentService
+	maxUploadSize     int64
+}
+
+// NewAttachmentHandler creates a new AttachmentHandler instance accepting
+// files of up to maxUploadSize bytes
+func NewAttachmentHandler(attachmentService service.AttachmentService, maxUploadSize int64) *AttachmentHandler {
+	return &AttachmentHandler{
+		attachmentService: attachmentService,
+		maxUploadSize:     maxUploadSize,
+	}
+}
+
+// LimitBody is a middleware that caps upload request bodies, so that a
+// client cannot stream unbounded form fields around the file
+func (h *AttachmentHandler) LimitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadSize+multipartOverhead)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Upload handles attaching a file to a todo
+// @Summary Upload an attachment
+// @Description Attach a file to a todo. The request is multipart/form-data with the file in a part named "file"; it is streamed to storage without being buffered. The content type is detected from the file itself and must be on the allow list.
+// @Tags attachments
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param file formData file true "File to attach"
+// @Success 201 {object} response.Response{data=model.Attachment}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 413 {object} response.Response
+// @Failure 415 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/{id}/attachments [post]
+func (h *AttachmentHandler) Upload(r *http.Request) (*model.Attachment, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	todoID, err := todoIDParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, NewAPIError(http.StatusBadRequest, "INVALID_MULTIPART", "Request must be multipart/form-data")
+	}
+
+	// Parts other than the file are skipped; the file part is streamed to
+	// the service as it arrives
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, NewAPIError(http.StatusBadRequest, "MISSING_FILE", `Request must have a part named "file"`)
+		}
+		if err != nil {
+			return nil, uploadError(err)
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		if part.FileName() == "" {
+			return nil, NewAPIError(http.StatusBadRequest, "MISSING_FILENAME", `The "file" part must have a filename`)
+		}
+
+		body := &uploadReader{r: part}
+		attachment, err := h.attachmentService.Upload(r.Context(), userID, todoID, part.FileName(), body)
+		if err != nil {
+			// A failure to read the upload is the client's, not the storage's
+			if body.err != nil {
+				return nil, uploadError(body.err)
+			}
+			return nil, err
+		}
+		return attachment, nil
+	}
+}
+
+// List handles listing the attachments of a todo
+// @Summary List attachments
+// @Description Get the attachments of a todo owned by the authenticated user
+// @Tags attachments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Success 200 {object} response.Response{data=[]model.Attachment}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/{id}/attachments [get]
+func (h *AttachmentHandler) List(r *http.Request) ([]*model.Attachment, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	todoID, err := todoIDParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.attachmentService.List(r.Context(), userID, todoID)
+}
+
+// Download handles downloading an attachment
+// @Summary Download an attachment
+// @Description Download the contents of an attachment. Range and conditional requests are supported; the ETag is the file's SHA-256.
+// @Tags attachments
+// @Produce octet-stream
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param attachmentID path int true "Attachment ID"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Success 200 {file} binary
+// @Success 206 {file} binary
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 416 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/{id}/attachments/{attachmentID} [get]
+func (h *AttachmentHandler) Download(r *http.Request) (Renderer, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	todoID, attachmentID, err := attachmentParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment, object, err := h.attachmentService.Open(r.Context(), userID, todoID, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	return attachmentContent{attachment: attachment, object: object}, nil
+}
+
+// Delete handles deleting an attachment
+// @Summary Delete an attachment
+// @Description Delete an attachment and its contents
+// @Tags attachments
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param attachmentID path int true "Attachment ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/{id}/attachments/{attachmentID} [delete]
+func (h *AttachmentHandler) Delete(r *http.Request) (struct{}, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return struct{}{}, errUnauthorized
+	}
+
+	todoID, attachmentID, err := attachmentParams(r)
+	if err != nil {
+		return struct{}{}, err
+	}
+
+	return struct{}{}, h.attachmentService.Delete(r.Context(), userID, todoID, attachmentID)
+}
+
+// SignedURL handles creating a short-lived download link
+// @Summary Create a download link
+// @Description Create a link that downloads the attachment without authentication until it expires, for use in <img> tags or by other clients
+// @Tags attachments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param attachmentID path int true "Attachment ID"
+// @Success 200 {object} response.Response{data=model.AttachmentURL}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/{id}/attachments/{attachmentID}/url [post]
+func (h *AttachmentHandler) SignedURL(r *http.Request) (*model.AttachmentURL, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	todoID, attachmentID, err := attachmentParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.attachmentService.SignedURL(r.Context(), userID, todoID, attachmentID)
+}
+
+// DownloadSigned handles downloading an attachment through a signed link
+// @Summary Download through a signed link
+// @Description Download an attachment with a link created by POST /todos/{id}/attachments/{attachmentID}/url. No authentication is needed. Range and conditional requests are supported.
+// @Tags attachments
+// @Produce octet-stream
+// @Param id path int true "Attachment ID"
+// @Param expires query int true "Expiry as a Unix timestamp"
+// @Param signature query string true "Link signature"
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Success 200 {file} binary
+// @Success 206 {file} binary
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /files/{id} [get]
+func (h *AttachmentHandler) DownloadSigned(r *http.Request) (Renderer, error) {
+	attachmentID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return nil, NewAPIError(http.StatusBadRequest, "INVALID_ID", "Invalid attachment ID")
+	}
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		return nil, model.ErrInvalidSignature
+	}
+
+	attachment, object, err := h.attachmentService.OpenSigned(r.Context(), uint(attachmentID), expires, r.URL.Query().Get("signature"))
+	if err != nil {
+		return nil, err
+	}
+	return attachmentContent{attachment: attachment, object: object}, nil
+}
+
+// attachmentContent is a handler result that serves an attachment's
+// contents, answering Range and conditional requests
+type attachmentContent struct {
+	attachment *model.Attachment
+	object     *storage.Object
+}
+
+// Render implements Renderer. The status is chosen by http.ServeContent.
+func (c attachmentContent) Render(w http.ResponseWriter, r *http.Request, status int) {
+	defer c.object.Close()
+
+	header := w.Header()
+	header.Set("Content-Type", c.attachment.ContentType)
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": c.attachment.Filename}))
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Cache-Control", "private")
+	header.Set("ETag", fmt.Sprintf(`"%s"`, c.attachment.SHA256))
+
+	http.ServeContent(w, r, "", c.attachment.CreatedAt, c.object)
+}
+
+// uploadReader records the error that ended reading an upload, so that a
+// truncated or malformed request body can be told apart from a failure to
+// store it
+type uploadReader struct {
+	r   io.Reader
+	err error
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	if err != nil && err != io.EOF {
+		u.err = err
+	}
+	return n, err
+}
+
+// uploadError maps a failure to read an upload request body to its API error
+func uploadError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return model.ErrAttachmentTooLarge
+	}
+	return NewAPIError(http.StatusBadRequest, "INVALID_MULTIPART", "Failed to read multipart request body")
+}
+
+// attachmentParams parses the {id} and {attachmentID} URL parameters
+func attachmentParams(r *http.Request) (uint, uint, error) {
+	todoID, err := todoIDParam(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	attachmentID, err := strconv.ParseUint(chi.URLParam(r, "attachmentID"), 10, 64)
+	if err != nil {
+		return 0, 0, NewAPIError(http.StatusBadRequest, "INVALID_ID", "Invalid attachment ID")
+	}
+	return todoID, uint(attachmentID), nil
+}
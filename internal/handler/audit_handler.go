@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"myapp/internal/middleware"
+	"myapp/internal/model"
+	"myapp/internal/service"
+)
+
+// AuditHandler handles HTTP requests for reading the audit log
+type AuditHandler struct {
+	auditService service.AuditService
+}
+
+// NewAuditHandler creates a new AuditHandler instance
+func NewAuditHandler(auditService service.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// List handles searching the audit log
+// @Summary Search the audit log
+// @Description Get audit entries matching the filters, newest first. Requires the admin role.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param actor_id query int false "ID of the user who performed the action"
+// @Param action query string false "Action, e.g. auth.login_failed or todo.updated"
+// @Param resource_type query string false "Resource type, e.g. user or todo"
+// @Param resource_id query string false "Resource ID"
+// @Param from query string false "Earliest time, RFC 3339"
+// @Param to query string false "Latest time (exclusive), RFC 3339"
+// @Param before query int false "Cursor from next_before of the previous page"
+// @Param limit query int false "Maximum number of entries (default 50, max 200)"
+// @Success 200 {object} response.Response{data=model.AuditPage}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/audit [get]
+func (h *AuditHandler) List(r *http.Request) (*model.AuditPage, error) {
+	query := r.URL.Query()
+	filter := model.AuditFilter{
+		Action:       query.Get("action"),
+		ResourceType: query.Get("resource_type"),
+		ResourceID:   query.Get("resource_id"),
+	}
+
+	if v := query.Get("actor_id"); v != "" {
+		actorID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, NewAPIError(http.StatusBadRequest, "INVALID_ACTOR_ID", "actor_id must be a user ID")
+		}
+		id := uint(actorID)
+		filter.ActorID = &id
+	}
+
+	var err error
+	if filter.From, err = timeParam(r, "from"); err != nil {
+		return nil, err
+	}
+	if filter.To, err = timeParam(r, "to"); err != nil {
+		return nil, err
+	}
+	if filter.BeforeID, filter.Limit, err = pageParams(r); err != nil {
+		return nil, err
+	}
+
+	return h.auditService.List(r.Context(), filter)
+}
+
+// Verify handles checking the integrity of the audit log
+// @Summary Verify the audit log
+// @Description Walk the audit log hash chain and report the first entry that was altered, removed or inserted out of order. Requires the admin role.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=model.AuditVerification}
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /admin/audit/verify [get]
+func (h *AuditHandler) Verify(r *http.Request) (*model.AuditVerification, error) {
+	return h.auditService.Verify(r.Context())
+}
+
+// Activity handles listing the authenticated user's own actions
+// @Summary Get my activity
+// @Description Get the audited actions performed by the authenticated user, including failed logins to their account, newest first
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param before query int false "Cursor from next_before of the previous page"
+// @Param limit query int false "Maximum number of entries (default 50, max 200)"
+// @Success 200 {object} response.Response{data=model.AuditPage}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /users/me/activity [get]
+func (h *AuditHandler) Activity(r *http.Request) (*model.AuditPage, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	beforeID, limit, err := pageParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.auditService.Activity(r.Context(), userID, beforeID, limit)
+}
+
+// pageParams parses the before cursor and limit query parameters
+func pageParams(r *http.Request) (uint64, int, error) {
+	var beforeID uint64
+	if v := r.URL.Query().Get("before"); v != "" {
+		var err error
+		if beforeID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return 0, 0, NewAPIError(http.StatusBadRequest, "INVALID_CURSOR", "before must be an entry ID")
+		}
+	}
+	limit, err := limitParam(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	return beforeID, limit, nil
+}
+
+// limitParam parses the optional limit query parameter, returning 0 if it
+// is not set
+func limitParam(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 {
+		return 0, NewAPIError(http.StatusBadRequest, "INVALID_LIMIT", "limit must be a positive integer")
+	}
+	return limit, nil
+}
+
+// timeParam parses an optional RFC 3339 time query parameter
+func timeParam(r *http.Request, name string) (*time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, NewAPIError(http.StatusBadRequest, "INVALID_TIME", name+" must be an RFC 3339 time")
+	}
+	return &t, nil
+}
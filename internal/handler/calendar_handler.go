@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"myapp/internal/ical"
+	"myapp/internal/middleware"
+	"myapp/internal/model"
+	"myapp/internal/service"
+	"myapp/internal/validation"
+)
+
+// maxImportBytes is the largest file accepted by calendar imports
+const maxImportBytes int64 = 8 << 20
+
+// CalendarHandler handles HTTP requests for calendar feeds and imports
+type CalendarHandler struct {
+	calendarService service.CalendarService
+	todoService     service.TodoService
+	validator       validation.Validator
+}
+
+// NewCalendarHandler creates a new CalendarHandler instance
+func NewCalendarHandler(calendarService service.CalendarService, todoService service.TodoService, validator validation.Validator) *CalendarHandler {
+	return &CalendarHandler{
+		calendarService: calendarService,
+		todoService:     todoService,
+		validator:       validator,
+	}
+}
+
+// IssueFeedToken handles creating or rotating the calendar feed link
+// @Summary Create a calendar feed link
+// @Description Create a secret link to an iCalendar feed of the user's todos, for subscribing from calendar apps. Any previous link stops working. The link is only shown once.
+// @Tags calendar
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=model.CalendarFeed}
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /users/me/calendar-token [post]
+func (h *CalendarHandler) IssueFeedToken(r *http.Request) (*model.CalendarFeed, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	return h.calendarService.IssueFeedToken(r.Context(), userID)
+}
+
+// RevokeFeedToken handles disabling the calendar feed link
+// @Summary Revoke the calendar feed link
+// @Description Stop the user's calendar feed link from working
+// @Tags calendar
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /users/me/calendar-token [delete]
+func (h *CalendarHandler) RevokeFeedToken(r *http.Request) (struct{}, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return struct{}{}, errUnauthorized
+	}
+
+	return struct{}{}, h.calendarService.RevokeFeedToken(r.Context(), userID)
+}
+
+// Feed handles serving a calendar feed
+// @Summary Get a calendar feed
+// @Description Get the user's todos as iCalendar VTODO entries with their due dates and completion status. No authentication is needed; the token in the link identifies the user.
+// @Tags calendar
+// @Produce text/calendar
+// @Param token path string true "Feed token"
+// @Success 200 {file} binary
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /calendar/{token}.ics [get]
+func (h *CalendarHandler) Feed(r *http.Request) (Renderer, error) {
+	todos, err := h.calendarService.Feed(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := ical.EncodeTodos(&buf, "Todos", todos, time.Now()); err != nil {
+		return nil, err
+	}
+	return calendarContent(buf.Bytes()), nil
+}
+
+// ImportICS handles importing todos from an iCalendar file
+// @Summary Import todos from iCalendar
+// @Description Create todos from the VTODO entries of an iCalendar file, sent as the request body (text/calendar) or as a multipart/form-data part named "file". The import is atomic: if any entry is invalid, no todo is created.
+// @Tags todos
+// @Accept text/calendar
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file false "iCalendar file"
+// @Success 201 {object} response.Response{data=model.TodoImportResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 413 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/import/ics [post]
+func (h *CalendarHandler) ImportICS(r *http.Request) (*model.TodoImportResponse, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	data, err := readImport(r)
+	if err != nil {
+		return nil, err
+	}
+
+	todos, err := ical.DecodeTodos(bytes.NewReader(data))
+	if err != nil {
+		var syntaxErr *ical.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, NewAPIError(http.StatusBadRequest, "INVALID_ICS", syntaxErr.Error())
+		}
+		return nil, err
+	}
+
+	req := &model.TodoImportRequest{Todos: todos}
+	if err := h.validator.Validate(r.Context(), req); err != nil {
+		return nil, err
+	}
+
+	return h.todoService.Import(r.Context(), userID, req)
+}
+
+// calendarContent is a handler result that serves an encoded calendar
+type calendarContent []byte
+
+// Render implements Renderer
+func (c calendarContent) Render(w http.ResponseWriter, r *http.Request, status int) {
+	header := w.Header()
+	header.Set("Content-Type", "text/calendar; charset=utf-8")
+	header.Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": "todos.ics"}))
+	header.Set("Content-Length", strconv.Itoa(len(c)))
+	header.Set("Cache-Control", "private, no-cache")
+	w.WriteHeader(status)
+	w.Write(c)
+}
+
+// readImport reads the file of an import request up to maxImportBytes
+func readImport(r *http.Request) ([]byte, error) {
+	body, err := importFile(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxImportBytes+1))
+	if err != nil {
+		return nil, NewAPIError(http.StatusBadRequest, "INVALID_REQUEST", "Failed to read request body")
+	}
+	if int64(len(data)) > maxImportBytes {
+		return nil, NewAPIError(http.StatusRequestEntityTooLarge, "REQUEST_TOO_LARGE",
+			fmt.Sprintf("Imported file must not exceed %d bytes", maxImportBytes))
+	}
+	return data, nil
+}
+
+// importFile returns the file of an import request, which is either the
+// request body or the multipart part named "file"
+func importFile(r *http.Request) (io.Reader, error) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, NewAPIError(http.StatusBadRequest, "INVALID_MULTIPART", "Invalid multipart/form-data request")
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, NewAPIError(http.StatusBadRequest, "MISSING_FILE", `Request must have a part named "file"`)
+		}
+		if err != nil {
+			return nil, NewAPIError(http.StatusBadRequest, "INVALID_MULTIPART", "Failed to read multipart request body")
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"myapp/internal/middleware"
+	"myapp/internal/model"
+	"myapp/internal/service"
+)
+
+// CommentHandler handles HTTP requests for comments on todos and the todo
+// activity timeline
+type CommentHandler struct {
+	commentService service.CommentService
+}
+
+// NewCommentHandler creates a new CommentHandler instance
+func NewCommentHandler(commentService service.CommentService) *CommentHandler {
+	return &CommentHandler{commentService: commentService}
+}
+
+// Create handles commenting on a todo
+// @Summary Comment on a todo
+// @Description Add a comment to a todo. Mentions written as @username are resolved to users; unknown usernames are left as text.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param request body model.CommentRequest true "Comment"
+// @Success 201 {object} response.Response{data=model.Comment}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/{id}/comments [post]
+func (h *CommentHandler) Create(r *http.Request, req *model.CommentRequest) (*model.Comment, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	todoID, err := todoIDParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.commentService.Create(r.Context(), userID, todoID, req)
+}
+
+// List handles listing the comments on a todo
+// @Summary List comments
+// @Description Get the comments on a todo, oldest first
+// @Tags comments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Success 200 {object} response.Response{data=[]model.Comment}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/{id}/comments [get]
+func (h *CommentHandler) List(r *http.Request) ([]*model.Comment, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	todoID, err := todoIDParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.commentService.List(r.Context(), userID, todoID)
+}
+
+// Update handles editing a comment
+// @Summary Edit a comment
+// @Description Replace the body of a comment. Only its author can edit it; mentions are resolved again.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param commentID path int true "Comment ID"
+// @Param request body model.CommentRequest true "Comment"
+// @Success 200 {object} response.Response{data=model.Comment}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/{id}/comments/{commentID} [put]
+func (h *CommentHandler) Update(r *http.Request, req *model.CommentRequest) (*model.Comment, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	todoID, commentID, err := commentParams(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.commentService.Update(r.Context(), userID, todoID, commentID, req)
+}
+
+// Delete handles deleting a comment
+// @Summary Delete a comment
+// @Description Delete a comment. Only its author can delete it.
+// @Tags comments
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param commentID path int true "Comment ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/{id}/comments/{commentID} [delete]
+func (h *CommentHandler) Delete(r *http.Request) (struct{}, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return struct{}{}, errUnauthorized
+	}
+
+	todoID, commentID, err := commentParams(r)
+	if err != nil {
+		return struct{}{}, err
+	}
+
+	return struct{}{}, h.commentService.Delete(r.Context(), userID, todoID, commentID)
+}
+
+// Activity handles reading a todo's activity timeline
+// @Summary Get a todo's activity
+// @Description Get the comments on a todo merged with the changes made to it, newest first
+// @Tags comments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param before query string false "Cursor from next_before of the previous page"
+// @Param limit query int false "Maximum number of items (default 50, max 200)"
+// @Success 200 {object} response.Response{data=model.ActivityPage}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/{id}/activity [get]
+func (h *CommentHandler) Activity(r *http.Request) (*model.ActivityPage, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	todoID, err := todoIDParam(r)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := limitParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.commentService.Activity(r.Context(), userID, todoID, r.URL.Query().Get("before"), limit)
+}
+
+// commentParams parses the {id} and {commentID} URL parameters
+func commentParams(r *http.Request) (uint, uint, error) {
+	todoID, err := todoIDParam(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	commentID, err := strconv.ParseUint(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil {
+		return 0, 0, NewAPIError(http.StatusBadRequest, "INVALID_ID", "Invalid comment ID")
+	}
+	return todoID, uint(commentID), nil
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"myapp/internal/model"
+	"myapp/internal/pkg/response"
+	"myapp/internal/validation"
+)
+
+// APIError is an error carrying the HTTP status and error code it should be
+// rendered with
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// NewAPIError creates a new APIError
+func NewAPIError(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+// errUnauthorized is returned by handlers when no user is in the context
+var errUnauthorized = NewAPIError(http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized")
+
+// domainErrors maps service and model errors to their API representation
+var domainErrors = []struct {
+	err error
+	api *APIError
+}{
+	{model.ErrTodoNotFound, NewAPIError(http.StatusNotFound, "TODO_NOT_FOUND", "Todo not found")},
+	{model.ErrTodoVersionConflict, NewAPIError(http.StatusPreconditionFailed, "PRECONDITION_FAILED", "Todo has been modified since it was retrieved")},
+	{model.ErrInvalidRecurrence, NewAPIError(http.StatusUnprocessableEntity, "INVALID_RECURRENCE", "Recurrence must be a valid RRULE with a due date")},
+	{model.ErrWebhookNotFound, NewAPIError(http.StatusNotFound, "WEBHOOK_NOT_FOUND", "Webhook not found")},
+	{model.ErrInvalidWebhookURL, NewAPIError(http.StatusUnprocessableEntity, "INVALID_WEBHOOK_URL", "Webhook URL must be an http or https URL of a public host")},
+	{model.ErrAttachmentNotFound, NewAPIError(http.StatusNotFound, "ATTACHMENT_NOT_FOUND", "Attachment not found")},
+	{model.ErrAttachmentTooLarge, NewAPIError(http.StatusRequestEntityTooLarge, "ATTACHMENT_TOO_LARGE", "Attachment exceeds the maximum size")},
+	{model.ErrUnsupportedMediaType, NewAPIError(http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", "Attachment type is not allowed")},
+	{model.ErrInvalidSignature, NewAPIError(http.StatusForbidden, "INVALID_SIGNATURE", "Link is invalid or has expired")},
+	{model.ErrCommentNotFound, NewAPIError(http.StatusNotFound, "COMMENT_NOT_FOUND", "Comment not found")},
+	{model.ErrNotCommentAuthor, NewAPIError(http.StatusForbidden, "NOT_COMMENT_AUTHOR", "Only the author can change a comment")},
+	{model.ErrCalendarNotFound, NewAPIError(http.StatusNotFound, "CALENDAR_NOT_FOUND", "Calendar not found")},
+	{model.ErrWorkspaceNotFound, NewAPIError(http.StatusNotFound, "WORKSPACE_NOT_FOUND", "Workspace not found")},
+	{model.ErrNotWorkspaceOwner, NewAPIError(http.StatusForbidden, "NOT_WORKSPACE_OWNER", "Only workspace owners can manage members")},
+	{model.ErrAlreadyWorkspaceMember, NewAPIError(http.StatusConflict, "ALREADY_MEMBER", "User is already a member of this workspace")},
+	{model.ErrLastWorkspaceOwner, NewAPIError(http.StatusConflict, "LAST_OWNER", "A workspace must keep at least one owner")},
+	{model.ErrUserNotFound, NewAPIError(http.StatusNotFound, "USER_NOT_FOUND", "User not found")},
+	{model.ErrInvalidCursor, NewAPIError(http.StatusBadRequest, "INVALID_CURSOR", "Invalid cursor")},
+	{model.ErrRegistrationDisabled, NewAPIError(http.StatusForbidden, "REGISTRATION_DISABLED", "Registration is currently disabled")},
+	{model.ErrEmailAlreadyExists, NewAPIError(http.StatusConflict, "EMAIL_EXISTS", "User with this email already exists")},
+	{model.ErrUsernameAlreadyExists, NewAPIError(http.StatusConflict, "USERNAME_EXISTS", "User with this username already exists")},
+	{model.ErrUserAlreadyExists, NewAPIError(http.StatusConflict, "USER_EXISTS", "User with this email or username already exists")},
+	{model.ErrInvalidCredentials, NewAPIError(http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid email or password")},
+	{model.ErrInvalidPassword, NewAPIError(http.StatusForbidden, "INVALID_PASSWORD", "Current password is incorrect")},
+	{model.ErrUnauthorized, errUnauthorized},
+}
+
+// writeError renders any error returned by a handler through the standard
+// response envelope. Unknown errors become a generic 500.
+func writeError(w http.ResponseWriter, err error) {
+	var (
+		apiErr *APIError
+		errs   validation.ValidationErrors
+	)
+
+	switch {
+	case errors.As(err, &apiErr):
+		response.NewServiceError(apiErr.Status, apiErr.Code, apiErr.Message).Write(w)
+		return
+	case errors.As(err, &errs):
+		response.NewValidationError(errs).Write(w)
+		return
+	}
+
+	for _, de := range domainErrors {
+		if errors.Is(err, de.err) {
+			response.NewServiceError(de.api.Status, de.api.Code, de.api.Message).Write(w)
+			return
+		}
+	}
+
+	response.NewServiceError(http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error").Write(w)
+}
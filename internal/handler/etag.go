@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"myapp/internal/model"
+	"myapp/internal/pkg/response"
+)
+
+// todoETag returns the strong entity tag of a todo, derived from its version
+func todoETag(todo *model.Todo) string {
+	return fmt.Sprintf(`"%d"`, todo.Version)
+}
+
+// contentETag returns a strong entity tag derived from the JSON encoding of
+// data, for representations without a version of their own
+func contentETag(data interface{}) (string, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:18]) + `"`, nil
+}
+
+// etagMatches reports whether etag matches an If-Match or If-None-Match
+// header value. If-Match uses strong comparison; If-None-Match uses weak
+// comparison, which ignores the W/ prefix.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+			etag = strings.TrimPrefix(etag, "W/")
+		} else if strings.HasPrefix(candidate, "W/") || strings.HasPrefix(etag, "W/") {
+			continue
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// taggedResult is a handler result carrying validators: an entity tag and,
+// optionally, a modification time. It sets the ETag and Last-Modified
+// headers and answers conditional GET requests with 304 Not Modified.
+type taggedResult struct {
+	data         interface{}
+	etag         string
+	lastModified time.Time
+}
+
+// Render implements Renderer
+func (t taggedResult) Render(w http.ResponseWriter, r *http.Request, status int) {
+	w.Header().Set("ETag", t.etag)
+	lastModified := t.lastModifiedValidator()
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && t.notModified(r, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	response.NewSuccess(status, t.data).Write(w)
+}
+
+// lastModifiedValidator returns the modification time to send, truncated to
+// the second precision of HTTP dates. Times in the last second are not sent:
+// a change later in the same second would carry the same date, so clients
+// revalidating with it would miss the change.
+func (t taggedResult) lastModifiedValidator() time.Time {
+	if t.lastModified.IsZero() || time.Since(t.lastModified) < time.Second {
+		return time.Time{}
+	}
+	return t.lastModified.Truncate(time.Second)
+}
+
+// notModified evaluates If-None-Match or, only if it is absent,
+// If-Modified-Since against the validators of the result
+func (t taggedResult) notModified(r *http.Request, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, t.etag, true)
+	}
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !lastModified.After(since)
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+
+	"myapp/internal/feature"
+	"myapp/internal/middleware"
+)
+
+// FeatureHandler handles HTTP requests for feature flags
+type FeatureHandler struct {
+	flags *feature.Flags
+}
+
+// NewFeatureHandler creates a new FeatureHandler instance
+func NewFeatureHandler(flags *feature.Flags) *FeatureHandler {
+	return &FeatureHandler{flags: flags}
+}
+
+// List handles evaluating the feature flags for the authenticated user
+// @Summary Get my feature flags
+// @Description Get every feature flag with whether it is on for the authenticated user, so that clients can show or hide features accordingly
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=map[string]bool}
+// @Failure 401 {object} response.Response
+// @Router /users/me/features [get]
+func (h *FeatureHandler) List(r *http.Request) (map[string]bool, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+	return h.flags.For(userID), nil
+}
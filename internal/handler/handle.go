@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+
+	"myapp/internal/middleware"
+	"myapp/internal/pkg/response"
+	"myapp/internal/validation"
+)
+
+// Renderer is implemented by handler results that write their own response,
+// for example to set headers or answer with 304 Not Modified
+type Renderer interface {
+	Render(w http.ResponseWriter, r *http.Request, status int)
+}
+
+// TypedHandler handles a decoded and validated request body
+type TypedHandler[Req, Resp any] func(r *http.Request, req *Req) (Resp, error)
+
+// Handle adapts a TypedHandler to an http.HandlerFunc. The request body is
+// decoded and validated by middleware.Bind; the handler's result is written
+// with the given status through the standard response envelope, and its
+// error through writeError.
+func Handle[Req, Resp any](validator validation.Validator, status int, fn TypedHandler[Req, Resp]) http.HandlerFunc {
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := middleware.GetValidated[Req](r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		resp, err := fn(r, req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeResult(w, r, status, resp)
+	})
+
+	return middleware.Bind[Req](validator)(inner).ServeHTTP
+}
+
+// Respond adapts a handler without a request body to an http.HandlerFunc,
+// rendering its result the same way as Handle
+func Respond[Resp any](status int, fn func(r *http.Request) (Resp, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := fn(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeResult(w, r, status, resp)
+	}
+}
+
+// writeResult writes a successful result, omitting the body for 204.
+// Results implementing Renderer write themselves.
+func writeResult(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	if renderer, ok := data.(Renderer); ok {
+		renderer.Render(w, r, status)
+		return
+	}
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	response.NewSuccess(status, data).Write(w)
+}
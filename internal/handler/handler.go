@@ -2,18 +2,37 @@ package handler
 
 import (
 	"myapp/internal/service"
+	"myapp/internal/validation"
 )
 
 // Handler contains all HTTP handlers
 type Handler struct {
-	UserHandler *UserHandler
-	TodoHandler *TodoHandler
+	UserHandler       *UserHandler
+	TodoHandler       *TodoHandler
+	StreamHandler     *StreamHandler
+	WebhookHandler    *WebhookHandler
+	AuditHandler      *AuditHandler
+	AttachmentHandler *AttachmentHandler
+	CommentHandler    *CommentHandler
+	CalendarHandler   *CalendarHandler
+	WorkspaceHandler  *WorkspaceHandler
+	FeatureHandler    *FeatureHandler
+	Validator         validation.Validator
 }
 
 // New creates a new Handler instance
-func New(authService service.AuthService, todoService service.TodoService) *Handler {
+func New(authService service.AuthService, todoService service.TodoService, webhookService service.WebhookService, auditService service.AuditService, commentService service.CommentService, calendarService service.CalendarService, workspaceService service.WorkspaceService, validator validation.Validator, streamHandler *StreamHandler, attachmentHandler *AttachmentHandler, featureHandler *FeatureHandler) *Handler {
 	return &Handler{
-		UserHandler: NewUserHandler(authService),
-		TodoHandler: NewTodoHandler(todoService),
+		UserHandler:       NewUserHandler(authService),
+		TodoHandler:       NewTodoHandler(todoService, validator),
+		StreamHandler:     streamHandler,
+		WebhookHandler:    NewWebhookHandler(webhookService),
+		AuditHandler:      NewAuditHandler(auditService),
+		AttachmentHandler: attachmentHandler,
+		CommentHandler:    NewCommentHandler(commentService),
+		CalendarHandler:   NewCalendarHandler(calendarService, todoService, validator),
+		WorkspaceHandler:  NewWorkspaceHandler(workspaceService, authService),
+		FeatureHandler:    featureHandler,
+		Validator:         validator,
 	}
 }
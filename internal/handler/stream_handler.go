@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"myapp/internal/events"
+	"myapp/internal/middleware"
+	"myapp/internal/pkg/response"
+)
+
+const (
+	// DefaultHeartbeatInterval is how often idle streams are kept alive
+	DefaultHeartbeatInterval = 15 * time.Second
+
+	// resetEvent tells a resuming client that events were missed and it
+	// should resynchronise through the change feed
+	resetEvent = "reset"
+
+	// sseRetry is the reconnection delay suggested to EventSource clients
+	sseRetry = 3 * time.Second
+
+	wsWriteTimeout = 10 * time.Second
+	wsReadLimit    = 512
+)
+
+// StreamHandler pushes a user's todo events over Server-Sent Events and
+// WebSocket connections
+type StreamHandler struct {
+	bus       *events.Bus
+	heartbeat time.Duration
+	upgrader  websocket.Upgrader
+}
+
+// NewStreamHandler creates a new StreamHandler instance
+func NewStreamHandler(bus *events.Bus, heartbeat time.Duration) *StreamHandler {
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeatInterval
+	}
+	return &StreamHandler{
+		bus:       bus,
+		heartbeat: heartbeat,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
+	}
+}
+
+// SSE handles streaming todo events as Server-Sent Events
+// @Summary Stream todo events (SSE)
+// @Description Stream the user's todo.created, todo.updated and todo.deleted events as Server-Sent Events. Reconnect with Last-Event-ID to resume; a "reset" event means events were missed and the client should resynchronise via /todos/changes.
+// @Tags todos
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param Last-Event-ID header string false "ID of the last event received"
+// @Success 200 {string} string "Event stream"
+// @Failure 401 {object} response.Response
+// @Router /todos/stream [get]
+func (h *StreamHandler) SSE(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		response.NewServiceError(http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized").Write(w)
+		return
+	}
+
+	sub, replay, resumed := h.bus.Subscribe(userID, lastEventID(r))
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds()); err != nil {
+		return
+	}
+	if !resumed {
+		if err := writeSSE(w, events.Event{Type: resetEvent, Data: struct{}{}, Time: time.Now()}); err != nil {
+			return
+		}
+	}
+	for _, event := range replay {
+		if err := writeSSE(w, event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Done():
+			return
+		case event := <-sub.Events():
+			if err := writeSSE(w, event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// WebSocket handles streaming todo events over a WebSocket connection
+// @Summary Stream todo events (WebSocket)
+// @Description Upgrade to a WebSocket that receives the user's todo events as JSON messages. Pass last_event_id to resume; a message of type "reset" means events were missed.
+// @Tags todos
+// @Security BearerAuth
+// @Param last_event_id query string false "ID of the last event received"
+// @Success 101 "Switching Protocols"
+// @Failure 401 {object} response.Response
+// @Router /todos/ws [get]
+func (h *StreamHandler) WebSocket(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		response.NewServiceError(http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized").Write(w)
+		return
+	}
+
+	// Upgrade writes its own error response on failure
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sub, replay, resumed := h.bus.Subscribe(userID, lastEventID(r))
+	defer sub.Close()
+
+	// The read loop processes pongs and close frames; clients send nothing
+	// else. It ends when the connection is closed or stops answering pings.
+	conn.SetReadLimit(wsReadLimit)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+	})
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(event events.Event) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteJSON(event)
+	}
+
+	if !resumed {
+		if err := send(events.Event{Type: resetEvent, Data: struct{}{}, Time: time.Now()}); err != nil {
+			return
+		}
+	}
+	for _, event := range replay {
+		if err := send(event); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-sub.Done():
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber fell behind"),
+				time.Now().Add(wsWriteTimeout))
+			return
+		case event := <-sub.Events():
+			if err := send(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// lastEventID returns the event ID a client wants to resume after
+func lastEventID(r *http.Request) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return r.URL.Query().Get("last_event_id")
+}
+
+// writeSSE writes a single event in text/event-stream format
+func writeSSE(w io.Writer, event events.Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	if event.ID != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", event.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
@@ -1,27 +1,38 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"myapp/internal/middleware"
 	"myapp/internal/model"
-	httputil "myapp/internal/pkg/http"
+	"myapp/internal/pkg/jsonpatch"
+	"myapp/internal/portability"
 	"myapp/internal/service"
+	"myapp/internal/validation"
 )
 
 // TodoHandler handles HTTP requests for todo operations
 type TodoHandler struct {
 	todoService service.TodoService
+	validator   validation.Validator
 }
 
 // NewTodoHandler creates a new TodoHandler instance
-func NewTodoHandler(todoService service.TodoService) *TodoHandler {
+func NewTodoHandler(todoService service.TodoService, validator validation.Validator) *TodoHandler {
 	return &TodoHandler{
 		todoService: todoService,
+		validator:   validator,
 	}
 }
 
@@ -32,32 +43,22 @@ func NewTodoHandler(todoService service.TodoService) *TodoHandler {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
 // @Param request body model.TodoCreateRequest true "Todo creation details"
-// @Success 201 {object} model.Todo
+// @Success 201 {object} response.Response{data=model.Todo}
 // @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 409 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /todos [post]
-func (h *TodoHandler) Create(w http.ResponseWriter, r *http.Request) {
+func (h *TodoHandler) Create(r *http.Request, req *model.TodoCreateRequest) (*model.Todo, error) {
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
-		httputil.Error(w, http.StatusUnauthorized, "Unauthorized")
-		return
-	}
-
-	var req model.TodoCreateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httputil.Error(w, http.StatusBadRequest, "Invalid request body")
-		return
-	}
-
-	todo, err := h.todoService.Create(r.Context(), userID, &req)
-	if err != nil {
-		httputil.Error(w, http.StatusInternalServerError, "Failed to create todo")
-		return
+		return nil, errUnauthorized
 	}
 
-	httputil.JSON(w, http.StatusCreated, todo)
+	return h.todoService.Create(r.Context(), userID, req)
 }
 
 // GetByID handles retrieving a todo by ID
@@ -68,39 +69,32 @@ func (h *TodoHandler) Create(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Todo ID"
-// @Success 200 {object} model.Todo
+// @Param If-None-Match header string false "Entity tag from a previous response"
+// @Success 200 {object} response.Response{data=model.Todo}
+// @Success 304 "Not Modified"
+// @Header 200 {string} ETag "Entity tag of the todo"
 // @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
-// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /todos/{id} [get]
-func (h *TodoHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+func (h *TodoHandler) GetByID(r *http.Request) (Renderer, error) {
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
-		httputil.Error(w, http.StatusUnauthorized, "Unauthorized")
-		return
+		return nil, errUnauthorized
 	}
 
-	// Get todo ID from URL parameters
-	todoID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	todoID, err := todoIDParam(r)
 	if err != nil {
-		httputil.Error(w, http.StatusBadRequest, "Invalid todo ID")
-		return
+		return nil, err
 	}
 
-	todo, err := h.todoService.GetByID(r.Context(), uint(todoID))
+	todo, err := h.todoService.GetByID(r.Context(), userID, todoID)
 	if err != nil {
-		httputil.Error(w, http.StatusInternalServerError, "Failed to get todo")
-		return
+		return nil, err
 	}
 
-	// Check if the todo belongs to the user
-	if todo.UserID != userID {
-		httputil.Error(w, http.StatusForbidden, "Access denied")
-		return
-	}
-
-	httputil.JSON(w, http.StatusOK, todo)
+	return taggedResult{data: todo, etag: todoETag(todo)}, nil
 }
 
 // GetByUserID handles retrieving all todos for a user
@@ -110,67 +104,177 @@ func (h *TodoHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {array} model.Todo
+// @Param If-None-Match header string false "Entity tag from a previous response"
+// @Param If-Modified-Since header string false "Last-Modified date from a previous response"
+// @Success 200 {object} response.Response{data=[]model.Todo}
+// @Success 304 "Not Modified"
+// @Header 200 {string} ETag "Entity tag of the list"
+// @Header 200 {string} Last-Modified "When a todo was last created, changed or deleted"
 // @Failure 401 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /todos [get]
-func (h *TodoHandler) GetByUserID(w http.ResponseWriter, r *http.Request) {
+func (h *TodoHandler) GetByUserID(r *http.Request) (Renderer, error) {
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
-		httputil.Error(w, http.StatusUnauthorized, "Unauthorized")
-		return
+		return nil, errUnauthorized
 	}
 
+	// Read before the list, so that a change in between makes the list
+	// newer than its date rather than older
+	lastModified, err := h.todoService.LastModified(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
 	todos, err := h.todoService.GetByUserID(r.Context(), userID)
 	if err != nil {
-		httputil.Error(w, http.StatusInternalServerError, "Failed to get todos")
-		return
+		return nil, err
 	}
 
-	httputil.JSON(w, http.StatusOK, todos)
+	etag, err := contentETag(todos)
+	if err != nil {
+		return nil, err
+	}
+	return taggedResult{data: todos, etag: etag, lastModified: lastModified}, nil
 }
 
 // Update handles updating a todo
-// @Summary Update a todo
-// @Description Update an existing todo item
+// @Summary Replace a todo
+// @Description Replace all fields of an existing todo item
 // @Tags todos
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Todo ID"
+// @Param If-Match header string false "Entity tag the update is conditional on"
 // @Param request body model.TodoUpdateRequest true "Todo update details"
-// @Success 200 {object} model.Todo
+// @Success 200 {object} response.Response{data=model.Todo}
+// @Header 200 {string} ETag "Entity tag of the updated todo"
 // @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 412 {object} response.Response
+// @Failure 422 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /todos/{id} [put]
-func (h *TodoHandler) Update(w http.ResponseWriter, r *http.Request) {
+func (h *TodoHandler) Update(r *http.Request, req *model.TodoUpdateRequest) (Renderer, error) {
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
-		httputil.Error(w, http.StatusUnauthorized, "Unauthorized")
-		return
+		return nil, errUnauthorized
 	}
 
-	// Get todo ID from URL parameters
-	todoID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	todoID, err := todoIDParam(r)
 	if err != nil {
-		httputil.Error(w, http.StatusBadRequest, "Invalid todo ID")
-		return
+		return nil, err
+	}
+
+	expectedVersion, err := h.ifMatchVersion(r, userID, todoID)
+	if err != nil {
+		return nil, err
+	}
+
+	todo, err := h.todoService.Update(r.Context(), userID, todoID, expectedVersion, req)
+	if err != nil {
+		if errors.Is(err, model.ErrTodoVersionConflict) && expectedVersion == 0 {
+			return nil, NewAPIError(http.StatusConflict, "CONFLICT", "Todo was modified concurrently, please retry")
+		}
+		return nil, err
+	}
+
+	return taggedResult{data: todo, etag: todoETag(todo)}, nil
+}
+
+// Patch handles partially updating a todo
+// @Summary Patch a todo
+// @Description Partially update a todo with an RFC 7396 JSON Merge Patch (application/merge-patch+json) or an RFC 6902 JSON Patch (application/json-patch+json). Setting a member to null in a merge patch clears it. The patched todo is validated as a whole.
+// @Tags todos
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param If-Match header string false "Entity tag the update is conditional on"
+// @Param request body object true "Merge patch or JSON Patch document"
+// @Success 200 {object} response.Response{data=model.Todo}
+// @Header 200 {string} ETag "Entity tag of the updated todo"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 412 {object} response.Response
+// @Failure 413 {object} response.Response
+// @Failure 415 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/{id} [patch]
+func (h *TodoHandler) Patch(r *http.Request) (Renderer, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	todoID, err := todoIDParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	apply, err := patchFunc(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := io.ReadAll(io.LimitReader(r.Body, middleware.DefaultMaxBodyBytes+1))
+	if err != nil {
+		return nil, NewAPIError(http.StatusBadRequest, "INVALID_REQUEST", "Failed to read request body")
+	}
+	if int64(len(patch)) > middleware.DefaultMaxBodyBytes {
+		return nil, NewAPIError(http.StatusRequestEntityTooLarge, "REQUEST_TOO_LARGE",
+			fmt.Sprintf("Request body must not exceed %d bytes", middleware.DefaultMaxBodyBytes))
 	}
 
+	current, err := h.todoService.GetByID(r.Context(), userID, todoID)
+	if err != nil {
+		return nil, err
+	}
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" && !etagMatches(ifMatch, todoETag(current), false) {
+		return nil, model.ErrTodoVersionConflict
+	}
+
+	doc, err := json.Marshal(current.UpdateRequest())
+	if err != nil {
+		return nil, err
+	}
+	patched, err := apply(doc, patch)
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return nil, NewAPIError(http.StatusConflict, "PATCH_TEST_FAILED", err.Error())
+		}
+		return nil, NewAPIError(http.StatusBadRequest, "INVALID_PATCH", err.Error())
+	}
+
+	// The patched document must still be a valid todo
 	var req model.TodoUpdateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httputil.Error(w, http.StatusBadRequest, "Invalid request body")
-		return
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return nil, NewAPIError(http.StatusUnprocessableEntity, "INVALID_PATCH",
+			fmt.Sprintf("Patched todo is invalid: %v", err))
+	}
+	if err := h.validator.Validate(r.Context(), &req); err != nil {
+		return nil, err
 	}
 
-	todo, err := h.todoService.Update(r.Context(), userID, uint(todoID), &req)
+	// Conditional on the version the patch was applied to, so a concurrent
+	// change is reported instead of being overwritten
+	todo, err := h.todoService.Update(r.Context(), userID, todoID, current.Version, &req)
 	if err != nil {
-		httputil.Error(w, http.StatusInternalServerError, "Failed to update todo")
-		return
+		if errors.Is(err, model.ErrTodoVersionConflict) && ifMatch == "" {
+			return nil, NewAPIError(http.StatusConflict, "CONFLICT", "Todo was modified concurrently, please retry")
+		}
+		return nil, err
 	}
 
-	httputil.JSON(w, http.StatusOK, todo)
+	return taggedResult{data: todo, etag: todoETag(todo)}, nil
 }
 
 // Delete handles deleting a todo
@@ -184,26 +288,401 @@ func (h *TodoHandler) Update(w http.ResponseWriter, r *http.Request) {
 // @Success 204 "No Content"
 // @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /todos/{id} [delete]
-func (h *TodoHandler) Delete(w http.ResponseWriter, r *http.Request) {
+func (h *TodoHandler) Delete(r *http.Request) (struct{}, error) {
 	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
-		httputil.Error(w, http.StatusUnauthorized, "Unauthorized")
-		return
+		return struct{}{}, errUnauthorized
 	}
 
-	// Get todo ID from URL parameters
-	todoID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	todoID, err := todoIDParam(r)
 	if err != nil {
-		httputil.Error(w, http.StatusBadRequest, "Invalid todo ID")
-		return
+		return struct{}{}, err
 	}
 
-	if err := h.todoService.Delete(r.Context(), userID, uint(todoID)); err != nil {
-		httputil.Error(w, http.StatusInternalServerError, "Failed to delete todo")
+	return struct{}{}, h.todoService.Delete(r.Context(), userID, todoID)
+}
+
+// ifMatchVersion evaluates the If-Match header against the current todo and
+// returns the version the update must be conditional on, or 0 if the
+// request carries no If-Match header
+func (h *TodoHandler) ifMatchVersion(r *http.Request, userID, todoID uint) (uint, error) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return 0, nil
+	}
+
+	current, err := h.todoService.GetByID(r.Context(), userID, todoID)
+	if err != nil {
+		return 0, err
+	}
+	if !etagMatches(ifMatch, todoETag(current), false) {
+		return 0, model.ErrTodoVersionConflict
+	}
+	return current.Version, nil
+}
+
+// Bulk handles applying many todo changes in one request
+// @Summary Bulk todo operations
+// @Description Create, update, complete or delete many todos in one transaction. In atomic mode (the default) nothing is committed if any item fails; in best_effort mode every item that succeeds is committed. Each item reports its own result.
+// @Tags todos
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Param request body model.TodoBulkRequest true "Bulk operations"
+// @Success 200 {object} response.Response{data=model.TodoBulkResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 413 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/bulk [post]
+func (h *TodoHandler) Bulk(r *http.Request, req *model.TodoBulkRequest) (*model.TodoBulkResponse, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	return h.todoService.Bulk(r.Context(), userID, req)
+}
+
+// Changes handles the todo change feed
+// @Summary Get todo changes
+// @Description Get todos created, updated or deleted since a cursor, oldest first. Omit since for a full sync; afterwards pass the returned cursor. Fetch again while has_more is true.
+// @Tags sync
+// @Produce json
+// @Security BearerAuth
+// @Param since query string false "Cursor returned by a previous call"
+// @Param limit query int false "Maximum number of changes (default 100, max 500)"
+// @Success 200 {object} response.Response{data=model.TodoChangesResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/changes [get]
+func (h *TodoHandler) Changes(r *http.Request) (*model.TodoChangesResponse, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return nil, NewAPIError(http.StatusBadRequest, "INVALID_LIMIT", "limit must be a positive integer")
+		}
+	}
+
+	return h.todoService.Changes(r.Context(), userID, r.URL.Query().Get("since"), limit)
+}
+
+// Push handles applying changes made offline by a client
+// @Summary Push todo changes
+// @Description Apply changes made offline. With the version strategy (default) a change is applied only if it was based on the current server version; with last_writer_wins it is applied if it was made after the server's last update. Conflicts return the server copy.
+// @Tags sync
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
+// @Param request body model.TodoPushRequest true "Client changes"
+// @Success 200 {object} response.Response{data=model.TodoPushResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/changes [post]
+func (h *TodoHandler) Push(r *http.Request, req *model.TodoPushRequest) (*model.TodoPushResponse, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	return h.todoService.Push(r.Context(), userID, req)
+}
+
+// Occurrences handles previewing the upcoming occurrences of a recurring todo
+// @Summary Preview a recurring todo's occurrences
+// @Description Get the due dates that follow a recurring todo's current due date, i.e. the todos that completing it would produce in turn. A todo without recurrence has none.
+// @Tags todos
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param count query int false "Number of occurrences (default 10, max 100)"
+// @Success 200 {object} response.Response{data=model.TodoOccurrencesResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/{id}/occurrences [get]
+func (h *TodoHandler) Occurrences(r *http.Request) (*model.TodoOccurrencesResponse, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	todoID, err := todoIDParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	if v := r.URL.Query().Get("count"); v != "" {
+		if count, err = strconv.Atoi(v); err != nil || count < 1 {
+			return nil, NewAPIError(http.StatusBadRequest, "INVALID_COUNT", "count must be a positive integer")
+		}
+	}
+
+	return h.todoService.Occurrences(r.Context(), userID, todoID, count)
+}
+
+// PreviewOccurrences handles expanding a recurrence rule before it is saved
+// @Summary Preview a recurrence rule
+// @Description Expand an RRULE (FREQ=DAILY, WEEKLY or MONTHLY with INTERVAL, BYDAY, UNTIL and COUNT) from a first due date, in the given time zone or the user's. The first due date is included if it matches the rule.
+// @Tags todos
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.TodoOccurrencesRequest true "Rule to expand"
+// @Success 200 {object} response.Response{data=model.TodoOccurrencesResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/occurrences [post]
+func (h *TodoHandler) PreviewOccurrences(r *http.Request, req *model.TodoOccurrencesRequest) (*model.TodoOccurrencesResponse, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	return h.todoService.PreviewOccurrences(r.Context(), userID, req)
+}
+
+// Export handles downloading all of the user's todos as a file
+// @Summary Export todos
+// @Description Download all of the user's todos as CSV, a JSON array or newline-delimited JSON. The file is streamed, and can be imported again with POST /todos/import. In CSV files, cells that spreadsheet applications would evaluate as formulas are prefixed with an apostrophe.
+// @Tags todos
+// @Produce text/csv
+// @Produce json
+// @Produce application/x-ndjson
+// @Security BearerAuth
+// @Param format query string false "File format: csv, json (default) or ndjson"
+// @Success 200 {file} binary
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/export [get]
+func (h *TodoHandler) Export(r *http.Request) (Renderer, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	format := portability.JSON
+	if v := r.URL.Query().Get("format"); v != "" {
+		if format, err = portability.ParseFormat(v); err != nil {
+			return nil, NewAPIError(http.StatusBadRequest, "INVALID_FORMAT", "format must be csv, json or ndjson")
+		}
+	}
+
+	return todoExport{
+		format: format,
+		export: func(fn func(*model.Todo) error) error {
+			return h.todoService.Export(r.Context(), userID, fn)
+		},
+	}, nil
+}
+
+// Import handles creating todos from a file
+// @Summary Import todos
+// @Description Create todos from a CSV, JSON array or newline-delimited JSON file in the format written by GET /todos/export, sent as the request body or as a multipart/form-data part named "file". The format is given by the format parameter or the body's content type. The file is read as it arrives: every row is validated like a PUT request body, each problem is reported with its line number and invalid rows are skipped, while valid rows are committed in batches of 500, each in its own transaction. A file that cannot be read any further is rejected if nothing was committed yet, and otherwise reported as an error with code invalid_file. A dry run only validates.
+// @Tags todos
+// @Accept text/csv
+// @Accept json
+// @Accept application/x-ndjson
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param format query string false "File format: csv, json or ndjson"
+// @Param dry_run query bool false "Validate the file without creating todos"
+// @Param file formData file false "File to import"
+// @Success 200 {object} response.Response{data=model.TodoImportReport}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /todos/import [post]
+func (h *TodoHandler) Import(r *http.Request) (*model.TodoImportReport, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	format, err := importFormat(r)
+	if err != nil {
+		return nil, err
+	}
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			return nil, NewAPIError(http.StatusBadRequest, "INVALID_DRY_RUN", "dry_run must be true or false")
+		}
+	}
+
+	file, err := importFile(r)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := portability.NewReader(format, file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows that decoded are validated like PUT request bodies
+	next := func() (model.TodoImportRow, error) {
+		row, err := reader.Read()
+		if err != nil {
+			var syntaxErr *portability.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				return row, &model.TodoImportError{Line: syntaxErr.Line, Code: model.TodoImportInvalidFile, Message: syntaxErr.Msg}
+			}
+			if err != io.EOF {
+				err = &model.TodoImportError{Code: model.TodoImportInvalidFile, Message: "Failed to read request body"}
+			}
+			return row, err
+		}
+		if len(row.Errors) > 0 {
+			return row, nil
+		}
+		if err := h.validator.Validate(r.Context(), &row.Todo); err != nil {
+			var errs validation.ValidationErrors
+			if !errors.As(err, &errs) {
+				return row, err
+			}
+			for _, e := range errs.Errors {
+				row.Errors = append(row.Errors, model.TodoImportError{
+					Line:    row.Line,
+					Field:   e.Field,
+					Code:    string(e.Code),
+					Message: e.Message,
+				})
+			}
+		}
+		return row, nil
+	}
+
+	report, err := h.todoService.ImportStream(r.Context(), userID, next, dryRun)
+	if err != nil {
+		var fileErr *model.TodoImportError
+		if errors.As(err, &fileErr) {
+			return nil, NewAPIError(http.StatusBadRequest, "INVALID_IMPORT", fileErr.Error())
+		}
+		return nil, err
+	}
+	if report.Rows == 0 {
+		return nil, NewAPIError(http.StatusBadRequest, "EMPTY_IMPORT", "File contains no todos")
+	}
+	return report, nil
+}
+
+// todoExport is a handler result that streams the user's todos as a file
+type todoExport struct {
+	format portability.Format
+	export func(fn func(*model.Todo) error) error
+}
+
+// Render implements Renderer. The response starts with the first bytes of
+// the file, so a failure before then is still reported as an error
+// response; a failure after then aborts the response, so that a client
+// does not mistake a truncated file for a complete one.
+func (e todoExport) Render(w http.ResponseWriter, r *http.Request, status int) {
+	out := &lazyWriter{w: w, start: func() {
+		header := w.Header()
+		header.Set("Content-Type", e.format.ContentType())
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": fmt.Sprintf("todos-%s.%s", time.Now().UTC().Format("20060102"), e.format),
+		}))
+		header.Set("Cache-Control", "no-store")
+		header.Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+	}}
+
+	writer := portability.NewWriter(e.format, out)
+	err := e.export(writer.Write)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		return
+	}
+	if !out.started {
+		writeError(w, err)
 		return
 	}
+	slog.ErrorContext(r.Context(), "todos: export failed after the response started", "error", err)
+	panic(http.ErrAbortHandler)
+}
+
+// lazyWriter calls start before the first write to w
+type lazyWriter struct {
+	w       io.Writer
+	start   func()
+	started bool
+}
+
+func (l *lazyWriter) Write(p []byte) (int, error) {
+	if !l.started {
+		l.started = true
+		l.start()
+	}
+	return l.w.Write(p)
+}
+
+// importFormat returns the format of an import request, from the format
+// query parameter or else from the content type of the request body
+func importFormat(r *http.Request) (portability.Format, error) {
+	if v := r.URL.Query().Get("format"); v != "" {
+		format, err := portability.ParseFormat(v)
+		if err != nil {
+			return "", NewAPIError(http.StatusBadRequest, "INVALID_FORMAT", "format must be csv, json or ndjson")
+		}
+		return format, nil
+	}
+	format, err := portability.FormatForContentType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "", NewAPIError(http.StatusBadRequest, "INVALID_FORMAT", "format must be given as a parameter or by the content type")
+	}
+	return format, nil
+}
+
+// patchFunc selects the patch format from the request content type
+func patchFunc(contentType string) (func(doc, patch []byte) ([]byte, error), error) {
+	mediaType := "application/merge-patch+json"
+	if contentType != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, NewAPIError(http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", "Invalid Content-Type")
+		}
+		mediaType = parsed
+	}
 
-	w.WriteHeader(http.StatusNoContent)
+	switch mediaType {
+	case "application/merge-patch+json", "application/json":
+		return jsonpatch.MergePatch, nil
+	case "application/json-patch+json":
+		return jsonpatch.Apply, nil
+	default:
+		return nil, NewAPIError(http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE",
+			"Content-Type must be application/merge-patch+json or application/json-patch+json")
+	}
+}
+
+// todoIDParam parses the {id} URL parameter
+func todoIDParam(r *http.Request) (uint, error) {
+	todoID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return 0, NewAPIError(http.StatusBadRequest, "INVALID_ID", "Invalid todo ID")
+	}
+	return uint(todoID), nil
 }
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"myapp/internal/config"
+	"myapp/internal/middleware"
+	"myapp/internal/model"
+	"myapp/internal/service"
+	"myapp/internal/validation"
+)
+
+// stubAuth accepts any bearer token as user
+type stubAuth struct {
+	service.AuthService
+	user *model.User
+}
+
+func (a stubAuth) GetUserByToken(ctx context.Context, token string) (*model.User, uint, error) {
+	return a.user, 1, nil
+}
+
+// stubTodos is a TodoService holding one todo, updated like the real
+// service: conditionally on its version, which each update increments
+type stubTodos struct {
+	service.TodoService
+
+	mu   sync.Mutex
+	todo model.Todo
+}
+
+func (s *stubTodos) GetByID(ctx context.Context, userID, id uint) (*model.Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id != s.todo.ID || userID != s.todo.UserID {
+		return nil, model.ErrTodoNotFound
+	}
+	todo := s.todo
+	return &todo, nil
+}
+
+func (s *stubTodos) Update(ctx context.Context, userID, id, expectedVersion uint, req *model.TodoUpdateRequest) (*model.Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id != s.todo.ID || userID != s.todo.UserID {
+		return nil, model.ErrTodoNotFound
+	}
+	if expectedVersion != 0 && expectedVersion != s.todo.Version {
+		return nil, model.ErrTodoVersionConflict
+	}
+	s.todo.Title = req.Title
+	s.todo.Description = req.Description
+	s.todo.Completed = req.Completed
+	s.todo.Version++
+	todo := s.todo
+	return &todo, nil
+}
+
+// newTodoServer serves the todo routes the way the application does:
+// authenticated and compressed with the default compression settings
+func newTodoServer(todos service.TodoService, user *model.User) http.Handler {
+	validator := validation.NewEngine(nil)
+	h := NewTodoHandler(todos, validator)
+	compression := config.Default().Compression
+
+	r := chi.NewRouter()
+	r.Use(middleware.Compress(int(compression.MinSize), compression.Types))
+	r.Use(middleware.Auth(stubAuth{user: user}))
+	r.Get("/todos/{id}", Respond(http.StatusOK, h.GetByID))
+	r.Put("/todos/{id}", Handle(validator, http.StatusOK, h.Update))
+	r.Patch("/todos/{id}", Respond(http.StatusOK, h.Patch))
+	return r
+}
+
+func TestTodoIfMatchWithCompressedETag(t *testing.T) {
+	user := &model.User{ID: 1, Email: "alice@example.com", Username: "alice", Timezone: "Europe/Berlin"}
+	tests := []struct {
+		method      string
+		contentType string
+		body        string
+	}{
+		{http.MethodPatch, "application/merge-patch+json", `{"completed":true}`},
+		{http.MethodPut, "application/json", `{"title":"renamed","completed":true}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			// Large enough that its representation is compressed
+			todos := &stubTodos{todo: model.Todo{
+				ID:          1,
+				UserID:      user.ID,
+				WorkspaceID: 1,
+				Version:     1,
+				Title:       strings.Repeat("t", 100),
+				Description: strings.Repeat("d", 500),
+				User:        *user,
+			}}
+			server := newTodoServer(todos, user)
+
+			get := httptest.NewRequest(http.MethodGet, "/todos/1", nil)
+			get.Header.Set("Authorization", "Bearer token")
+			get.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, get)
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+				t.Fatalf("GET = %d, Content-Encoding %q; want a compressed 200", rec.Code, rec.Header().Get("Content-Encoding"))
+			}
+			// A strong tag of its own
+			etag := rec.Header().Get("ETag")
+			if etag != `"1-gzip"` {
+				t.Fatalf("GET ETag = %s, want %s", etag, `"1-gzip"`)
+			}
+
+			// The tag of the compressed representation makes the update
+			// conditional on the version it was read at
+			update := httptest.NewRequest(tt.method, "/todos/1", strings.NewReader(tt.body))
+			update.Header.Set("Authorization", "Bearer token")
+			update.Header.Set("Accept-Encoding", "gzip")
+			update.Header.Set("Content-Type", tt.contentType)
+			update.Header.Set("If-Match", etag)
+			rec = httptest.NewRecorder()
+			server.ServeHTTP(rec, update)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s with If-Match %s = %d, want 200: %s", tt.method, etag, rec.Code, rec.Body)
+			}
+
+			// Once updated, the tag no longer matches
+			update = httptest.NewRequest(tt.method, "/todos/1", strings.NewReader(tt.body))
+			update.Header.Set("Authorization", "Bearer token")
+			update.Header.Set("Content-Type", tt.contentType)
+			update.Header.Set("If-Match", etag)
+			rec = httptest.NewRecorder()
+			server.ServeHTTP(rec, update)
+			if rec.Code != http.StatusPreconditionFailed {
+				t.Fatalf("%s with stale If-Match %s = %d, want 412", tt.method, etag, rec.Code)
+			}
+		})
+	}
+}
+
+func TestTodoIfMatchUsesStrongComparison(t *testing.T) {
+	user := &model.User{ID: 1, Username: "alice"}
+	todos := &stubTodos{todo: model.Todo{ID: 1, UserID: user.ID, WorkspaceID: 1, Version: 1, Title: "todo"}}
+	server := newTodoServer(todos, user)
+
+	for _, ifMatch := range []string{`W/"1"`, `W/"1-gzip"`} {
+		req := httptest.NewRequest(http.MethodPatch, "/todos/1", strings.NewReader(`{"completed":true}`))
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		req.Header.Set("If-Match", ifMatch)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("PATCH with If-Match %s = %d, want 412", ifMatch, rec.Code)
+		}
+	}
+}
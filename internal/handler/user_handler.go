@@ -1,12 +1,12 @@
 package handler
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
 
+	"myapp/internal/middleware"
 	"myapp/internal/model"
 	"myapp/internal/pkg/response"
-	"myapp/internal/pkg/validation"
 	"myapp/internal/service"
 )
 
@@ -24,86 +24,67 @@ func NewUserHandler(authService service.AuthService) *UserHandler {
 
 // Register handles user registration
 // @Summary Register a new user
-// @Description Register a new user with email and password
+// @Description Register a new user with email and password, together with a personal workspace the returned tokens act in
 // @Tags users
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "Key that makes retries of this request safe"
 // @Param request body model.RegisterRequest true "User registration details"
-// @Success 201 {object} model.RegisterResponse
+// @Success 201 {object} response.Response{data=service.AuthResponse}
 // @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
 // @Failure 409 {object} response.Response
+// @Failure 413 {object} response.Response
+// @Failure 422 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /register [post]
-func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
-	var req model.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.NewServiceError(http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body").Write(w)
-		return
-	}
-
-	// Validate request
-	if errs := validation.ValidateRegisterRequest(&req); errs.HasErrors() {
-		response.NewValidationError(errs.Errors).Write(w)
-		return
-	}
-
-	// Register user
-	resp, err := h.authService.Register(r.Context(), &req)
-	if err != nil {
-		switch err {
-		case model.ErrEmailAlreadyExists:
-			response.NewServiceError(http.StatusConflict, "EMAIL_EXISTS", "User with this email already exists").Write(w)
-		case model.ErrUsernameAlreadyExists:
-			response.NewServiceError(http.StatusConflict, "USERNAME_EXISTS", "User with this username already exists").Write(w)
-		default:
-			response.NewServiceError(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to register user").Write(w)
-		}
-		return
-	}
-
-	response.NewSuccess(http.StatusCreated, resp).Write(w)
+func (h *UserHandler) Register(r *http.Request, req *model.RegisterRequest) (*service.AuthResponse, error) {
+	return h.authService.Register(r.Context(), req)
 }
 
 // Login handles user login
 // @Summary Login a user
-// @Description Login a user with email and password
+// @Description Login a user with email and password. The tokens act in the requested workspace, or in the first workspace the user joined.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param request body model.LoginRequest true "User login credentials"
-// @Success 200 {object} model.LoginResponse
+// @Success 200 {object} response.Response{data=service.AuthResponse}
 // @Failure 400 {object} response.Response
 // @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 422 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /login [post]
-func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
-	var req model.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.NewServiceError(http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body").Write(w)
-		return
-	}
-
-	// Validate request
-	if errs := validation.ValidateLoginRequest(&req); errs.HasErrors() {
-		response.NewValidationError(errs.Errors).Write(w)
-		return
+func (h *UserHandler) Login(r *http.Request, req *model.LoginRequest) (*service.AuthResponse, error) {
+	resp, err := h.authService.Login(r.Context(), req.Email, req.Password, req.WorkspaceID)
+	if errors.Is(err, model.ErrUserNotFound) {
+		return nil, NewAPIError(http.StatusUnauthorized, "USER_NOT_FOUND", "User not found")
 	}
+	return resp, err
+}
 
-	// Login user
-	resp, err := h.authService.Login(r.Context(), req.Email, req.Password)
+// ChangePassword handles replacing the authenticated user's password
+// @Summary Change password
+// @Description Replace the authenticated user's password after checking the current one. Tokens issued before stay valid until they expire.
+// @Tags users
+// @Accept json
+// @Security BearerAuth
+// @Param request body model.ChangePasswordRequest true "Current and new password"
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /users/me/password [put]
+func (h *UserHandler) ChangePassword(r *http.Request, req *model.ChangePasswordRequest) (struct{}, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
 	if err != nil {
-		switch err {
-		case model.ErrInvalidCredentials:
-			response.NewServiceError(http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid email or password").Write(w)
-		case model.ErrUserNotFound:
-			response.NewServiceError(http.StatusUnauthorized, "USER_NOT_FOUND", "User not found").Write(w)
-		default:
-			response.NewServiceError(http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to login").Write(w)
-		}
-		return
+		return struct{}{}, errUnauthorized
 	}
 
-	response.NewSuccess(http.StatusOK, resp).Write(w)
+	return struct{}{}, h.authService.ChangePassword(r.Context(), userID, req.CurrentPassword, req.NewPassword)
 }
 
 // GetProfile handles getting the user's profile
@@ -113,10 +94,15 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param If-None-Match header string false "Entity tag from a previous response"
+// @Param If-Modified-Since header string false "Last-Modified date from a previous response"
 // @Success 200 {object} model.UserResponse
+// @Success 304 "Not Modified"
+// @Header 200 {string} ETag "Entity tag of the profile"
+// @Header 200 {string} Last-Modified "When the profile was last changed"
 // @Failure 401 {object} response.Response
 // @Failure 500 {object} response.Response
-// @Router /profile [get]
+// @Router /users/me [get]
 func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	// Get token from Authorization header
 	token := r.Header.Get("Authorization")
@@ -131,11 +117,17 @@ func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user from token
-	user, err := h.authService.GetUserByToken(r.Context(), token)
+	user, _, err := h.authService.GetUserByToken(r.Context(), token)
 	if err != nil {
 		response.NewServiceError(http.StatusUnauthorized, "UNAUTHORIZED", "Invalid token").Write(w)
 		return
 	}
 
-	response.NewSuccess(http.StatusOK, user.ToResponse()).Write(w)
+	profile := user.ToResponse()
+	etag, err := contentETag(profile)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	taggedResult{data: profile, etag: etag, lastModified: user.UpdatedAt}.Render(w, r, http.StatusOK)
 }
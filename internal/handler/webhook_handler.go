@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"myapp/internal/middleware"
+	"myapp/internal/model"
+	"myapp/internal/service"
+)
+
+// WebhookHandler handles HTTP requests for webhook operations
+type WebhookHandler struct {
+	webhookService service.WebhookService
+}
+
+// NewWebhookHandler creates a new WebhookHandler instance
+func NewWebhookHandler(webhookService service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+// Create handles registering a webhook
+// @Summary Register a webhook
+// @Description Register an endpoint to receive the given event types. Deliveries are POSTed as JSON with an X-Webhook-Signature header of "sha256=" followed by the hex HMAC-SHA256 of "<X-Webhook-Timestamp>.<body>", keyed with the secret returned here. The secret is only shown once.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.WebhookCreateRequest true "Webhook details"
+// @Success 201 {object} response.Response{data=model.WebhookCreateResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /webhooks [post]
+func (h *WebhookHandler) Create(r *http.Request, req *model.WebhookCreateRequest) (*model.WebhookCreateResponse, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	return h.webhookService.Create(r.Context(), userID, req)
+}
+
+// List handles listing the user's webhooks
+// @Summary List webhooks
+// @Description Get all webhooks registered by the authenticated user
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]model.Webhook}
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /webhooks [get]
+func (h *WebhookHandler) List(r *http.Request) ([]*model.Webhook, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	return h.webhookService.GetByUserID(r.Context(), userID)
+}
+
+// GetByID handles retrieving a webhook by ID
+// @Summary Get a webhook
+// @Description Get a webhook registered by the authenticated user
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} response.Response{data=model.Webhook}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /webhooks/{id} [get]
+func (h *WebhookHandler) GetByID(r *http.Request) (*model.Webhook, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	webhookID, err := webhookIDParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.webhookService.GetByID(r.Context(), userID, webhookID)
+}
+
+// Update handles replacing a webhook
+// @Summary Replace a webhook
+// @Description Replace the URL, event types and active flag of a webhook. The signing secret is kept.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Param request body model.WebhookUpdateRequest true "Webhook details"
+// @Success 200 {object} response.Response{data=model.Webhook}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /webhooks/{id} [put]
+func (h *WebhookHandler) Update(r *http.Request, req *model.WebhookUpdateRequest) (*model.Webhook, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	webhookID, err := webhookIDParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.webhookService.Update(r.Context(), userID, webhookID, req)
+}
+
+// Delete handles deleting a webhook
+// @Summary Delete a webhook
+// @Description Delete a webhook together with its delivery log
+// @Tags webhooks
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /webhooks/{id} [delete]
+func (h *WebhookHandler) Delete(r *http.Request) (struct{}, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return struct{}{}, errUnauthorized
+	}
+
+	webhookID, err := webhookIDParam(r)
+	if err != nil {
+		return struct{}{}, err
+	}
+
+	return struct{}{}, h.webhookService.Delete(r.Context(), userID, webhookID)
+}
+
+// Deliveries handles listing a webhook's delivery log
+// @Summary List webhook deliveries
+// @Description Get the most recent deliveries of a webhook, newest first, with their status, attempt count and last response
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook ID"
+// @Param limit query int false "Maximum number of deliveries (default 50, max 200)"
+// @Success 200 {object} response.Response{data=[]model.WebhookDelivery}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) Deliveries(r *http.Request) ([]*model.WebhookDelivery, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	webhookID, err := webhookIDParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return nil, NewAPIError(http.StatusBadRequest, "INVALID_LIMIT", "limit must be a positive integer")
+		}
+	}
+
+	return h.webhookService.Deliveries(r.Context(), userID, webhookID, limit)
+}
+
+// webhookIDParam parses the webhook ID from the URL
+func webhookIDParam(r *http.Request) (uint, error) {
+	webhookID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return 0, NewAPIError(http.StatusBadRequest, "INVALID_ID", "Invalid webhook ID")
+	}
+	return uint(webhookID), nil
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"myapp/internal/middleware"
+	"myapp/internal/model"
+	"myapp/internal/service"
+)
+
+// WorkspaceHandler handles HTTP requests for workspaces and their members
+type WorkspaceHandler struct {
+	workspaceService service.WorkspaceService
+	authService      service.AuthService
+}
+
+// NewWorkspaceHandler creates a new WorkspaceHandler instance
+func NewWorkspaceHandler(workspaceService service.WorkspaceService, authService service.AuthService) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		workspaceService: workspaceService,
+		authService:      authService,
+	}
+}
+
+// Create handles creating a workspace
+// @Summary Create a workspace
+// @Description Create a workspace owned by the authenticated user. Use the token endpoint to act in it.
+// @Tags workspaces
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body model.WorkspaceRequest true "Workspace details"
+// @Success 201 {object} response.Response{data=model.WorkspaceMembership}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /workspaces [post]
+func (h *WorkspaceHandler) Create(r *http.Request, req *model.WorkspaceRequest) (*model.WorkspaceMembership, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	return h.workspaceService.Create(r.Context(), userID, req)
+}
+
+// List handles listing the user's workspaces
+// @Summary List workspaces
+// @Description Get the workspaces the authenticated user is a member of, with their role in each
+// @Tags workspaces
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]model.WorkspaceMembership}
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /workspaces [get]
+func (h *WorkspaceHandler) List(r *http.Request) ([]*model.WorkspaceMembership, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	return h.workspaceService.List(r.Context(), userID)
+}
+
+// IssueToken handles switching to another workspace
+// @Summary Switch workspace
+// @Description Get tokens that act in another workspace the authenticated user is a member of
+// @Tags workspaces
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Workspace ID"
+// @Success 200 {object} response.Response{data=service.RefreshResponse}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /workspaces/{id}/token [post]
+func (h *WorkspaceHandler) IssueToken(r *http.Request) (*service.RefreshResponse, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	workspaceID, err := workspaceIDParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.authService.SwitchWorkspace(r.Context(), userID, workspaceID)
+}
+
+// ListMembers handles listing a workspace's members
+// @Summary List workspace members
+// @Description Get the members of a workspace the authenticated user is a member of
+// @Tags workspaces
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Workspace ID"
+// @Success 200 {object} response.Response{data=[]model.WorkspaceMember}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /workspaces/{id}/members [get]
+func (h *WorkspaceHandler) ListMembers(r *http.Request) ([]*model.WorkspaceMember, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	workspaceID, err := workspaceIDParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.workspaceService.ListMembers(r.Context(), userID, workspaceID)
+}
+
+// AddMember handles adding a user to a workspace
+// @Summary Add a workspace member
+// @Description Add the user with the given email to a workspace the authenticated user owns, as a member unless another role is given
+// @Tags workspaces
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Workspace ID"
+// @Param request body model.WorkspaceMemberRequest true "Member details"
+// @Success 201 {object} response.Response{data=model.WorkspaceMember}
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /workspaces/{id}/members [post]
+func (h *WorkspaceHandler) AddMember(r *http.Request, req *model.WorkspaceMemberRequest) (*model.WorkspaceMember, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	workspaceID, err := workspaceIDParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.workspaceService.AddMember(r.Context(), userID, workspaceID, req)
+}
+
+// RemoveMember handles removing a user from a workspace
+// @Summary Remove a workspace member
+// @Description Remove a member from a workspace. Owners can remove anyone and members can remove themselves; the last owner cannot be removed.
+// @Tags workspaces
+// @Security BearerAuth
+// @Param id path int true "Workspace ID"
+// @Param userID path int true "User ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /workspaces/{id}/members/{userID} [delete]
+func (h *WorkspaceHandler) RemoveMember(r *http.Request) (struct{}, error) {
+	userID, err := middleware.GetUserIDFromContext(r)
+	if err != nil {
+		return struct{}{}, errUnauthorized
+	}
+
+	workspaceID, err := workspaceIDParam(r)
+	if err != nil {
+		return struct{}{}, err
+	}
+	memberID, err := strconv.ParseUint(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		return struct{}{}, NewAPIError(http.StatusBadRequest, "INVALID_ID", "Invalid user ID")
+	}
+
+	return struct{}{}, h.workspaceService.RemoveMember(r.Context(), userID, workspaceID, uint(memberID))
+}
+
+// workspaceIDParam parses the {id} URL parameter
+func workspaceIDParam(r *http.Request) (uint, error) {
+	workspaceID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return 0, NewAPIError(http.StatusBadRequest, "INVALID_ID", "Invalid workspace ID")
+	}
+	return uint(workspaceID), nil
+}
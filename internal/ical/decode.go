@@ -0,0 +1,278 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// maxLineBytes bounds the length of a physical line
+	maxLineBytes = 1 << 20
+	// maxDepth bounds the nesting of components
+	maxDepth = 16
+)
+
+// SyntaxError describes malformed iCalendar data
+type SyntaxError struct {
+	// Line is the physical line the content line starts on, counting from 1
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("ical: line %d: %s", e.Line, e.Msg)
+}
+
+// Property is a decoded content line. Parameter names are upper-cased and
+// their values unquoted; the value is kept in iCalendar form.
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+	// Line is the physical line the property starts on
+	Line int
+}
+
+// Text returns the value of a TEXT property, unescaped
+func (p *Property) Text() string {
+	return UnescapeText(p.Value)
+}
+
+// Time parses the value of a DATE or DATE-TIME property. Date-times in UTC
+// form or with a TZID parameter are returned in that location; floating
+// date-times and dates, which have no time zone, are returned in UTC.
+// dateOnly reports whether the value was a DATE.
+func (p *Property) Time() (t time.Time, dateOnly bool, err error) {
+	if strings.EqualFold(p.Params["VALUE"], "DATE") || len(p.Value) == len(dateLayout) {
+		t, err = time.Parse(dateLayout, p.Value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%s: invalid date %q", p.Name, p.Value)
+		}
+		return t, true, nil
+	}
+
+	if strings.HasSuffix(p.Value, "Z") {
+		t, err = time.Parse(dateTimeUTCLayout, p.Value)
+	} else {
+		loc := time.UTC
+		if tzid := p.Params["TZID"]; tzid != "" {
+			if loc, err = time.LoadLocation(strings.TrimPrefix(tzid, "/")); err != nil {
+				return time.Time{}, false, fmt.Errorf("%s: unknown time zone %q", p.Name, tzid)
+			}
+		}
+		t, err = time.ParseInLocation(dateTimeLayout, p.Value, loc)
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%s: invalid date-time %q", p.Name, p.Value)
+	}
+	return t, false, nil
+}
+
+// Component is a decoded component such as VCALENDAR or VTODO
+type Component struct {
+	Name       string
+	Properties []*Property
+	Components []*Component
+}
+
+// Get returns the first property with the given name, or nil
+func (c *Component) Get(name string) *Property {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Walk calls fn for c and every component nested in it, depth first
+func (c *Component) Walk(fn func(*Component)) {
+	fn(c)
+	for _, child := range c.Components {
+		child.Walk(fn)
+	}
+}
+
+// Decode reads the top-level components, usually a single VCALENDAR, from r.
+// Folded lines are unfolded; both CRLF and bare LF line breaks are accepted.
+func Decode(r io.Reader) ([]*Component, error) {
+	var (
+		top   []*Component
+		stack []*Component
+	)
+
+	err := readLines(r, func(lineNo int, line string) error {
+		prop, err := parseLine(line)
+		if err != nil {
+			return &SyntaxError{Line: lineNo, Msg: err.Error()}
+		}
+		prop.Line = lineNo
+
+		switch prop.Name {
+		case "BEGIN":
+			if len(stack) == maxDepth {
+				return &SyntaxError{Line: lineNo, Msg: "components nested too deeply"}
+			}
+			comp := &Component{Name: strings.ToUpper(prop.Value)}
+			if len(stack) == 0 {
+				top = append(top, comp)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, comp)
+			}
+			stack = append(stack, comp)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(prop.Value) {
+				return &SyntaxError{Line: lineNo, Msg: fmt.Sprintf("unexpected END:%s", prop.Value)}
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return &SyntaxError{Line: lineNo, Msg: fmt.Sprintf("property %s outside of a component", prop.Name)}
+			}
+			comp := stack[len(stack)-1]
+			comp.Properties = append(comp.Properties, prop)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stack) > 0 {
+		return nil, &SyntaxError{Line: 0, Msg: fmt.Sprintf("missing END:%s", stack[len(stack)-1].Name)}
+	}
+	if len(top) == 0 {
+		return nil, &SyntaxError{Line: 0, Msg: "no components"}
+	}
+	return top, nil
+}
+
+// readLines unfolds the physical lines of r into content lines and calls fn
+// with each one and the line number it starts on. Blank lines are skipped.
+func readLines(r io.Reader, fn func(lineNo int, line string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineBytes)
+
+	var (
+		current strings.Builder
+		start   int
+		lineNo  int
+	)
+	flush := func() error {
+		if current.Len() == 0 {
+			return nil
+		}
+		line := current.String()
+		current.Reset()
+		return fn(start, line)
+	}
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		if line != "" && (line[0] == ' ' || line[0] == '\t') {
+			if current.Len() == 0 {
+				return &SyntaxError{Line: lineNo, Msg: "continuation line without a content line"}
+			}
+			if current.Len()+len(line) > maxLineBytes {
+				return &SyntaxError{Line: start, Msg: "content line too long"}
+			}
+			current.WriteString(line[1:])
+			continue
+		}
+
+		if err := flush(); err != nil {
+			return err
+		}
+		current.WriteString(line)
+		start = lineNo
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return &SyntaxError{Line: lineNo + 1, Msg: "line too long"}
+		}
+		return err
+	}
+	return flush()
+}
+
+// parseLine parses a content line of the form name *(";" param) ":" value
+func parseLine(line string) (*Property, error) {
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return nil, errors.New("malformed content line")
+	}
+	name := strings.ToUpper(line[:i])
+	if !isName(name) {
+		return nil, fmt.Errorf("invalid property name %q", line[:i])
+	}
+	prop := &Property{Name: name}
+
+	rest := line[i:]
+	for rest[0] == ';' {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 || !isName(strings.ToUpper(rest[:eq])) {
+			return nil, fmt.Errorf("malformed parameter in %s", name)
+		}
+		paramName := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+
+		var values []string
+		for {
+			var value string
+			if strings.HasPrefix(rest, `"`) {
+				end := strings.IndexByte(rest[1:], '"')
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated quoted parameter %s", paramName)
+				}
+				value, rest = rest[1:end+1], rest[end+2:]
+			} else {
+				end := strings.IndexAny(rest, ",;:")
+				if end < 0 {
+					return nil, fmt.Errorf("missing value in %s", name)
+				}
+				value, rest = rest[:end], rest[end:]
+			}
+			values = append(values, value)
+			if rest == "" || rest[0] != ',' {
+				break
+			}
+			rest = rest[1:]
+		}
+
+		if prop.Params == nil {
+			prop.Params = make(map[string]string)
+		}
+		prop.Params[paramName] = strings.Join(values, ",")
+		if rest == "" {
+			return nil, fmt.Errorf("missing value in %s", name)
+		}
+	}
+
+	if rest[0] != ':' {
+		return nil, fmt.Errorf("malformed content line %s", name)
+	}
+	prop.Value = rest[1:]
+	return prop, nil
+}
+
+// isName reports whether s is a valid property or parameter name: letters,
+// digits and dashes
+func isName(s string) bool {
+	for _, c := range s {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return s != ""
+}
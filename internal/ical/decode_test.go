@@ -0,0 +1,113 @@
+package ical
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	input := "\ufeffBEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"SUMMARY;LANGUAGE=de:Milch\\, Brot\r\n" +
+		"  und Eier\r\n" +
+		"DESCRIPTION:first\\nsecond\n" +
+		"\r\n" +
+		"DUE;TZID=Europe/Berlin:20260601T090000\r\n" +
+		"X-LIST;MEMBER=\"mailto:a@example.com\",\"mailto:b@example.com\":x\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+	comps, err := Decode(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comps) != 1 || comps[0].Name != "VCALENDAR" || len(comps[0].Components) != 1 {
+		t.Fatalf("Decode = %+v, want a VCALENDAR holding a VTODO", comps)
+	}
+	todo := comps[0].Components[0]
+
+	summary := todo.Get("SUMMARY")
+	if summary.Text() != "Milch, Brot und Eier" || summary.Params["LANGUAGE"] != "de" || summary.Line != 4 {
+		t.Errorf("SUMMARY = %q %v on line %d, want the unfolded text on line 4", summary.Text(), summary.Params, summary.Line)
+	}
+	if got := todo.Get("DESCRIPTION").Text(); got != "first\nsecond" {
+		t.Errorf("DESCRIPTION = %q, want two lines", got)
+	}
+	if got := todo.Get("X-LIST").Params["MEMBER"]; got != "mailto:a@example.com,mailto:b@example.com" {
+		t.Errorf("MEMBER = %q, want both quoted values", got)
+	}
+	due, dateOnly, err := todo.Get("DUE").Time()
+	if err != nil || dateOnly || due.Location().String() != "Europe/Berlin" || due.Hour() != 9 {
+		t.Errorf("DUE = %v, %v, %v; want 09:00 in Europe/Berlin", due, dateOnly, err)
+	}
+	if todo.Get("LOCATION") != nil {
+		t.Error("Get returned a property that is not there")
+	}
+}
+
+func TestPropertyTime(t *testing.T) {
+	tests := []struct {
+		prop     Property
+		want     string
+		dateOnly bool
+		wantErr  bool
+	}{
+		{Property{Name: "DUE", Value: "20260601T090000Z"}, "2026-06-01T09:00:00Z", false, false},
+		{Property{Name: "DUE", Value: "20260601T090000"}, "2026-06-01T09:00:00Z", false, false},
+		{Property{Name: "DUE", Value: "20260601T090000", Params: map[string]string{"TZID": "/America/New_York"}}, "2026-06-01T09:00:00-04:00", false, false},
+		{Property{Name: "DUE", Value: "20260601"}, "2026-06-01T00:00:00Z", true, false},
+		{Property{Name: "DUE", Value: "20260601", Params: map[string]string{"VALUE": "DATE"}}, "2026-06-01T00:00:00Z", true, false},
+		{Property{Name: "DUE", Value: "20260601T090000", Params: map[string]string{"TZID": "Mars/Olympus"}}, "", false, true},
+		{Property{Name: "DUE", Value: "2026-06-01"}, "", false, true},
+		{Property{Name: "DUE", Value: "20261301"}, "", true, true},
+	}
+	for _, tt := range tests {
+		got, dateOnly, err := tt.prop.Time()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Time(%s %v) = %v, want an error", tt.prop.Value, tt.prop.Params, got)
+			}
+			continue
+		}
+		if err != nil || got.Format(time.RFC3339) != tt.want || dateOnly != tt.dateOnly {
+			t.Errorf("Time(%s %v) = %s, %v, %v; want %s, %v", tt.prop.Value, tt.prop.Params, got.Format(time.RFC3339), dateOnly, err, tt.want, tt.dateOnly)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	deep := strings.Repeat("BEGIN:X\r\n", maxDepth+1)
+	tests := []struct {
+		name  string
+		input string
+		line  int
+		msg   string
+	}{
+		{"empty", "", 0, "no components"},
+		{"property outside component", "VERSION:2.0\r\n", 1, "outside of a component"},
+		{"missing END", "BEGIN:VCALENDAR\r\n", 0, "missing END:VCALENDAR"},
+		{"mismatched END", "BEGIN:VCALENDAR\r\nEND:VTODO\r\n", 2, "unexpected END:VTODO"},
+		{"continuation first", " folded\r\n", 1, "continuation line without a content line"},
+		{"no colon", "BEGIN:VCALENDAR\r\nSUMMARY\r\n", 2, "malformed content line"},
+		{"bad name", "BEGIN:VCALENDAR\r\nSUM_MARY:x\r\n", 2, "invalid property name"},
+		{"bad parameter", "BEGIN:VCALENDAR\r\nSUMMARY;=de:x\r\n", 2, "malformed parameter"},
+		{"unterminated quote", "BEGIN:VCALENDAR\r\nSUMMARY;X=\"de:x\r\n", 2, "unterminated quoted parameter"},
+		{"too deep", deep, maxDepth + 1, "nested too deeply"},
+		{"physical line too long", "BEGIN:VCALENDAR\r\nSUMMARY:" + strings.Repeat("x", maxLineBytes) + "\r\n", 2, "line too long"},
+		{"content line too long", "BEGIN:VCALENDAR\r\nSUMMARY:x\r\n" + strings.Repeat(" "+strings.Repeat("x", 1<<16)+"\r\n", maxLineBytes>>16), 2, "content line too long"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.input))
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Decode() = %v, want a SyntaxError", err)
+			}
+			if syntaxErr.Line != tt.line || !strings.Contains(syntaxErr.Msg, tt.msg) {
+				t.Fatalf("Decode() = %v, want line %d: %s", err, tt.line, tt.msg)
+			}
+		})
+	}
+}
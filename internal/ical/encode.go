@@ -0,0 +1,140 @@
+// Package ical reads and writes iCalendar (RFC 5545) data. Content lines
+// are folded at 75 octets and TEXT values are escaped as the RFC requires;
+// decoding unfolds and unescapes them again.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the longest content line allowed before folding,
+// excluding the line break
+const maxLineOctets = 75
+
+const (
+	dateTimeUTCLayout = "20060102T150405Z"
+	dateTimeLayout    = "20060102T150405"
+	dateLayout        = "20060102"
+)
+
+// Encoder writes iCalendar content lines to a stream. The first write error
+// is kept and returned by Flush; later writes are skipped.
+type Encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// NewEncoder creates an Encoder writing to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// Begin starts a component such as VCALENDAR or VTODO
+func (e *Encoder) Begin(component string) {
+	e.Property("BEGIN", component)
+}
+
+// End ends a component started with Begin
+func (e *Encoder) End(component string) {
+	e.Property("END", component)
+}
+
+// Property writes a property whose value is already in iCalendar form, such
+// as a date or an RRULE
+func (e *Encoder) Property(name, value string) {
+	e.writeLine(name + ":" + value)
+}
+
+// Text writes a property of value type TEXT, escaping the value
+func (e *Encoder) Text(name, value string) {
+	e.Property(name, EscapeText(value))
+}
+
+// DateTime writes a DATE-TIME property in UTC form
+func (e *Encoder) DateTime(name string, t time.Time) {
+	e.Property(name, t.UTC().Format(dateTimeUTCLayout))
+}
+
+// Flush writes any buffered data and returns the first error encountered
+func (e *Encoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	e.err = e.w.Flush()
+	return e.err
+}
+
+// writeLine writes a content line, folding it so that no physical line
+// exceeds maxLineOctets. Folds never split a UTF-8 sequence.
+func (e *Encoder) writeLine(line string) {
+	if e.err != nil {
+		return
+	}
+
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		e.write(line[:cut])
+		e.write("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts towards the limit
+		limit = maxLineOctets - 1
+	}
+	e.write(line)
+	e.write("\r\n")
+}
+
+func (e *Encoder) write(s string) {
+	if e.err == nil {
+		_, e.err = e.w.WriteString(s)
+	}
+}
+
+// textEscaper escapes TEXT values. Line breaks become \n; a bare CR is
+// dropped since TEXT cannot represent it.
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", "",
+)
+
+// EscapeText escapes a value of type TEXT
+func EscapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// UnescapeText reverses EscapeText. Unknown escapes are kept as the escaped
+// character.
+func UnescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i == len(s)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,135 @@
+package ical
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEncoderFoldsLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"short", "Buy milk"},
+		{"exactly one line", strings.Repeat("a", maxLineOctets-len("SUMMARY:"))},
+		{"one octet over", strings.Repeat("a", maxLineOctets-len("SUMMARY:")+1)},
+		{"several lines", strings.Repeat("abcdefghij", 30)},
+		{"two-byte runes", strings.Repeat("é", 100)},
+		{"three-byte runes", strings.Repeat("€", 100)},
+		{"four-byte runes", strings.Repeat("😀", 60)},
+		{"mixed", "a" + strings.Repeat("€😀é", 40)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewEncoder(&buf)
+			enc.Text("SUMMARY", tt.value)
+			if err := enc.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			out := buf.String()
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("output %q does not end with CRLF", out)
+			}
+			lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+			for i, line := range lines {
+				if len(line) > maxLineOctets {
+					t.Errorf("line %d is %d octets, want at most %d", i+1, len(line), maxLineOctets)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d %q splits a UTF-8 sequence", i+1, line)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d %q does not start with a space", i+1, line)
+				}
+				// Folds are only as early as the UTF-8 sequence requires
+				if i < len(lines)-1 && len(line) < maxLineOctets-3 {
+					t.Errorf("line %d is folded at %d octets", i+1, len(line))
+				}
+			}
+
+			comps, err := Decode(strings.NewReader("BEGIN:VTODO\r\n" + out + "END:VTODO\r\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := comps[0].Get("SUMMARY").Text(); got != tt.value {
+				t.Fatalf("decoded %q, want %q", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		text, escaped string
+	}{
+		{"plain", "plain"},
+		{`back\slash`, `back\\slash`},
+		{"a;b,c", `a\;b\,c`},
+		{"line\nbreak", `line\nbreak`},
+		{"crlf\r\nbreak", `crlf\nbreak`},
+		{"bare\rcr", "barecr"},
+		{`\n`, `\\n`},
+		{"colon: kept", "colon: kept"},
+	}
+	for _, tt := range tests {
+		if got := EscapeText(tt.text); got != tt.escaped {
+			t.Errorf("EscapeText(%q) = %q, want %q", tt.text, got, tt.escaped)
+		}
+	}
+}
+
+func TestUnescapeText(t *testing.T) {
+	tests := []struct {
+		escaped, text string
+	}{
+		{"plain", "plain"},
+		{`back\\slash`, `back\slash`},
+		{`a\;b\,c`, "a;b,c"},
+		{`line\nbreak`, "line\nbreak"},
+		{`line\Nbreak`, "line\nbreak"},
+		{`\\n`, `\n`},
+		{`unknown\x`, "unknownx"},
+		{`trailing\`, `trailing\`},
+	}
+	for _, tt := range tests {
+		if got := UnescapeText(tt.escaped); got != tt.text {
+			t.Errorf("UnescapeText(%q) = %q, want %q", tt.escaped, got, tt.text)
+		}
+	}
+}
+
+func TestEncoderDateTime(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	berlin := time.FixedZone("CEST", 2*60*60)
+	enc.DateTime("DUE", time.Date(2026, time.June, 1, 9, 30, 0, 0, berlin))
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "DUE:20260601T073000Z\r\n"; got != want {
+		t.Fatalf("DateTime wrote %q, want %q", got, want)
+	}
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestEncoderKeepsFirstError(t *testing.T) {
+	enc := NewEncoder(failingWriter{})
+	enc.Begin("VCALENDAR")
+	enc.Text("SUMMARY", strings.Repeat("x", 8192))
+	enc.End("VCALENDAR")
+	if err := enc.Flush(); err == nil || err.Error() != "disk full" {
+		t.Fatalf("Flush() = %v, want the write error", err)
+	}
+}
@@ -0,0 +1,126 @@
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"myapp/internal/model"
+)
+
+// ProductID identifies this application in the calendars it writes
+const ProductID = "-//MyApp//Todos//EN"
+
+// EncodeTodos writes todos as a VCALENDAR of VTODO components named name.
+// stamp is written as each component's DTSTAMP. Recurrence rules are not
+// written: the next occurrence of a recurring todo only exists once the
+// current one is completed, and appears in the feed then.
+func EncodeTodos(w io.Writer, name string, todos []*model.Todo, stamp time.Time) error {
+	enc := NewEncoder(w)
+	enc.Begin("VCALENDAR")
+	enc.Property("VERSION", "2.0")
+	enc.Text("PRODID", ProductID)
+	enc.Property("CALSCALE", "GREGORIAN")
+	enc.Text("X-WR-CALNAME", name)
+
+	for _, todo := range todos {
+		enc.Begin("VTODO")
+		enc.Text("UID", fmt.Sprintf("todo-%d@myapp", todo.ID))
+		enc.DateTime("DTSTAMP", stamp)
+		enc.DateTime("CREATED", todo.CreatedAt)
+		enc.DateTime("LAST-MODIFIED", todo.UpdatedAt)
+		enc.Property("SEQUENCE", fmt.Sprint(todo.Version-1))
+		enc.Text("SUMMARY", todo.Title)
+		if todo.Description != "" {
+			enc.Text("DESCRIPTION", todo.Description)
+		}
+		if todo.DueAt != nil {
+			enc.DateTime("DUE", *todo.DueAt)
+		}
+		if todo.Completed {
+			enc.Property("STATUS", "COMPLETED")
+			enc.Property("PERCENT-COMPLETE", "100")
+		} else {
+			enc.Property("STATUS", "NEEDS-ACTION")
+		}
+		enc.End("VTODO")
+	}
+
+	enc.End("VCALENDAR")
+	return enc.Flush()
+}
+
+// DecodeTodos reads the VTODO components of an iCalendar file as todos,
+// in file order. Other components, such as events, are ignored.
+//
+// A DUE with a TZID sets the todo's time zone. Dates and floating
+// date-times are taken as UTC. A recurring todo without DUE falls due at
+// its DTSTART.
+func DecodeTodos(r io.Reader) ([]model.TodoUpdateRequest, error) {
+	calendars, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		todos    []model.TodoUpdateRequest
+		firstErr error
+	)
+	for _, calendar := range calendars {
+		calendar.Walk(func(c *Component) {
+			if c.Name != "VTODO" || firstErr != nil {
+				return
+			}
+			todo, err := decodeTodo(c)
+			if err != nil {
+				firstErr = err
+				return
+			}
+			todos = append(todos, todo)
+		})
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return todos, nil
+}
+
+// decodeTodo maps a VTODO component to a todo
+func decodeTodo(c *Component) (model.TodoUpdateRequest, error) {
+	var todo model.TodoUpdateRequest
+	if p := c.Get("SUMMARY"); p != nil {
+		todo.Title = strings.TrimSpace(p.Text())
+	}
+	if p := c.Get("DESCRIPTION"); p != nil {
+		todo.Description = p.Text()
+	}
+	if p := c.Get("STATUS"); p != nil && strings.EqualFold(p.Value, "COMPLETED") {
+		todo.Completed = true
+	}
+	if c.Get("COMPLETED") != nil {
+		todo.Completed = true
+	}
+
+	rrule := c.Get("RRULE")
+	if rrule != nil {
+		todo.Recurrence = rrule.Value
+	}
+
+	due := c.Get("DUE")
+	if due == nil && rrule != nil {
+		due = c.Get("DTSTART")
+	}
+	if due != nil {
+		t, dateOnly, err := due.Time()
+		if err != nil {
+			return todo, &SyntaxError{Line: due.Line, Msg: err.Error()}
+		}
+		if tzid := due.Params["TZID"]; tzid != "" && !dateOnly {
+			todo.Timezone = t.Location().String()
+		}
+		t = t.UTC()
+		todo.DueAt = &t
+	}
+	return todo, nil
+}
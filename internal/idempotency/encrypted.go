@@ -0,0 +1,94 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errUndecryptable is returned when a stored body cannot be decrypted, such
+// as after the key changed
+var errUndecryptable = errors.New("idempotency: stored response cannot be decrypted")
+
+// EncryptedStore is a Store that encrypts response bodies with AES-GCM
+// before handing them to another Store, since they may hold access tokens
+// and other secrets. Headers are stored as is.
+type EncryptedStore struct {
+	next Store
+	aead cipher.AEAD
+}
+
+// NewEncryptedStore creates an EncryptedStore keeping records in next. The
+// encryption key is derived from secret.
+func NewEncryptedStore(next Store, secret []byte) (*EncryptedStore, error) {
+	key := sha256.Sum256(append([]byte("myapp idempotency:"), secret...))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedStore{next: next, aead: aead}, nil
+}
+
+// Begin implements Store. A response that cannot be decrypted, as after the
+// key changed, is forgotten and the caller acquires the key to process the
+// request anew.
+func (s *EncryptedStore) Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, error) {
+	rec, err := s.begin(ctx, key, fingerprint, ttl)
+	if errors.Is(err, errUndecryptable) {
+		if err := s.next.Release(ctx, key); err != nil {
+			return nil, err
+		}
+		rec, err = s.begin(ctx, key, fingerprint, ttl)
+	}
+	return rec, err
+}
+
+// begin calls Begin of the next Store and decrypts the record it returns
+func (s *EncryptedStore) begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, error) {
+	rec, err := s.next.Begin(ctx, key, fingerprint, ttl)
+	if err != nil || rec == nil {
+		return rec, err
+	}
+	if rec.Body, err = s.open(key, rec.Body); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Complete implements Store
+func (s *EncryptedStore) Complete(ctx context.Context, key string, resp Response) error {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	// The key is authenticated so that a body cannot be replayed for another
+	resp.Body = s.aead.Seal(nonce, nonce, resp.Body, []byte(key))
+	return s.next.Complete(ctx, key, resp)
+}
+
+// Release implements Store
+func (s *EncryptedStore) Release(ctx context.Context, key string) error {
+	return s.next.Release(ctx, key)
+}
+
+// open decrypts a body sealed by Complete
+func (s *EncryptedStore) open(key string, sealed []byte) ([]byte, error) {
+	size := s.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errUndecryptable
+	}
+	body, err := s.aead.Open(nil, sealed[:size], sealed[size:], []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUndecryptable, err)
+	}
+	return body, nil
+}
@@ -0,0 +1,89 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store drops expired records
+const sweepInterval = time.Minute
+
+// MemoryStore is an in-process Store, suitable for a single instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]*Record
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates a new MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]*Record),
+		now:     time.Now,
+	}
+}
+
+// Begin implements Store
+func (s *MemoryStore) Begin(_ context.Context, key, fingerprint string, ttl time.Duration) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	if rec, ok := s.records[key]; ok && now.Before(rec.ExpiresAt) {
+		found, err := check(rec, fingerprint)
+		if err != nil || found == nil {
+			return nil, err
+		}
+		replay := *found
+		return &replay, nil
+	}
+
+	s.records[key] = &Record{
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+	return nil, nil
+}
+
+// Complete implements Store
+func (s *MemoryStore) Complete(_ context.Context, key string, resp Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[key]; ok {
+		rec.Completed = true
+		rec.StatusCode = resp.StatusCode
+		rec.ContentType = resp.Header.Get("Content-Type")
+		rec.Header = resp.Header
+		rec.Body = resp.Body
+	}
+	return nil
+}
+
+// Release implements Store
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// sweep removes expired records at most once per sweepInterval
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, rec := range s.records {
+		if !now.Before(rec.ExpiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresStore is a Store backed by the idempotency_keys table, shared by
+// every instance of the API
+type PostgresStore struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewPostgresStore creates a new PostgresStore
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Begin implements Store
+func (s *PostgresStore) Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, error) {
+	var replay *Record
+
+	if err := s.sweep(ctx, time.Now()); err != nil {
+		return nil, err
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		rec := Record{
+			Key:         key,
+			Fingerprint: fingerprint,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(ttl),
+		}
+
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rec)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 1 {
+			return nil
+		}
+
+		var existing Record
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ?", key).First(&existing).Error; err != nil {
+			return err
+		}
+
+		// An expired record is taken over as if it did not exist
+		if !now.Before(existing.ExpiresAt) {
+			return tx.Model(&Record{}).Where("key = ?", key).Updates(map[string]interface{}{
+				"fingerprint":  fingerprint,
+				"completed":    false,
+				"status_code":  0,
+				"content_type": "",
+				"headers":      nil,
+				"body":         nil,
+				"created_at":   now,
+				"expires_at":   now.Add(ttl),
+			}).Error
+		}
+
+		found, err := check(&existing, fingerprint)
+		replay = found
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+// Complete implements Store
+func (s *PostgresStore) Complete(ctx context.Context, key string, resp Response) error {
+	header, err := json.Marshal(resp.Header)
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Model(&Record{}).Where("key = ?", key).Updates(map[string]interface{}{
+		"completed":    true,
+		"status_code":  resp.StatusCode,
+		"content_type": resp.Header.Get("Content-Type"),
+		"headers":      string(header),
+		"body":         resp.Body,
+	}).Error
+}
+
+// Release implements Store
+func (s *PostgresStore) Release(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("key = ?", key).Delete(&Record{}).Error
+}
+
+// sweep deletes expired records at most once per sweepInterval
+func (s *PostgresStore) sweep(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	due := now.Sub(s.lastSweep) >= sweepInterval
+	if due {
+		s.lastSweep = now
+	}
+	s.mu.Unlock()
+
+	if !due {
+		return nil
+	}
+	return s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&Record{}).Error
+}
@@ -0,0 +1,71 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrRequestInProgress is returned when a request with the same key is
+	// still being processed
+	ErrRequestInProgress = errors.New("request with this idempotency key is in progress")
+
+	// ErrFingerprintMismatch is returned when a key is reused for a request
+	// with a different payload
+	ErrFingerprintMismatch = errors.New("idempotency key reused with a different request")
+)
+
+// Record holds the stored state of an idempotent request
+type Record struct {
+	Key         string      `gorm:"primaryKey;size:320"`
+	Fingerprint string      `gorm:"size:64;not null"`
+	Completed   bool        `gorm:"not null;default:false"`
+	StatusCode  int         `gorm:"not null;default:0"`
+	ContentType string      `gorm:"size:255"`
+	Header      http.Header `gorm:"column:headers;serializer:json"`
+	Body        []byte      `gorm:""`
+	CreatedAt   time.Time   `gorm:"not null"`
+	ExpiresAt   time.Time   `gorm:"not null;index"`
+}
+
+// TableName sets the table name for Record
+func (Record) TableName() string {
+	return "idempotency_keys"
+}
+
+// Response is the first response produced for an idempotency key
+type Response struct {
+	StatusCode int
+	// Header holds the headers to replay, such as Content-Type, ETag and
+	// Location
+	Header http.Header
+	Body   []byte
+}
+
+// Store persists idempotency records
+type Store interface {
+	// Begin reserves key for a request with the given fingerprint. It returns
+	// (nil, nil) when the caller acquired the key and should process the
+	// request, or the completed record when the response should be replayed.
+	// It returns ErrRequestInProgress or ErrFingerprintMismatch otherwise.
+	Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, error)
+
+	// Complete stores the response for a key acquired with Begin
+	Complete(ctx context.Context, key string, resp Response) error
+
+	// Release forgets a key acquired with Begin so the request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// check decides what Begin should report for an existing, unexpired record
+func check(rec *Record, fingerprint string) (*Record, error) {
+	if rec.Fingerprint != fingerprint {
+		return nil, ErrFingerprintMismatch
+	}
+	if !rec.Completed {
+		return nil, ErrRequestInProgress
+	}
+	return rec, nil
+}
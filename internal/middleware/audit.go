@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+
+	"myapp/internal/audit"
+)
+
+// AuditRequest records the client IP, user agent and request ID in the
+// request context for audit entries written while handling the request. It
+// must run after the RequestID and ClientIP middleware.
+func AuditRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := audit.WithRequest(r.Context(), audit.Request{
+			IP:        GetClientIP(r),
+			UserAgent: r.UserAgent(),
+			RequestID: chimiddleware.GetReqID(r.Context()),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
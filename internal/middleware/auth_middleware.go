@@ -9,6 +9,7 @@ import (
 	"myapp/internal/model"
 	httputil "myapp/internal/pkg/http"
 	"myapp/internal/service"
+	"myapp/internal/tenant"
 )
 
 // contextKey is a custom type for context keys
@@ -16,7 +17,9 @@ type contextKey string
 
 const userKey contextKey = "user"
 
-// Auth is a middleware that checks for a valid JWT token in the Authorization header
+// Auth is a middleware that checks for a valid JWT token in the Authorization
+// header. The request context carries the token's user and, through package
+// tenant, the workspace the token was issued for.
 func Auth(authService service.AuthService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -42,19 +45,39 @@ func Auth(authService service.AuthService) func(http.Handler) http.Handler {
 			}
 
 			// Verify the token and get the user
-			user, err := authService.GetUserByToken(r.Context(), token)
+			user, workspaceID, err := authService.GetUserByToken(r.Context(), token)
 			if err != nil {
 				httputil.Error(w, http.StatusUnauthorized, "Invalid or expired token")
 				return
 			}
 
-			// Add the user to the request context
+			// Add the user and workspace to the request context
 			ctx := context.WithValue(r.Context(), userKey, user)
+			ctx = tenant.WithWorkspace(ctx, workspaceID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// RequireRole is a middleware that only lets users with the given role
+// through. It must run after Auth.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := r.Context().Value(userKey).(*model.User)
+			if !ok {
+				httputil.Error(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			if user.Role != role {
+				httputil.Error(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // GetUserIDFromContext retrieves the user ID from the request context
 func GetUserIDFromContext(r *http.Request) (uint, error) {
 	user := r.Context().Value(userKey)
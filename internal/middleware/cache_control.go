@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"time"
+)
+
+// PrivateCache is a middleware for authenticated routes. Responses to GET
+// and HEAD requests may be kept by the client but not by shared caches, and
+// must be revalidated before reuse; handlers that set ETag or Last-Modified
+// answer the revalidation with 304 Not Modified. Responses to other
+// requests must not be stored. As the response depends on the user and the
+// workspace of the bearer token, it varies with the Authorization header.
+// Handlers may still set their own Cache-Control.
+func PrivateCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			header.Set("Cache-Control", "private, no-cache")
+		} else {
+			header.Set("Cache-Control", "no-store")
+		}
+		header.Add("Vary", "Authorization")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CacheAssets is a middleware for static files that lets any cache keep
+// files with one of the given extensions, such as ".js", for maxAge. Other
+// files, such as HTML pages and API descriptions, must be revalidated, as
+// they change from one release to the next under the same URL.
+func CacheAssets(maxAge time.Duration, extensions ...string) func(http.Handler) http.Handler {
+	assetCacheControl := fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(extensions, path.Ext(r.URL.Path)) {
+				w.Header().Set("Cache-Control", assetCacheControl)
+			} else {
+				w.Header().Set("Cache-Control", "no-cache")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
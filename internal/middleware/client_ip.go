@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const clientIPKey contextKey = "clientIP"
+
+// ClientIP is a middleware that determines the IP address of the client
+// and stores it in the request context for GetClientIP. It is the address
+// of the peer, unless the peer is one of trustedProxies: then it is the last
+// address in X-Forwarded-For that is not a trusted proxy, or else the
+// X-Real-IP header. Forwarding headers of requests from anywhere else are
+// ignored, as clients can set them to anything. r.RemoteAddr is left as is.
+func ClientIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	trusted := func(addr netip.Addr) bool {
+		for _, prefix := range trustedProxies {
+			if prefix.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := peerIP(r)
+			if addr, err := netip.ParseAddr(ip); err == nil && trusted(addr) {
+				ip = forwardedIP(r.Header, trusted, ip)
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey, ip)))
+		})
+	}
+}
+
+// GetClientIP returns the client IP determined by ClientIP, or the peer
+// address if ClientIP did not run
+func GetClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey).(string); ok {
+		return ip
+	}
+	return peerIP(r)
+}
+
+// ParseTrustedProxies parses IP addresses, such as 10.0.0.1, and CIDR
+// ranges, such as 10.0.0.0/8
+func ParseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// peerIP returns the address of the connection's peer without the port
+func peerIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedIP returns the client address reported by trusted proxies.
+// X-Forwarded-For is walked from the proxy nearest to us outwards, since
+// every proxy appends the address it received the request from; the first
+// untrusted address is the client. fallback is returned if the headers name
+// no valid address.
+func forwardedIP(header http.Header, trusted func(netip.Addr) bool, fallback string) string {
+	var hops []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	if len(hops) > 0 {
+		ip := fallback
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			ip = addr.Unmap().String()
+			if !trusted(addr) {
+				break
+			}
+		}
+		return ip
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String()
+	}
+	return fallback
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		peer      string
+		forwarded []string
+		realIP    string
+		want      string
+	}{
+		{"direct client", "203.0.113.7:4321", nil, "", "203.0.113.7"},
+		{"spoofed header from untrusted peer", "203.0.113.7:4321", []string{"1.2.3.4"}, "5.6.7.8", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.5:80", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"client prepends a spoofed hop", "10.0.0.5:80", []string{"1.2.3.4, 198.51.100.1"}, "", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.5:80", []string{"198.51.100.1, 192.168.1.1", "10.1.1.1"}, "", "198.51.100.1"},
+		{"only trusted hops", "10.0.0.5:80", []string{"10.1.1.1"}, "", "10.1.1.1"},
+		{"malformed hop", "10.0.0.5:80", []string{"garbage, 10.1.1.1"}, "", "10.1.1.1"},
+		{"X-Real-IP from trusted proxy", "192.168.1.1:80", nil, "198.51.100.2", "198.51.100.2"},
+		{"trusted proxy without headers", "10.0.0.5:80", nil, "", "10.0.0.5"},
+		{"IPv6 peer", "[2001:db8::1]:443", []string{"1.2.3.4"}, "", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := ClientIP(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = GetClientIP(r)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.peer
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("client IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesRejectsInvalid(t *testing.T) {
+	if _, err := ParseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatal("accepted an invalid range")
+	}
+}
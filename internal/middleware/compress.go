@@ -0,0 +1,340 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// brotliLevel trades compression ratio for speed, since responses are
+// compressed as they are served
+const brotliLevel = 4
+
+var (
+	gzipWriters = sync.Pool{New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return w
+	}}
+	brotliWriters = sync.Pool{New: func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, brotliLevel)
+	}}
+)
+
+// Compress is a middleware that compresses responses with brotli or gzip,
+// whichever the client ranks higher in Accept-Encoding, preferring brotli
+// on a tie. Only responses whose media type matches one of types, such as
+// application/json or text/*, and whose body is at least minSize bytes are
+// compressed; Vary: Accept-Encoding is set on all responses of those types.
+// Responses that are already encoded and partial responses are sent as is,
+// as are WebSocket upgrades. Compressed responses drop Accept-Ranges, and
+// the coding is appended to their entity tags, as in "7-gzip", giving them
+// strong tags of their own. Handlers see If-Match and If-None-Match with
+// the coding removed, so they compare against the tags they set.
+func Compress(minSize int, types []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(types) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			encoding := ""
+			if r.Method != http.MethodHead {
+				encoding = negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			}
+			r, revalidated := withDecodedETags(r)
+
+			// Not deferred: after a panic nothing buffered is sent, so that
+			// Recoverer can still respond
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, revalidated: revalidated, minSize: minSize, types: types}
+			next.ServeHTTP(cw, r)
+			cw.close()
+		})
+	}
+}
+
+// withDecodedETags returns r with the codings encodedETag appends removed
+// from the entity tags of its If-Match and If-None-Match headers, and the
+// coding removed from If-None-Match, if any
+func withDecodedETags(r *http.Request) (*http.Request, string) {
+	ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+	if ifMatch == "" && ifNoneMatch == "" {
+		return r, ""
+	}
+	r = r.Clone(r.Context())
+	encoding := ""
+	if ifMatch != "" {
+		ifMatch, _ = decodeETags(ifMatch)
+		r.Header.Set("If-Match", ifMatch)
+	}
+	if ifNoneMatch != "" {
+		ifNoneMatch, encoding = decodeETags(ifNoneMatch)
+		r.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	return r, encoding
+}
+
+// encodedETag appends encoding to the opaque part of etag
+func encodedETag(etag, encoding string) string {
+	if !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// decodeETags removes the codings encodedETag appends from the entity tags
+// of an If-Match or If-None-Match header value, returning the new value
+// and the last coding removed
+func decodeETags(header string) (string, string) {
+	tags := strings.Split(header, ",")
+	encoding := ""
+	for i, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		for _, coding := range []string{"br", "gzip"} {
+			if opaque, ok := strings.CutSuffix(tag, "-"+coding+`"`); ok {
+				tag, encoding = opaque+`"`, coding
+				break
+			}
+		}
+		tags[i] = tag
+	}
+	return strings.Join(tags, ", "), encoding
+}
+
+// negotiateEncoding picks br or gzip from an Accept-Encoding header, or
+// returns "" if the client accepts neither
+func negotiateEncoding(header string) string {
+	quality := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		quality[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range []string{"br", "gzip"} {
+		q, ok := quality[coding]
+		if !ok {
+			q = quality["*"]
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether the media type of contentType matches one
+// of types
+func compressible(contentType string, types []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range types {
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter buffers the start of a response until it knows whether the
+// response is compressed, then passes it on through an encoder or as is
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	// revalidated is the coding of the entity tag in If-None-Match, if any
+	revalidated string
+	minSize     int
+	types       []string
+
+	status      int
+	wroteHeader bool
+	committed   bool
+	buf         []byte
+	encoder     io.WriteCloser
+}
+
+// WriteHeader implements http.ResponseWriter. The status is held back
+// with the start of the body.
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	if status < http.StatusOK && status != http.StatusSwitchingProtocols {
+		// Informational responses, such as 103 Early Hints, precede the
+		// final one
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status, cw.wroteHeader = status, true
+
+	header := cw.Header()
+	// A client revalidating the compressed representation it holds keeps
+	// its tag if it would be served that representation again
+	if status == http.StatusNotModified && cw.revalidated != "" && cw.revalidated == cw.encoding {
+		if etag := header.Get("ETag"); etag != "" {
+			header.Set("ETag", encodedETag(etag, cw.encoding))
+		}
+	}
+	if !compressible(header.Get("Content-Type"), cw.types) {
+		cw.commit(false)
+		return
+	}
+	header.Add("Vary", "Accept-Encoding")
+
+	eligible := cw.encoding != "" && status != http.StatusNoContent && status != http.StatusPartialContent &&
+		status != http.StatusNotModified && header.Get("Content-Encoding") == ""
+	if !eligible {
+		cw.commit(false)
+		return
+	}
+	if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil {
+		cw.commit(length >= cw.minSize)
+	}
+}
+
+// Write implements http.ResponseWriter
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.committed {
+		if cw.encoder != nil {
+			return cw.encoder.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.flushBuffer(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush implements http.Flusher. A response still being buffered is
+// compressed only if it has reached the minimum size.
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.committed {
+		if err := cw.flushBuffer(len(cw.buf) >= cw.minSize); err != nil {
+			return
+		}
+	}
+	if flusher, ok := cw.encoder.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			return
+		}
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// commit sends the held back status and headers, starting an encoder if
+// compress is set
+func (cw *compressWriter) commit(compress bool) {
+	cw.committed = true
+	if compress {
+		header := cw.Header()
+		header.Del("Content-Length")
+		// Ranges refer to the uncompressed bytes, which are served as is
+		header.Del("Accept-Ranges")
+		header.Set("Content-Encoding", cw.encoding)
+		if etag := header.Get("ETag"); etag != "" {
+			header.Set("ETag", encodedETag(etag, cw.encoding))
+		}
+		cw.encoder = newEncoder(cw.encoding, cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+// flushBuffer commits the response and writes the buffered body
+func (cw *compressWriter) flushBuffer(compress bool) error {
+	cw.commit(compress)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close sends a response still being buffered, too small to compress, and
+// finishes the encoder
+func (cw *compressWriter) close() {
+	if cw.wroteHeader && !cw.committed {
+		_ = cw.flushBuffer(false)
+	}
+	if cw.encoder != nil {
+		_ = cw.encoder.Close()
+		releaseEncoder(cw.encoder)
+		cw.encoder = nil
+	}
+}
+
+// newEncoder takes a pooled encoder for encoding writing to w
+func newEncoder(encoding string, w io.Writer) io.WriteCloser {
+	if encoding == "br" {
+		bw := brotliWriters.Get().(*brotli.Writer)
+		bw.Reset(w)
+		return bw
+	}
+	gw := gzipWriters.Get().(*gzip.Writer)
+	gw.Reset(w)
+	return gw
+}
+
+// releaseEncoder returns a closed encoder to its pool
+func releaseEncoder(encoder io.WriteCloser) {
+	switch e := encoder.(type) {
+	case *brotli.Writer:
+		e.Reset(io.Discard)
+		brotliWriters.Put(e)
+	case *gzip.Writer:
+		e.Reset(io.Discard)
+		gzipWriters.Put(e)
+	}
+}
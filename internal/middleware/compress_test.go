@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// taggedHandler serves a JSON body of size bytes with the entity tag "7",
+// answering a matching If-None-Match with 304 Not Modified
+func taggedHandler(size int, seen *http.Header) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*seen = r.Header.Clone()
+		w.Header().Set("ETag", `"7"`)
+		if r.Header.Get("If-None-Match") == `"7"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`"` + strings.Repeat("x", size) + `"`))
+	})
+}
+
+func TestCompressETags(t *testing.T) {
+	tests := []struct {
+		name           string
+		size           int
+		acceptEncoding string
+		header, value  string
+		wantSeen       string
+		wantStatus     int
+		wantETag       string
+	}{
+		{"compressed", 2048, "gzip", "", "", "", http.StatusOK, `"7-gzip"`},
+		{"brotli", 2048, "br", "", "", "", http.StatusOK, `"7-br"`},
+		{"too small to compress", 10, "gzip", "", "", "", http.StatusOK, `"7"`},
+		{"not accepted", 2048, "identity", "", "", "", http.StatusOK, `"7"`},
+		{"If-Match with coding", 2048, "gzip", "If-Match", `"7-gzip"`, `"7"`, http.StatusOK, `"7-gzip"`},
+		{"If-Match list", 2048, "gzip", "If-Match", `"6-br", "7-gzip"`, `"6", "7"`, http.StatusOK, `"7-gzip"`},
+		{"weak If-Match", 2048, "gzip", "If-Match", `W/"7-gzip"`, `W/"7"`, http.StatusOK, `"7-gzip"`},
+		{"revalidated compressed", 2048, "gzip", "If-None-Match", `"7-gzip"`, `"7"`, http.StatusNotModified, `"7-gzip"`},
+		{"revalidated uncompressed", 2048, "gzip", "If-None-Match", `"7"`, `"7"`, http.StatusNotModified, `"7"`},
+		{"revalidated with another coding", 2048, "br", "If-None-Match", `"7-gzip"`, `"7"`, http.StatusNotModified, `"7"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen http.Header
+			h := Compress(1024, []string{"application/json"})(taggedHandler(tt.size, &seen))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if tt.header != "" && seen.Get(tt.header) != tt.wantSeen {
+				t.Errorf("handler saw %s: %s, want %s", tt.header, seen.Get(tt.header), tt.wantSeen)
+			}
+			if rec.Code != tt.wantStatus || rec.Header().Get("ETag") != tt.wantETag {
+				t.Errorf("response = %d with ETag %s, want %d with %s", rec.Code, rec.Header().Get("ETag"), tt.wantStatus, tt.wantETag)
+			}
+		})
+	}
+}
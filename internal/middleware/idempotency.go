@@ -0,0 +1,238 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"myapp/internal/idempotency"
+	"myapp/internal/pkg/response"
+	"myapp/internal/tenant"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header carrying the client's key
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is set on responses replayed from the store
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// maxIdempotencyKeyLength bounds the accepted key length
+	maxIdempotencyKeyLength = 255
+
+	// spoolMemoryBytes is how much of a request body is kept in memory while
+	// it is fingerprinted; the rest goes to a temporary file
+	spoolMemoryBytes = 1 << 20
+)
+
+// replayedHeaders are the response headers stored and replayed with the body
+var replayedHeaders = []string{"Content-Type", "ETag", "Last-Modified", "Location"}
+
+// Idempotency is a middleware that makes POST requests carrying an
+// Idempotency-Key header safe to retry. The first response for a key is
+// stored for ttl and replayed to later requests with the same key and
+// payload. A concurrent request with the same key gets 409 and a request
+// reusing the key with a different payload gets 422. Keys are scoped to the
+// authenticated user when there is one, and to the client IP otherwise. As
+// the stored responses are replayed to whoever presents the key, it should
+// not be applied to routes that issue tokens.
+//
+// The whole body is read and fingerprinted before the request is handled,
+// so bodies of requests with a key are limited to maxBody bytes; larger
+// ones get 413.
+func Idempotency(store idempotency.Store, ttl time.Duration, maxBody int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				response.NewServiceError(http.StatusBadRequest, "INVALID_IDEMPOTENCY_KEY",
+					fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength)).Write(w)
+				return
+			}
+
+			// Fingerprint the whole body and hand the same bytes on
+			body, sum, err := spoolBody(r, maxBody)
+			switch {
+			case errors.Is(err, errBodyTooLarge):
+				response.NewServiceError(http.StatusRequestEntityTooLarge, "REQUEST_TOO_LARGE",
+					fmt.Sprintf("Requests with an Idempotency-Key must not exceed %d bytes", maxBody)).Write(w)
+				return
+			case err != nil:
+				response.NewServiceError(http.StatusBadRequest, "INVALID_REQUEST", "Failed to read request body").Write(w)
+				return
+			}
+			defer body.Close()
+			r.Body = body
+
+			scopedKey := idempotencyScope(r) + ":" + key
+			rec, err := store.Begin(r.Context(), scopedKey, sum, ttl)
+			switch {
+			case errors.Is(err, idempotency.ErrRequestInProgress):
+				response.NewServiceError(http.StatusConflict, "IDEMPOTENCY_CONFLICT",
+					"A request with this Idempotency-Key is already in progress").Write(w)
+				return
+			case errors.Is(err, idempotency.ErrFingerprintMismatch):
+				response.NewServiceError(http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED",
+					"Idempotency-Key was already used with a different request").Write(w)
+				return
+			case err != nil:
+				response.NewServiceError(http.StatusInternalServerError, "INTERNAL_ERROR", "Internal server error").Write(w)
+				return
+			case rec != nil:
+				for _, name := range replayedHeaders {
+					if value := rec.Header.Get(name); value != "" {
+						w.Header().Set(name, value)
+					}
+				}
+				if rec.Header == nil && rec.ContentType != "" {
+					// Stored before headers were kept
+					w.Header().Set("Content-Type", rec.ContentType)
+				}
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(rec.StatusCode)
+				_, _ = w.Write(rec.Body)
+				return
+			}
+
+			// Store updates must outlive a client that disconnects mid-request
+			storeCtx := context.WithoutCancel(r.Context())
+
+			rw := &recordingWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			completed := false
+			defer func() {
+				// Free the key unless a response was stored so the client may retry
+				if !completed {
+					_ = store.Release(storeCtx, scopedKey)
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+
+			// Server errors are not cached so that a retry can succeed
+			if rw.statusCode >= http.StatusInternalServerError {
+				return
+			}
+			completed = true
+			header := make(http.Header)
+			for _, name := range replayedHeaders {
+				if value := rw.Header().Get(name); value != "" {
+					header.Set(name, value)
+				}
+			}
+			_ = store.Complete(storeCtx, scopedKey, idempotency.Response{
+				StatusCode: rw.statusCode,
+				Header:     header,
+				Body:       rw.body.Bytes(),
+			})
+		})
+	}
+}
+
+// idempotencyScope namespaces keys per user and workspace, or per client IP
+// for anonymous requests, so clients cannot collide and responses are never
+// replayed into another workspace
+func idempotencyScope(r *http.Request) string {
+	if userID, err := GetUserIDFromContext(r); err == nil {
+		workspaceID, _ := tenant.WorkspaceID(r.Context())
+		return fmt.Sprintf("user:%d:workspace:%d", userID, workspaceID)
+	}
+	return "anonymous:" + GetClientIP(r)
+}
+
+// errBodyTooLarge is returned by spoolBody for bodies over the limit
+var errBodyTooLarge = errors.New("request body too large")
+
+// spoolBody reads the whole body of r, up to limit bytes, and returns a
+// body replaying it along with the request's fingerprint, which identifies
+// it by method, path and body. The start of the body is kept in memory and
+// the rest in a temporary file, removed when the returned body is closed.
+func spoolBody(r *http.Request, limit int64) (io.ReadCloser, string, error) {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+
+	src := io.TeeReader(io.LimitReader(r.Body, limit+1), h)
+	head, err := io.ReadAll(io.LimitReader(src, spoolMemoryBytes))
+	if err != nil {
+		return nil, "", err
+	}
+	spooled := &spooledBody{Reader: bytes.NewReader(head)}
+
+	if len(head) == spoolMemoryBytes {
+		file, err := os.CreateTemp("", "idempotency-*")
+		if err != nil {
+			return nil, "", err
+		}
+		spooled.file = file
+		n, err := io.Copy(file, src)
+		if err == nil && int64(len(head))+n > limit {
+			err = errBodyTooLarge
+		}
+		if err == nil {
+			_, err = file.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			spooled.Close()
+			return nil, "", err
+		}
+		spooled.Reader = io.MultiReader(bytes.NewReader(head), file)
+	} else if int64(len(head)) > limit {
+		return nil, "", errBodyTooLarge
+	}
+
+	return spooled, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// spooledBody replays a request body read by spoolBody
+type spooledBody struct {
+	io.Reader
+	file *os.File
+}
+
+// Close removes the temporary file, if any
+func (b *spooledBody) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	file := b.file
+	b.file = nil
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// recordingWriter passes a response through while keeping a copy of it
+type recordingWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+// WriteHeader captures the status code
+func (rw *recordingWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.statusCode = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Write copies the body before passing it on
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"myapp/internal/idempotency"
+)
+
+func idempotentHandler(t *testing.T, store idempotency.Store, maxBody int64) (http.Handler, *int) {
+	t.Helper()
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"1"`)
+		w.Header().Set("Location", "/todos/1")
+		w.Header().Set("X-Not-Replayed", "yes")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"size":`+strings.Repeat("1", len(body)%7+1)+`}`)
+	})
+	return Idempotency(store, time.Hour, maxBody)(next), &calls
+}
+
+func postWithKey(h http.Handler, key string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyReplaysStoredHeaders(t *testing.T) {
+	h, calls := idempotentHandler(t, idempotency.NewMemoryStore(), 1<<20)
+
+	first := postWithKey(h, "k", []byte(`{"title":"a"}`))
+	replay := postWithKey(h, "k", []byte(`{"title":"a"}`))
+
+	if *calls != 1 {
+		t.Fatalf("handler called %d times, want 1", *calls)
+	}
+	if replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %d %q, want %d %q", replay.Code, replay.Body, first.Code, first.Body)
+	}
+	for _, name := range []string{"Content-Type", "ETag", "Location"} {
+		if got, want := replay.Header().Get(name), first.Header().Get(name); got != want {
+			t.Errorf("replayed %s = %q, want %q", name, got, want)
+		}
+	}
+	if replay.Header().Get("X-Not-Replayed") != "" {
+		t.Error("replay carries a header that is not stored")
+	}
+	if replay.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Error("replay is not marked as replayed")
+	}
+}
+
+func TestIdempotencyFingerprintsWholeBody(t *testing.T) {
+	h, calls := idempotentHandler(t, idempotency.NewMemoryStore(), 8<<20)
+
+	// The bodies differ only past the part kept in memory
+	a := bytes.Repeat([]byte("x"), 3*spoolMemoryBytes)
+	b := bytes.Clone(a)
+	b[len(b)-1] = 'y'
+
+	if rec := postWithKey(h, "k", a); rec.Code != http.StatusCreated {
+		t.Fatalf("first request = %d", rec.Code)
+	}
+	if rec := postWithKey(h, "k", b); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("request with a different body = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	if rec := postWithKey(h, "k", a); rec.Code != http.StatusCreated || *calls != 1 {
+		t.Fatalf("retry = %d after %d calls, want a replay", rec.Code, *calls)
+	}
+}
+
+func TestIdempotencyRejectsBodiesOverLimit(t *testing.T) {
+	h, calls := idempotentHandler(t, idempotency.NewMemoryStore(), 2*spoolMemoryBytes)
+
+	if rec := postWithKey(h, "k", make([]byte, 2*spoolMemoryBytes)); rec.Code != http.StatusCreated {
+		t.Fatalf("body at the limit = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if rec := postWithKey(h, "other", make([]byte, 2*spoolMemoryBytes+1)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("body over the limit = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	if *calls != 1 {
+		t.Fatalf("handler called %d times, want 1", *calls)
+	}
+}
+
+func TestIdempotencyEncryptsStoredBodies(t *testing.T) {
+	memory := idempotency.NewMemoryStore()
+	store, err := idempotency.NewEncryptedStore(memory, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _ := idempotentHandler(t, store, 1<<20)
+
+	first := postWithKey(h, "k", []byte(`{}`))
+	replay := postWithKey(h, "k", []byte(`{}`))
+	if replay.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %q, want %q", replay.Body, first.Body)
+	}
+
+	// Read the stored record straight from the inner store
+	req := httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(`{}`))
+	_, sum, err := spoolBody(req, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := memory.Begin(context.Background(), "anonymous:"+GetClientIP(req)+":k", sum, time.Hour)
+	if err != nil || rec == nil {
+		t.Fatalf("stored record: %v, %v", rec, err)
+	}
+	if bytes.Contains(rec.Body, first.Body.Bytes()) {
+		t.Fatal("response body is stored in plain text")
+	}
+
+}
+
+func TestIdempotencyReexecutesAfterKeyChange(t *testing.T) {
+	memory := idempotency.NewMemoryStore()
+	store, err := idempotency.NewEncryptedStore(memory, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _ := idempotentHandler(t, store, 1<<20)
+	postWithKey(h, "k", []byte(`{}`))
+
+	rotated, err := idempotency.NewEncryptedStore(memory, []byte("other secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, calls := idempotentHandler(t, rotated, 1<<20)
+	rec := postWithKey(h, "k", []byte(`{}`))
+	if rec.Code != http.StatusCreated || *calls != 1 {
+		t.Fatalf("request after the key changed = %d with %d calls, want it handled anew", rec.Code, *calls)
+	}
+	replay := postWithKey(h, "k", []byte(`{}`))
+	if *calls != 1 || replay.Body.String() != rec.Body.String() {
+		t.Fatalf("retry = %q with %d calls, want %q replayed", replay.Body, *calls, rec.Body)
+	}
+}
+
+func TestIdempotencyScopesAnonymousKeysByClientIP(t *testing.T) {
+	h, calls := idempotentHandler(t, idempotency.NewMemoryStore(), 1<<20)
+	for _, addr := range []string{"192.0.2.1:1234", "192.0.2.2:1234", "192.0.2.1:5678"} {
+		req := httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(`{}`))
+		req.RemoteAddr = addr
+		req.Header.Set(IdempotencyKeyHeader, "k")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if *calls != 2 {
+		t.Fatalf("handler called %d times, want once per client IP", *calls)
+	}
+}
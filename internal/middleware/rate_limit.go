@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"myapp/internal/pkg/response"
+	"myapp/internal/ratelimit"
+)
+
+// RateLimit is a middleware that refuses requests beyond the limiter's
+// limit with 429 Too Many Requests. Authenticated requests are counted per
+// user and anonymous ones per client IP, so it should run after Auth and
+// ClientIP.
+func RateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result := limiter.Allow(rateLimitKey(r))
+			if result.Limit > 0 {
+				w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+				w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			}
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+				response.NewServiceError(http.StatusTooManyRequests, "RATE_LIMITED",
+					"Too many requests, please retry later").Write(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey identifies the client a request is counted against
+func rateLimitKey(r *http.Request) string {
+	if userID, err := GetUserIDFromContext(r); err == nil {
+		return fmt.Sprintf("user:%d", userID)
+	}
+	return "ip:" + GetClientIP(r)
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"myapp/internal/ratelimit"
+)
+
+func TestRateLimitCountsClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := ratelimit.NewLimiter(ratelimit.Limit{Requests: 1, Window: time.Hour})
+	h := ClientIP(proxies)(RateLimit(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	request := func(peer, forwarded string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = peer
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// A direct client cannot get a fresh bucket by forging the header
+	if code := request("203.0.113.7:1000", ""); code != http.StatusOK {
+		t.Fatalf("first request = %d, want 200", code)
+	}
+	if code := request("203.0.113.7:1001", "198.51.100.9"); code != http.StatusTooManyRequests {
+		t.Fatalf("request with a forged X-Forwarded-For = %d, want 429", code)
+	}
+
+	// Clients behind a trusted proxy are counted separately, not as the proxy
+	if code := request("10.0.0.5:80", "198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("first client behind the proxy = %d, want 200", code)
+	}
+	if code := request("10.0.0.5:80", "198.51.100.2"); code != http.StatusOK {
+		t.Fatalf("second client behind the proxy = %d, want 200", code)
+	}
+	if code := request("10.0.0.5:80", "198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("repeated client behind the proxy = %d, want 429", code)
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"myapp/internal/database"
+	"myapp/internal/pkg/jwt"
+)
+
+// ReadYourWrites is a middleware that gives each request a database session,
+// so that its reads follow its own writes to the primary instead of a read
+// replica that may lag behind. A user whose request writes also keeps
+// reading from the primary for the stickiness window from the time of the
+// write, so that even the requests they send before this one's response
+// arrives see it. The user is taken from the bearer token rather than from
+// Auth, so that it should run before Auth and the workspace lookups of Auth
+// see the user's own writes.
+func ReadYourWrites(stickiness *database.Stickiness, tokens jwt.TokenService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := stickinessKey(r, tokens)
+			next.ServeHTTP(w, r.WithContext(stickiness.Begin(r.Context(), key)))
+		})
+	}
+}
+
+// stickinessKey identifies the user of a request by its access token. It is
+// empty for requests without a valid one; Auth rejects those that need it.
+func stickinessKey(r *http.Request, tokens jwt.TokenService) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	claims, err := tokens.ValidateToken(token)
+	if err != nil || claims.Type != jwt.AccessToken {
+		return ""
+	}
+	return fmt.Sprintf("user:%d", claims.UserID)
+}
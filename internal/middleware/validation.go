@@ -1,20 +1,26 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
-	"reflect"
 	"strings"
 
-	httputil "myapp/internal/pkg/http"
-
-	"github.com/go-playground/validator/v10"
+	"myapp/internal/pkg/response"
+	"myapp/internal/validation"
 )
 
+// DefaultMaxBodyBytes is the request body limit applied by Bind
+const DefaultMaxBodyBytes int64 = 1 << 20
+
 var (
-	validate           = validator.New()
 	ErrNoValidatedData = errors.New("no validated data found in context")
+
+	// errTrailingData is returned when the body holds more than one JSON value
+	errTrailingData = errors.New("request body must contain a single JSON object")
 )
 
 // validatedKey is a custom type for the context key
@@ -22,55 +28,89 @@ type validatedKey string
 
 const validatedContextKey validatedKey = "validated"
 
-// ValidateRequest validates a request body against a struct
-func ValidateRequest(next http.HandlerFunc, v interface{}) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if err := json.NewDecoder(r.Body).Decode(v); err != nil {
-			httputil.Error(w, http.StatusBadRequest, "Invalid request body")
-			return
-		}
-
-		if err := validate.Struct(v); err != nil {
-			if validationErrors, ok := err.(validator.ValidationErrors); ok {
-				var errorMessages []string
-				for _, e := range validationErrors {
-					errorMessages = append(errorMessages, formatValidationError(e))
+// Bind returns a middleware that decodes the JSON request body into a T,
+// validates it and stores the result in the request context, where it can
+// be retrieved with GetValidated. Bodies larger than DefaultMaxBodyBytes and
+// bodies containing unknown fields are rejected.
+func Bind[T any](validator validation.Validator) func(http.Handler) http.Handler {
+	return BindWithLimit[T](validator, DefaultMaxBodyBytes)
+}
+
+// BindWithLimit is like Bind but with a custom body size limit in bytes
+func BindWithLimit[T any](validator validation.Validator, maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req := new(T)
+			if err := decodeJSON(w, r, req, maxBytes); err != nil {
+				writeDecodeError(w, err)
+				return
+			}
+
+			if err := validator.Validate(r.Context(), req); err != nil {
+				var errs validation.ValidationErrors
+				if errors.As(err, &errs) {
+					response.NewValidationError(errs).Write(w)
+					return
 				}
-				httputil.Error(w, http.StatusBadRequest, strings.Join(errorMessages, ", "))
+				response.NewServiceError(http.StatusBadRequest, "INVALID_REQUEST", "Invalid request").Write(w)
 				return
 			}
-			httputil.Error(w, http.StatusBadRequest, "Invalid request")
-			return
-		}
 
-		next.ServeHTTP(w, r)
+			ctx := context.WithValue(r.Context(), validatedContextKey, req)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
 	}
 }
 
-// formatValidationError formats a validation error into a human-readable message
-func formatValidationError(err validator.FieldError) string {
-	switch err.Tag() {
-	case "required":
-		return err.Field() + " is required"
-	case "email":
-		return err.Field() + " must be a valid email"
-	case "min":
-		return err.Field() + " must be at least " + err.Param() + " characters long"
-	case "max":
-		return err.Field() + " must be at most " + err.Param() + " characters long"
-	default:
-		return err.Field() + " is invalid"
+// GetValidated returns the request value stored by Bind
+func GetValidated[T any](r *http.Request) (*T, error) {
+	req, ok := r.Context().Value(validatedContextKey).(*T)
+	if !ok {
+		return nil, ErrNoValidatedData
 	}
+	return req, nil
 }
 
-// GetValidated returns the validated struct from the context
-func GetValidated(r *http.Request, s interface{}) error {
-	val := r.Context().Value(validatedContextKey)
-	if val == nil {
-		return ErrNoValidatedData
-	}
+// decodeJSON strictly decodes a single JSON value from the request body
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}, maxBytes int64) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	dec.DisallowUnknownFields()
 
-	// Copy the validated data to the provided struct
-	reflect.ValueOf(s).Elem().Set(reflect.ValueOf(val).Elem())
+	if err := dec.Decode(dst); err != nil {
+		return err
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return errTrailingData
+	}
 	return nil
 }
+
+// writeDecodeError maps a JSON decoding failure to an API error response
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var (
+		maxBytesErr *http.MaxBytesError
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		response.NewServiceError(http.StatusRequestEntityTooLarge, "REQUEST_TOO_LARGE",
+			fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit)).Write(w)
+	case errors.Is(err, errTrailingData):
+		response.NewServiceError(http.StatusBadRequest, "INVALID_REQUEST", "Request body must contain a single JSON object").Write(w)
+	case errors.Is(err, io.EOF):
+		response.NewServiceError(http.StatusBadRequest, "INVALID_REQUEST", "Request body must not be empty").Write(w)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		response.NewServiceError(http.StatusBadRequest, "INVALID_REQUEST", "Request body contains malformed JSON").Write(w)
+	case errors.As(err, &typeErr):
+		response.NewServiceError(http.StatusBadRequest, "INVALID_REQUEST",
+			fmt.Sprintf("Field %q has an invalid type", typeErr.Field)).Write(w)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		response.NewServiceError(http.StatusBadRequest, "UNKNOWN_FIELD",
+			fmt.Sprintf("Request body contains unknown field %s", field)).Write(w)
+	default:
+		response.NewServiceError(http.StatusBadRequest, "INVALID_REQUEST", err.Error()).Write(w)
+	}
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Attachment is the metadata of a file attached to a todo. The file itself
+// is kept in blob storage under StorageKey.
+type Attachment struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	TodoID      uint      `json:"todo_id" gorm:"not null;index"`
+	UserID      uint      `json:"user_id" gorm:"not null"`
+	Filename    string    `json:"filename" gorm:"not null"`
+	ContentType string    `json:"content_type" gorm:"not null"`
+	Size        int64     `json:"size" gorm:"not null"`
+	SHA256      string    `json:"sha256" gorm:"column:sha256;not null"`
+	StorageKey  string    `json:"-" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AttachmentURL is a short-lived link that downloads an attachment without
+// authentication
+type AttachmentURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,202 @@
+package model
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Audited actions
+const (
+	AuditLoginSucceeded   = "auth.login_succeeded"
+	AuditLoginFailed      = "auth.login_failed"
+	AuditUserRegistered   = "auth.registered"
+	AuditTokenRefreshed   = "auth.token_refreshed"
+	AuditPasswordChanged  = "auth.password_changed"
+	AuditPasswordFailed   = "auth.password_change_failed"
+	AuditCalendarIssued   = "calendar.token_issued"
+	AuditCalendarRevoked  = "calendar.token_revoked"
+	AuditWorkspaceCreated = "workspace.created"
+	AuditMemberAdded      = "workspace.member_added"
+	AuditMemberRemoved    = "workspace.member_removed"
+	AuditTodoCreated      = "todo.created"
+	AuditTodoUpdated      = "todo.updated"
+	AuditTodoDeleted      = "todo.deleted"
+)
+
+// Audited resource types
+const (
+	AuditResourceUser      = "user"
+	AuditResourceTodo      = "todo"
+	AuditResourceWorkspace = "workspace"
+)
+
+// AuditEntry is a row of the append-only audit log. Each entry stores a hash
+// over its own content and is then linked into the hash chain by an
+// AuditLink, so altering or removing a row breaks the chain from that point
+// on. Entries recorded before links were introduced also carry the hash of
+// their predecessor in PrevHash.
+type AuditEntry struct {
+	ID           uint64    `json:"id" gorm:"primaryKey"`
+	OccurredAt   time.Time `json:"occurred_at" gorm:"not null"`
+	ActorID      *uint     `json:"actor_id,omitempty"`
+	Action       string    `json:"action" gorm:"not null"`
+	ResourceType string    `json:"resource_type,omitempty"`
+	ResourceID   string    `json:"resource_id,omitempty"`
+	IP           string    `json:"ip,omitempty"`
+	UserAgent    string    `json:"user_agent,omitempty"`
+	RequestID    string    `json:"request_id,omitempty"`
+	Changes      JSONText  `json:"changes,omitempty" gorm:"type:text" swaggertype:"object"`
+	Metadata     JSONText  `json:"metadata,omitempty" gorm:"type:text" swaggertype:"object"`
+	PrevHash     string    `json:"prev_hash,omitempty" gorm:"not null"`
+	Hash         string    `json:"hash" gorm:"not null"`
+}
+
+// TableName sets the table name for AuditEntry
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
+
+// ComputeHash returns the hex SHA-256 over the entry's previous hash and
+// content. Every field is length-prefixed so that no two different entries
+// share an encoding.
+func (e *AuditEntry) ComputeHash() string {
+	actor := ""
+	if e.ActorID != nil {
+		actor = strconv.FormatUint(uint64(*e.ActorID), 10)
+	}
+
+	h := sha256.New()
+	for _, field := range []string{
+		e.PrevHash,
+		e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		actor,
+		e.Action,
+		e.ResourceType,
+		e.ResourceID,
+		e.IP,
+		e.UserAgent,
+		e.RequestID,
+		string(e.Changes),
+		string(e.Metadata),
+	} {
+		fmt.Fprintf(h, "%d:%s;", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AuditLink places an audit entry in the hash chain. Links are appended in
+// sequence by a single writer; each one hashes the previous link's hash
+// together with the entry's hash.
+type AuditLink struct {
+	Seq      uint64      `gorm:"primaryKey"`
+	EntryID  uint64      `gorm:"not null;uniqueIndex"`
+	Entry    *AuditEntry `gorm:"foreignKey:EntryID"`
+	PrevHash string      `gorm:"not null"`
+	Hash     string      `gorm:"not null"`
+}
+
+// TableName sets the table name for AuditLink
+func (AuditLink) TableName() string {
+	return "audit_chain"
+}
+
+// ChainHash returns the hex SHA-256 linking an entry hash to the previous
+// link's hash
+func ChainHash(prevHash, entryHash string) string {
+	sum := sha256.Sum256([]byte(prevHash + ":" + entryHash))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditPending queues an audit entry until it is linked into the chain
+type AuditPending struct {
+	EntryID uint64 `gorm:"primaryKey"`
+}
+
+// TableName sets the table name for AuditPending
+func (AuditPending) TableName() string {
+	return "audit_pending"
+}
+
+// AuditFilter selects audit entries, newest first
+type AuditFilter struct {
+	ActorID      *uint
+	Action       string
+	ResourceType string
+	ResourceID   string
+	From         *time.Time
+	To           *time.Time
+	// BeforeID continues a listing below the given entry ID
+	BeforeID uint64
+	// ByTime orders entries by occurred_at, then ID, instead of by ID
+	ByTime bool
+	// BeforeTime continues a ByTime listing below the entry at BeforeTime
+	// with ID BeforeID
+	BeforeTime *time.Time
+	Limit      int
+}
+
+// AuditPage is a page of audit entries, newest first
+type AuditPage struct {
+	Entries []*AuditEntry `json:"entries"`
+	// NextBefore is the before cursor of the next page, or 0 on the last page
+	NextBefore uint64 `json:"next_before,omitempty"`
+}
+
+// AuditVerification is the result of checking the audit log hash chain
+type AuditVerification struct {
+	Valid   bool `json:"valid"`
+	Checked int  `json:"checked"`
+	// BrokenAt is the ID of the first entry whose hashes do not match
+	BrokenAt uint64 `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// JSONText is a JSON document stored in a text column. It is kept as the
+// exact bytes written, so hashes computed over it stay stable.
+type JSONText []byte
+
+// NewJSONText encodes v as JSONText
+func NewJSONText(v interface{}) (JSONText, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return JSONText(data), nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (j JSONText) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// Value implements driver.Valuer
+func (j JSONText) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// Scan implements sql.Scanner
+func (j *JSONText) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+	case string:
+		*j = JSONText(v)
+	case []byte:
+		*j = append(JSONText(nil), v...)
+	default:
+		return errors.New("unsupported type for JSONText")
+	}
+	return nil
+}
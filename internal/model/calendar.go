@@ -0,0 +1,7 @@
+package model
+
+// CalendarFeed is a user's calendar subscription link. The link carries a
+// secret token and is only shown when it is issued.
+type CalendarFeed struct {
+	URL string `json:"url"`
+}
@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// Activity item types
+const (
+	ActivityComment = "comment"
+	ActivityChange  = "change"
+)
+
+// Comment is a message left on a todo
+type Comment struct {
+	ID        uint              `json:"id" gorm:"primaryKey"`
+	TodoID    uint              `json:"todo_id" gorm:"not null;index"`
+	UserID    uint              `json:"user_id" gorm:"not null"`
+	Body      string            `json:"body" gorm:"type:text;not null"`
+	Mentions  []*CommentMention `json:"mentions" gorm:"foreignKey:CommentID"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// CommentMention is a user mentioned as @username in a comment. The username
+// is kept as written, so the comment still reads correctly if it changes.
+type CommentMention struct {
+	CommentID uint   `json:"-" gorm:"primaryKey"`
+	UserID    uint   `json:"user_id" gorm:"primaryKey"`
+	Username  string `json:"username" gorm:"not null"`
+}
+
+// CommentRequest represents the request body for creating or editing a comment
+type CommentRequest struct {
+	Body string `json:"body" validate:"required,max=5000"`
+}
+
+// ActivityItem is an entry of a todo's activity timeline: either a comment
+// or a recorded change to the todo
+type ActivityItem struct {
+	Type       string    `json:"type" enums:"comment,change"`
+	OccurredAt time.Time `json:"occurred_at"`
+	ActorID    *uint     `json:"actor_id,omitempty"`
+	// Comment is set for comment items
+	Comment *Comment `json:"comment,omitempty"`
+	// Action and Changes are set for change items, e.g. todo.updated with
+	// the fields that changed
+	Action  string   `json:"action,omitempty"`
+	Changes JSONText `json:"changes,omitempty" swaggertype:"object"`
+}
+
+// ActivityPage is a page of a todo's activity timeline, newest first
+type ActivityPage struct {
+	Items []*ActivityItem `json:"items"`
+	// NextBefore is the before cursor of the next page, empty on the last page
+	NextBefore string `json:"next_before,omitempty"`
+}
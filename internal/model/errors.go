@@ -6,6 +6,9 @@ var (
 	// ErrInvalidCredentials is returned when credentials are invalid
 	ErrInvalidCredentials = errors.New("invalid credentials")
 
+	// ErrInvalidPassword is returned when the current password given to change it is wrong
+	ErrInvalidPassword = errors.New("invalid password")
+
 	// ErrUserNotFound is returned when a user is not found
 	ErrUserNotFound = errors.New("user not found")
 
@@ -27,6 +30,61 @@ var (
 	// ErrUnauthorized is returned when a user is not authorized
 	ErrUnauthorized = errors.New("unauthorized")
 
+	// ErrTodoNotFound is returned when a todo does not exist or is not owned by the caller
+	ErrTodoNotFound = errors.New("todo not found")
+
+	// ErrTodoVersionConflict is returned when a todo changed since the version the client last saw
+	ErrTodoVersionConflict = errors.New("todo version conflict")
+
+	// ErrInvalidRecurrence is returned when a todo's recurrence rule cannot be parsed
+	ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+
+	// ErrInvalidCursor is returned when a change feed or activity cursor
+	// cannot be decoded
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrWebhookNotFound is returned when a webhook does not exist or is not owned by the caller
+	ErrWebhookNotFound = errors.New("webhook not found")
+
+	// ErrInvalidWebhookURL is returned when a webhook URL is not a public http or https address
+	ErrInvalidWebhookURL = errors.New("invalid webhook URL")
+
+	// ErrAttachmentNotFound is returned when an attachment does not exist on the given todo
+	ErrAttachmentNotFound = errors.New("attachment not found")
+
+	// ErrAttachmentTooLarge is returned when an uploaded file exceeds the size limit
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+
+	// ErrUnsupportedMediaType is returned when an uploaded file's content type is not allowed
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+	// ErrInvalidSignature is returned when a signed URL is malformed, tampered with or expired
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrCommentNotFound is returned when a comment does not exist on the given todo
+	ErrCommentNotFound = errors.New("comment not found")
+
+	// ErrNotCommentAuthor is returned when a user edits or deletes someone else's comment
+	ErrNotCommentAuthor = errors.New("not the comment author")
+
+	// ErrCalendarNotFound is returned when a calendar feed token does not belong to any user
+	ErrCalendarNotFound = errors.New("calendar not found")
+
+	// ErrWorkspaceNotFound is returned when a workspace does not exist or the caller is not a member
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+
+	// ErrNotWorkspaceOwner is returned when a member who is not an owner manages a workspace
+	ErrNotWorkspaceOwner = errors.New("not a workspace owner")
+
+	// ErrAlreadyWorkspaceMember is returned when adding a user who is already a member
+	ErrAlreadyWorkspaceMember = errors.New("already a workspace member")
+
+	// ErrLastWorkspaceOwner is returned when removing the only owner of a workspace
+	ErrLastWorkspaceOwner = errors.New("last workspace owner")
+
+	// ErrRegistrationDisabled is returned when registration has been switched off
+	ErrRegistrationDisabled = errors.New("registration disabled")
+
 	// ErrValidation is returned when validation fails
 	ErrValidation = errors.New("validation failed")
 )
@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// OutboxStatus is the state of an outbox message
+type OutboxStatus string
+
+const (
+	// OutboxPending messages are waiting to be dispatched
+	OutboxPending OutboxStatus = "pending"
+	// OutboxProcessed messages were handled by every handler
+	OutboxProcessed OutboxStatus = "processed"
+	// OutboxDead messages exhausted their attempts and need attention
+	OutboxDead OutboxStatus = "dead"
+)
+
+// OutboxMessage is a domain event stored in the outbox table, written in the
+// same transaction as the change it describes
+type OutboxMessage struct {
+	ID          uint         `gorm:"primaryKey"`
+	EventID     string       `gorm:"not null;uniqueIndex"`
+	Type        string       `gorm:"not null"`
+	UserID      uint         `gorm:"not null"`
+	Payload     string       `gorm:"type:text;not null"`
+	OccurredAt  time.Time    `gorm:"not null"`
+	Status      OutboxStatus `gorm:"not null;default:pending"`
+	Attempts    int          `gorm:"not null;default:0"`
+	AvailableAt time.Time    `gorm:"not null"`
+	ProcessedAt *time.Time
+	LastError   string
+	CreatedAt   time.Time
+}
+
+// TableName sets the table name for OutboxMessage
+func (OutboxMessage) TableName() string {
+	return "outbox"
+}
@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // Todo represents a todo item in the system
@@ -11,21 +13,74 @@ type Todo struct {
 	Description string     `json:"description"`
 	Completed   bool       `json:"completed" gorm:"default:false"`
 	UserID      uint       `json:"user_id" gorm:"not null"`
-	User        User       `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	DeletedAt   *time.Time `json:"deleted_at,omitempty" gorm:"index"`
+	WorkspaceID uint       `json:"workspace_id" gorm:"not null;index"`
+	Version     uint       `json:"version" gorm:"not null;default:1"`
+	DueAt       *time.Time `json:"due_at"`
+	// Recurrence is an RRULE (RFC 5545) such as FREQ=WEEKLY;BYDAY=MO,FR.
+	// Completing a recurring todo creates its next occurrence.
+	Recurrence string `json:"recurrence,omitempty" gorm:"not null;default:''"`
+	// Timezone is the IANA time zone the recurrence is evaluated in, so
+	// occurrences keep their local time of day across DST changes
+	Timezone string `json:"timezone,omitempty" gorm:"not null;default:''"`
+	// RecurrenceStart is the due date of the first todo of the series,
+	// from which COUNT is counted
+	RecurrenceStart *time.Time     `json:"-"`
+	User            User           `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"deleted_at" gorm:"index" swaggertype:"string"`
+	ChangeSeq       int64          `json:"-" gorm:"not null;default:0"`
+	CreatedSeq      int64          `json:"-" gorm:"not null;default:0"`
+	// NextOccurrence is the todo created when this update completed a
+	// recurring todo
+	NextOccurrence *Todo `json:"next_occurrence,omitempty" gorm:"-"`
 }
 
-// TodoCreateRequest represents the request body for creating a todo
+// TodoCreateRequest represents the request body for creating a todo. A
+// recurring todo needs a due date; its time zone defaults to the user's.
 type TodoCreateRequest struct {
-	Title       string `json:"title" validate:"required,min=3,max=100"`
-	Description string `json:"description" validate:"max=500"`
+	Title       string     `json:"title" validate:"required,min=3,max=100"`
+	Description string     `json:"description" validate:"max=500"`
+	DueAt       *time.Time `json:"due_at" validate:"required_with=Recurrence"`
+	Recurrence  string     `json:"recurrence" validate:"omitempty,max=255,rrule"`
+	Timezone    string     `json:"timezone" validate:"omitempty,timezone"`
 }
 
-// TodoUpdateRequest represents the request body for updating a todo
+// TodoUpdateRequest represents the full, replaceable state of a todo. It is
+// the request body of PUT and the document PATCH requests are applied to.
 type TodoUpdateRequest struct {
-	Title       string `json:"title" validate:"omitempty,min=3,max=100"`
-	Description string `json:"description" validate:"omitempty,max=500"`
-	Completed   bool   `json:"completed"`
+	Title       string     `json:"title" validate:"required,min=3,max=100"`
+	Description string     `json:"description" validate:"max=500"`
+	Completed   bool       `json:"completed"`
+	DueAt       *time.Time `json:"due_at" validate:"required_with=Recurrence"`
+	Recurrence  string     `json:"recurrence" validate:"omitempty,max=255,rrule"`
+	Timezone    string     `json:"timezone" validate:"omitempty,timezone"`
+}
+
+// UpdateRequest returns the todo's current state as a TodoUpdateRequest
+func (t *Todo) UpdateRequest() TodoUpdateRequest {
+	return TodoUpdateRequest{
+		Title:       t.Title,
+		Description: t.Description,
+		Completed:   t.Completed,
+		DueAt:       t.DueAt,
+		Recurrence:  t.Recurrence,
+		Timezone:    t.Timezone,
+	}
+}
+
+// TodoOccurrencesRequest represents the request body for previewing the
+// occurrences of a recurrence rule
+type TodoOccurrencesRequest struct {
+	DueAt      time.Time `json:"due_at" validate:"required"`
+	Recurrence string    `json:"recurrence" validate:"required,max=255,rrule"`
+	Timezone   string    `json:"timezone" validate:"omitempty,timezone"`
+	Count      int       `json:"count" validate:"omitempty,min=1,max=100"`
+}
+
+// TodoOccurrencesResponse lists upcoming occurrences of a recurring todo
+type TodoOccurrencesResponse struct {
+	Recurrence  string      `json:"recurrence"`
+	Timezone    string      `json:"timezone"`
+	Occurrences []time.Time `json:"occurrences"`
 }
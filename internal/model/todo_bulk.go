@@ -0,0 +1,73 @@
+package model
+
+// TodoBulkOp is the kind of change a bulk item applies
+type TodoBulkOp string
+
+const (
+	// TodoBulkCreate creates a new todo
+	TodoBulkCreate TodoBulkOp = "create"
+	// TodoBulkUpdate replaces the title, description and completion of a todo
+	TodoBulkUpdate TodoBulkOp = "update"
+	// TodoBulkComplete marks a todo as completed
+	TodoBulkComplete TodoBulkOp = "complete"
+	// TodoBulkDelete deletes a todo
+	TodoBulkDelete TodoBulkOp = "delete"
+)
+
+// TodoBulkMode controls how a bulk request handles failing items
+type TodoBulkMode string
+
+const (
+	// TodoBulkAtomic applies all items or none of them
+	TodoBulkAtomic TodoBulkMode = "atomic"
+	// TodoBulkBestEffort applies every item that succeeds
+	TodoBulkBestEffort TodoBulkMode = "best_effort"
+)
+
+// MaxTodoBulkItems is the maximum number of items in one bulk request
+const MaxTodoBulkItems = 500
+
+// TodoBulkRequest represents the request body for bulk todo operations
+type TodoBulkRequest struct {
+	Mode  TodoBulkMode   `json:"mode" validate:"omitempty,oneof=atomic best_effort"`
+	Items []TodoBulkItem `json:"items" validate:"required,min=1,max=500,dive"`
+}
+
+// TodoBulkItem is a single operation in a bulk request. ID is required for
+// every operation except create; Title, Description and Completed are used
+// by create and update. A non-zero Version makes the item conditional on
+// the todo still being at that version.
+type TodoBulkItem struct {
+	Op          TodoBulkOp `json:"op" validate:"required,oneof=create update complete delete"`
+	ID          uint       `json:"id,omitempty" validate:"required_unless=Op create"`
+	Version     uint       `json:"version,omitempty"`
+	Title       string     `json:"title,omitempty" validate:"required_if=Op create,required_if=Op update,omitempty,min=3,max=100"`
+	Description string     `json:"description,omitempty" validate:"max=500"`
+	Completed   bool       `json:"completed,omitempty"`
+}
+
+// TodoBulkItemResult is the outcome of a single bulk item
+type TodoBulkItemResult struct {
+	Index   int        `json:"index"`
+	Op      TodoBulkOp `json:"op"`
+	ID      uint       `json:"id,omitempty"`
+	Success bool       `json:"success"`
+	Todo    *Todo      `json:"todo,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// TodoBulkResponse represents the result of a bulk request
+type TodoBulkResponse struct {
+	Mode      TodoBulkMode         `json:"mode"`
+	Committed bool                 `json:"committed"`
+	Results   []TodoBulkItemResult `json:"results"`
+}
+
+// Bulk item error codes
+const (
+	TodoBulkErrNotFound        = "not_found"
+	TodoBulkErrVersionConflict = "version_conflict"
+	TodoBulkErrInternal        = "internal_error"
+	TodoBulkErrRolledBack      = "rolled_back"
+	TodoBulkErrSkipped         = "skipped"
+)
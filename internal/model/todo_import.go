@@ -0,0 +1,62 @@
+package model
+
+import "fmt"
+
+// MaxTodoImportItems is the maximum number of todos in one import
+const MaxTodoImportItems = 1000
+
+// TodoImportRequest is a set of todos decoded from an imported file. Each
+// todo is validated like the body of a PUT request.
+type TodoImportRequest struct {
+	Todos []TodoUpdateRequest `json:"todos" validate:"required,min=1,max=1000,dive"`
+}
+
+// TodoImportResponse represents the result of an import
+type TodoImportResponse struct {
+	Imported int     `json:"imported"`
+	Todos    []*Todo `json:"todos"`
+}
+
+// MaxTodoImportRows is the maximum number of rows in an imported file
+const MaxTodoImportRows = 10000
+
+// TodoImportRow is a todo read from a row of an imported file, together
+// with the problems found in that row
+type TodoImportRow struct {
+	Line   int
+	Todo   TodoUpdateRequest
+	Errors []TodoImportError
+}
+
+// TodoImportError reports a problem with a row of an imported file. Line
+// is the line the row starts on, counting from 1. A file that cannot be
+// read any further is reported with code TodoImportInvalidFile.
+type TodoImportError struct {
+	Line    int    `json:"line"`
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// TodoImportInvalidFile is the code of a TodoImportError that stopped an
+// import because the rest of the file cannot be read
+const TodoImportInvalidFile = "invalid_file"
+
+func (e *TodoImportError) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// TodoImportReport represents the result of importing a file. Valid rows
+// are committed in batches as the file is read, unless the import is a dry
+// run; Committed reports whether any were.
+type TodoImportReport struct {
+	DryRun    bool              `json:"dry_run"`
+	Committed bool              `json:"committed"`
+	Rows      int               `json:"rows"`
+	Valid     int               `json:"valid"`
+	Imported  int               `json:"imported"`
+	Errors    []TodoImportError `json:"errors"`
+}
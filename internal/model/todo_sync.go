@@ -0,0 +1,103 @@
+package model
+
+import "time"
+
+// TodoChangeType describes how a todo changed since a sync cursor
+type TodoChangeType string
+
+const (
+	// TodoChangeCreated is reported for todos created after the cursor
+	TodoChangeCreated TodoChangeType = "created"
+	// TodoChangeUpdated is reported for todos updated after the cursor
+	TodoChangeUpdated TodoChangeType = "updated"
+	// TodoChangeDeleted is reported for todos deleted after the cursor
+	TodoChangeDeleted TodoChangeType = "deleted"
+)
+
+// TodoChange is a single entry in the change feed. Todo is omitted for
+// deleted todos.
+type TodoChange struct {
+	Type      TodoChangeType `json:"type"`
+	ID        uint           `json:"id"`
+	Todo      *Todo          `json:"todo,omitempty"`
+	DeletedAt *time.Time     `json:"deleted_at,omitempty"`
+}
+
+// TodoChangesResponse is a page of the change feed. Cursor is passed as
+// `since` to fetch the next page; HasMore reports whether one exists.
+type TodoChangesResponse struct {
+	Changes []TodoChange `json:"changes"`
+	Cursor  string       `json:"cursor"`
+	HasMore bool         `json:"has_more"`
+}
+
+// TodoSyncStrategy selects how pushed changes are reconciled
+type TodoSyncStrategy string
+
+const (
+	// TodoSyncVersion rejects changes whose base version is out of date
+	TodoSyncVersion TodoSyncStrategy = "version"
+	// TodoSyncLastWriterWins applies a change if it was made after the
+	// server's last update of the todo
+	TodoSyncLastWriterWins TodoSyncStrategy = "last_writer_wins"
+)
+
+// TodoSyncOp is the kind of change pushed by a client
+type TodoSyncOp string
+
+const (
+	// TodoSyncUpsert creates a todo when ID is zero and updates it otherwise
+	TodoSyncUpsert TodoSyncOp = "upsert"
+	// TodoSyncDelete deletes a todo
+	TodoSyncDelete TodoSyncOp = "delete"
+)
+
+// TodoPushRequest represents changes made offline by a client
+type TodoPushRequest struct {
+	Strategy TodoSyncStrategy `json:"strategy" validate:"omitempty,oneof=version last_writer_wins"`
+	Changes  []TodoPushChange `json:"changes" validate:"required,min=1,max=500,dive"`
+}
+
+// TodoPushChange is a single client change. ClientID is an identifier the
+// client chose for a todo created offline and is echoed in the result. For
+// the version strategy Version is the server version the change was based
+// on; for last_writer_wins UpdatedAt is when the client made the change.
+type TodoPushChange struct {
+	Op          TodoSyncOp `json:"op" validate:"required,oneof=upsert delete"`
+	ID          uint       `json:"id,omitempty" validate:"required_if=Op delete"`
+	ClientID    string     `json:"client_id,omitempty" validate:"max=100"`
+	Version     uint       `json:"version,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Title       string     `json:"title,omitempty" validate:"required_if=Op upsert,omitempty,min=3,max=100"`
+	Description string     `json:"description,omitempty" validate:"max=500"`
+	Completed   bool       `json:"completed,omitempty"`
+}
+
+// TodoPushStatus is the outcome of a pushed change
+type TodoPushStatus string
+
+const (
+	// TodoPushApplied means the change was applied
+	TodoPushApplied TodoPushStatus = "applied"
+	// TodoPushConflict means the server copy was kept; Todo holds it
+	TodoPushConflict TodoPushStatus = "conflict"
+	// TodoPushNotFound means the todo does not exist
+	TodoPushNotFound TodoPushStatus = "not_found"
+	// TodoPushFailed means the change could not be applied
+	TodoPushFailed TodoPushStatus = "failed"
+)
+
+// TodoPushResult is the outcome of a single pushed change
+type TodoPushResult struct {
+	Index    int            `json:"index"`
+	ClientID string         `json:"client_id,omitempty"`
+	ID       uint           `json:"id,omitempty"`
+	Status   TodoPushStatus `json:"status"`
+	Todo     *Todo          `json:"todo,omitempty"`
+}
+
+// TodoPushResponse represents the result of a push. Changes made by other
+// clients are fetched by pulling from the cursor held before pushing.
+type TodoPushResponse struct {
+	Results []TodoPushResult `json:"results"`
+}
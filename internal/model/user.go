@@ -6,17 +6,31 @@ import (
 	"gorm.io/gorm"
 )
 
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // User represents the user model in the database
 type User struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Email     string         `gorm:"uniqueIndex;not null" json:"email"`
-	Username  string         `gorm:"uniqueIndex;not null" json:"username"`
-	Password  string         `gorm:"not null" json:"-"`
-	FirstName string         `json:"first_name"`
-	LastName  string         `json:"last_name"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	Email     string `gorm:"uniqueIndex;not null" json:"email"`
+	Username  string `gorm:"uniqueIndex;not null" json:"username"`
+	Password  string `gorm:"not null" json:"-"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Role      string `gorm:"not null;default:user" json:"role"`
+	Timezone  string `gorm:"not null;default:UTC" json:"timezone"`
+	// CalendarTokenHash is the SHA-256 of the token in the user's calendar
+	// feed link, or nil if no link has been issued
+	CalendarTokenHash *string `gorm:"uniqueIndex" json:"-"`
+	// CalendarWorkspaceID is the workspace whose todos the calendar feed
+	// shows, the one the link was issued in
+	CalendarWorkspaceID *uint          `json:"-"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // UserResponse is the response struct for user data
@@ -26,6 +40,8 @@ type UserResponse struct {
 	Username  string    `json:"username"`
 	FirstName string    `json:"first_name"`
 	LastName  string    `json:"last_name"`
+	Role      string    `json:"role"`
+	Timezone  string    `json:"timezone"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -38,6 +54,8 @@ func (u *User) ToResponse() UserResponse {
 		Username:  u.Username,
 		FirstName: u.FirstName,
 		LastName:  u.LastName,
+		Role:      u.Role,
+		Timezone:  u.Timezone,
 		CreatedAt: u.CreatedAt,
 		UpdatedAt: u.UpdatedAt,
 	}
@@ -46,7 +64,10 @@ func (u *User) ToResponse() UserResponse {
 // LoginRequest represents login request data
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email,max=100"`
-	Password string `json:"password" validate:"required,password"`
+	Password string `json:"password" validate:"required,max=128"`
+	// WorkspaceID is the workspace to sign in to; it defaults to the first
+	// workspace the user joined
+	WorkspaceID uint `json:"workspace_id,omitempty"`
 }
 
 // LoginResponse represents login response data
@@ -59,9 +80,18 @@ type LoginResponse struct {
 type RegisterRequest struct {
 	Email     string `json:"email" validate:"required,email,max=100"`
 	Username  string `json:"username" validate:"required,username"`
-	Password  string `json:"password" validate:"required,password"`
-	FirstName string `json:"first_name" validate:"required,min=2,max=50,alpha"`
-	LastName  string `json:"last_name" validate:"required,min=2,max=50,alpha"`
+	Password  string `json:"password" validate:"required,max=72,password"`
+	FirstName string `json:"first_name" validate:"required,name"`
+	LastName  string `json:"last_name" validate:"required,name"`
+	// Timezone is the IANA time zone recurring todos are scheduled in; it
+	// defaults to UTC
+	Timezone string `json:"timezone" validate:"omitempty,timezone"`
+}
+
+// ChangePasswordRequest represents a request to replace the caller's password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required,max=128"`
+	NewPassword     string `json:"new_password" validate:"required,max=72,password"`
 }
 
 // RegisterResponse represents registration response data
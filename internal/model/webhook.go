@@ -0,0 +1,123 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// WebhookDeliveryStatus is the state of a webhook delivery
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryPending deliveries are waiting for their next attempt
+	WebhookDeliveryPending WebhookDeliveryStatus = "pending"
+	// WebhookDeliverySucceeded deliveries were acknowledged with a 2xx response
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	// WebhookDeliveryFailed deliveries exhausted their attempts
+	WebhookDeliveryFailed WebhookDeliveryStatus = "failed"
+)
+
+// Webhook is an endpoint registered by a user to receive events
+type Webhook struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	URL       string     `json:"url" gorm:"not null"`
+	Secret    string     `json:"-" gorm:"not null"`
+	Events    EventTypes `json:"events" gorm:"type:text;not null" swaggertype:"array,string"`
+	Active    bool       `json:"active" gorm:"not null;default:true"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Subscribes reports whether the webhook receives events of eventType
+func (w *Webhook) Subscribes(eventType string) bool {
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is a single event queued for, or delivered to, a webhook.
+// Pending rows form the delivery outbox; finished rows are the delivery log.
+type WebhookDelivery struct {
+	ID             uint                  `json:"id" gorm:"primaryKey"`
+	WebhookID      uint                  `json:"webhook_id" gorm:"not null;index"`
+	Webhook        *Webhook              `json:"-" gorm:"foreignKey:WebhookID"`
+	EventID        string                `json:"event_id" gorm:"not null"`
+	EventType      string                `json:"event_type" gorm:"not null"`
+	Payload        string                `json:"payload" gorm:"type:text;not null"`
+	Status         WebhookDeliveryStatus `json:"status" gorm:"not null;default:pending"`
+	Attempts       int                   `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at" gorm:"not null"`
+	LastAttemptAt  *time.Time            `json:"last_attempt_at,omitempty"`
+	ResponseStatus int                   `json:"response_status,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+// WebhookEventTypes lists the event types webhooks can subscribe to
+var WebhookEventTypes = []string{
+	"todo.created",
+	"todo.updated",
+	"todo.completed",
+	"todo.deleted",
+	"comment.created",
+	"comment.updated",
+	"comment.deleted",
+	"user.registered",
+}
+
+// WebhookCreateRequest represents the request body for registering a webhook
+type WebhookCreateRequest struct {
+	URL    string   `json:"url" validate:"required,http_url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=todo.created todo.updated todo.completed todo.deleted comment.created comment.updated comment.deleted user.registered"`
+}
+
+// WebhookUpdateRequest represents the full, replaceable state of a webhook
+type WebhookUpdateRequest struct {
+	URL    string   `json:"url" validate:"required,http_url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=todo.created todo.updated todo.completed todo.deleted comment.created comment.updated comment.deleted user.registered"`
+	Active bool     `json:"active"`
+}
+
+// WebhookCreateResponse is returned when a webhook is registered. The signing
+// secret is only ever shown here.
+type WebhookCreateResponse struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// EventTypes is a list of event types stored as a JSON array
+type EventTypes []string
+
+// Value implements driver.Valuer
+func (e EventTypes) Value() (driver.Value, error) {
+	if e == nil {
+		e = EventTypes{}
+	}
+	b, err := json.Marshal([]string(e))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner
+func (e *EventTypes) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*e = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), e)
+	case []byte:
+		return json.Unmarshal(v, e)
+	default:
+		return errors.New("unsupported type for EventTypes")
+	}
+}
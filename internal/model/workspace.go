@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// Workspace member roles
+const (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleMember = "member"
+)
+
+// Workspace is a tenant: todos belong to exactly one workspace, and users
+// only see the data of the workspace their token was issued for. Every user
+// gets a personal workspace when registering.
+type Workspace struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Name string `json:"name" gorm:"not null"`
+	// CreatedBy is the user who created the workspace
+	CreatedBy uint      `json:"created_by" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WorkspaceMember makes a user a member of a workspace
+type WorkspaceMember struct {
+	WorkspaceID uint      `json:"workspace_id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"primaryKey"`
+	Role        string    `json:"role" gorm:"not null;default:member" enums:"owner,member"`
+	CreatedAt   time.Time `json:"created_at"`
+	User        *User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// WorkspaceMembership is a workspace together with the caller's role in it
+type WorkspaceMembership struct {
+	Workspace
+	Role string `json:"role" enums:"owner,member"`
+}
+
+// WorkspaceRequest represents the request body for creating a workspace
+type WorkspaceRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+}
+
+// WorkspaceMemberRequest represents the request body for adding a member to
+// a workspace
+type WorkspaceMemberRequest struct {
+	Email string `json:"email" validate:"required,email,max=100"`
+	Role  string `json:"role" validate:"omitempty,oneof=owner member"`
+}
@@ -0,0 +1,268 @@
+// Package outbox dispatches domain events written to the outbox table to
+// in-process handlers. Because events are stored in the same transaction as
+// the change they describe, every committed change is dispatched at least
+// once, even if the process stops right after the commit. Each event is
+// dispatched by one instance only, so handlers are meant for effects outside
+// the process, such as queueing webhooks; Fanout publishes events to the
+// clients connected to every instance.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"myapp/internal/events"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+)
+
+// AllEvents subscribes a handler to every event type
+const AllEvents = "*"
+
+// Handler handles a dispatched domain event. Delivery is at least once, so
+// handlers must tolerate seeing the same event ID more than once.
+type Handler func(ctx context.Context, event events.DomainEvent) error
+
+// Config configures a Dispatcher
+type Config struct {
+	// BatchSize is the number of messages claimed per poll
+	BatchSize int
+	// PollInterval is how long to wait between polls when the outbox is
+	// idle and no commit has been signalled through Notify
+	PollInterval time.Duration
+	// Timeout bounds the handlers of one message
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts before a message is marked dead
+	MaxAttempts int
+	// BackoffBase is the delay before the first retry; each further retry
+	// doubles it, up to BackoffMax
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Retention is how long processed messages are kept before being purged
+	Retention time.Duration
+}
+
+// DefaultConfig returns the default dispatcher configuration
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:    100,
+		PollInterval: time.Second,
+		Timeout:      30 * time.Second,
+		MaxAttempts:  10,
+		BackoffBase:  time.Second,
+		BackoffMax:   10 * time.Minute,
+		Retention:    7 * 24 * time.Hour,
+	}
+}
+
+// purgeInterval is how often processed messages past retention are removed
+const purgeInterval = time.Hour
+
+type subscription struct {
+	eventType string
+	name      string
+	handler   Handler
+}
+
+// Dispatcher delivers outbox messages to the handlers subscribed to their
+// event type
+type Dispatcher struct {
+	repo   repository.OutboxRepository
+	config Config
+	wake   chan struct{}
+
+	mu            sync.RWMutex
+	subscriptions []subscription
+}
+
+// NewDispatcher creates a new Dispatcher; zero config fields take their
+// default values
+func NewDispatcher(repo repository.OutboxRepository, config Config) *Dispatcher {
+	defaults := DefaultConfig()
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.BackoffBase <= 0 {
+		config.BackoffBase = defaults.BackoffBase
+	}
+	if config.BackoffMax <= 0 {
+		config.BackoffMax = defaults.BackoffMax
+	}
+	if config.Retention <= 0 {
+		config.Retention = defaults.Retention
+	}
+	return &Dispatcher{
+		repo:   repo,
+		config: config,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Subscribe registers a handler for an event type, or for every event type
+// with AllEvents. The name identifies the handler in logs and errors.
+func (d *Dispatcher) Subscribe(eventType, name string, handler Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscriptions = append(d.subscriptions, subscription{eventType: eventType, name: name, handler: handler})
+}
+
+// Notify wakes the dispatcher to poll immediately. It is meant to be called
+// after a transaction that wrote to the outbox has committed.
+func (d *Dispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run dispatches messages until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	nextPurge := time.Now()
+	for {
+		if time.Now().After(nextPurge) {
+			d.purge(ctx)
+			nextPurge = time.Now().Add(purgeInterval)
+		}
+
+		n, err := d.ProcessDue(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("outbox: failed to claim messages", "error", err)
+		}
+
+		// Keep draining while batches come back full
+		if err == nil && n == d.config.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-time.After(d.config.PollInterval):
+		}
+	}
+}
+
+// ProcessDue claims one batch of available messages and dispatches them in
+// order. It returns the number of messages dispatched.
+//
+// The claim is leased for two handler timeouts rather than for the whole
+// batch, so that messages abandoned by a crashed dispatcher are retried
+// soon. While the batch is worked through, the lease of the messages still
+// waiting is renewed before it runs out; if that fails, they are left for
+// the next claim.
+func (d *Dispatcher) ProcessDue(ctx context.Context) (int, error) {
+	lease := 2 * d.config.Timeout
+	leasedUntil := time.Now().Add(lease)
+	messages, err := d.repo.ClaimDue(ctx, time.Now(), lease, d.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, message := range messages {
+		if time.Until(leasedUntil) < d.config.Timeout {
+			leasedUntil = time.Now().Add(lease)
+			if err := d.repo.RenewLease(ctx, messageIDs(messages[i:]), leasedUntil); err != nil {
+				return i, fmt.Errorf("renewing lease: %w", err)
+			}
+		}
+		d.dispatch(message)
+	}
+	return len(messages), nil
+}
+
+// messageIDs returns the IDs of messages
+func messageIDs(messages []*model.OutboxMessage) []uint {
+	ids := make([]uint, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+	return ids
+}
+
+// dispatch runs the handlers of one message and records the outcome. It is
+// not tied to the Run context so that shutdown does not abandon handlers
+// halfway and count the attempt as failed.
+func (d *Dispatcher) dispatch(message *model.OutboxMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.config.Timeout)
+	defer cancel()
+
+	event := events.DomainEvent{
+		ID:         message.EventID,
+		Type:       message.Type,
+		UserID:     message.UserID,
+		OccurredAt: message.OccurredAt,
+		Payload:    json.RawMessage(message.Payload),
+	}
+
+	now := time.Now()
+	message.Attempts++
+	err := d.handle(ctx, event)
+	switch {
+	case err == nil:
+		message.Status = model.OutboxProcessed
+		message.ProcessedAt = &now
+		message.LastError = ""
+	case message.Attempts >= d.config.MaxAttempts:
+		message.Status = model.OutboxDead
+		message.LastError = err.Error()
+		slog.Error("outbox: giving up on event", "event_id", event.ID, "type", event.Type, "attempts", message.Attempts, "error", err)
+	default:
+		message.AvailableAt = now.Add(d.backoff(message.Attempts))
+		message.LastError = err.Error()
+	}
+
+	if err := d.repo.Update(ctx, message); err != nil {
+		slog.Error("outbox: failed to record event", "event_id", event.ID, "error", err)
+	}
+}
+
+// handle runs every handler subscribed to the event, returning the first
+// failure. Handlers that succeeded run again when the event is retried.
+func (d *Dispatcher) handle(ctx context.Context, event events.DomainEvent) error {
+	d.mu.RLock()
+	subscriptions := d.subscriptions
+	d.mu.RUnlock()
+
+	for _, sub := range subscriptions {
+		if sub.eventType != AllEvents && sub.eventType != event.Type {
+			continue
+		}
+		if err := sub.handler(ctx, event); err != nil {
+			return fmt.Errorf("%s: %w", sub.name, err)
+		}
+	}
+	return nil
+}
+
+// purge removes processed messages older than the retention period
+func (d *Dispatcher) purge(ctx context.Context) {
+	if _, err := d.repo.DeleteProcessedBefore(ctx, time.Now().Add(-d.config.Retention)); err != nil && ctx.Err() == nil {
+		slog.Error("outbox: failed to purge processed messages", "error", err)
+	}
+}
+
+// backoff returns the delay before the attempt following the given one
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.config.BackoffBase
+	for i := 1; i < attempt && delay < d.config.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > d.config.BackoffMax {
+		delay = d.config.BackoffMax
+	}
+	return delay
+}
@@ -0,0 +1,108 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"myapp/internal/events"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+)
+
+// renewal is a lease renewal and the number of messages dispatched before it
+type renewal struct {
+	ids        []uint
+	dispatched int
+}
+
+// leaseOutbox hands out its messages in one claim and records the leases
+// taken and the outcomes of dispatching
+type leaseOutbox struct {
+	repository.OutboxRepository
+	messages []*model.OutboxMessage
+	renewErr error
+
+	mu         sync.Mutex
+	lease      time.Duration
+	renewals   []renewal
+	dispatched []uint
+}
+
+func (s *leaseOutbox) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*model.OutboxMessage, error) {
+	s.lease = lease
+	return s.messages, nil
+}
+
+func (s *leaseOutbox) RenewLease(ctx context.Context, ids []uint, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.renewErr != nil {
+		return s.renewErr
+	}
+	s.renewals = append(s.renewals, renewal{ids: ids, dispatched: len(s.dispatched)})
+	return nil
+}
+
+func (s *leaseOutbox) Update(ctx context.Context, message *model.OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dispatched = append(s.dispatched, message.ID)
+	return nil
+}
+
+func newLeaseOutbox(n int) *leaseOutbox {
+	repo := &leaseOutbox{}
+	for id := uint(1); id <= uint(n); id++ {
+		repo.messages = append(repo.messages, &model.OutboxMessage{ID: id, Status: model.OutboxPending})
+	}
+	return repo
+}
+
+func TestProcessDueRenewsLeaseOfWaitingMessages(t *testing.T) {
+	const timeout = 40 * time.Millisecond
+	repo := newLeaseOutbox(6)
+	d := NewDispatcher(repo, Config{Timeout: timeout})
+	d.Subscribe(AllEvents, "slow", func(ctx context.Context, event events.DomainEvent) error {
+		time.Sleep(timeout / 2)
+		return nil
+	})
+
+	n, err := d.ProcessDue(context.Background())
+	if err != nil || n != 6 {
+		t.Fatalf("ProcessDue() = %d, %v; want 6 messages dispatched", n, err)
+	}
+	if repo.lease != 2*timeout {
+		t.Errorf("claim leased for %v, want two handler timeouts", repo.lease)
+	}
+	if len(repo.renewals) == 0 {
+		t.Fatal("lease never renewed while the batch ran longer than it")
+	}
+	for _, r := range repo.renewals {
+		if want := messageIDs(repo.messages[r.dispatched:]); !slices.Equal(r.ids, want) {
+			t.Fatalf("renewed %v after %d messages, want the waiting %v", r.ids, r.dispatched, want)
+		}
+	}
+}
+
+func TestProcessDueStopsWhenRenewalFails(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+	repo := newLeaseOutbox(10)
+	repo.renewErr = errors.New("connection lost")
+	d := NewDispatcher(repo, Config{Timeout: timeout})
+	d.Subscribe(AllEvents, "slow", func(ctx context.Context, event events.DomainEvent) error {
+		time.Sleep(timeout / 2)
+		return nil
+	})
+
+	n, err := d.ProcessDue(context.Background())
+	if !errors.Is(err, repo.renewErr) {
+		t.Fatalf("ProcessDue() error = %v, want the renewal error", err)
+	}
+	if n == 0 || n >= 10 || len(repo.dispatched) != n {
+		t.Fatalf("ProcessDue() dispatched %d of 10 (%v), want it to stop at the failed renewal", n, repo.dispatched)
+	}
+}
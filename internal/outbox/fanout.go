@@ -0,0 +1,152 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"myapp/internal/events"
+	"myapp/internal/repository"
+)
+
+const (
+	// fanoutRetryMin and fanoutRetryMax bound the wait before listening
+	// again after the connection was lost
+	fanoutRetryMin = time.Second
+	fanoutRetryMax = 30 * time.Second
+
+	// fanoutLoadTimeout bounds loading the payload of a truncated event
+	fanoutLoadTimeout = 5 * time.Second
+)
+
+// Fanout publishes every committed domain event to the in-process bus of
+// this instance, so that clients streaming from any instance see changes
+// made through all of them. Events are announced by OutboxRepository.Add on
+// repository.EventChannel; unlike outbox messages, which are claimed by a
+// single dispatcher, notifications reach every listening instance.
+//
+// Notifications sent while the connection is down are lost. Once listening
+// again, the fan-out calls reset, which should make clients resynchronise,
+// such as Bus.Reset.
+type Fanout struct {
+	db        *sql.DB
+	repo      repository.OutboxRepository
+	publisher events.Publisher
+	reset     func()
+}
+
+// NewFanout creates a Fanout listening on a connection of db, a pool of the
+// pgx driver, and publishing to publisher
+func NewFanout(db *sql.DB, repo repository.OutboxRepository, publisher events.Publisher, reset func()) *Fanout {
+	return &Fanout{db: db, repo: repo, publisher: publisher, reset: reset}
+}
+
+// Run publishes announced events until ctx is cancelled, listening again
+// with backoff whenever the connection is lost
+func (f *Fanout) Run(ctx context.Context) {
+	delay := fanoutRetryMin
+	interrupted := false
+	for {
+		err := f.listen(ctx, func() {
+			if interrupted {
+				f.reset()
+			}
+			delay = fanoutRetryMin
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("outbox: event fan-out interrupted", "error", err, "retry_in", delay)
+		interrupted = true
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, fanoutRetryMax)
+	}
+}
+
+// listen takes a connection out of the pool, listens on it and publishes
+// notifications until it fails. listening is called once notifications are
+// being received.
+func (f *Fanout) listen(ctx context.Context, listening func()) error {
+	conn, err := f.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var listenErr error
+	_ = conn.Raw(func(driverConn any) error {
+		// The connection is discarded rather than returned to the pool
+		// while still listening
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			listenErr = fmt.Errorf("outbox: fan-out needs a pgx connection, got %T", driverConn)
+			return driver.ErrBadConn
+		}
+		listenErr = f.receive(ctx, stdConn.Conn(), listening)
+		return driver.ErrBadConn
+	})
+	return listenErr
+}
+
+// receive listens on conn and publishes notifications until it fails
+func (f *Fanout) receive(ctx context.Context, conn *pgx.Conn, listening func()) error {
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{repository.EventChannel}.Sanitize()); err != nil {
+		return err
+	}
+	listening()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		f.publish(ctx, notification.Payload)
+	}
+}
+
+// publish publishes an announced event, loading its payload from the outbox
+// if it was left out of the notification
+func (f *Fanout) publish(ctx context.Context, payload string) {
+	var notification repository.EventNotification
+	if err := json.Unmarshal([]byte(payload), &notification); err != nil {
+		slog.Error("outbox: malformed event notification", "error", err)
+		return
+	}
+	event := notification.Event
+
+	if notification.Truncated {
+		loadCtx, cancel := context.WithTimeout(ctx, fanoutLoadTimeout)
+		message, err := f.repo.GetByEventID(loadCtx, event.ID)
+		cancel()
+		if err != nil {
+			slog.Error("outbox: failed to load announced event", "event_id", event.ID, "error", err)
+			return
+		}
+		event.Payload = json.RawMessage(message.Payload)
+	}
+
+	f.publisher.Publish(event.UserID, event.Type, event.Payload)
+}
+
+// Publish returns a dispatcher handler publishing events to publisher. It
+// stands in for a Fanout where the database has no notifications, such as
+// SQLite. Each event is dispatched by one instance, so only its clients
+// see the event; this suits a single instance.
+func Publish(publisher events.Publisher) Handler {
+	return func(ctx context.Context, event events.DomainEvent) error {
+		publisher.Publish(event.UserID, event.Type, event.Payload)
+		return nil
+	}
+}
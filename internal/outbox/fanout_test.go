@@ -0,0 +1,65 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"myapp/internal/events"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+)
+
+type published struct {
+	userID    uint
+	eventType string
+	data      interface{}
+}
+
+type recordingPublisher []published
+
+func (p *recordingPublisher) Publish(userID uint, eventType string, data interface{}) {
+	*p = append(*p, published{userID, eventType, data})
+}
+
+// stubOutbox serves GetByEventID from a map
+type stubOutbox struct {
+	repository.OutboxRepository
+	messages map[string]*model.OutboxMessage
+}
+
+func (s *stubOutbox) GetByEventID(ctx context.Context, eventID string) (*model.OutboxMessage, error) {
+	return s.messages[eventID], nil
+}
+
+func TestFanoutPublishesNotifications(t *testing.T) {
+	large := `{"title":"` + strings.Repeat("x", 10000) + `"}`
+	repo := &stubOutbox{messages: map[string]*model.OutboxMessage{
+		"large": {EventID: "large", Payload: large},
+	}}
+	var publisher recordingPublisher
+	f := NewFanout(nil, repo, &publisher, nil)
+
+	for _, notification := range []repository.EventNotification{
+		{Event: events.DomainEvent{ID: "small", Type: events.TodoCreated, UserID: 1, Payload: json.RawMessage(`{"id":1}`)}},
+		{Event: events.DomainEvent{ID: "large", Type: events.TodoUpdated, UserID: 2}, Truncated: true},
+	} {
+		payload, err := json.Marshal(notification)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.publish(context.Background(), string(payload))
+	}
+	f.publish(context.Background(), "not json")
+
+	if len(publisher) != 2 {
+		t.Fatalf("published %d events, want 2", len(publisher))
+	}
+	if p := publisher[0]; p.userID != 1 || p.eventType != events.TodoCreated || string(p.data.(json.RawMessage)) != `{"id":1}` {
+		t.Errorf("first event = %+v", p)
+	}
+	if p := publisher[1]; p.userID != 2 || p.eventType != events.TodoUpdated || string(p.data.(json.RawMessage)) != large {
+		t.Errorf("truncated event was published without its stored payload")
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
-	"myapp/internal/pkg/validation"
+	"myapp/internal/validation"
 )
 
 // Error sends an error response
@@ -13,8 +13,12 @@ func Error(w http.ResponseWriter, status int, message string) {
 }
 
 // ValidationError sends a validation error response
-func ValidationError(w http.ResponseWriter, errors *validation.ValidationErrors) {
-	JSON(w, http.StatusBadRequest, map[string]interface{}{
+func ValidationError(w http.ResponseWriter, errors validation.ValidationErrors) {
+	status := errors.StatusCode
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	JSON(w, status, map[string]interface{}{
 		"error":  "Validation failed",
 		"errors": errors.Errors,
 	})
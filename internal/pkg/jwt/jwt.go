@@ -29,19 +29,21 @@ const (
 
 // Claims represents the claims in a JWT token
 type Claims struct {
-	UserID uint      `json:"user_id"`
-	Type   TokenType `json:"type"`
+	UserID uint `json:"user_id"`
+	// WorkspaceID is the workspace the token acts in
+	WorkspaceID uint      `json:"workspace_id"`
+	Type        TokenType `json:"type"`
 	jwt.RegisteredClaims
 }
 
 // TokenService defines the interface for JWT operations
 type TokenService interface {
 	// GenerateAccessToken generates a new access token
-	GenerateAccessToken(userID uint) (string, error)
+	GenerateAccessToken(userID, workspaceID uint) (string, error)
 	// GenerateRefreshToken generates a new refresh token
-	GenerateRefreshToken(userID uint) (string, error)
-	// ValidateToken validates a token and returns the user ID
-	ValidateToken(tokenString string) (uint, TokenType, error)
+	GenerateRefreshToken(userID, workspaceID uint) (string, error)
+	// ValidateToken validates a token and returns its claims
+	ValidateToken(tokenString string) (*Claims, error)
 	// ParseToken parses a token without validation
 	ParseToken(tokenString string) (*Claims, error)
 }
@@ -79,22 +81,25 @@ func NewServiceWithConfig(config ServiceConfig) *Service {
 	}
 }
 
-// GenerateAccessToken generates a new access token for a user
-func (s *Service) GenerateAccessToken(userID uint) (string, error) {
-	return s.generateToken(userID, AccessToken, s.accessExpiry)
+// GenerateAccessToken generates a new access token for a user acting in a
+// workspace
+func (s *Service) GenerateAccessToken(userID, workspaceID uint) (string, error) {
+	return s.generateToken(userID, workspaceID, AccessToken, s.accessExpiry)
 }
 
-// GenerateRefreshToken generates a new refresh token for a user
-func (s *Service) GenerateRefreshToken(userID uint) (string, error) {
-	return s.generateToken(userID, RefreshToken, s.refreshExpiry)
+// GenerateRefreshToken generates a new refresh token for a user acting in a
+// workspace
+func (s *Service) GenerateRefreshToken(userID, workspaceID uint) (string, error) {
+	return s.generateToken(userID, workspaceID, RefreshToken, s.refreshExpiry)
 }
 
 // generateToken generates a token with the given parameters
-func (s *Service) generateToken(userID uint, tokenType TokenType, expiry time.Duration) (string, error) {
+func (s *Service) generateToken(userID, workspaceID uint, tokenType TokenType, expiry time.Duration) (string, error) {
 	now := time.Now()
 	claims := Claims{
-		UserID: userID,
-		Type:   tokenType,
+		UserID:      userID,
+		WorkspaceID: workspaceID,
+		Type:        tokenType,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -110,14 +115,18 @@ func (s *Service) generateToken(userID uint, tokenType TokenType, expiry time.Du
 	return tokenString, nil
 }
 
-// ValidateToken validates a token and returns the user ID and token type
-func (s *Service) ValidateToken(tokenString string) (uint, TokenType, error) {
+// ValidateToken validates a token and returns its claims. Tokens issued
+// before workspaces were introduced carry no workspace and are rejected.
+func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
 	claims, err := s.ParseToken(tokenString)
 	if err != nil {
-		return 0, "", err
+		return nil, err
+	}
+	if claims.WorkspaceID == 0 {
+		return nil, ErrInvalidToken
 	}
 
-	return claims.UserID, claims.Type, nil
+	return claims, nil
 }
 
 // ParseToken parses a token without validation
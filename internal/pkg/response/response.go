@@ -3,6 +3,8 @@ package response
 import (
 	"encoding/json"
 	"net/http"
+
+	"myapp/internal/validation"
 )
 
 // Response represents a standard API response
@@ -20,22 +22,6 @@ type Error struct {
 	Details interface{} `json:"details,omitempty"`
 }
 
-// ValidationError represents a validation error
-type ValidationError struct {
-	Field   string `json:"field"`
-	Message string `json:"message"`
-}
-
-// ValidationErrors represents a collection of validation errors
-type ValidationErrors struct {
-	Errors []ValidationError `json:"errors"`
-}
-
-// HasErrors returns true if there are any validation errors
-func (v *ValidationErrors) HasErrors() bool {
-	return len(v.Errors) > 0
-}
-
 // NewSuccess creates a new success response
 func NewSuccess(status int, data interface{}) *Response {
 	return &Response{
@@ -58,13 +44,18 @@ func NewError(status int, code, message string, details interface{}) *Response {
 	}
 }
 
-// NewValidationError creates a new validation error response
-func NewValidationError(errors []ValidationError) *Response {
+// NewValidationError creates a new validation error response. The status
+// code is taken from the validation errors themselves.
+func NewValidationError(errs validation.ValidationErrors) *Response {
+	status := errs.StatusCode
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
 	return NewError(
-		http.StatusBadRequest,
+		status,
 		"VALIDATION_ERROR",
 		"Validation failed",
-		ValidationErrors{Errors: errors},
+		errs,
 	)
 }
 
@@ -1,37 +0,0 @@
-package validation
-
-import "fmt"
-
-// ValidationError represents a validation error with field and message
-type ValidationError struct {
-	Field   string `json:"field"`
-	Message string `json:"message"`
-}
-
-// Error implements the error interface
-func (e *ValidationError) Error() string {
-	return fmt.Sprintf("%s: %s", e.Field, e.Message)
-}
-
-// ValidationErrors is a collection of validation errors
-type ValidationErrors struct {
-	Errors []ValidationError `json:"errors"`
-}
-
-// Error implements the error interface
-func (e *ValidationErrors) Error() string {
-	return "validation failed"
-}
-
-// Add adds a new validation error
-func (e *ValidationErrors) Add(field, message string) {
-	e.Errors = append(e.Errors, ValidationError{
-		Field:   field,
-		Message: message,
-	})
-}
-
-// HasErrors returns true if there are any validation errors
-func (e *ValidationErrors) HasErrors() bool {
-	return len(e.Errors) > 0
-}
@@ -0,0 +1,109 @@
+// Package portability reads and writes todos in the file formats used to
+// move data in and out of the application: CSV, a JSON array and
+// newline-delimited JSON. Writers stream; readers report problems with a
+// single row separately from problems with the file as a whole.
+package portability
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"myapp/internal/model"
+)
+
+// Format is a supported file format
+type Format string
+
+// Supported formats
+const (
+	CSV    Format = "csv"
+	JSON   Format = "json"
+	NDJSON Format = "ndjson"
+)
+
+// ErrUnknownFormat is returned for formats other than csv, json and ndjson
+var ErrUnknownFormat = errors.New("unknown format")
+
+// contentTypes maps formats to their media types
+var contentTypes = map[Format]string{
+	CSV:    "text/csv",
+	JSON:   "application/json",
+	NDJSON: "application/x-ndjson",
+}
+
+// ParseFormat parses a format name, case-insensitively
+func ParseFormat(name string) (Format, error) {
+	format := Format(strings.ToLower(name))
+	if _, ok := contentTypes[format]; !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownFormat, name)
+	}
+	return format, nil
+}
+
+// FormatForContentType returns the format of a media type such as text/csv,
+// ignoring parameters
+func FormatForContentType(contentType string) (Format, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		for format, t := range contentTypes {
+			if mediaType == t {
+				return format, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownFormat, contentType)
+}
+
+// ContentType returns the media type of the format
+func (f Format) ContentType() string {
+	return contentTypes[f] + "; charset=utf-8"
+}
+
+// Record is a todo as it appears in an exported file. ID and the timestamps
+// are informational: they are written on export and ignored on import.
+type Record struct {
+	ID          uint       `json:"id,omitempty"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	DueAt       *time.Time `json:"due_at"`
+	Recurrence  string     `json:"recurrence"`
+	Timezone    string     `json:"timezone"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// NewRecord returns the exported form of a todo
+func NewRecord(todo *model.Todo) Record {
+	createdAt, updatedAt := todo.CreatedAt.UTC(), todo.UpdatedAt.UTC()
+	record := Record{
+		ID:          todo.ID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Completed:   todo.Completed,
+		Recurrence:  todo.Recurrence,
+		Timezone:    todo.Timezone,
+		CreatedAt:   &createdAt,
+		UpdatedAt:   &updatedAt,
+	}
+	if todo.DueAt != nil {
+		dueAt := todo.DueAt.UTC()
+		record.DueAt = &dueAt
+	}
+	return record
+}
+
+// Todo returns the todo a record describes
+func (r Record) Todo() model.TodoUpdateRequest {
+	return model.TodoUpdateRequest{
+		Title:       r.Title,
+		Description: r.Description,
+		Completed:   r.Completed,
+		DueAt:       r.DueAt,
+		Recurrence:  r.Recurrence,
+		Timezone:    r.Timezone,
+	}
+}
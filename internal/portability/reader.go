@@ -0,0 +1,463 @@
+package portability
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"myapp/internal/model"
+)
+
+// codeInvalidFormat is the error code of rows whose values cannot be decoded
+const codeInvalidFormat = "invalid_format"
+
+// SyntaxError describes a file that cannot be read any further, as opposed
+// to a row that cannot be decoded
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Line == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// maxRowBytes bounds the bytes read for a single row, so that a file
+// without line breaks cannot make a Reader buffer it whole
+const maxRowBytes = 1 << 20
+
+// errRowTooLarge is returned by a rowBudget once a row exceeds maxRowBytes
+var errRowTooLarge = fmt.Errorf("row exceeds %d bytes", maxRowBytes)
+
+// Reader reads the rows of a file one at a time, so that files are read in
+// constant memory. Rows whose values cannot be decoded are returned with
+// their errors so that every problem in a file can be reported; a
+// *SyntaxError is returned if the file itself is malformed or has more than
+// model.MaxTodoImportRows rows, after which the Reader must not be used.
+type Reader struct {
+	budget *rowBudget
+	read   func() (model.TodoImportRow, error)
+	rows   int
+}
+
+// NewReader returns a Reader decoding r in the given format
+func NewReader(format Format, r io.Reader) (*Reader, error) {
+	budget := &rowBudget{r: r, remaining: maxRowBytes}
+	reader := &Reader{budget: budget}
+	switch format {
+	case CSV:
+		reader.read = newCSVReader(budget).read
+	case NDJSON:
+		reader.read = newNDJSONReader(budget).read
+	case JSON:
+		reader.read = newJSONReader(budget).read
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+	return reader, nil
+}
+
+// Read returns the next row, or io.EOF after the last one
+func (r *Reader) Read() (model.TodoImportRow, error) {
+	r.budget.reset()
+	row, err := r.read()
+	if err != nil {
+		return row, err
+	}
+	if r.rows == model.MaxTodoImportRows {
+		return model.TodoImportRow{}, tooManyRows(row.Line)
+	}
+	r.rows++
+	return row, nil
+}
+
+// rowBudget limits the bytes read from r until the next reset. Decoders
+// read ahead of the row they return, so the budget is approximate.
+type rowBudget struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (b *rowBudget) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, errRowTooLarge
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.r.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *rowBudget) reset() {
+	b.remaining = maxRowBytes
+}
+
+// readError converts an error reading the underlying file into a
+// *SyntaxError if the file is at fault
+func readError(line int, err error) error {
+	if errors.Is(err, errRowTooLarge) {
+		return &SyntaxError{Line: line, Msg: errRowTooLarge.Error()}
+	}
+	return err
+}
+
+// csvReader reads a CSV file with a header row. Columns are matched by
+// name; title is required and the exported informational columns are
+// accepted and ignored.
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+	index  map[string]int
+	line   int
+}
+
+func newCSVReader(r io.Reader) *csvReader {
+	reader := csv.NewReader(skipBOM(r))
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	return &csvReader{reader: reader}
+}
+
+func (c *csvReader) read() (model.TodoImportRow, error) {
+	if c.header == nil {
+		if err := c.readHeader(); err != nil {
+			return model.TodoImportRow{}, err
+		}
+	}
+
+	fields, err := c.reader.Read()
+	if err == io.EOF {
+		return model.TodoImportRow{}, io.EOF
+	}
+	if err != nil {
+		// Errors other than parse errors happen after the last row read
+		return model.TodoImportRow{}, csvError(c.line+1, err)
+	}
+	line, _ := c.reader.FieldPos(0)
+	c.line = line
+
+	row := model.TodoImportRow{Line: line}
+	if len(fields) != len(c.header) {
+		row.Errors = append(row.Errors, rowError(line, "", fmt.Sprintf("row has %d fields, header has %d", len(fields), len(c.header))))
+		return row, nil
+	}
+
+	cell := func(name string) string {
+		if i, ok := c.index[name]; ok {
+			return fields[i]
+		}
+		return ""
+	}
+	row.Todo = model.TodoUpdateRequest{
+		Title:       unescapeCell(cell("title")),
+		Description: unescapeCell(cell("description")),
+		Recurrence:  unescapeCell(cell("recurrence")),
+		Timezone:    strings.TrimSpace(cell("timezone")),
+	}
+	if v := strings.TrimSpace(cell("completed")); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			row.Errors = append(row.Errors, rowError(line, "completed", "completed must be true or false"))
+		}
+		row.Todo.Completed = completed
+	}
+	if v := strings.TrimSpace(cell("due_at")); v != "" {
+		dueAt, err := parseTime(v)
+		if err != nil {
+			row.Errors = append(row.Errors, rowError(line, "due_at", "due_at must be an RFC 3339 date-time or a YYYY-MM-DD date"))
+		}
+		row.Todo.DueAt = dueAt
+	}
+	return row, nil
+}
+
+// readHeader reads and checks the header row
+func (c *csvReader) readHeader() error {
+	header, err := c.reader.Read()
+	if err == io.EOF {
+		return &SyntaxError{Msg: "file is empty"}
+	}
+	if err != nil {
+		return csvError(1, err)
+	}
+	c.line = 1
+
+	c.header = slices.Clone(header)
+	c.index = make(map[string]int, len(header))
+	for i, name := range c.header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !isColumn(name) {
+			return &SyntaxError{Line: 1, Msg: fmt.Sprintf("unknown column %q", c.header[i])}
+		}
+		if _, ok := c.index[name]; ok {
+			return &SyntaxError{Line: 1, Msg: fmt.Sprintf("duplicate column %q", c.header[i])}
+		}
+		c.index[name] = i
+	}
+	if _, ok := c.index["title"]; !ok {
+		return &SyntaxError{Line: 1, Msg: `missing column "title"`}
+	}
+	return nil
+}
+
+// ndjsonReader reads one JSON object per line. Blank lines are skipped.
+type ndjsonReader struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	return &ndjsonReader{reader: bufio.NewReader(r)}
+}
+
+func (n *ndjsonReader) read() (model.TodoImportRow, error) {
+	for {
+		text, err := n.reader.ReadBytes('\n')
+		if len(text) == 0 && err == io.EOF {
+			return model.TodoImportRow{}, io.EOF
+		}
+		n.line++
+		if err != nil && err != io.EOF {
+			return model.TodoImportRow{}, readError(n.line, err)
+		}
+
+		text = bytes.TrimSpace(text)
+		if n.line == 1 {
+			text = bytes.TrimPrefix(text, []byte("\ufeff"))
+		}
+		if len(text) == 0 {
+			if err == io.EOF {
+				return model.TodoImportRow{}, io.EOF
+			}
+			continue
+		}
+
+		row := model.TodoImportRow{Line: n.line}
+		var record Record
+		if err := decodeRecord(bytes.NewReader(text), &record); err != nil {
+			row.Errors = append(row.Errors, jsonRowError(n.line, err))
+		} else {
+			row.Todo = record.Todo()
+		}
+		return row, nil
+	}
+}
+
+// jsonReader reads a JSON array of objects, decoding one element at a time
+// so that an element of the wrong shape only fails its own row
+type jsonReader struct {
+	lines   *lineCounter
+	dec     *json.Decoder
+	started bool
+}
+
+func newJSONReader(r io.Reader) *jsonReader {
+	lines := newLineCounter(skipBOM(r))
+	return &jsonReader{lines: lines, dec: json.NewDecoder(lines)}
+}
+
+func (j *jsonReader) read() (model.TodoImportRow, error) {
+	if !j.started {
+		j.started = true
+		if tok, err := j.dec.Token(); err != nil || tok != json.Delim('[') {
+			if errors.Is(err, errRowTooLarge) {
+				return model.TodoImportRow{}, readError(1, err)
+			}
+			return model.TodoImportRow{}, &SyntaxError{Line: 1, Msg: "file must contain a JSON array"}
+		}
+	}
+
+	if !j.dec.More() {
+		if _, err := j.dec.Token(); err != nil {
+			return model.TodoImportRow{}, &SyntaxError{Line: j.lines.lineAt(j.lines.read), Msg: "unterminated JSON array"}
+		}
+		return model.TodoImportRow{}, io.EOF
+	}
+
+	// More has buffered the start of the element, unless the input ended
+	skip, more := skipSeparator(j.dec.Buffered())
+	line := j.lines.lineAt(j.dec.InputOffset() + skip)
+	var raw json.RawMessage
+	if err := j.dec.Decode(&raw); err != nil {
+		if errors.Is(err, errRowTooLarge) {
+			return model.TodoImportRow{}, readError(line, err)
+		}
+		if !more {
+			return model.TodoImportRow{}, &SyntaxError{Line: line, Msg: "unterminated JSON array"}
+		}
+		return model.TodoImportRow{}, &SyntaxError{Line: line, Msg: "invalid JSON"}
+	}
+
+	row := model.TodoImportRow{Line: line}
+	var record Record
+	if err := decodeRecord(bytes.NewReader(raw), &record); err != nil {
+		row.Errors = append(row.Errors, jsonRowError(line, err))
+	} else {
+		row.Todo = record.Todo()
+	}
+	return row, nil
+}
+
+// skipBOM returns a reader of r without a leading UTF-8 byte order mark.
+// Nothing is read from r before the first read.
+func skipBOM(r io.Reader) io.Reader {
+	return &bomReader{r: bufio.NewReader(r)}
+}
+
+type bomReader struct {
+	r       *bufio.Reader
+	checked bool
+}
+
+func (b *bomReader) Read(p []byte) (int, error) {
+	if !b.checked {
+		b.checked = true
+		if bom, err := b.r.Peek(3); err == nil && string(bom) == "\ufeff" {
+			_, _ = b.r.Discard(3)
+		}
+	}
+	return b.r.Read(p)
+}
+
+// decodeRecord decodes a single JSON object, rejecting unknown fields
+func decodeRecord(r io.Reader, record *Record) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(record); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("row must contain a single JSON object")
+	}
+	return nil
+}
+
+// jsonRowError describes why a JSON row could not be decoded
+func jsonRowError(line int, err error) model.TodoImportError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return rowError(line, typeErr.Field, fmt.Sprintf("%s must be of type %s", typeErr.Field, typeErr.Type))
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return rowError(line, "", "invalid JSON")
+	}
+	var parseErr *time.ParseError
+	if errors.As(err, &parseErr) {
+		return rowError(line, "", "times must be RFC 3339 date-times")
+	}
+	return rowError(line, "", strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// rowError returns an error of a row whose value cannot be decoded
+func rowError(line int, field, message string) model.TodoImportError {
+	return model.TodoImportError{Line: line, Field: field, Code: codeInvalidFormat, Message: message}
+}
+
+// csvError converts an error from encoding/csv, which stops reading
+func csvError(line int, err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		if errors.Is(parseErr.Err, errRowTooLarge) {
+			return readError(parseErr.StartLine, parseErr.Err)
+		}
+		return &SyntaxError{Line: parseErr.StartLine, Msg: parseErr.Err.Error()}
+	}
+	return readError(line, err)
+}
+
+func tooManyRows(line int) error {
+	return &SyntaxError{Line: line, Msg: fmt.Sprintf("file has more than %d rows", model.MaxTodoImportRows)}
+}
+
+// isColumn reports whether name is a known CSV column
+func isColumn(name string) bool {
+	for _, column := range columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTime parses an RFC 3339 date-time or a date, which is taken as
+// midnight UTC
+func parseTime(v string) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		if t, err = time.Parse(time.DateOnly, v); err != nil {
+			return nil, err
+		}
+	}
+	t = t.UTC()
+	return &t, nil
+}
+
+// skipSeparator returns the number of bytes of whitespace and separating
+// comma that buffered starts with, and whether anything follows them
+func skipSeparator(buffered io.Reader) (int64, bool) {
+	var n int64
+	br := bufio.NewReader(buffered)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return n, false
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n', ',':
+			n++
+		default:
+			return n, true
+		}
+	}
+}
+
+// lineCounter reads from r and maps increasing byte offsets of what was
+// read to line numbers. Only the line breaks between the last offset asked
+// for and the end of what was read are kept.
+type lineCounter struct {
+	r      io.Reader
+	read   int64
+	breaks []int64
+	line   int
+}
+
+func newLineCounter(r io.Reader) *lineCounter {
+	return &lineCounter{r: r, line: 1}
+}
+
+func (c *lineCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			c.breaks = append(c.breaks, c.read+int64(i))
+		}
+	}
+	c.read += int64(n)
+	return n, err
+}
+
+// lineAt returns the line of the byte at off. Offsets must not decrease
+// between calls.
+func (c *lineCounter) lineAt(off int64) int {
+	i := 0
+	for i < len(c.breaks) && c.breaks[i] < off {
+		i++
+	}
+	c.line += i
+	c.breaks = c.breaks[i:]
+	return c.line
+}
@@ -0,0 +1,170 @@
+package portability
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"myapp/internal/model"
+)
+
+// readAll reads every row of data, stopping at the first error
+func readAll(t *testing.T, format Format, data string) ([]model.TodoImportRow, error) {
+	t.Helper()
+	reader, err := NewReader(format, strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []model.TodoImportRow
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestReaderRows(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		data   string
+		titles []string
+		lines  []int
+		errors []int
+	}{
+		{
+			name:   "csv",
+			format: CSV,
+			data:   "\ufefftitle,completed,due_at\nFirst,true,2026-01-02\n\"Multi\nline\",maybe,\nThird,,not a date\n",
+			titles: []string{"First", "Multi\nline", "Third"},
+			lines:  []int{2, 3, 5},
+			errors: []int{0, 1, 1},
+		},
+		{
+			name:   "ndjson",
+			format: NDJSON,
+			data:   "\ufeff{\"title\":\"First\"}\n\n{\"title\":1}\r\n{\"title\":\"Third\",\"other\":true}\n{\"title\":\"Fourth\"}",
+			titles: []string{"First", "", "", "Fourth"},
+			lines:  []int{1, 3, 4, 5},
+			errors: []int{0, 1, 1, 0},
+		},
+		{
+			name:   "json",
+			format: JSON,
+			data:   "[\n  {\"title\": \"First\"},\n  {\n    \"title\": 1\n  },\n\n  {\"title\": \"Third\"}, {\"title\": \"Fourth\"}\n]\n",
+			titles: []string{"First", "", "Third", "Fourth"},
+			lines:  []int{2, 3, 7, 7},
+			errors: []int{0, 1, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := readAll(t, tt.format, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != len(tt.titles) {
+				t.Fatalf("got %d rows, want %d", len(rows), len(tt.titles))
+			}
+			for i, row := range rows {
+				if row.Todo.Title != tt.titles[i] || row.Line != tt.lines[i] || len(row.Errors) != tt.errors[i] {
+					t.Errorf("row %d = line %d, %q, %v; want line %d, %q, %d errors",
+						i, row.Line, row.Todo.Title, row.Errors, tt.lines[i], tt.titles[i], tt.errors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReaderSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		data   string
+		rows   int
+		line   int
+		msg    string
+	}{
+		{"empty csv", CSV, "", 0, 0, "file is empty"},
+		{"unknown column", CSV, "title,colour\n", 0, 1, `unknown column "colour"`},
+		{"missing title", CSV, "description\nx\n", 0, 1, `missing column "title"`},
+		{"bare quote", CSV, "title\nFirst\n\"Second\nThird\n", 1, 3, `extraneous or missing " in quoted-field`},
+		{"not an array", JSON, `{"title":"First"}`, 0, 1, "file must contain a JSON array"},
+		{"invalid element", JSON, "[\n{\"title\":\"First\"},\n{\"title\":}\n]", 1, 3, "invalid JSON"},
+		{"unterminated array", JSON, "[\n{\"title\":\"First\"}\n", 1, 3, "unterminated JSON array"},
+		{"long ndjson row", NDJSON, "{\"title\":\"First\"}\n{\"title\":\"" + strings.Repeat("x", 2*maxRowBytes) + "\"}\n", 1, 2, errRowTooLarge.Error()},
+		{"long csv row", CSV, "title\nFirst\n" + strings.Repeat("x", 2*maxRowBytes) + "\n", 1, 3, errRowTooLarge.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := readAll(t, tt.format, tt.data)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("err = %v, want a *SyntaxError", err)
+			}
+			if len(rows) != tt.rows || syntaxErr.Line != tt.line || syntaxErr.Msg != tt.msg {
+				t.Fatalf("got %d rows and %v; want %d rows and line %d: %s", len(rows), err, tt.rows, tt.line, tt.msg)
+			}
+		})
+	}
+}
+
+func TestReaderLimitsRows(t *testing.T) {
+	var data strings.Builder
+	data.WriteString("title\n")
+	for i := 0; i <= model.MaxTodoImportRows; i++ {
+		fmt.Fprintf(&data, "Todo %d\n", i)
+	}
+
+	rows, err := readAll(t, CSV, data.String())
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || len(rows) != model.MaxTodoImportRows {
+		t.Fatalf("got %d rows and %v; want %d rows and a *SyntaxError", len(rows), err, model.MaxTodoImportRows)
+	}
+}
+
+func TestReaderStreams(t *testing.T) {
+	files := map[Format][2]string{
+		CSV:    {"title\nFirst\n", "Second\n"},
+		NDJSON: {"{\"title\":\"First\"}\n", "{\"title\":\"Second\"}\n"},
+		JSON:   {"[{\"title\":\"First\"},", "{\"title\":\"Second\"}]"},
+	}
+	for format, parts := range files {
+		t.Run(string(format), func(t *testing.T) {
+			first, rest := parts[0], parts[1]
+
+			pr, pw := io.Pipe()
+			reader, err := NewReader(format, pr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			go func() {
+				io.WriteString(pw, first)
+			}()
+
+			// The first row is returned before the rest of the file is sent
+			row, err := reader.Read()
+			if err != nil || row.Todo.Title != "First" {
+				t.Fatalf("Read = %+v, %v; want the first row", row, err)
+			}
+
+			go func() {
+				io.WriteString(pw, rest)
+				pw.Close()
+			}()
+			if row, err := reader.Read(); err != nil || row.Todo.Title != "Second" {
+				t.Fatalf("Read = %+v, %v; want the second row", row, err)
+			}
+			if _, err := reader.Read(); err != io.EOF {
+				t.Fatalf("Read = %v, want io.EOF", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package portability
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"myapp/internal/model"
+)
+
+// columns are the CSV columns, in order
+var columns = []string{"id", "title", "description", "completed", "due_at", "recurrence", "timezone", "created_at", "updated_at"}
+
+// Writer streams todos to a file. Close must be called to complete the file.
+type Writer interface {
+	Write(todo *model.Todo) error
+	Close() error
+}
+
+// NewWriter creates a Writer for the format writing to w
+func NewWriter(format Format, w io.Writer) Writer {
+	switch format {
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}
+	case NDJSON:
+		buf := bufio.NewWriter(w)
+		return &ndjsonWriter{buf: buf, enc: json.NewEncoder(buf)}
+	default:
+		return &jsonWriter{buf: bufio.NewWriter(w)}
+	}
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) Write(todo *model.Todo) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	r := NewRecord(todo)
+	return c.w.Write([]string{
+		strconv.FormatUint(uint64(r.ID), 10),
+		escapeCell(r.Title),
+		escapeCell(r.Description),
+		strconv.FormatBool(r.Completed),
+		formatTime(r.DueAt),
+		escapeCell(r.Recurrence),
+		r.Timezone,
+		formatTime(r.CreatedAt),
+		formatTime(r.UpdatedAt),
+	})
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// writeHeader writes the header row once, so that an empty export still
+// has one
+func (c *csvWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.w.Write(columns)
+}
+
+type ndjsonWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(todo *model.Todo) error {
+	return n.enc.Encode(NewRecord(todo))
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.buf.Flush()
+}
+
+// jsonWriter writes a JSON array one element per line
+type jsonWriter struct {
+	buf   *bufio.Writer
+	count int
+}
+
+func (j *jsonWriter) Write(todo *model.Todo) error {
+	element, err := json.Marshal(NewRecord(todo))
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if j.count == 0 {
+		sep = "[\n"
+	}
+	j.count++
+	if _, err := j.buf.WriteString(sep); err != nil {
+		return err
+	}
+	_, err = j.buf.Write(element)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	end := "\n]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+	if _, err := j.buf.WriteString(end); err != nil {
+		return err
+	}
+	return j.buf.Flush()
+}
+
+// formatTime formats an optional time as RFC 3339, or as an empty cell
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// escapeCell defuses cells that spreadsheet applications would evaluate as
+// formulas by prefixing them with an apostrophe. The reader removes it again.
+func escapeCell(s string) string {
+	if isFormula(s) {
+		return "'" + s
+	}
+	return s
+}
+
+// unescapeCell reverses escapeCell
+func unescapeCell(s string) string {
+	if strings.HasPrefix(s, "'") && isFormula(s[1:]) {
+		return s[1:]
+	}
+	return s
+}
+
+// isFormula reports whether a cell starts with a character that makes
+// spreadsheet applications treat it as a formula
+func isFormula(s string) bool {
+	return s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0]))
+}
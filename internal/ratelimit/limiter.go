@@ -0,0 +1,104 @@
+// Package ratelimit limits how often clients may call the API. Each client,
+// identified by a key such as its user ID or IP address, has a token bucket
+// that holds up to Limit.Requests tokens and refills over Limit.Window.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limit is a rate limit: Requests per Window, with bursts of up to Requests
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Result describes the outcome of Allow
+type Result struct {
+	// Allowed reports whether the request may proceed
+	Allowed bool
+	// Limit is the number of requests allowed per window
+	Limit int
+	// Remaining is the number of requests the client may still make now
+	Remaining int
+	// RetryAfter is how long a refused client has to wait for a token
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps a token bucket per key in memory. A zero Limit disables
+// limiting. It is safe for concurrent use.
+type Limiter struct {
+	mu        sync.Mutex
+	limit     Limit
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewLimiter creates a Limiter enforcing limit
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// SetLimit changes the limit. Buckets are reset, so that no client is held
+// to a limit that no longer applies.
+func (l *Limiter) SetLimit(limit Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit != l.limit {
+		l.limit = limit
+		l.buckets = make(map[string]*bucket)
+	}
+}
+
+// Allow takes a token from key's bucket if one is available
+func (l *Limiter) Allow(key string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit := l.limit
+	if limit.Requests <= 0 || limit.Window <= 0 {
+		return Result{Allowed: true}
+	}
+
+	now := time.Now()
+	l.sweep(now)
+
+	rate := float64(limit.Requests) / limit.Window.Seconds()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(float64(limit.Requests), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return Result{Limit: limit.Requests, RetryAfter: wait}
+	}
+	b.tokens--
+	return Result{Allowed: true, Limit: limit.Requests, Remaining: int(b.tokens)}
+}
+
+// sweep drops buckets that have refilled completely, since they are
+// indistinguishable from new ones. It runs at most once per window.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.limit.Window {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.limit.Window {
+			delete(l.buckets, key)
+		}
+	}
+}
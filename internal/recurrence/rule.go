@@ -0,0 +1,366 @@
+// Package recurrence implements the subset of iCalendar (RFC 5545) RRULE
+// recurrence rules used for repeating todos: FREQ=DAILY, WEEKLY or MONTHLY
+// with INTERVAL, BYDAY, UNTIL and COUNT. Weeks start on Monday.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the unit a rule repeats in
+type Frequency string
+
+// Supported frequencies
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// maxInterval bounds INTERVAL to keep expansion cheap
+const maxInterval = 1000
+
+// maxPeriods bounds the number of days, weeks or months scanned while
+// expanding a rule, so that a rule whose BYDAY never matches terminates
+const maxPeriods = 100000
+
+// ErrInvalidRule is wrapped by every error returned by Parse
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// WeekdayNum is an entry of BYDAY. A non-zero N selects the Nth weekday of
+// the month, counting from the end when negative, and is only valid in
+// monthly rules.
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// Rule is a parsed recurrence rule. The series it describes starts at a
+// DTSTART supplied when expanding it.
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []WeekdayNum
+	// Until is the last instant an occurrence may fall on. When UntilDate
+	// is set it was given as a date and covers that whole day in the
+	// series' time zone.
+	Until     *time.Time
+	UntilDate bool
+	// Count is the total number of occurrences, or 0 for no limit
+	Count int
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+const (
+	untilDateTimeLayout = "20060102T150405Z"
+	untilDateLayout     = "20060102"
+)
+
+// Parse parses an RRULE value such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR".
+// An "RRULE:" prefix is accepted.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("%w: empty rule", ErrInvalidRule)
+	}
+
+	rule := &Rule{Interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: %s given more than once", ErrInvalidRule, key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			rule.Freq = Frequency(value)
+			if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly {
+				err = fmt.Errorf("FREQ must be DAILY, WEEKLY or MONTHLY")
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+			if err != nil || rule.Interval < 1 || rule.Interval > maxInterval {
+				err = fmt.Errorf("INTERVAL must be between 1 and %d", maxInterval)
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(value)
+			if err != nil || rule.Count < 1 {
+				err = fmt.Errorf("COUNT must be a positive integer")
+			}
+		case "UNTIL":
+			err = rule.parseUntil(value)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(value)
+		case "WKST":
+			if value != "MO" {
+				err = fmt.Errorf("only WKST=MO is supported")
+			}
+		default:
+			err = fmt.Errorf("%s is not supported", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL cannot both be given", ErrInvalidRule)
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != Monthly {
+			return nil, fmt.Errorf("%w: numbered BYDAY is only supported with FREQ=MONTHLY", ErrInvalidRule)
+		}
+	}
+	return rule, nil
+}
+
+func (r *Rule) parseUntil(value string) error {
+	if t, err := time.Parse(untilDateTimeLayout, value); err == nil {
+		r.Until = &t
+		return nil
+	}
+	if t, err := time.Parse(untilDateLayout, value); err == nil {
+		r.Until, r.UntilDate = &t, true
+		return nil
+	}
+	return fmt.Errorf("UNTIL must be a date (YYYYMMDD) or UTC date-time (YYYYMMDDTHHMMSSZ)")
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY value %q", item)
+		}
+		weekday, ok := weekdayCodes[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid BYDAY value %q", item)
+		}
+		n := 0
+		if prefix := item[:len(item)-2]; prefix != "" {
+			var err error
+			if n, err = strconv.Atoi(prefix); err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("invalid BYDAY value %q", item)
+			}
+		}
+		days = append(days, WeekdayNum{Weekday: weekday, N: n})
+	}
+	return days, nil
+}
+
+// String returns the rule in canonical RRULE form, without the "RRULE:"
+// prefix
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			code := strings.ToUpper(day.Weekday.String()[:2])
+			if day.N != 0 {
+				code = strconv.Itoa(day.N) + code
+			}
+			days = append(days, code)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Until != nil {
+		if r.UntilDate {
+			parts = append(parts, "UNTIL="+r.Until.Format(untilDateLayout))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilDateTimeLayout))
+		}
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrences returns up to n occurrences strictly after after, of the
+// series that starts at start. Occurrences keep the wall-clock time of
+// start in start's location, so a daily 09:00 todo stays at 09:00 across
+// daylight saving changes. COUNT is counted from start.
+func (r *Rule) Occurrences(start, after time.Time, n int) []time.Time {
+	var occurrences []time.Time
+	if n <= 0 {
+		return occurrences
+	}
+	r.each(start, func(t time.Time) bool {
+		if t.After(after) {
+			occurrences = append(occurrences, t)
+		}
+		return len(occurrences) < n
+	})
+	return occurrences
+}
+
+// Next returns the first occurrence strictly after after, or false if the
+// series has ended by then
+func (r *Rule) Next(start, after time.Time) (time.Time, bool) {
+	occurrences := r.Occurrences(start, after, 1)
+	if len(occurrences) == 0 {
+		return time.Time{}, false
+	}
+	return occurrences[0], true
+}
+
+// each calls fn with the occurrences of the series in order until fn
+// returns false or the series ends
+func (r *Rule) each(start time.Time, fn func(time.Time) bool) {
+	loc := start.Location()
+	until := r.until(loc)
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	count := 0
+	for period := 0; period < maxPeriods; period++ {
+		for _, t := range r.candidates(start, period*interval) {
+			if t.Before(start) {
+				continue
+			}
+			if until != nil && t.After(*until) {
+				return
+			}
+			count++
+			if !fn(t) || (r.Count > 0 && count >= r.Count) {
+				return
+			}
+		}
+	}
+}
+
+// until returns the last instant an occurrence may fall on, resolving a
+// date-only UNTIL to the end of that day in loc
+func (r *Rule) until(loc *time.Location) *time.Time {
+	if r.Until == nil {
+		return nil
+	}
+	if !r.UntilDate {
+		return r.Until
+	}
+	y, m, d := r.Until.Date()
+	end := time.Date(y, m, d+1, 0, 0, 0, 0, loc).Add(-time.Nanosecond)
+	return &end
+}
+
+// candidates returns the occurrences of the period offset periods after the
+// one containing start, in order
+func (r *Rule) candidates(start time.Time, offset int) []time.Time {
+	loc := start.Location()
+	y, m, d := start.Date()
+	hour, minute, sec := start.Clock()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, minute, sec, 0, loc)
+	}
+
+	switch r.Freq {
+	case Daily:
+		t := at(y, m, d+offset)
+		if len(r.ByDay) > 0 && !r.matchesWeekday(t.Weekday()) {
+			return nil
+		}
+		return []time.Time{t}
+
+	case Weekly:
+		monday := d - mondayOffset(start.Weekday()) + 7*offset
+		weekdays := []time.Weekday{start.Weekday()}
+		if len(r.ByDay) > 0 {
+			weekdays = weekdays[:0]
+			for _, day := range r.ByDay {
+				weekdays = append(weekdays, day.Weekday)
+			}
+		}
+		var ts []time.Time
+		for _, weekday := range weekdays {
+			ts = append(ts, at(y, m, monday+mondayOffset(weekday)))
+		}
+		return sortUnique(ts)
+
+	case Monthly:
+		first := time.Date(y, m+time.Month(offset), 1, 0, 0, 0, 0, loc)
+		year, month := first.Year(), first.Month()
+		daysInMonth := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+
+		if len(r.ByDay) == 0 {
+			// Months without the start's day of month are skipped
+			if d > daysInMonth {
+				return nil
+			}
+			return []time.Time{at(year, month, d)}
+		}
+
+		var ts []time.Time
+		for day := 1; day <= daysInMonth; day++ {
+			weekday := time.Date(year, month, day, 0, 0, 0, 0, loc).Weekday()
+			for _, byDay := range r.ByDay {
+				if byDay.Weekday != weekday {
+					continue
+				}
+				nth := (day-1)/7 + 1
+				nthFromEnd := -((daysInMonth-day)/7 + 1)
+				if byDay.N == 0 || byDay.N == nth || byDay.N == nthFromEnd {
+					ts = append(ts, at(year, month, day))
+				}
+			}
+		}
+		return sortUnique(ts)
+	}
+	return nil
+}
+
+// matchesWeekday reports whether BYDAY includes weekday
+func (r *Rule) matchesWeekday(weekday time.Weekday) bool {
+	for _, day := range r.ByDay {
+		if day.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// mondayOffset returns the number of days weekday falls after Monday
+func mondayOffset(weekday time.Weekday) int {
+	return (int(weekday) + 6) % 7
+}
+
+// sortUnique sorts ts and removes duplicates
+func sortUnique(ts []time.Time) []time.Time {
+	sort.Slice(ts, func(i, j int) bool { return ts[i].Before(ts[j]) })
+	unique := ts[:0]
+	for i, t := range ts {
+		if i == 0 || !t.Equal(ts[i-1]) {
+			unique = append(unique, t)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,188 @@
+package recurrence
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR"},
+		{"freq=weekly;byday=tu;wkst=mo", "FREQ=WEEKLY;BYDAY=TU"},
+		{"FREQ=DAILY;INTERVAL=1;COUNT=5", "FREQ=DAILY;COUNT=5"},
+		{"FREQ=MONTHLY;BYDAY=-1FR,2MO", "FREQ=MONTHLY;BYDAY=-1FR,2MO"},
+		{"FREQ=DAILY;UNTIL=20261231", "FREQ=DAILY;UNTIL=20261231"},
+		{"FREQ=DAILY;UNTIL=20261231T235959Z", "FREQ=DAILY;UNTIL=20261231T235959Z"},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.rule)
+		if err != nil {
+			t.Errorf("Parse(%q) = %v", tt.rule, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		rule    string
+		message string
+	}{
+		{"", "empty rule"},
+		{"INTERVAL=2", "FREQ is required"},
+		{"FREQ=YEARLY", "FREQ must be"},
+		{"FREQ=DAILY;FREQ=WEEKLY", "more than once"},
+		{"FREQ=DAILY;INTERVAL=0", "INTERVAL must be"},
+		{"FREQ=DAILY;INTERVAL=1001", "INTERVAL must be"},
+		{"FREQ=DAILY;COUNT=-1", "COUNT must be"},
+		{"FREQ=DAILY;COUNT=2;UNTIL=20261231", "cannot both"},
+		{"FREQ=DAILY;UNTIL=2026-12-31", "UNTIL must be"},
+		{"FREQ=DAILY;UNTIL=20261231T235959", "UNTIL must be"},
+		{"FREQ=WEEKLY;BYDAY=XX", "invalid BYDAY"},
+		{"FREQ=MONTHLY;BYDAY=6MO", "invalid BYDAY"},
+		{"FREQ=MONTHLY;BYDAY=0MO", "invalid BYDAY"},
+		{"FREQ=WEEKLY;BYDAY=1MO", "only supported with FREQ=MONTHLY"},
+		{"FREQ=WEEKLY;WKST=SU", "WKST"},
+		{"FREQ=DAILY;BYHOUR=9", "not supported"},
+		{"FREQ=DAILY;COUNT", "malformed"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.rule)
+		if !errors.Is(err, ErrInvalidRule) || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("Parse(%q) = %v, want ErrInvalidRule mentioning %q", tt.rule, err, tt.message)
+		}
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Thursday
+	start := time.Date(2026, time.January, 1, 9, 0, 0, 0, berlin)
+
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		after time.Time
+		n     int
+		want  []string
+	}{
+		{"daily", "FREQ=DAILY", start, start.Add(-time.Second), 3,
+			[]string{"2026-01-01 09:00 CET", "2026-01-02 09:00 CET", "2026-01-03 09:00 CET"}},
+		{"strictly after", "FREQ=DAILY", start, start, 2,
+			[]string{"2026-01-02 09:00 CET", "2026-01-03 09:00 CET"}},
+		{"interval", "FREQ=DAILY;INTERVAL=3", start, start, 2,
+			[]string{"2026-01-04 09:00 CET", "2026-01-07 09:00 CET"}},
+		{"count counted from start", "FREQ=DAILY;COUNT=3", start, start.AddDate(0, 0, 1), 5,
+			[]string{"2026-01-03 09:00 CET"}},
+		{"until date covers the day", "FREQ=DAILY;UNTIL=20260103", start, start, 5,
+			[]string{"2026-01-02 09:00 CET", "2026-01-03 09:00 CET"}},
+		{"until date-time", "FREQ=DAILY;UNTIL=20260103T075959Z", start, start, 5,
+			[]string{"2026-01-02 09:00 CET"}},
+		{"daily by day", "FREQ=DAILY;BYDAY=SA,SU", start, start, 3,
+			[]string{"2026-01-03 09:00 CET", "2026-01-04 09:00 CET", "2026-01-10 09:00 CET"}},
+		{"weekly on the start's weekday", "FREQ=WEEKLY", start, start, 2,
+			[]string{"2026-01-08 09:00 CET", "2026-01-15 09:00 CET"}},
+		{"weekly by day skips days before start", "FREQ=WEEKLY;BYDAY=MO,FR", start, start.Add(-time.Second), 3,
+			[]string{"2026-01-02 09:00 CET", "2026-01-05 09:00 CET", "2026-01-09 09:00 CET"}},
+		{"biweekly by day", "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH", start, start, 3,
+			[]string{"2026-01-13 09:00 CET", "2026-01-15 09:00 CET", "2026-01-27 09:00 CET"}},
+		{"monthly skips short months", "FREQ=MONTHLY", time.Date(2026, time.January, 31, 9, 0, 0, 0, berlin), start, 3,
+			[]string{"2026-01-31 09:00 CET", "2026-03-31 09:00 CEST", "2026-05-31 09:00 CEST"}},
+		{"last Friday", "FREQ=MONTHLY;BYDAY=-1FR", start, start, 3,
+			[]string{"2026-01-30 09:00 CET", "2026-02-27 09:00 CET", "2026-03-27 09:00 CET"}},
+		{"second Monday and first Thursday", "FREQ=MONTHLY;BYDAY=2MO,1TH", start, start, 3,
+			[]string{"2026-01-12 09:00 CET", "2026-02-05 09:00 CET", "2026-02-09 09:00 CET"}},
+		{"spring forward keeps wall-clock time", "FREQ=DAILY",
+			time.Date(2026, time.March, 28, 9, 0, 0, 0, berlin), time.Date(2026, time.March, 28, 9, 0, 0, 0, berlin), 2,
+			[]string{"2026-03-29 09:00 CEST", "2026-03-30 09:00 CEST"}},
+		{"fall back keeps wall-clock time", "FREQ=WEEKLY;BYDAY=SA,SU",
+			time.Date(2026, time.October, 24, 23, 30, 0, 0, berlin), time.Date(2026, time.October, 24, 23, 30, 0, 0, berlin), 2,
+			[]string{"2026-10-25 23:30 CET", "2026-10-31 23:30 CET"}},
+		{"other zone", "FREQ=DAILY;COUNT=2",
+			time.Date(2026, time.March, 7, 8, 0, 0, 0, newYork), time.Date(2026, time.March, 7, 0, 0, 0, 0, newYork), 5,
+			[]string{"2026-03-07 08:00 EST", "2026-03-08 08:00 EDT"}},
+		{"none requested", "FREQ=DAILY", start, start, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, o := range rule.Occurrences(tt.start, tt.after, tt.n) {
+				got = append(got, o.Format("2006-01-02 15:04 MST"))
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Fatalf("Occurrences = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOccurrencesAcrossDSTKeepTheDuration(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, err := Parse("FREQ=DAILY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, time.March, 28, 9, 0, 0, 0, berlin)
+	next, ok := rule.Next(start, start)
+	if !ok {
+		t.Fatal("Next returned no occurrence")
+	}
+	// The day the clocks go forward is an hour short
+	if d := next.Sub(start); d != 23*time.Hour {
+		t.Fatalf("occurrence after the DST change is %v later, want 23h", d)
+	}
+}
+
+func TestNextAfterEnd(t *testing.T) {
+	start := time.Date(2026, time.January, 1, 9, 0, 0, 0, time.UTC)
+	for _, s := range []string{"FREQ=DAILY;COUNT=2", "FREQ=DAILY;UNTIL=20260102"} {
+		rule, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next, ok := rule.Next(start, start.AddDate(0, 0, 1)); ok {
+			t.Errorf("%s: Next after the last occurrence = %v, want none", s, next)
+		}
+	}
+}
+
+func TestOccurrencesOfRareRules(t *testing.T) {
+	// February only has a 5th Monday in leap years where it starts on one
+	rule, err := Parse("FREQ=MONTHLY;INTERVAL=12;BYDAY=5MO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, time.February, 1, 9, 0, 0, 0, time.UTC)
+	var got []string
+	for _, o := range rule.Occurrences(start, start, 3) {
+		got = append(got, o.Format("2006-01-02"))
+	}
+	if want := "2044-02-29, 2072-02-29, 2112-02-29"; strings.Join(got, ", ") != want {
+		t.Fatalf("Occurrences = %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/database"
+	"myapp/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// AttachmentRepository defines the interface for attachment metadata
+// operations
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment *model.Attachment) error
+	GetByID(ctx context.Context, id uint) (*model.Attachment, error)
+	GetByIDForTodo(ctx context.Context, id uint, todoID uint) (*model.Attachment, error)
+	GetByTodoID(ctx context.Context, todoID uint) ([]*model.Attachment, error)
+	Delete(ctx context.Context, id uint) error
+}
+
+type attachmentRepository struct {
+	db *gorm.DB
+}
+
+// NewAttachmentRepository creates a new AttachmentRepository instance
+func NewAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+func (r *attachmentRepository) Create(ctx context.Context, attachment *model.Attachment) error {
+	return r.db.WithContext(ctx).Create(attachment).Error
+}
+
+func (r *attachmentRepository) GetByID(ctx context.Context, id uint) (*model.Attachment, error) {
+	var attachment model.Attachment
+	if err := r.db.WithContext(ctx).Scopes(database.ReadOnly).First(&attachment, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &attachment, nil
+}
+
+// GetByIDForTodo retrieves an attachment by ID that belongs to the given
+// todo, returning ErrNotFound if it does not exist or is on another todo
+func (r *attachmentRepository) GetByIDForTodo(ctx context.Context, id uint, todoID uint) (*model.Attachment, error) {
+	var attachment model.Attachment
+	if err := r.db.WithContext(ctx).Scopes(database.ReadOnly).Where("todo_id = ?", todoID).First(&attachment, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &attachment, nil
+}
+
+func (r *attachmentRepository) GetByTodoID(ctx context.Context, todoID uint) ([]*model.Attachment, error) {
+	var attachments []*model.Attachment
+	if err := r.db.WithContext(ctx).Scopes(database.ReadOnly).Where("todo_id = ?", todoID).Order("id").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (r *attachmentRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&model.Attachment{}, id).Error
+}
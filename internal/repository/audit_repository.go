@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/database"
+	"myapp/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// auditChainLock is the advisory lock taken by the sequencer linking
+// entries into the hash chain, so that only one instance appends links at a
+// time. Recording entries does not take it.
+const auditChainLock = 0x61756469 // "audi"
+
+// AuditRepository defines the interface for audit log operations. The log
+// is append-only; there are no update or delete methods.
+type AuditRepository interface {
+	Append(ctx context.Context, entry *model.AuditEntry) error
+	List(ctx context.Context, filter model.AuditFilter) ([]*model.AuditEntry, error)
+	ChainPending(ctx context.Context, limit int) (int, error)
+	GetChain(ctx context.Context, afterSeq uint64, limit int) ([]*model.AuditLink, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository creates a new AuditRepository instance
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// Append inserts entry, setting its timestamp and content hash, and queues
+// it to be linked into the chain by ChainPending
+func (r *auditRepository) Append(ctx context.Context, entry *model.AuditEntry) error {
+	// Truncated to the database's precision so the hash can be recomputed
+	// from the stored row
+	entry.OccurredAt = time.Now().UTC().Truncate(time.Microsecond)
+	entry.PrevHash = ""
+	entry.Hash = entry.ComputeHash()
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.AuditPending{EntryID: entry.ID}).Error
+	})
+}
+
+// ChainPending links up to limit queued entries into the chain, in the
+// order of their IDs, and returns how many were linked. It returns 0
+// without waiting if another instance is linking entries.
+func (r *auditRepository) ChainPending(ctx context.Context, limit int) (int, error) {
+	linked := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// SQLite transactions hold the database's write lock from the start,
+		// so only one instance can be linking entries already
+		if !database.IsSQLite(tx) {
+			var locked bool
+			if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", auditChainLock).Scan(&locked).Error; err != nil {
+				return err
+			}
+			if !locked {
+				return nil
+			}
+		}
+
+		var pending []*model.AuditEntry
+		if err := tx.Joins("JOIN audit_pending ON audit_pending.entry_id = audit_log.id").
+			Order("audit_log.id").
+			Limit(limit).
+			Find(&pending).Error; err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		var prev []string
+		if err := tx.Model(&model.AuditLink{}).Order("seq DESC").Limit(1).Pluck("hash", &prev).Error; err != nil {
+			return err
+		}
+		prevHash := ""
+		if len(prev) > 0 {
+			prevHash = prev[0]
+		}
+
+		links := make([]*model.AuditLink, len(pending))
+		ids := make([]uint64, len(pending))
+		for i, entry := range pending {
+			links[i] = &model.AuditLink{EntryID: entry.ID, PrevHash: prevHash, Hash: model.ChainHash(prevHash, entry.Hash)}
+			prevHash = links[i].Hash
+			ids[i] = entry.ID
+		}
+		if err := tx.Omit("Entry").Create(&links).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("entry_id IN ?", ids).Delete(&model.AuditPending{}).Error; err != nil {
+			return err
+		}
+		linked = len(links)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return linked, nil
+}
+
+// List returns entries matching filter, newest first
+func (r *auditRepository) List(ctx context.Context, filter model.AuditFilter) ([]*model.AuditEntry, error) {
+	query := r.db.WithContext(ctx).Scopes(database.ReadOnly).Model(&model.AuditEntry{})
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ResourceType != "" {
+		query = query.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		query = query.Where("resource_id = ?", filter.ResourceID)
+	}
+	if filter.From != nil {
+		query = query.Where("occurred_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("occurred_at < ?", *filter.To)
+	}
+	order := "id DESC"
+	if filter.ByTime {
+		order = "occurred_at DESC, id DESC"
+	}
+	switch {
+	case filter.BeforeTime != nil:
+		query = query.Where("occurred_at < ? OR (occurred_at = ? AND id < ?)", *filter.BeforeTime, *filter.BeforeTime, filter.BeforeID)
+	case filter.BeforeID != 0:
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+
+	var entries []*model.AuditEntry
+	if err := query.Order(order).Limit(filter.Limit).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetChain returns up to limit links after afterSeq in chain order, with
+// their entries
+func (r *auditRepository) GetChain(ctx context.Context, afterSeq uint64, limit int) ([]*model.AuditLink, error) {
+	var links []*model.AuditLink
+	if err := r.db.WithContext(ctx).Scopes(database.ReadOnly).
+		Preload("Entry").
+		Where("seq > ?", afterSeq).
+		Order("seq").
+		Limit(limit).
+		Find(&links).Error; err != nil {
+		return nil, err
+	}
+	return links, nil
+}
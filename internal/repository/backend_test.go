@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"myapp/internal/database"
+	"myapp/internal/model"
+)
+
+// testDatabaseEnv names a PostgreSQL database the tests may create schemas
+// in. The PostgreSQL backend is skipped if it is not set.
+const testDatabaseEnv = "TEST_DATABASE_DSN"
+
+// testBackend is a storage backend the repository tests run against
+type testBackend struct {
+	name       string
+	users      UserRepository
+	todos      TodoRepository
+	workspaces WorkspaceRepository
+}
+
+// forEachBackend runs fn against a fresh, empty instance of every backend
+func forEachBackend(t *testing.T, fn func(t *testing.T, b testBackend)) {
+	t.Helper()
+	backends := map[string]func(t *testing.T) testBackend{
+		"memory":   memoryBackend,
+		"sqlite":   sqliteBackend,
+		"postgres": postgresBackend,
+	}
+	for _, name := range []string{"memory", "sqlite", "postgres"} {
+		t.Run(name, func(t *testing.T) {
+			fn(t, backends[name](t))
+		})
+	}
+}
+
+func memoryBackend(t *testing.T) testBackend {
+	workspaces := newMemoryWorkspaces()
+	return testBackend{
+		name:       "memory",
+		users:      NewMemoryUserRepository(workspaces),
+		todos:      NewMemoryTodoRepository(),
+		workspaces: workspaces,
+	}
+}
+
+// sqliteBackend creates a database in a temporary directory
+func sqliteBackend(t *testing.T) testBackend {
+	db, err := database.Open(database.Config{
+		Driver: database.SQLite,
+		DSN:    filepath.Join(t.TempDir(), "test.db"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Logger = logger.Discard
+	t.Cleanup(func() { database.Close(db) })
+
+	return testBackend{
+		name:       "sqlite",
+		users:      NewUserRepository(db),
+		todos:      NewTodoRepository(db),
+		workspaces: NewWorkspaceRepository(db),
+	}
+}
+
+// postgresBackend migrates a new schema of the database named by
+// TEST_DATABASE_DSN, which is dropped when the test ends
+func postgresBackend(t *testing.T) testBackend {
+	dsn := os.Getenv(testDatabaseEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseEnv)
+	}
+
+	admin := openPostgres(t, dsn)
+	schema := fmt.Sprintf("repository_test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := admin.Exec("DROP SCHEMA " + schema + " CASCADE").Error; err != nil {
+			t.Errorf("dropping test schema: %v", err)
+		}
+	})
+
+	db := openPostgres(t, withSearchPath(dsn, schema))
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := filepath.Glob(filepath.Join("..", "db", "migrations", "*.up.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(migrations)
+	for _, migration := range migrations {
+		script, err := os.ReadFile(migration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Scripts without arguments are sent with the simple protocol,
+		// which accepts several statements
+		if _, err := sqlDB.Exec(string(script)); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(migration), err)
+		}
+	}
+
+	return testBackend{
+		name:       "postgres",
+		users:      NewUserRepository(db),
+		todos:      NewTodoRepository(db),
+		workspaces: NewWorkspaceRepository(db),
+	}
+}
+
+func openPostgres(t *testing.T, dsn string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// withSearchPath adds a search_path run-time parameter to a DSN in either
+// the keyword/value or the URL form
+func withSearchPath(dsn, schema string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&search_path=" + schema
+	}
+	return dsn + "?search_path=" + schema
+}
+
+// memoryWorkspaces keeps the workspaces and memberships the tests create,
+// for the in-memory backend
+type memoryWorkspaces struct {
+	WorkspaceRepository
+
+	mu         sync.Mutex
+	workspaces map[uint]*model.Workspace
+	members    map[[2]uint]*model.WorkspaceMember
+}
+
+func newMemoryWorkspaces() *memoryWorkspaces {
+	return &memoryWorkspaces{
+		workspaces: make(map[uint]*model.Workspace),
+		members:    make(map[[2]uint]*model.WorkspaceMember),
+	}
+}
+
+func (r *memoryWorkspaces) Create(ctx context.Context, workspace *model.Workspace) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	workspace.ID = uint(len(r.workspaces) + 1)
+	stored := *workspace
+	r.workspaces[workspace.ID] = &stored
+	return nil
+}
+
+func (r *memoryWorkspaces) AddMember(ctx context.Context, member *model.WorkspaceMember) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *member
+	r.members[[2]uint{member.WorkspaceID, member.UserID}] = &stored
+	return nil
+}
+
+func (r *memoryWorkspaces) GetMembership(ctx context.Context, workspaceID uint, userID uint) (*model.WorkspaceMembership, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	member, ok := r.members[[2]uint{workspaceID, userID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &model.WorkspaceMembership{Workspace: *r.workspaces[workspaceID], Role: member.Role}, nil
+}
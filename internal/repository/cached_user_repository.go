@@ -0,0 +1,351 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"myapp/internal/cache"
+	"myapp/internal/model"
+)
+
+// UserCache caches users by ID, for the lookup that authenticates every
+// request. A cached user records the workspaces it was found a member of,
+// so that lookups stay confined to the workspace of their context. Users
+// are cached without their credentials, so users read through the cache
+// have no password or calendar token hash; code that checks or changes
+// those reads the user in a unit of work.
+//
+// Cached users are invalidated when they are updated or deleted and when
+// they leave a workspace, through the repositories returned by Wrap and the
+// units of work returned by UnitOfWork. Changes made by other instances are
+// only seen once the entry expires, unless the cache is shared.
+//
+// A user's entry is stored under the user's current generation, a random
+// token kept in the cache next to it. Invalidating a user starts a new
+// generation, which orphans the entry, including one written afterwards
+// by a lookup that read the database before the change committed.
+type UserCache struct {
+	cache cache.Cache
+	ttl   time.Duration
+	group singleflight.Group
+}
+
+// userLoadTimeout bounds the database lookup of a cache miss. The lookup is
+// shared by every request waiting for the same user, so it is not cancelled
+// with the request that started it.
+const userLoadTimeout = 5 * time.Second
+
+// cachedUser is a cache entry: a user without credentials
+type cachedUser struct {
+	ID                  uint
+	Email               string
+	Username            string
+	FirstName           string
+	LastName            string
+	Role                string
+	Timezone            string
+	CalendarWorkspaceID *uint
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	// Workspaces are the workspaces the user was found a member of
+	Workspaces []uint
+}
+
+// newCachedUser returns the entry of a user read from the database
+func newCachedUser(user *model.User) *cachedUser {
+	return &cachedUser{
+		ID:                  user.ID,
+		Email:               user.Email,
+		Username:            user.Username,
+		FirstName:           user.FirstName,
+		LastName:            user.LastName,
+		Role:                user.Role,
+		Timezone:            user.Timezone,
+		CalendarWorkspaceID: user.CalendarWorkspaceID,
+		CreatedAt:           user.CreatedAt,
+		UpdatedAt:           user.UpdatedAt,
+	}
+}
+
+// user returns the cached user
+func (e *cachedUser) user() *model.User {
+	return &model.User{
+		ID:                  e.ID,
+		Email:               e.Email,
+		Username:            e.Username,
+		FirstName:           e.FirstName,
+		LastName:            e.LastName,
+		Role:                e.Role,
+		Timezone:            e.Timezone,
+		CalendarWorkspaceID: e.CalendarWorkspaceID,
+		CreatedAt:           e.CreatedAt,
+		UpdatedAt:           e.UpdatedAt,
+	}
+}
+
+// NewUserCache creates a UserCache storing users in c for ttl
+func NewUserCache(c cache.Cache, ttl time.Duration) *UserCache {
+	return &UserCache{cache: c, ttl: ttl}
+}
+
+// Wrap returns a UserRepository that reads users by ID through the cache
+// and invalidates them when they change
+func (c *UserCache) Wrap(next UserRepository) UserRepository {
+	return &cachedUserRepository{UserRepository: next, cache: c}
+}
+
+// UnitOfWork returns a UnitOfWork that invalidates the users a transaction
+// updated, deleted or removed from a workspace once it commits. Within the
+// transaction users are read from the database.
+func (c *UserCache) UnitOfWork(next UnitOfWork) UnitOfWork {
+	return &cachingUnitOfWork{next: next, cache: c}
+}
+
+// Invalidate drops cached users by starting a new generation for each.
+// Failures are logged, as the entries still expire.
+func (c *UserCache) Invalidate(ctx context.Context, ids ...uint) {
+	ids = slices.Compact(slices.Sorted(slices.Values(ids)))
+	for _, id := range ids {
+		generation, err := newUserGeneration()
+		if err == nil {
+			err = c.cache.Set(ctx, userGenerationKey(id), generation, c.ttl)
+		}
+		if err != nil {
+			slog.Error("repository: failed to invalidate cached user", "user", id, "error", err)
+		}
+	}
+}
+
+// get returns the user with the given ID, from the cache if it was found a
+// member of the workspace of ctx before. Concurrent misses for a user share
+// one database lookup per workspace.
+func (c *UserCache) get(ctx context.Context, next UserRepository, id uint) (*model.User, error) {
+	workspaceID, err := userScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	generation, err := c.generation(ctx, id)
+	if err != nil {
+		// Without a generation, a lookup cannot tell whether the user
+		// changed while it read the database, so nothing is cached
+		slog.Warn("repository: user cache lookup failed", "user", id, "error", err)
+		user, err := next.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return newCachedUser(user).user(), nil
+	}
+
+	entry, err := c.load(ctx, id, generation)
+	if err == nil && (workspaceID == 0 || slices.Contains(entry.Workspaces, workspaceID)) {
+		return entry.user(), nil
+	}
+
+	key := fmt.Sprintf("%d/%d/%s", id, workspaceID, generation)
+	result := c.group.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), userLoadTimeout)
+		defer cancel()
+
+		user, err := next.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		loaded := newCachedUser(user)
+		c.store(ctx, loaded, entry, generation, workspaceID)
+		return loaded, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*cachedUser).user(), nil
+	}
+}
+
+// generation returns the current generation of a user, starting one if
+// the user has none
+func (c *UserCache) generation(ctx context.Context, id uint) (string, error) {
+	key := userGenerationKey(id)
+	generation, err := c.cache.Get(ctx, key)
+	if err == nil {
+		return string(generation), nil
+	}
+	if !errors.Is(err, cache.ErrMiss) {
+		return "", err
+	}
+
+	if generation, err = newUserGeneration(); err != nil {
+		return "", err
+	}
+	added, err := c.cache.Add(ctx, key, generation, c.ttl)
+	if err != nil {
+		return "", err
+	}
+	if !added {
+		// Started concurrently, by another lookup or an invalidation
+		if generation, err = c.cache.Get(ctx, key); err != nil {
+			return "", err
+		}
+	}
+	return string(generation), nil
+}
+
+// load reads the entry of a user's generation. Failures other than misses
+// are logged and treated as misses.
+func (c *UserCache) load(ctx context.Context, id uint, generation string) (*cachedUser, error) {
+	data, err := c.cache.Get(ctx, userCacheKey(id, generation))
+	if err != nil {
+		if !errors.Is(err, cache.ErrMiss) {
+			slog.Warn("repository: user cache lookup failed", "user", id, "error", err)
+		}
+		return nil, err
+	}
+	var entry cachedUser
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+		slog.Warn("repository: dropping undecodable cached user", "user", id, "error", err)
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// store caches a user just read from the database under the generation
+// read before, adding the workspace it was found a member of to those of
+// the previous entry
+func (c *UserCache) store(ctx context.Context, entry *cachedUser, previous *cachedUser, generation string, workspaceID uint) {
+	if previous != nil {
+		entry.Workspaces = previous.Workspaces
+	}
+	if workspaceID != 0 && !slices.Contains(entry.Workspaces, workspaceID) {
+		entry.Workspaces = append(entry.Workspaces, workspaceID)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+		slog.Warn("repository: failed to encode user for caching", "user", entry.ID, "error", err)
+		return
+	}
+	if err := c.cache.Set(ctx, userCacheKey(entry.ID, generation), buf.Bytes(), c.ttl); err != nil {
+		slog.Warn("repository: failed to cache user", "user", entry.ID, "error", err)
+	}
+}
+
+// newUserGeneration returns a random generation token
+func newUserGeneration() ([]byte, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+// userGenerationKey is the cache key of a user's generation
+func userGenerationKey(id uint) string {
+	return "user:" + strconv.FormatUint(uint64(id), 10) + ":generation"
+}
+
+// userCacheKey is the cache key of a user's entry in a generation
+func userCacheKey(id uint, generation string) string {
+	return "user:" + strconv.FormatUint(uint64(id), 10) + ":" + generation
+}
+
+// cachedUserRepository reads users by ID through a UserCache. Other lookups
+// go to the database.
+type cachedUserRepository struct {
+	UserRepository
+	cache *UserCache
+}
+
+// GetByID implements UserRepository
+func (r *cachedUserRepository) GetByID(ctx context.Context, id uint) (*model.User, error) {
+	return r.cache.get(ctx, r.UserRepository, id)
+}
+
+// Update implements UserRepository
+func (r *cachedUserRepository) Update(ctx context.Context, user *model.User) error {
+	defer r.cache.Invalidate(context.WithoutCancel(ctx), user.ID)
+	return r.UserRepository.Update(ctx, user)
+}
+
+// Delete implements UserRepository
+func (r *cachedUserRepository) Delete(ctx context.Context, id uint) error {
+	defer r.cache.Invalidate(context.WithoutCancel(ctx), id)
+	return r.UserRepository.Delete(ctx, id)
+}
+
+// cachingUnitOfWork invalidates the users changed by a transaction after it
+// commits
+type cachingUnitOfWork struct {
+	next  UnitOfWork
+	cache *UserCache
+	// changed collects the users changed by the enclosing transaction, if
+	// this unit of work is nested in one
+	changed *[]uint
+}
+
+// Do implements UnitOfWork
+func (u *cachingUnitOfWork) Do(ctx context.Context, fn func(tx *Repositories) error) error {
+	var changed []uint
+	err := u.next.Do(ctx, func(tx *Repositories) error {
+		tx.User = &invalidatingUserRepository{UserRepository: tx.User, changed: &changed}
+		tx.Workspace = &invalidatingWorkspaceRepository{WorkspaceRepository: tx.Workspace, changed: &changed}
+		tx.UnitOfWork = &cachingUnitOfWork{next: tx.UnitOfWork, cache: u.cache, changed: &changed}
+		return fn(tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	if u.changed != nil {
+		// Committed to a savepoint; the enclosing transaction invalidates
+		*u.changed = append(*u.changed, changed...)
+		return nil
+	}
+	u.cache.Invalidate(context.WithoutCancel(ctx), changed...)
+	return nil
+}
+
+// invalidatingUserRepository records the users a transaction changes
+type invalidatingUserRepository struct {
+	UserRepository
+	changed *[]uint
+}
+
+// Update implements UserRepository
+func (r *invalidatingUserRepository) Update(ctx context.Context, user *model.User) error {
+	*r.changed = append(*r.changed, user.ID)
+	return r.UserRepository.Update(ctx, user)
+}
+
+// Delete implements UserRepository
+func (r *invalidatingUserRepository) Delete(ctx context.Context, id uint) error {
+	*r.changed = append(*r.changed, id)
+	return r.UserRepository.Delete(ctx, id)
+}
+
+// invalidatingWorkspaceRepository records the users a transaction removes
+// from a workspace
+type invalidatingWorkspaceRepository struct {
+	WorkspaceRepository
+	changed *[]uint
+}
+
+// RemoveMember implements WorkspaceRepository
+func (r *invalidatingWorkspaceRepository) RemoveMember(ctx context.Context, workspaceID uint, userID uint) error {
+	*r.changed = append(*r.changed, userID)
+	return r.WorkspaceRepository.RemoveMember(ctx, workspaceID, userID)
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"myapp/internal/cache"
+	"myapp/internal/model"
+	"myapp/internal/tenant"
+)
+
+// stubUsers is a UserRepository holding one user. GetByID waits for
+// release, if set, after signalling on entered.
+type stubUsers struct {
+	UserRepository
+
+	mu      sync.Mutex
+	user    model.User
+	calls   int
+	ctx     context.Context
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (r *stubUsers) GetByID(ctx context.Context, id uint) (*model.User, error) {
+	r.mu.Lock()
+	r.calls++
+	r.ctx = ctx
+	user := r.user
+	r.mu.Unlock()
+
+	if r.release != nil {
+		r.entered <- struct{}{}
+		<-r.release
+	}
+	return &user, nil
+}
+
+func (r *stubUsers) set(fn func(user *model.User)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&r.user)
+}
+
+func (r *stubUsers) callCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+// recordingCache records the values written to it
+type recordingCache struct {
+	cache.Cache
+
+	mu     sync.Mutex
+	values [][]byte
+}
+
+func (c *recordingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	c.values = append(c.values, value)
+	c.mu.Unlock()
+	return c.Cache.Set(ctx, key, value, ttl)
+}
+
+func newStubUsers() *stubUsers {
+	token := "calendar-token-hash"
+	return &stubUsers{user: model.User{
+		ID:                1,
+		Email:             "alice@example.com",
+		Username:          "alice",
+		Password:          "$2a$10$secretbcrypthash",
+		CalendarTokenHash: &token,
+	}}
+}
+
+func TestUserCacheStoresNoCredentials(t *testing.T) {
+	ctx := tenant.WithWorkspace(context.Background(), 1)
+	users := newStubUsers()
+	recorder := &recordingCache{Cache: cache.NewLRU(10)}
+	repo := NewUserCache(recorder, time.Minute).Wrap(users)
+
+	for i := 0; i < 2; i++ {
+		user, err := repo.GetByID(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Username != "alice" || user.Password != "" || user.CalendarTokenHash != nil {
+			t.Fatalf("GetByID = %+v, want alice without credentials", user)
+		}
+	}
+	if users.callCount() != 1 {
+		t.Fatalf("read the database %d times, want once", users.callCount())
+	}
+
+	if len(recorder.values) == 0 {
+		t.Fatal("nothing was cached")
+	}
+	for _, value := range recorder.values {
+		if bytes.Contains(value, []byte("secretbcrypthash")) || bytes.Contains(value, []byte("calendar-token-hash")) {
+			t.Fatalf("cached value %q holds credentials", value)
+		}
+	}
+}
+
+func TestUserCacheDropsUserReadBeforeInvalidation(t *testing.T) {
+	ctx := tenant.WithWorkspace(context.Background(), 1)
+	users := newStubUsers()
+	users.entered, users.release = make(chan struct{}), make(chan struct{})
+	userCache := NewUserCache(cache.NewLRU(10), time.Minute)
+	repo := userCache.Wrap(users)
+
+	// A lookup reads the user, then the user changes and the cache is
+	// invalidated before the lookup stores what it read
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		repo.GetByID(ctx, 1)
+	}()
+	<-users.entered
+	users.set(func(user *model.User) { user.FirstName = "Changed" })
+	userCache.Invalidate(ctx, 1)
+	close(users.release)
+	<-done
+
+	users.entered, users.release = nil, nil
+	user, err := repo.GetByID(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.FirstName != "Changed" {
+		t.Fatalf("GetByID after invalidation = %+v, want the changed user", user)
+	}
+}
+
+func TestUserCacheLoadOutlivesCaller(t *testing.T) {
+	users := newStubUsers()
+	users.entered, users.release = make(chan struct{}), make(chan struct{})
+	repo := NewUserCache(cache.NewLRU(10), time.Minute).Wrap(users)
+
+	ctx, cancel := context.WithCancel(tenant.WithWorkspace(context.Background(), 1))
+	errs := make(chan error)
+	go func() {
+		_, err := repo.GetByID(ctx, 1)
+		errs <- err
+	}()
+	<-users.entered
+
+	// The caller gives up, but the lookup it started goes on, with a
+	// deadline of its own
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("GetByID = %v, want context.Canceled", err)
+	}
+	users.mu.Lock()
+	loadCtx := users.ctx
+	users.mu.Unlock()
+	if loadCtx.Err() != nil {
+		t.Fatalf("lookup context = %v, want it still running", loadCtx.Err())
+	}
+	if _, ok := loadCtx.Deadline(); !ok {
+		t.Fatal("lookup context has no deadline")
+	}
+	close(users.release)
+
+	// Once it finishes, its result is cached
+	for deadline := time.Now().Add(time.Second); loadCtx.Err() == nil && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := repo.GetByID(tenant.WithWorkspace(context.Background(), 1), 1); err != nil {
+		t.Fatal(err)
+	}
+	if users.callCount() != 1 {
+		t.Fatalf("read the database %d times, want once", users.callCount())
+	}
+}
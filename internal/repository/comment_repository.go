@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/database"
+	"myapp/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CommentRepository defines the interface for comment operations
+type CommentRepository interface {
+	Create(ctx context.Context, comment *model.Comment) error
+	GetByIDForTodo(ctx context.Context, id uint, todoID uint) (*model.Comment, error)
+	GetByTodoID(ctx context.Context, todoID uint) ([]*model.Comment, error)
+	ListForTodo(ctx context.Context, todoID uint, before *time.Time, beforeID uint, limit int) ([]*model.Comment, error)
+	Update(ctx context.Context, comment *model.Comment) error
+	Delete(ctx context.Context, id uint) error
+}
+
+type commentRepository struct {
+	db *gorm.DB
+}
+
+// NewCommentRepository creates a new CommentRepository instance
+func NewCommentRepository(db *gorm.DB) CommentRepository {
+	return &commentRepository{db: db}
+}
+
+// Create inserts a comment together with its mentions
+func (r *commentRepository) Create(ctx context.Context, comment *model.Comment) error {
+	return r.db.WithContext(ctx).Create(comment).Error
+}
+
+// GetByIDForTodo retrieves a comment by ID that belongs to the given todo,
+// returning ErrNotFound if it does not exist or is on another todo
+func (r *commentRepository) GetByIDForTodo(ctx context.Context, id uint, todoID uint) (*model.Comment, error) {
+	var comment model.Comment
+	if err := r.db.WithContext(ctx).Scopes(database.ReadOnly).Preload("Mentions").Where("todo_id = ?", todoID).First(&comment, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &comment, nil
+}
+
+// GetByTodoID returns the comments of a todo, oldest first
+func (r *commentRepository) GetByTodoID(ctx context.Context, todoID uint) ([]*model.Comment, error) {
+	var comments []*model.Comment
+	if err := r.db.WithContext(ctx).Scopes(database.ReadOnly).Preload("Mentions").Where("todo_id = ?", todoID).Order("id").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// ListForTodo returns up to limit comments of a todo, newest first. If
+// before is set, the listing continues below the comment created at before
+// with ID beforeID: comments created earlier, or at the same time with a
+// lower ID, so that comments sharing a timestamp are not skipped.
+func (r *commentRepository) ListForTodo(ctx context.Context, todoID uint, before *time.Time, beforeID uint, limit int) ([]*model.Comment, error) {
+	query := r.db.WithContext(ctx).Scopes(database.ReadOnly).Preload("Mentions").Where("todo_id = ?", todoID)
+	if before != nil {
+		query = query.Where("created_at < ? OR (created_at = ? AND id < ?)", *before, *before, beforeID)
+	}
+
+	var comments []*model.Comment
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// Update saves a comment's body and replaces its mentions
+func (r *commentRepository) Update(ctx context.Context, comment *model.Comment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Mentions").Save(comment).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("comment_id = ?", comment.ID).Delete(&model.CommentMention{}).Error; err != nil {
+			return err
+		}
+		if len(comment.Mentions) == 0 {
+			return nil
+		}
+		for _, mention := range comment.Mentions {
+			mention.CommentID = comment.ID
+		}
+		return tx.Create(comment.Mentions).Error
+	})
+}
+
+// Delete removes a comment together with its mentions
+func (r *commentRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&model.Comment{}, id).Error
+}
@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"myapp/internal/model"
+	"myapp/internal/tenant"
+)
+
+// conformance lists the behaviour every UserRepository and TodoRepository
+// backend must share. Each case runs on freshly seeded tenants and passes
+// if run returns an error matching want; run returns any other error for
+// an unexpected result.
+var conformance = []struct {
+	name string
+	run  func(s *tenants, b testBackend) error
+	want error
+}{
+	// Missing records
+	{"user GetByID missing", func(s *tenants, b testBackend) error {
+		_, err := b.users.GetByID(s.a, 9999)
+		return err
+	}, ErrNotFound},
+	{"user GetByEmail missing", func(s *tenants, b testBackend) error {
+		_, err := b.users.GetByEmail(s.a, "nobody@example.com")
+		return err
+	}, ErrNotFound},
+	{"user GetByUsername missing", func(s *tenants, b testBackend) error {
+		_, err := b.users.GetByUsername(s.a, "nobody")
+		return err
+	}, ErrNotFound},
+	{"user GetByCalendarTokenHash missing", func(s *tenants, b testBackend) error {
+		_, err := b.users.GetByCalendarTokenHash(s.a, "nobody-calendar")
+		return err
+	}, ErrNotFound},
+	{"user Update missing", func(s *tenants, b testBackend) error {
+		return b.users.Update(s.a, &model.User{ID: 9999, Email: "nobody@example.com", Username: "nobody", Password: "hash"})
+	}, ErrNotFound},
+	{"todo GetByID missing", func(s *tenants, b testBackend) error {
+		_, err := b.todos.GetByID(s.a, 9999)
+		return err
+	}, ErrNotFound},
+	{"todo GetByIDForUser of another user", func(s *tenants, b testBackend) error {
+		_, err := b.todos.GetByIDForUser(s.a, s.aliceTodo.ID, s.carol.ID)
+		return err
+	}, ErrNotFound},
+	{"todo Delete missing", func(s *tenants, b testBackend) error {
+		return b.todos.Delete(s.a, 9999)
+	}, ErrNotFound},
+
+	// Unique values, which deleted users keep holding
+	{"user Create with taken email", func(s *tenants, b testBackend) error {
+		return b.users.Create(context.Background(), &model.User{Email: s.alice.Email, Username: "alice2", Password: "hash"})
+	}, ErrDuplicate},
+	{"user Create with taken username", func(s *tenants, b testBackend) error {
+		return b.users.Create(context.Background(), &model.User{Email: "alice2@example.com", Username: s.alice.Username, Password: "hash"})
+	}, ErrDuplicate},
+	{"user Create with taken calendar token", func(s *tenants, b testBackend) error {
+		return b.users.Create(context.Background(), &model.User{Email: "alice2@example.com", Username: "alice2", Password: "hash", CalendarTokenHash: s.alice.CalendarTokenHash})
+	}, ErrDuplicate},
+	{"user Create with email of deleted user", func(s *tenants, b testBackend) error {
+		if err := b.users.Delete(s.a, s.alice.ID); err != nil {
+			return err
+		}
+		return b.users.Create(context.Background(), &model.User{Email: s.alice.Email, Username: "alice2", Password: "hash"})
+	}, ErrDuplicate},
+	{"user Update to taken username", func(s *tenants, b testBackend) error {
+		user, err := b.users.GetByID(s.a, s.carol.ID)
+		if err != nil {
+			return err
+		}
+		user.Username = s.alice.Username
+		return b.users.Update(s.a, user)
+	}, ErrDuplicate},
+	{"user Update keeping its own values", func(s *tenants, b testBackend) error {
+		user, err := b.users.GetByID(s.a, s.alice.ID)
+		if err != nil {
+			return err
+		}
+		user.FirstName = "Alice"
+		return b.users.Update(s.a, user)
+	}, nil},
+
+	// Soft-deleted users
+	{"user GetByID deleted", func(s *tenants, b testBackend) error {
+		if err := b.users.Delete(s.a, s.alice.ID); err != nil {
+			return err
+		}
+		_, err := b.users.GetByID(s.a, s.alice.ID)
+		return err
+	}, ErrNotFound},
+	{"user GetByEmail deleted", func(s *tenants, b testBackend) error {
+		if err := b.users.Delete(s.a, s.alice.ID); err != nil {
+			return err
+		}
+		_, err := b.users.GetByEmail(s.a, s.alice.Email)
+		return err
+	}, ErrNotFound},
+
+	// Optimistic updates
+	{"todo Update with current version", func(s *tenants, b testBackend) error {
+		todo, err := b.todos.GetByID(s.a, s.aliceTodo.ID)
+		if err != nil {
+			return err
+		}
+		version, seq := todo.Version, todo.ChangeSeq
+		todo.Title = "renamed"
+		if err := b.todos.Update(s.a, todo); err != nil {
+			return err
+		}
+		stored, err := b.todos.GetByID(s.a, todo.ID)
+		if err != nil {
+			return err
+		}
+		if stored.Title != "renamed" || stored.Version != version+1 || stored.ChangeSeq <= seq {
+			return fmt.Errorf("stored title %q, version %d, change %d; want %q, %d, after %d",
+				stored.Title, stored.Version, stored.ChangeSeq, "renamed", version+1, seq)
+		}
+		return nil
+	}, nil},
+	{"todo Update with stale version", func(s *tenants, b testBackend) error {
+		first, err := b.todos.GetByID(s.a, s.aliceTodo.ID)
+		if err != nil {
+			return err
+		}
+		second := *first
+		first.Title = "first"
+		if err := b.todos.Update(s.a, first); err != nil {
+			return err
+		}
+		second.Title = "second"
+		err = b.todos.Update(s.a, &second)
+		if stored, getErr := b.todos.GetByID(s.a, first.ID); getErr != nil || stored.Title != "first" {
+			return fmt.Errorf("stored todo = %+v, %v; want the first update kept", stored, getErr)
+		}
+		return err
+	}, ErrConflict},
+	{"todo Update deleted", func(s *tenants, b testBackend) error {
+		todo, err := b.todos.GetByID(s.a, s.aliceTodo.ID)
+		if err != nil {
+			return err
+		}
+		if err := b.todos.Delete(s.a, todo.ID); err != nil {
+			return err
+		}
+		return b.todos.Update(s.a, todo)
+	}, ErrConflict},
+
+	// Tombstones
+	{"todo GetByID deleted", func(s *tenants, b testBackend) error {
+		if err := b.todos.Delete(s.a, s.aliceTodo.ID); err != nil {
+			return err
+		}
+		_, err := b.todos.GetByID(s.a, s.aliceTodo.ID)
+		return err
+	}, ErrNotFound},
+	{"todo Delete deleted", func(s *tenants, b testBackend) error {
+		if err := b.todos.Delete(s.a, s.aliceTodo.ID); err != nil {
+			return err
+		}
+		return b.todos.Delete(s.a, s.aliceTodo.ID)
+	}, ErrNotFound},
+	{"todo changes include tombstones", func(s *tenants, b testBackend) error {
+		if err := b.todos.Delete(s.a, s.aliceTodo.ID); err != nil {
+			return err
+		}
+		changes, err := b.todos.GetChangesForUser(s.a, s.alice.ID, s.aliceTodo.ChangeSeq, 10)
+		if err != nil {
+			return err
+		}
+		if len(changes) != 1 || changes[0].ID != s.aliceTodo.ID || !changes[0].DeletedAt.Valid || changes[0].ChangeSeq <= s.aliceTodo.ChangeSeq {
+			return fmt.Errorf("changes = %+v, want the tombstone of todo %d", changes, s.aliceTodo.ID)
+		}
+		return nil
+	}, nil},
+	{"todo full sync leaves out tombstones", func(s *tenants, b testBackend) error {
+		if err := b.todos.Delete(s.a, s.aliceTodo.ID); err != nil {
+			return err
+		}
+		changes, err := b.todos.GetChangesForUser(s.a, s.alice.ID, 0, 10)
+		if err != nil {
+			return err
+		}
+		todos, err := b.todos.GetByUserID(s.a, s.alice.ID)
+		if err != nil {
+			return err
+		}
+		if len(changes) != 0 || len(todos) != 0 {
+			return fmt.Errorf("changes = %+v, todos = %+v; want neither to have the deleted todo", changes, todos)
+		}
+		return nil
+	}, nil},
+	{"todo changes in change order", func(s *tenants, b testBackend) error {
+		batch := []*model.Todo{{Title: "1", UserID: s.alice.ID}, {Title: "2", UserID: s.alice.ID}}
+		if err := b.todos.CreateBatch(s.a, batch); err != nil {
+			return err
+		}
+		if err := b.todos.Delete(s.a, batch[0].ID); err != nil {
+			return err
+		}
+		changes, err := b.todos.GetChangesForUser(s.a, s.alice.ID, s.aliceTodo.ChangeSeq, 10)
+		if err != nil {
+			return err
+		}
+		if got := fmt.Sprint(todoIDs(changes)); got != fmt.Sprint([]uint{batch[1].ID, batch[0].ID}) {
+			return fmt.Errorf("changes = %s, want the created todo before the deleted one", got)
+		}
+		return nil
+	}, nil},
+
+	// Workspace scoping
+	{"todo GetByID in another workspace", func(s *tenants, b testBackend) error {
+		_, err := b.todos.GetByID(s.b, s.aliceTodo.ID)
+		return err
+	}, ErrNotFound},
+	{"todo Update in another workspace", func(s *tenants, b testBackend) error {
+		todo := *s.carolA
+		todo.Title = "moved"
+		return b.todos.Update(s.b, &todo)
+	}, ErrConflict},
+	{"todo Delete in another workspace", func(s *tenants, b testBackend) error {
+		return b.todos.Delete(s.b, s.aliceTodo.ID)
+	}, ErrNotFound},
+	{"todo GetByID without workspace", func(s *tenants, b testBackend) error {
+		_, err := b.todos.GetByID(context.Background(), s.aliceTodo.ID)
+		return err
+	}, tenant.ErrNoWorkspace},
+	{"user GetByID of non-member", func(s *tenants, b testBackend) error {
+		_, err := b.users.GetByID(s.a, s.bob.ID)
+		return err
+	}, ErrNotFound},
+	{"user GetByID across tenants", func(s *tenants, b testBackend) error {
+		_, err := b.users.GetByID(tenant.CrossTenant(context.Background()), s.bob.ID)
+		return err
+	}, nil},
+	{"user GetByID without workspace", func(s *tenants, b testBackend) error {
+		_, err := b.users.GetByID(context.Background(), s.alice.ID)
+		return err
+	}, tenant.ErrNoWorkspace},
+}
+
+func TestRepositoryConformance(t *testing.T) {
+	for _, tt := range conformance {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, b testBackend) {
+				s := seedTenants(t, b)
+				err := tt.run(s, b)
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("got %v, want %v", err, tt.want)
+				}
+			})
+		})
+	}
+}
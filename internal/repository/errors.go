@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned by every repository lookup that matches no row.
+// Ownership-scoped lookups return it for rows owned by someone else as well,
+// so callers cannot distinguish "missing" from "not yours".
+var ErrNotFound = errors.New("record not found")
+
+// ErrConflict is returned by optimistic updates when the row's version no
+// longer matches the version the caller read.
+var ErrConflict = errors.New("record was modified concurrently")
+
+// ErrDuplicate is returned when an insert or update would give a row a
+// value that must be unique, such as an email address, that another row
+// already has
+var ErrDuplicate = errors.New("record already exists")
+
+// translateError maps driver-level errors onto the repository error contract
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	default:
+		return err
+	}
+}
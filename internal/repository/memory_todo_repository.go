@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"myapp/internal/model"
+	"myapp/internal/tenant"
+)
+
+// memoryTodoRepository is an in-process TodoRepository, for running without
+// PostgreSQL. Deleted todos are kept as tombstones for the change feed, and
+// change sequence numbers are assigned under one lock, so they become
+// visible in increasing order as with the advisory locks of the PostgreSQL
+// repository.
+type memoryTodoRepository struct {
+	mu      sync.RWMutex
+	todos   map[uint]*model.Todo
+	nextID  uint
+	lastSeq int64
+}
+
+// NewMemoryTodoRepository creates a TodoRepository that keeps todos in
+// memory
+func NewMemoryTodoRepository() TodoRepository {
+	return &memoryTodoRepository{todos: make(map[uint]*model.Todo)}
+}
+
+// Create implements TodoRepository
+func (r *memoryTodoRepository) Create(ctx context.Context, todo *model.Todo) error {
+	return r.CreateBatch(ctx, []*model.Todo{todo})
+}
+
+// CreateBatch implements TodoRepository
+func (r *memoryTodoRepository) CreateBatch(ctx context.Context, todos []*model.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	userID := todos[0].UserID
+	for _, todo := range todos {
+		if todo.UserID != userID {
+			return errors.New("repository: todo batch spans several users")
+		}
+	}
+	if err := assignWorkspace(ctx, todos...); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, todo := range todos {
+		if todo.Version == 0 {
+			todo.Version = 1
+		}
+		r.nextID++
+		todo.ID = r.nextID
+		todo.ChangeSeq = r.nextSeq()
+		todo.CreatedSeq = todo.ChangeSeq
+		if todo.CreatedAt.IsZero() {
+			todo.CreatedAt = now
+		}
+		if todo.UpdatedAt.IsZero() {
+			todo.UpdatedAt = now
+		}
+		r.todos[todo.ID] = copyTodo(todo)
+	}
+	return nil
+}
+
+// GetByID implements TodoRepository
+func (r *memoryTodoRepository) GetByID(ctx context.Context, id uint) (*model.Todo, error) {
+	return r.get(ctx, id, func(*model.Todo) bool { return true })
+}
+
+// GetByIDForUser implements TodoRepository
+func (r *memoryTodoRepository) GetByIDForUser(ctx context.Context, id uint, userID uint) (*model.Todo, error) {
+	return r.get(ctx, id, func(t *model.Todo) bool { return t.UserID == userID })
+}
+
+// GetByUserID implements TodoRepository
+func (r *memoryTodoRepository) GetByUserID(ctx context.Context, userID uint) ([]*model.Todo, error) {
+	return r.list(ctx, func(t *model.Todo) bool { return !t.DeletedAt.Valid && t.UserID == userID }, byID)
+}
+
+// EachByUserID implements TodoRepository
+func (r *memoryTodoRepository) EachByUserID(ctx context.Context, userID uint, batchSize int, fn func(todos []*model.Todo) error) error {
+	todos, err := r.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if batchSize <= 0 {
+		batchSize = len(todos)
+	}
+	for len(todos) > 0 {
+		n := min(batchSize, len(todos))
+		if err := fn(todos[:n]); err != nil {
+			return err
+		}
+		todos = todos[n:]
+	}
+	return nil
+}
+
+// GetChangesForUser implements TodoRepository
+func (r *memoryTodoRepository) GetChangesForUser(ctx context.Context, userID uint, since int64, limit int) ([]*model.Todo, error) {
+	todos, err := r.list(ctx, func(t *model.Todo) bool {
+		return t.UserID == userID && t.ChangeSeq > since && (since != 0 || !t.DeletedAt.Valid)
+	}, func(a, b *model.Todo) int { return cmp.Compare(a.ChangeSeq, b.ChangeSeq) })
+	if err != nil {
+		return nil, err
+	}
+	if limit >= 0 && len(todos) > limit {
+		todos = todos[:limit]
+	}
+	return todos, nil
+}
+
+// LastModifiedForUser implements TodoRepository
+func (r *memoryTodoRepository) LastModifiedForUser(ctx context.Context, userID uint) (time.Time, error) {
+	todos, err := r.list(ctx, func(t *model.Todo) bool { return t.UserID == userID }, byID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var lastModified time.Time
+	for _, todo := range todos {
+		if todo.UpdatedAt.After(lastModified) {
+			lastModified = todo.UpdatedAt
+		}
+	}
+	return lastModified, nil
+}
+
+// Update implements TodoRepository
+func (r *memoryTodoRepository) Update(ctx context.Context, todo *model.Todo) error {
+	workspaceID, ok := tenant.WorkspaceID(ctx)
+	if !ok {
+		return tenant.ErrNoWorkspace
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.todos[todo.ID]
+	if !ok || stored.DeletedAt.Valid || stored.WorkspaceID != workspaceID || stored.Version != todo.Version {
+		return ErrConflict
+	}
+
+	todo.Version++
+	todo.ChangeSeq = r.nextSeq()
+	todo.UpdatedAt = time.Now()
+	updated := copyTodo(todo)
+	updated.WorkspaceID, updated.CreatedAt, updated.CreatedSeq, updated.DeletedAt = stored.WorkspaceID, stored.CreatedAt, stored.CreatedSeq, stored.DeletedAt
+	r.todos[todo.ID] = updated
+	return nil
+}
+
+// Delete implements TodoRepository
+func (r *memoryTodoRepository) Delete(ctx context.Context, id uint) error {
+	workspaceID, ok := tenant.WorkspaceID(ctx)
+	if !ok {
+		return tenant.ErrNoWorkspace
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.todos[id]
+	if !ok || stored.DeletedAt.Valid || stored.WorkspaceID != workspaceID {
+		return ErrNotFound
+	}
+	now := time.Now()
+	stored.DeletedAt.Time, stored.DeletedAt.Valid = now, true
+	stored.UpdatedAt = now
+	stored.ChangeSeq = r.nextSeq()
+	stored.Version++
+	return nil
+}
+
+// get returns the undeleted todo with the given ID in the workspace of ctx
+// if it matches match
+func (r *memoryTodoRepository) get(ctx context.Context, id uint, match func(t *model.Todo) bool) (*model.Todo, error) {
+	workspaceID, ok := tenant.WorkspaceID(ctx)
+	if !ok {
+		return nil, tenant.ErrNoWorkspace
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, ok := r.todos[id]
+	if !ok || todo.DeletedAt.Valid || todo.WorkspaceID != workspaceID || !match(todo) {
+		return nil, ErrNotFound
+	}
+	return copyTodo(todo), nil
+}
+
+// list returns the todos in the workspace of ctx that match match, deleted
+// or not, sorted by order
+func (r *memoryTodoRepository) list(ctx context.Context, match func(t *model.Todo) bool, order func(a, b *model.Todo) int) ([]*model.Todo, error) {
+	workspaceID, ok := tenant.WorkspaceID(ctx)
+	if !ok {
+		return nil, tenant.ErrNoWorkspace
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var todos []*model.Todo
+	for _, todo := range r.todos {
+		if todo.WorkspaceID == workspaceID && match(todo) {
+			todos = append(todos, copyTodo(todo))
+		}
+	}
+	slices.SortFunc(todos, order)
+	return todos, nil
+}
+
+// nextSeq allocates the next change sequence number. The caller must hold
+// the write lock.
+func (r *memoryTodoRepository) nextSeq() int64 {
+	r.lastSeq++
+	return r.lastSeq
+}
+
+// byID orders todos by ID
+func byID(a, b *model.Todo) int {
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// copyTodo returns a copy of t that shares no memory with it. Like the
+// PostgreSQL repository, it does not carry the user or the next occurrence.
+func copyTodo(t *model.Todo) *model.Todo {
+	c := *t
+	c.User = model.User{}
+	c.NextOccurrence = nil
+	if t.DueAt != nil {
+		due := *t.DueAt
+		c.DueAt = &due
+	}
+	if t.RecurrenceStart != nil {
+		start := *t.RecurrenceStart
+		c.RecurrenceStart = &start
+	}
+	return &c
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"myapp/internal/model"
+	"myapp/internal/tenant"
+)
+
+// memoryUserRepository is an in-process UserRepository, for running without
+// PostgreSQL. Like the users table, it keeps deleted users, which still hold
+// their email, username and calendar token.
+type memoryUserRepository struct {
+	workspaces WorkspaceRepository
+
+	mu     sync.RWMutex
+	users  map[uint]*model.User
+	nextID uint
+}
+
+// NewMemoryUserRepository creates a UserRepository that keeps users in
+// memory. Workspace membership, which confines lookups as in the PostgreSQL
+// repository, is looked up in workspaces.
+func NewMemoryUserRepository(workspaces WorkspaceRepository) UserRepository {
+	return &memoryUserRepository{
+		workspaces: workspaces,
+		users:      make(map[uint]*model.User),
+	}
+}
+
+// Create implements UserRepository
+func (r *memoryUserRepository) Create(_ context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.taken(user) {
+		return ErrDuplicate
+	}
+
+	now := time.Now()
+	r.nextID++
+	user.ID = r.nextID
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = now
+	}
+	// Column defaults of the users table
+	if user.Role == "" {
+		user.Role = model.RoleUser
+	}
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+	r.users[user.ID] = copyUser(user)
+	return nil
+}
+
+// GetByID implements UserRepository
+func (r *memoryUserRepository) GetByID(ctx context.Context, id uint) (*model.User, error) {
+	return r.find(ctx, func(u *model.User) bool { return u.ID == id })
+}
+
+// GetByEmail implements UserRepository
+func (r *memoryUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.find(ctx, func(u *model.User) bool { return u.Email == email })
+}
+
+// GetByUsername implements UserRepository
+func (r *memoryUserRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	return r.find(ctx, func(u *model.User) bool { return u.Username == username })
+}
+
+// GetByCalendarTokenHash implements UserRepository
+func (r *memoryUserRepository) GetByCalendarTokenHash(ctx context.Context, hash string) (*model.User, error) {
+	return r.find(ctx, func(u *model.User) bool { return u.CalendarTokenHash != nil && *u.CalendarTokenHash == hash })
+}
+
+// Update implements UserRepository
+func (r *memoryUserRepository) Update(ctx context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	workspaceID, err := userScope(ctx)
+	if err != nil {
+		return err
+	}
+	stored, ok := r.users[user.ID]
+	if !ok || stored.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	if err := r.member(ctx, workspaceID, stored.ID); err != nil {
+		return err
+	}
+	if r.taken(user) {
+		return ErrDuplicate
+	}
+
+	user.UpdatedAt = time.Now()
+	updated := copyUser(user)
+	updated.CreatedAt, updated.DeletedAt = stored.CreatedAt, stored.DeletedAt
+	r.users[user.ID] = updated
+	return nil
+}
+
+// Delete implements UserRepository
+func (r *memoryUserRepository) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	workspaceID, err := userScope(ctx)
+	if err != nil {
+		return err
+	}
+	stored, ok := r.users[id]
+	if !ok || stored.DeletedAt.Valid {
+		return nil
+	}
+	if err := r.member(ctx, workspaceID, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	stored.DeletedAt.Time, stored.DeletedAt.Valid = time.Now(), true
+	return nil
+}
+
+// find returns the undeleted user matching match that ctx may see
+func (r *memoryUserRepository) find(ctx context.Context, match func(u *model.User) bool) (*model.User, error) {
+	workspaceID, err := userScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	var found *model.User
+	for _, u := range r.users {
+		if !u.DeletedAt.Valid && match(u) {
+			found = copyUser(u)
+			break
+		}
+	}
+	r.mu.RUnlock()
+
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	if err := r.member(ctx, workspaceID, found.ID); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// userScope returns the workspace whose members ctx may see, as memberScope
+// does, or 0 if ctx may see every user
+func userScope(ctx context.Context) (uint, error) {
+	if tenant.IsCrossTenant(ctx) {
+		return 0, nil
+	}
+	workspaceID, ok := tenant.WorkspaceID(ctx)
+	if !ok {
+		return 0, tenant.ErrNoWorkspace
+	}
+	return workspaceID, nil
+}
+
+// member returns ErrNotFound if a user is not a member of the workspace,
+// unless the workspace is 0
+func (r *memoryUserRepository) member(ctx context.Context, workspaceID, userID uint) error {
+	if workspaceID == 0 {
+		return nil
+	}
+	_, err := r.workspaces.GetMembership(ctx, workspaceID, userID)
+	return err
+}
+
+// taken reports whether another user, deleted or not, has a unique value of
+// user
+func (r *memoryUserRepository) taken(user *model.User) bool {
+	for id, u := range r.users {
+		if id == user.ID {
+			continue
+		}
+		if u.Email == user.Email || u.Username == user.Username ||
+			(u.CalendarTokenHash != nil && user.CalendarTokenHash != nil && *u.CalendarTokenHash == *user.CalendarTokenHash) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyUser returns a copy of u that shares no memory with it
+func copyUser(u *model.User) *model.User {
+	c := *u
+	if u.CalendarTokenHash != nil {
+		hash := *u.CalendarTokenHash
+		c.CalendarTokenHash = &hash
+	}
+	if u.CalendarWorkspaceID != nil {
+		id := *u.CalendarWorkspaceID
+		c.CalendarWorkspaceID = &id
+	}
+	return &c
+}
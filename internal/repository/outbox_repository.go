@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"myapp/internal/database"
+	"myapp/internal/events"
+	"myapp/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventChannel is the PostgreSQL notification channel committed domain
+// events are announced on, with a JSON encoded EventNotification
+const EventChannel = "domain_events"
+
+// EventNotification announces a domain event on EventChannel
+type EventNotification struct {
+	Event events.DomainEvent `json:"event"`
+	// Truncated is set when the event payload was left out to fit in the
+	// notification; it can be loaded with GetByEventID
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// maxNotificationPayload keeps notifications below the 8000 byte limit of
+// PostgreSQL
+const maxNotificationPayload = 7900
+
+// OutboxRepository defines the interface for outbox operations. Add is meant
+// to be called on a repository bound to the transaction that makes the
+// change the event describes.
+type OutboxRepository interface {
+	Add(ctx context.Context, event events.DomainEvent) error
+	GetByEventID(ctx context.Context, eventID string) (*model.OutboxMessage, error)
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*model.OutboxMessage, error)
+	RenewLease(ctx context.Context, ids []uint, until time.Time) error
+	Update(ctx context.Context, message *model.OutboxMessage) error
+	DeleteProcessedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+// NewOutboxRepository creates a new OutboxRepository instance
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+// Add writes a domain event to the outbox and announces it on EventChannel.
+// PostgreSQL delivers the notification to every listening instance once the
+// transaction commits, and drops it on rollback. SQLite has no
+// notifications, so events are only written.
+func (r *outboxRepository) Add(ctx context.Context, event events.DomainEvent) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Create(&model.OutboxMessage{
+		EventID:     event.ID,
+		Type:        event.Type,
+		UserID:      event.UserID,
+		Payload:     string(event.Payload),
+		OccurredAt:  event.OccurredAt,
+		Status:      model.OutboxPending,
+		AvailableAt: event.OccurredAt,
+	}).Error; err != nil {
+		return err
+	}
+	if database.IsSQLite(db) {
+		return nil
+	}
+
+	notification, err := json.Marshal(EventNotification{Event: event})
+	if err != nil {
+		return err
+	}
+	if len(notification) > maxNotificationPayload {
+		event.Payload = nil
+		if notification, err = json.Marshal(EventNotification{Event: event, Truncated: true}); err != nil {
+			return err
+		}
+	}
+	return db.Exec("SELECT pg_notify(?, ?)", EventChannel, string(notification)).Error
+}
+
+// GetByEventID returns the message of a domain event
+func (r *outboxRepository) GetByEventID(ctx context.Context, eventID string) (*model.OutboxMessage, error) {
+	var message model.OutboxMessage
+	if err := r.db.WithContext(ctx).Where("event_id = ?", eventID).First(&message).Error; err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// ClaimDue returns up to limit pending messages that are available, oldest
+// first. Claimed messages are leased by moving their availability past the
+// lease, so concurrent dispatchers never pick up the same message and one
+// abandoned by a crashed dispatcher is retried once the lease expires.
+func (r *outboxRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*model.OutboxMessage, error) {
+	var messages []*model.OutboxMessage
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND available_at <= ?", model.OutboxPending, now).
+			Order("id").
+			Limit(limit).
+			Find(&messages).Error; err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(messages))
+		for i, message := range messages {
+			ids[i] = message.ID
+		}
+		return tx.Model(&model.OutboxMessage{}).
+			Where("id IN ?", ids).
+			Update("available_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// RenewLease extends the lease of claimed messages that are still pending
+// until the given time
+func (r *outboxRepository) RenewLease(ctx context.Context, ids []uint, until time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Model(&model.OutboxMessage{}).
+		Where("id IN ? AND status = ?", ids, model.OutboxPending).
+		Update("available_at", until).Error
+}
+
+// Update records the outcome of dispatching a message
+func (r *outboxRepository) Update(ctx context.Context, message *model.OutboxMessage) error {
+	return r.db.WithContext(ctx).Model(message).
+		Select("Status", "Attempts", "AvailableAt", "ProcessedAt", "LastError").
+		Updates(message).Error
+}
+
+// DeleteProcessedBefore removes messages processed before the given time and
+// returns how many were removed
+func (r *outboxRepository) DeleteProcessedBefore(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).
+		Where("status = ? AND processed_at < ?", model.OutboxProcessed, before).
+		Delete(&model.OutboxMessage{})
+	return res.RowsAffected, res.Error
+}
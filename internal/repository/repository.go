@@ -6,14 +6,28 @@ import (
 
 // Repositories holds all repository interfaces
 type Repositories struct {
-	User UserRepository
-	Todo TodoRepository
+	User       UserRepository
+	Todo       TodoRepository
+	Webhook    WebhookRepository
+	Outbox     OutboxRepository
+	Audit      AuditRepository
+	Attachment AttachmentRepository
+	Comment    CommentRepository
+	Workspace  WorkspaceRepository
+	UnitOfWork UnitOfWork
 }
 
 // NewRepositories creates a new Repositories instance
 func NewRepositories(db *gorm.DB) *Repositories {
 	return &Repositories{
-		User: NewUserRepository(db),
-		Todo: NewTodoRepository(db),
+		User:       NewUserRepository(db),
+		Todo:       NewTodoRepository(db),
+		Webhook:    NewWebhookRepository(db),
+		Outbox:     NewOutboxRepository(db),
+		Audit:      NewAuditRepository(db),
+		Attachment: NewAttachmentRepository(db),
+		Comment:    NewCommentRepository(db),
+		Workspace:  NewWorkspaceRepository(db),
+		UnitOfWork: NewUnitOfWork(db),
 	}
 }
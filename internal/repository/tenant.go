@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"myapp/internal/tenant"
+)
+
+// workspaceScope restricts a query on todos to the workspace of ctx. A
+// context without a workspace fails the query with tenant.ErrNoWorkspace
+// rather than letting it run unscoped.
+func workspaceScope(ctx context.Context) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		workspaceID, ok := tenant.WorkspaceID(ctx)
+		if !ok {
+			db.AddError(tenant.ErrNoWorkspace)
+			return db
+		}
+		return db.Where("todos.workspace_id = ?", workspaceID)
+	}
+}
+
+// memberScope restricts a query on users to the members of the workspace
+// of ctx. Contexts marked with tenant.CrossTenant see every user; any other
+// context without a workspace fails the query with tenant.ErrNoWorkspace.
+func memberScope(ctx context.Context) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if tenant.IsCrossTenant(ctx) {
+			return db
+		}
+		workspaceID, ok := tenant.WorkspaceID(ctx)
+		if !ok {
+			db.AddError(tenant.ErrNoWorkspace)
+			return db
+		}
+		return db.Where("EXISTS (SELECT 1 FROM workspace_members WHERE workspace_members.user_id = users.id AND workspace_members.workspace_id = ?)", workspaceID)
+	}
+}
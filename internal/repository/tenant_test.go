@@ -0,0 +1,414 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"myapp/internal/model"
+	"myapp/internal/tenant"
+)
+
+// tenants are two workspaces seeded with the same kinds of data. Alice is
+// a member of A, Bob of B and Carol of both; each member has todos in each
+// of their workspaces.
+type tenants struct {
+	a, b                   context.Context
+	workspaceA, workspaceB uint
+	alice, bob, carol      *model.User
+	aliceTodo, bobTodo     *model.Todo
+	carolA, carolB         *model.Todo
+}
+
+func seedTenants(t *testing.T, b testBackend) *tenants {
+	t.Helper()
+	ctx := context.Background()
+	s := &tenants{}
+
+	user := func(name string) *model.User {
+		token := name + "-calendar"
+		u := &model.User{Email: name + "@example.com", Username: name, Password: "hash", CalendarTokenHash: &token}
+		if err := b.users.Create(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	s.alice, s.bob, s.carol = user("alice"), user("bob"), user("carol")
+
+	workspace := func(owner *model.User, members ...*model.User) uint {
+		w := &model.Workspace{Name: owner.Username, CreatedBy: owner.ID}
+		if err := b.workspaces.Create(ctx, w); err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range append([]*model.User{owner}, members...) {
+			if err := b.workspaces.AddMember(ctx, &model.WorkspaceMember{WorkspaceID: w.ID, UserID: m.ID, Role: model.WorkspaceRoleMember}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.ID
+	}
+	s.workspaceA = workspace(s.alice, s.carol)
+	s.workspaceB = workspace(s.bob, s.carol)
+	s.a = tenant.WithWorkspace(ctx, s.workspaceA)
+	s.b = tenant.WithWorkspace(ctx, s.workspaceB)
+
+	todo := func(ctx context.Context, owner *model.User) *model.Todo {
+		todo := &model.Todo{Title: fmt.Sprintf("%s's todo", owner.Username), UserID: owner.ID}
+		if err := b.todos.Create(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+		return todo
+	}
+	s.aliceTodo = todo(s.a, s.alice)
+	s.bobTodo = todo(s.b, s.bob)
+	s.carolA = todo(s.a, s.carol)
+	s.carolB = todo(s.b, s.carol)
+	return s
+}
+
+// todoIDs returns the IDs of todos
+func todoIDs(todos []*model.Todo) []uint {
+	ids := make([]uint, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+	}
+	return ids
+}
+
+func TestTodoRepositoryCreateUsesContextWorkspace(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b testBackend) {
+		s := seedTenants(t, b)
+
+		// A workspace set by the caller is overridden by the context's
+		todo := &model.Todo{Title: "planted", UserID: s.carol.ID, WorkspaceID: s.workspaceB}
+		if err := b.todos.Create(s.a, todo); err != nil {
+			t.Fatal(err)
+		}
+		batch := []*model.Todo{
+			{Title: "planted 1", UserID: s.carol.ID, WorkspaceID: s.workspaceB},
+			{Title: "planted 2", UserID: s.carol.ID, WorkspaceID: s.workspaceB},
+		}
+		if err := b.todos.CreateBatch(s.a, batch); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, created := range append(batch, todo) {
+			if created.WorkspaceID != s.workspaceA {
+				t.Errorf("todo %d created in workspace %d, want %d", created.ID, created.WorkspaceID, s.workspaceA)
+			}
+			if _, err := b.todos.GetByID(s.b, created.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetByID in B = %v, want ErrNotFound", err)
+			}
+			if _, err := b.todos.GetByID(s.a, created.ID); err != nil {
+				t.Errorf("GetByID in A = %v", err)
+			}
+		}
+	})
+}
+
+func TestTodoRepositoryReadsStayInWorkspace(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b testBackend) {
+		s := seedTenants(t, b)
+
+		// Single todos of the other workspace are not found, even for
+		// their owner
+		for _, tt := range []struct {
+			ctx  context.Context
+			todo *model.Todo
+		}{
+			{s.b, s.aliceTodo},
+			{s.b, s.carolA},
+			{s.a, s.bobTodo},
+			{s.a, s.carolB},
+		} {
+			if _, err := b.todos.GetByID(tt.ctx, tt.todo.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetByID(%d) = %v, want ErrNotFound", tt.todo.ID, err)
+			}
+			if _, err := b.todos.GetByIDForUser(tt.ctx, tt.todo.ID, tt.todo.UserID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetByIDForUser(%d) = %v, want ErrNotFound", tt.todo.ID, err)
+			}
+		}
+
+		// Listings of a member of both workspaces only hold the todos of
+		// the context's
+		for _, tt := range []struct {
+			ctx  context.Context
+			want uint
+		}{
+			{s.a, s.carolA.ID},
+			{s.b, s.carolB.ID},
+		} {
+			todos, err := b.todos.GetByUserID(tt.ctx, s.carol.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := todoIDs(todos); len(ids) != 1 || ids[0] != tt.want {
+				t.Errorf("GetByUserID = %v, want [%d]", ids, tt.want)
+			}
+
+			var each []uint
+			err = b.todos.EachByUserID(tt.ctx, s.carol.ID, 1, func(todos []*model.Todo) error {
+				each = append(each, todoIDs(todos)...)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(each) != 1 || each[0] != tt.want {
+				t.Errorf("EachByUserID = %v, want [%d]", each, tt.want)
+			}
+
+			changes, err := b.todos.GetChangesForUser(tt.ctx, s.carol.ID, 0, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := todoIDs(changes); len(ids) != 1 || ids[0] != tt.want {
+				t.Errorf("GetChangesForUser = %v, want [%d]", ids, tt.want)
+			}
+		}
+
+		// Members of one workspace have nothing in the other
+		if todos, err := b.todos.GetByUserID(s.b, s.alice.ID); err != nil || len(todos) != 0 {
+			t.Errorf("GetByUserID(alice) in B = %v, %v; want none", todoIDs(todos), err)
+		}
+		if lastModified, err := b.todos.LastModifiedForUser(s.b, s.alice.ID); err != nil || !lastModified.IsZero() {
+			t.Errorf("LastModifiedForUser(alice) in B = %v, %v; want the zero time", lastModified, err)
+		}
+		if lastModified, err := b.todos.LastModifiedForUser(s.a, s.alice.ID); err != nil || lastModified.IsZero() {
+			t.Errorf("LastModifiedForUser(alice) in A = %v, %v; want a time", lastModified, err)
+		}
+	})
+}
+
+func TestTodoRepositoryChangesStayInWorkspace(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b testBackend) {
+		s := seedTenants(t, b)
+		since := latestChange(t, b, s)
+
+		// Updating a todo of workspace A from B changes nothing
+		stolen := *s.carolA
+		stolen.Title = "stolen"
+		if err := b.todos.Update(s.b, &stolen); !errors.Is(err, ErrConflict) {
+			t.Errorf("Update from B = %v, want ErrConflict", err)
+		}
+		if todo, err := b.todos.GetByID(s.a, s.carolA.ID); err != nil || todo.Title != s.carolA.Title || todo.Version != s.carolA.Version {
+			t.Errorf("todo after Update from B = %+v, %v; want it unchanged", todo, err)
+		}
+
+		// Deleting it from B fails and leaves it in place
+		if err := b.todos.Delete(s.b, s.carolA.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Delete from B = %v, want ErrNotFound", err)
+		}
+		if _, err := b.todos.GetByID(s.a, s.carolA.ID); err != nil {
+			t.Errorf("todo after Delete from B: %v", err)
+		}
+
+		// Carol's changes in B, including a tombstone, do not show in A's
+		// change feed
+		updated := *s.carolB
+		updated.Title = "renamed"
+		if err := b.todos.Update(s.b, &updated); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.todos.Delete(s.b, s.carolB.ID); err != nil {
+			t.Fatal(err)
+		}
+		if changes, err := b.todos.GetChangesForUser(s.a, s.carol.ID, since, 100); err != nil || len(changes) != 0 {
+			t.Errorf("GetChangesForUser in A = %v, %v; want none", todoIDs(changes), err)
+		}
+		changes, err := b.todos.GetChangesForUser(s.b, s.carol.ID, since, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := todoIDs(changes); len(ids) != 1 || ids[0] != s.carolB.ID || !changes[0].DeletedAt.Valid {
+			t.Errorf("GetChangesForUser in B = %v, want the tombstone of %d", ids, s.carolB.ID)
+		}
+	})
+}
+
+// latestChange returns the sequence number of carol's latest change in
+// either workspace
+func latestChange(t *testing.T, b testBackend, s *tenants) int64 {
+	t.Helper()
+	var since int64
+	for _, ctx := range []context.Context{s.a, s.b} {
+		todos, err := b.todos.GetChangesForUser(ctx, s.carol.ID, 0, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, todo := range todos {
+			since = max(since, todo.ChangeSeq)
+		}
+	}
+	return since
+}
+
+func TestTodoRepositoryRequiresWorkspace(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b testBackend) {
+		s := seedTenants(t, b)
+		ctx := context.Background()
+
+		todo := *s.aliceTodo
+		calls := map[string]error{
+			"Create":      b.todos.Create(ctx, &model.Todo{Title: "orphan", UserID: s.alice.ID}),
+			"CreateBatch": b.todos.CreateBatch(ctx, []*model.Todo{{Title: "orphan", UserID: s.alice.ID}}),
+			"Update":      b.todos.Update(ctx, &todo),
+			"Delete":      b.todos.Delete(ctx, s.aliceTodo.ID),
+			"EachByUserID": b.todos.EachByUserID(ctx, s.alice.ID, 10, func([]*model.Todo) error {
+				return nil
+			}),
+		}
+		_, calls["GetByID"] = b.todos.GetByID(ctx, s.aliceTodo.ID)
+		_, calls["GetByIDForUser"] = b.todos.GetByIDForUser(ctx, s.aliceTodo.ID, s.alice.ID)
+		_, calls["GetByUserID"] = b.todos.GetByUserID(ctx, s.alice.ID)
+		_, calls["GetChangesForUser"] = b.todos.GetChangesForUser(ctx, s.alice.ID, 0, 100)
+		_, calls["LastModifiedForUser"] = b.todos.LastModifiedForUser(ctx, s.alice.ID)
+
+		for name, err := range calls {
+			if !errors.Is(err, tenant.ErrNoWorkspace) {
+				t.Errorf("%s without a workspace = %v, want tenant.ErrNoWorkspace", name, err)
+			}
+		}
+		if todo, err := b.todos.GetByID(s.a, s.aliceTodo.ID); err != nil || todo.Version != s.aliceTodo.Version {
+			t.Errorf("todo after unscoped calls = %+v, %v; want it unchanged", todo, err)
+		}
+	})
+}
+
+func TestUserRepositorySeesOnlyMembers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b testBackend) {
+		s := seedTenants(t, b)
+
+		lookups := map[string]func(ctx context.Context, u *model.User) (*model.User, error){
+			"GetByID": func(ctx context.Context, u *model.User) (*model.User, error) {
+				return b.users.GetByID(ctx, u.ID)
+			},
+			"GetByEmail": func(ctx context.Context, u *model.User) (*model.User, error) {
+				return b.users.GetByEmail(ctx, u.Email)
+			},
+			"GetByUsername": func(ctx context.Context, u *model.User) (*model.User, error) {
+				return b.users.GetByUsername(ctx, u.Username)
+			},
+			"GetByCalendarTokenHash": func(ctx context.Context, u *model.User) (*model.User, error) {
+				return b.users.GetByCalendarTokenHash(ctx, *u.CalendarTokenHash)
+			},
+		}
+		for name, lookup := range lookups {
+			for _, tt := range []struct {
+				ctx     context.Context
+				user    *model.User
+				visible bool
+			}{
+				{s.a, s.alice, true},
+				{s.a, s.carol, true},
+				{s.a, s.bob, false},
+				{s.b, s.alice, false},
+				{s.b, s.carol, true},
+				{tenant.CrossTenant(context.Background()), s.bob, true},
+			} {
+				found, err := lookup(tt.ctx, tt.user)
+				if tt.visible && (err != nil || found.ID != tt.user.ID) {
+					t.Errorf("%s(%s) = %v, %v; want the user", name, tt.user.Username, found, err)
+				}
+				if !tt.visible && !errors.Is(err, ErrNotFound) {
+					t.Errorf("%s(%s) from another workspace = %v, %v; want ErrNotFound", name, tt.user.Username, found, err)
+				}
+			}
+		}
+	})
+}
+
+func TestUserRepositoryRequiresWorkspace(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b testBackend) {
+		s := seedTenants(t, b)
+		ctx := context.Background()
+
+		alice := *s.alice
+		alice.Timezone = "Europe/Paris"
+		calls := map[string]error{
+			"Update": b.users.Update(ctx, &alice),
+			"Delete": b.users.Delete(ctx, s.alice.ID),
+		}
+		_, calls["GetByID"] = b.users.GetByID(ctx, s.alice.ID)
+		_, calls["GetByEmail"] = b.users.GetByEmail(ctx, s.alice.Email)
+		_, calls["GetByUsername"] = b.users.GetByUsername(ctx, s.alice.Username)
+		_, calls["GetByCalendarTokenHash"] = b.users.GetByCalendarTokenHash(ctx, *s.alice.CalendarTokenHash)
+
+		for name, err := range calls {
+			if !errors.Is(err, tenant.ErrNoWorkspace) {
+				t.Errorf("%s without a workspace = %v, want tenant.ErrNoWorkspace", name, err)
+			}
+		}
+		if stored, err := b.users.GetByID(s.a, s.alice.ID); err != nil || stored.Timezone != s.alice.Timezone {
+			t.Errorf("user after unscoped calls = %+v, %v; want it unchanged", stored, err)
+		}
+	})
+}
+
+func TestUserRepositoryChangesOnlyMembers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b testBackend) {
+		s := seedTenants(t, b)
+		cross := tenant.CrossTenant(context.Background())
+
+		// Updating a user of workspace A from B changes nothing
+		alice, err := b.users.GetByID(s.a, s.alice.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		alice.Timezone = "Europe/Paris"
+		if err := b.users.Update(s.b, alice); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Update from B = %v, want ErrNotFound", err)
+		}
+		if stored, err := b.users.GetByID(cross, s.alice.ID); err != nil || stored.Timezone != s.alice.Timezone {
+			t.Errorf("user after Update from B = %+v, %v; want it unchanged", stored, err)
+		}
+
+		// Deleting them from B leaves them in place
+		if err := b.users.Delete(s.b, s.alice.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			t.Errorf("Delete from B = %v", err)
+		}
+		if _, err := b.users.GetByID(cross, s.alice.ID); err != nil {
+			t.Errorf("user after Delete from B: %v", err)
+		}
+
+		// Members of the context's workspace can be changed
+		if err := b.users.Update(s.a, alice); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.users.Delete(s.a, s.alice.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.users.GetByID(cross, s.alice.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("user after Delete from A = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestUserRepositoryCreateJoinsNoWorkspace(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b testBackend) {
+		s := seedTenants(t, b)
+
+		// Users exist outside every workspace until added as a member, even
+		// if created with a workspace in the context
+		dave := &model.User{Email: "dave@example.com", Username: "dave", Password: "hash"}
+		if err := b.users.Create(s.a, dave); err != nil {
+			t.Fatal(err)
+		}
+		for _, ctx := range []context.Context{s.a, s.b} {
+			if _, err := b.users.GetByID(ctx, dave.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetByID(dave) = %v, want ErrNotFound", err)
+			}
+		}
+
+		if err := b.workspaces.AddMember(context.Background(), &model.WorkspaceMember{WorkspaceID: s.workspaceB, UserID: dave.ID, Role: model.WorkspaceRoleMember}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.users.GetByID(s.a, dave.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetByID(dave) in A = %v, want ErrNotFound", err)
+		}
+		if _, err := b.users.GetByID(s.b, dave.ID); err != nil {
+			t.Errorf("GetByID(dave) in B = %v", err)
+		}
+	})
+}
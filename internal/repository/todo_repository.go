@@ -2,16 +2,35 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"myapp/internal/database"
 	"myapp/internal/model"
+	"myapp/internal/tenant"
+	"time"
 
 	"gorm.io/gorm"
 )
 
-// TodoRepository defines the interface for todo operations
+// changeFeedLock namespaces the advisory locks that serialize writes to a
+// user's todos. Holding the lock until commit guarantees that change
+// sequence numbers become visible in increasing order for each user, so a
+// change feed cursor never skips a change committed late.
+const changeFeedLock = 0x746f646f // "todo"
+
+// TodoRepository defines the interface for todo operations. Every
+// operation is confined to the workspace of its context: todos are created
+// in it, and todos of other workspaces are never returned or changed.
+// Operations fail with tenant.ErrNoWorkspace if the context has none.
 type TodoRepository interface {
 	Create(ctx context.Context, todo *model.Todo) error
+	CreateBatch(ctx context.Context, todos []*model.Todo) error
 	GetByID(ctx context.Context, id uint) (*model.Todo, error)
+	GetByIDForUser(ctx context.Context, id uint, userID uint) (*model.Todo, error)
 	GetByUserID(ctx context.Context, userID uint) ([]*model.Todo, error)
+	EachByUserID(ctx context.Context, userID uint, batchSize int, fn func(todos []*model.Todo) error) error
+	GetChangesForUser(ctx context.Context, userID uint, since int64, limit int) ([]*model.Todo, error)
+	LastModifiedForUser(ctx context.Context, userID uint) (time.Time, error)
 	Update(ctx context.Context, todo *model.Todo) error
 	Delete(ctx context.Context, id uint) error
 }
@@ -25,30 +44,217 @@ func NewTodoRepository(db *gorm.DB) TodoRepository {
 	return &todoRepository{db: db}
 }
 
+// scoped returns a query confined to the workspace of ctx
+func (r *todoRepository) scoped(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Scopes(workspaceScope(ctx))
+}
+
+// assignWorkspace places todos in the workspace of ctx
+func assignWorkspace(ctx context.Context, todos ...*model.Todo) error {
+	workspaceID, ok := tenant.WorkspaceID(ctx)
+	if !ok {
+		return tenant.ErrNoWorkspace
+	}
+	for _, todo := range todos {
+		todo.WorkspaceID = workspaceID
+	}
+	return nil
+}
+
+// Create inserts a todo into the workspace of ctx and assigns its change
+// sequence number
 func (r *todoRepository) Create(ctx context.Context, todo *model.Todo) error {
-	return r.db.WithContext(ctx).Create(todo).Error
+	if err := assignWorkspace(ctx, todo); err != nil {
+		return err
+	}
+	if todo.Version == 0 {
+		todo.Version = 1
+	}
+	return r.write(ctx, todo.UserID, func(tx *gorm.DB, seq int64) error {
+		todo.ChangeSeq = seq
+		todo.CreatedSeq = seq
+		return tx.Create(todo).Error
+	})
 }
 
+// CreateBatch inserts todos of a single user into the workspace of ctx with one statement, assigning
+// each its own change sequence number in order
+func (r *todoRepository) CreateBatch(ctx context.Context, todos []*model.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	userID := todos[0].UserID
+	for _, todo := range todos {
+		if todo.UserID != userID {
+			return errors.New("repository: todo batch spans several users")
+		}
+		if todo.Version == 0 {
+			todo.Version = 1
+		}
+	}
+	if err := assignWorkspace(ctx, todos...); err != nil {
+		return err
+	}
+
+	return r.writeN(ctx, userID, len(todos), func(tx *gorm.DB, seqs []int64) error {
+		for i, todo := range todos {
+			todo.ChangeSeq = seqs[i]
+			todo.CreatedSeq = seqs[i]
+		}
+		return tx.Create(&todos).Error
+	})
+}
+
+// GetByID retrieves a todo by ID, returning ErrNotFound if it does not exist
 func (r *todoRepository) GetByID(ctx context.Context, id uint) (*model.Todo, error) {
 	var todo model.Todo
-	if err := r.db.WithContext(ctx).First(&todo, id).Error; err != nil {
-		return nil, err
+	if err := r.scoped(ctx).Scopes(database.ReadOnly).First(&todo, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &todo, nil
+}
+
+// GetByIDForUser retrieves a todo by ID that belongs to the given user.
+// It returns ErrNotFound both when the todo does not exist and when it is
+// owned by another user.
+func (r *todoRepository) GetByIDForUser(ctx context.Context, id uint, userID uint) (*model.Todo, error) {
+	var todo model.Todo
+	if err := r.scoped(ctx).Scopes(database.ReadOnly).Where("user_id = ?", userID).First(&todo, id).Error; err != nil {
+		return nil, translateError(err)
 	}
 	return &todo, nil
 }
 
 func (r *todoRepository) GetByUserID(ctx context.Context, userID uint) ([]*model.Todo, error) {
 	var todos []*model.Todo
-	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&todos).Error; err != nil {
+	if err := r.scoped(ctx).Scopes(database.ReadOnly).Where("user_id = ?", userID).Find(&todos).Error; err != nil {
 		return nil, err
 	}
 	return todos, nil
 }
 
+// EachByUserID calls fn with the user's todos in batches of up to batchSize,
+// in ID order, so that all of them can be processed without holding them
+// in memory at once. It stops at the first error returned by fn.
+func (r *todoRepository) EachByUserID(ctx context.Context, userID uint, batchSize int, fn func(todos []*model.Todo) error) error {
+	var todos []*model.Todo
+	return r.scoped(ctx).Scopes(database.ReadOnly).Where("user_id = ?", userID).
+		FindInBatches(&todos, batchSize, func(tx *gorm.DB, batch int) error {
+			return fn(todos)
+		}).Error
+}
+
+// GetChangesForUser returns up to limit of the user's todos changed after
+// the since sequence number, in change order. Deleted todos are included as
+// tombstones, except on a full sync from sequence 0.
+func (r *todoRepository) GetChangesForUser(ctx context.Context, userID uint, since int64, limit int) ([]*model.Todo, error) {
+	query := r.scoped(ctx).Scopes(database.ReadOnly).Unscoped().
+		Where("user_id = ? AND change_seq > ?", userID, since)
+	if since == 0 {
+		query = query.Where("deleted_at IS NULL")
+	}
+
+	var todos []*model.Todo
+	if err := query.Order("change_seq").Limit(limit).Find(&todos).Error; err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// LastModifiedForUser returns when the user's todos last changed, counting
+// deletions, or the zero time if the user has none
+func (r *todoRepository) LastModifiedForUser(ctx context.Context, userID uint) (time.Time, error) {
+	// Selecting the column rather than MAX(updated_at) keeps its type, which
+	// SQLite only knows for columns
+	var lastModified []time.Time
+	err := r.scoped(ctx).Scopes(database.ReadOnly).Unscoped().Model(&model.Todo{}).
+		Where("user_id = ?", userID).Order("updated_at DESC").Limit(1).Pluck("updated_at", &lastModified).Error
+	if err != nil || len(lastModified) == 0 {
+		return time.Time{}, err
+	}
+	return lastModified[0], nil
+}
+
+// Update saves a todo if its stored version still equals todo.Version and
+// increments the version. It returns ErrConflict if the row was changed by
+// someone else in the meantime.
 func (r *todoRepository) Update(ctx context.Context, todo *model.Todo) error {
-	return r.db.WithContext(ctx).Save(todo).Error
+	expected, previousSeq := todo.Version, todo.ChangeSeq
+
+	err := r.write(ctx, todo.UserID, func(tx *gorm.DB, seq int64) error {
+		todo.Version = expected + 1
+		todo.ChangeSeq = seq
+
+		res := tx.Model(&model.Todo{}).
+			Scopes(workspaceScope(ctx)).
+			Where("id = ? AND version = ?", todo.ID, expected).
+			Select("*").
+			Omit("ID", "WorkspaceID", "User", "CreatedAt", "CreatedSeq", "DeletedAt").
+			Updates(todo)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrConflict
+		}
+		return nil
+	})
+	if err != nil {
+		todo.Version, todo.ChangeSeq = expected, previousSeq
+	}
+	return err
 }
 
+// Delete soft-deletes a todo, leaving a tombstone for the change feed
 func (r *todoRepository) Delete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&model.Todo{}, id).Error
+	var todo model.Todo
+	if err := r.scoped(ctx).Select("id", "user_id").First(&todo, id).Error; err != nil {
+		return translateError(err)
+	}
+
+	return r.write(ctx, todo.UserID, func(tx *gorm.DB, seq int64) error {
+		return tx.Model(&model.Todo{}).Scopes(workspaceScope(ctx)).Where("id = ?", id).Updates(map[string]interface{}{
+			"deleted_at": time.Now(),
+			"change_seq": seq,
+			"version":    gorm.Expr("version + 1"),
+		}).Error
+	})
+}
+
+// write runs a change to a user's todos in a transaction holding the user's
+// change feed lock and passes it the next change sequence number
+func (r *todoRepository) write(ctx context.Context, userID uint, fn func(tx *gorm.DB, seq int64) error) error {
+	return r.writeN(ctx, userID, 1, func(tx *gorm.DB, seqs []int64) error {
+		return fn(tx, seqs[0])
+	})
+}
+
+// writeN is like write for a change to n todos, passing it the next n
+// change sequence numbers in increasing order
+func (r *todoRepository) writeN(ctx context.Context, userID uint, n int, fn func(tx *gorm.DB, seqs []int64) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var seqs []int64
+		if database.IsSQLite(tx) {
+			// SQLite transactions hold the database's write lock from the
+			// start, so they are serialized already
+			var last int64
+			if err := tx.Raw("UPDATE todo_change_seq SET value = value + ? RETURNING value", n).Scan(&last).Error; err != nil {
+				return err
+			}
+			for seq := last - int64(n) + 1; seq <= last; seq++ {
+				seqs = append(seqs, seq)
+			}
+		} else {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", changeFeedLock, int32(userID)).Error; err != nil {
+				return err
+			}
+			if err := tx.Raw("SELECT nextval('todo_change_seq') FROM generate_series(1, ?) ORDER BY 1", n).Scan(&seqs).Error; err != nil {
+				return err
+			}
+		}
+		if len(seqs) != n {
+			return fmt.Errorf("repository: allocated %d change sequence numbers, want %d", len(seqs), n)
+		}
+		return fn(tx, seqs)
+	})
 }
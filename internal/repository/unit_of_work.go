@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// UnitOfWork runs work that spans several repositories in one database
+// transaction
+type UnitOfWork interface {
+	// Do runs fn with repositories bound to a transaction, committing if fn
+	// returns nil and rolling back otherwise. Calling Do on the UnitOfWork of
+	// transaction-bound repositories creates a savepoint, so a nested failure
+	// only rolls back the nested work.
+	Do(ctx context.Context, fn func(tx *Repositories) error) error
+}
+
+type unitOfWork struct {
+	db          *gorm.DB
+	afterCommit []func()
+}
+
+// NewUnitOfWork creates a new UnitOfWork. The afterCommit hooks run once a
+// top-level transaction has committed, for example to wake up the outbox
+// dispatcher.
+func NewUnitOfWork(db *gorm.DB, afterCommit ...func()) UnitOfWork {
+	return &unitOfWork{db: db, afterCommit: afterCommit}
+}
+
+func (u *unitOfWork) Do(ctx context.Context, fn func(tx *Repositories) error) error {
+	err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewRepositories(tx))
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range u.afterCommit {
+		hook()
+	}
+	return nil
+}
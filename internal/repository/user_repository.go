@@ -2,18 +2,22 @@ package repository
 
 import (
 	"context"
-	"errors"
+	"myapp/internal/database"
 	"myapp/internal/model"
 
 	"gorm.io/gorm"
 )
 
-// UserRepository defines the interface for user-related database operations
+// UserRepository defines the interface for user-related database
+// operations. Lookups and changes only see the members of the workspace of
+// their context, or every user if the context is marked with
+// tenant.CrossTenant; they fail with tenant.ErrNoWorkspace otherwise.
 type UserRepository interface {
 	Create(ctx context.Context, user *model.User) error
 	GetByID(ctx context.Context, id uint) (*model.User, error)
 	GetByEmail(ctx context.Context, email string) (*model.User, error)
 	GetByUsername(ctx context.Context, username string) (*model.User, error)
+	GetByCalendarTokenHash(ctx context.Context, hash string) (*model.User, error)
 	Update(ctx context.Context, user *model.User) error
 	Delete(ctx context.Context, id uint) error
 }
@@ -30,53 +34,72 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	}
 }
 
-// Create inserts a new user into the database
+// scoped returns a query confined to the members of the workspace of ctx
+func (r *userRepository) scoped(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Scopes(memberScope(ctx))
+}
+
+// Create inserts a new user into the database. Users are not part of any
+// workspace until they are added as a member. It returns ErrDuplicate if
+// the email, username or calendar token is taken, even by a deleted user.
 func (r *userRepository) Create(ctx context.Context, user *model.User) error {
-	return r.db.WithContext(ctx).Create(user).Error
+	return translateError(r.db.WithContext(ctx).Create(user).Error)
 }
 
-// GetByID retrieves a user by ID
+// GetByID retrieves a user by ID, returning ErrNotFound if it does not exist.
+// Like GetByEmail and GetByUsername, it reads from the primary: they
+// authenticate requests, including the first ones of a user just
+// registered, that no replica may have seen yet.
 func (r *userRepository) GetByID(ctx context.Context, id uint) (*model.User, error) {
 	var user model.User
-	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
-		}
-		return nil, err
+	if err := r.scoped(ctx).First(&user, id).Error; err != nil {
+		return nil, translateError(err)
 	}
 	return &user, nil
 }
 
-// GetByEmail retrieves a user by email
+// GetByEmail retrieves a user by email, returning ErrNotFound if it does not exist
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
 	var user model.User
-	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
-		}
-		return nil, err
+	if err := r.scoped(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translateError(err)
 	}
 	return &user, nil
 }
 
-// GetByUsername retrieves a user by username
+// GetByUsername retrieves a user by username, returning ErrNotFound if it does not exist
 func (r *userRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
 	var user model.User
-	if err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
-		}
-		return nil, err
+	if err := r.scoped(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, translateError(err)
 	}
 	return &user, nil
 }
 
-// Update updates a user in the database
+// GetByCalendarTokenHash retrieves the user whose calendar feed token has
+// the given hash, returning ErrNotFound if there is none
+func (r *userRepository) GetByCalendarTokenHash(ctx context.Context, hash string) (*model.User, error) {
+	var user model.User
+	if err := r.scoped(ctx).Scopes(database.ReadOnly).Where("calendar_token_hash = ?", hash).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+// Update updates a user in the database, returning ErrNotFound if it does
+// not exist and ErrDuplicate like Create
 func (r *userRepository) Update(ctx context.Context, user *model.User) error {
-	return r.db.WithContext(ctx).Save(user).Error
+	res := r.scoped(ctx).Model(user).Select("*").Omit("ID", "CreatedAt", "DeletedAt").Updates(user)
+	if res.Error != nil {
+		return translateError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
 // Delete removes a user from the database
 func (r *userRepository) Delete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&model.User{}, id).Error
+	return r.scoped(ctx).Delete(&model.User{}, id).Error
 }
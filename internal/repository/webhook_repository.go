@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/database"
+	"myapp/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebhookRepository defines the interface for webhook and webhook delivery
+// operations
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *model.Webhook) error
+	GetByIDForUser(ctx context.Context, id uint, userID uint) (*model.Webhook, error)
+	GetByUserID(ctx context.Context, userID uint) ([]*model.Webhook, error)
+	GetActiveByUserID(ctx context.Context, userID uint) ([]*model.Webhook, error)
+	Update(ctx context.Context, webhook *model.Webhook) error
+	Delete(ctx context.Context, id uint) error
+
+	CreateDeliveries(ctx context.Context, deliveries []*model.WebhookDelivery) error
+	GetDeliveries(ctx context.Context, webhookID uint, limit int) ([]*model.WebhookDelivery, error)
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*model.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository creates a new WebhookRepository instance
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) Create(ctx context.Context, webhook *model.Webhook) error {
+	return r.db.WithContext(ctx).Create(webhook).Error
+}
+
+// GetByIDForUser retrieves a webhook by ID that belongs to the given user,
+// returning ErrNotFound if it does not exist or is owned by another user
+func (r *webhookRepository) GetByIDForUser(ctx context.Context, id uint, userID uint) (*model.Webhook, error) {
+	var webhook model.Webhook
+	if err := r.db.WithContext(ctx).Scopes(database.ReadOnly).Where("user_id = ?", userID).First(&webhook, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &webhook, nil
+}
+
+func (r *webhookRepository) GetByUserID(ctx context.Context, userID uint) ([]*model.Webhook, error) {
+	var webhooks []*model.Webhook
+	if err := r.db.WithContext(ctx).Scopes(database.ReadOnly).Where("user_id = ?", userID).Order("id").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// GetActiveByUserID returns the user's webhooks that are enabled
+func (r *webhookRepository) GetActiveByUserID(ctx context.Context, userID uint) ([]*model.Webhook, error) {
+	var webhooks []*model.Webhook
+	if err := r.db.WithContext(ctx).Where("user_id = ? AND active", userID).Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *webhookRepository) Update(ctx context.Context, webhook *model.Webhook) error {
+	return r.db.WithContext(ctx).Save(webhook).Error
+}
+
+// Delete removes a webhook together with its delivery log
+func (r *webhookRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&model.Webhook{}, id).Error
+}
+
+// CreateDeliveries queues deliveries, skipping any that already exist for
+// the same webhook and event
+func (r *webhookRepository) CreateDeliveries(ctx context.Context, deliveries []*model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(deliveries).Error
+}
+
+// GetDeliveries returns up to limit of a webhook's deliveries, newest first
+func (r *webhookRepository) GetDeliveries(ctx context.Context, webhookID uint, limit int) ([]*model.WebhookDelivery, error) {
+	var deliveries []*model.WebhookDelivery
+	if err := r.db.WithContext(ctx).Scopes(database.ReadOnly).
+		Where("webhook_id = ?", webhookID).
+		Order("id DESC").
+		Limit(limit).
+		Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ClaimDueDeliveries returns up to limit pending deliveries whose next
+// attempt is due, with their webhook loaded. Claimed deliveries are leased
+// by pushing their next attempt lease into the future, so concurrent
+// workers never pick up the same delivery and a delivery abandoned by a
+// crashed worker is retried once the lease expires.
+func (r *webhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*model.WebhookDelivery, error) {
+	var deliveries []*model.WebhookDelivery
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		if err := tx.Model(&model.WebhookDelivery{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.WebhookDeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&model.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error; err != nil {
+			return err
+		}
+
+		return tx.Preload("Webhook").Where("id IN ?", ids).Order("id").Find(&deliveries).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// UpdateDelivery records the outcome of a delivery attempt
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return r.db.WithContext(ctx).Model(delivery).
+		Select("Status", "Attempts", "NextAttemptAt", "LastAttemptAt", "ResponseStatus", "LastError").
+		Updates(delivery).Error
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/database"
+	"myapp/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// WorkspaceRepository defines the interface for workspace and membership
+// operations. It decides which workspaces a user may act in, so unlike the
+// tenant-scoped repositories it takes workspace IDs explicitly.
+type WorkspaceRepository interface {
+	Create(ctx context.Context, workspace *model.Workspace) error
+	GetMembership(ctx context.Context, workspaceID uint, userID uint) (*model.WorkspaceMembership, error)
+	ListForUser(ctx context.Context, userID uint) ([]*model.WorkspaceMembership, error)
+	AddMember(ctx context.Context, member *model.WorkspaceMember) error
+	ListMembers(ctx context.Context, workspaceID uint) ([]*model.WorkspaceMember, error)
+	CountOwners(ctx context.Context, workspaceID uint) (int64, error)
+	RemoveMember(ctx context.Context, workspaceID uint, userID uint) error
+}
+
+type workspaceRepository struct {
+	db *gorm.DB
+}
+
+// NewWorkspaceRepository creates a new WorkspaceRepository instance
+func NewWorkspaceRepository(db *gorm.DB) WorkspaceRepository {
+	return &workspaceRepository{db: db}
+}
+
+// Create inserts a workspace
+func (r *workspaceRepository) Create(ctx context.Context, workspace *model.Workspace) error {
+	return r.db.WithContext(ctx).Create(workspace).Error
+}
+
+// GetMembership retrieves a workspace together with the user's role in it,
+// returning ErrNotFound if it does not exist or the user is not a member
+func (r *workspaceRepository) GetMembership(ctx context.Context, workspaceID uint, userID uint) (*model.WorkspaceMembership, error) {
+	var membership model.WorkspaceMembership
+	if err := r.memberships(ctx, userID).Where("workspaces.id = ?", workspaceID).Take(&membership).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &membership, nil
+}
+
+// ListForUser returns the workspaces the user is a member of, in the order
+// they were joined
+func (r *workspaceRepository) ListForUser(ctx context.Context, userID uint) ([]*model.WorkspaceMembership, error) {
+	var memberships []*model.WorkspaceMembership
+	if err := r.memberships(ctx, userID).Order("workspace_members.created_at, workspaces.id").Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// AddMember inserts a membership
+func (r *workspaceRepository) AddMember(ctx context.Context, member *model.WorkspaceMember) error {
+	return r.db.WithContext(ctx).Create(member).Error
+}
+
+// ListMembers returns the members of a workspace with their users, in the
+// order they joined
+func (r *workspaceRepository) ListMembers(ctx context.Context, workspaceID uint) ([]*model.WorkspaceMember, error) {
+	var members []*model.WorkspaceMember
+	if err := r.db.WithContext(ctx).Scopes(database.ReadOnly).Preload("User").Where("workspace_id = ?", workspaceID).
+		Order("created_at, user_id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// CountOwners returns the number of owners of a workspace
+func (r *workspaceRepository) CountOwners(ctx context.Context, workspaceID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.WorkspaceMember{}).
+		Where("workspace_id = ? AND role = ?", workspaceID, model.WorkspaceRoleOwner).
+		Count(&count).Error
+	return count, err
+}
+
+// RemoveMember deletes a membership, returning ErrNotFound if the user is
+// not a member
+func (r *workspaceRepository) RemoveMember(ctx context.Context, workspaceID uint, userID uint) error {
+	res := r.db.WithContext(ctx).Where("workspace_id = ? AND user_id = ?", workspaceID, userID).Delete(&model.WorkspaceMember{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// memberships returns a query for the workspaces the user is a member of,
+// with the user's role
+func (r *workspaceRepository) memberships(ctx context.Context, userID uint) *gorm.DB {
+	return r.db.WithContext(ctx).Scopes(database.ReadOnly).Table("workspaces").
+		Select("workspaces.*, workspace_members.role").
+		Joins("JOIN workspace_members ON workspace_members.workspace_id = workspaces.id").
+		Where("workspace_members.user_id = ?", userID)
+}
@@ -2,46 +2,90 @@ package router
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 
 	"myapp/internal/handler"
 	authmiddleware "myapp/internal/middleware"
+	"myapp/internal/model"
 	"myapp/internal/router/routes"
 	"myapp/internal/service"
 )
 
-// New creates a new router with all routes configured
-func New(h *handler.Handler, authService service.AuthService) http.Handler {
+// swaggerAssetMaxAge is how long the Swagger UI's static files may be
+// cached. Their URLs do not change with new versions, so they are not
+// cached forever.
+const swaggerAssetMaxAge = 30 * 24 * time.Hour
+
+// New creates a new router with all routes configured. The middleware that
+// need settings are built by the caller and passed in; New decides which
+// routes each one applies to and in what order.
+func New(h *handler.Handler, authService service.AuthService, clientIP, compress, idempotency, rateLimit, readYourWrites func(http.Handler) http.Handler) http.Handler {
 	r := chi.NewRouter()
 
 	// Global middleware
-	r.Use(chimiddleware.Logger)
-	r.Use(chimiddleware.Recoverer)
 	r.Use(chimiddleware.RequestID)
-	r.Use(chimiddleware.RealIP)
+	r.Use(clientIP)
+	r.Use(authmiddleware.LogRequests)
+	r.Use(chimiddleware.Recoverer)
+	r.Use(compress)
+	r.Use(authmiddleware.AuditRequest)
 
 	// Swagger UI routes (public)
 	r.Group(func(r chi.Router) {
+		r.Use(authmiddleware.CacheAssets(swaggerAssetMaxAge, ".js", ".css", ".png"))
 		routes.SetupSwaggerRoutes(r)
 	})
 
-	// Public routes
+	// Public routes, rate limited per client IP. They issue tokens, so their
+	// responses must not be stored for idempotent replay.
 	r.Group(func(r chi.Router) {
+		r.Use(rateLimit)
+		r.Use(readYourWrites)
 		routes.SetupAuthRoutes(r, h)
 	})
 
-	// Protected routes
+	// Signed download routes (authenticated by the link's signature)
 	r.Group(func(r chi.Router) {
+		routes.SetupFileRoutes(r, h)
+	})
+
+	// Calendar feed routes (authenticated by the feed token)
+	r.Group(func(r chi.Router) {
+		routes.SetupCalendarRoutes(r, h)
+	})
+
+	// Protected routes, which clients may cache but must revalidate. Reads
+	// that follow a user's writes go to the primary database, including the
+	// lookups of Auth.
+	r.Group(func(r chi.Router) {
+		r.Use(authmiddleware.PrivateCache)
+		r.Use(readYourWrites)
+
 		// Auth middleware
 		r.Use(authmiddleware.Auth(authService))
+		r.Use(rateLimit)
+		r.Use(idempotency)
 
 		// User routes
 		routes.SetupUserRoutes(r, h)
 
 		// Todo routes
 		routes.SetupTodoRoutes(r, h)
+
+		// Webhook routes
+		routes.SetupWebhookRoutes(r, h)
+
+		// Workspace routes
+		routes.SetupWorkspaceRoutes(r, h)
+
+		// Admin routes
+		r.Group(func(r chi.Router) {
+			r.Use(authmiddleware.RequireRole(model.RoleAdmin))
+			routes.SetupAdminRoutes(r, h)
+		})
 	})
 
 	return r
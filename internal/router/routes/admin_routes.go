@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"net/http"
+
+	"myapp/internal/handler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SetupAdminRoutes sets up administrative routes. Callers must restrict
+// them to administrators.
+func SetupAdminRoutes(r chi.Router, h *handler.Handler) {
+	r.Route("/admin", func(r chi.Router) {
+		r.Get("/audit", handler.Respond(http.StatusOK, h.AuditHandler.List))
+		r.Get("/audit/verify", handler.Respond(http.StatusOK, h.AuditHandler.Verify))
+	})
+}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"net/http"
+
+	"myapp/internal/handler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SetupAttachmentRoutes sets up the routes for the attachments of a todo,
+// below the todo's /todos/{id} route
+func SetupAttachmentRoutes(r chi.Router, h *handler.Handler) {
+	r.Route("/attachments", func(r chi.Router) {
+		r.With(h.AttachmentHandler.LimitBody).Post("/", handler.Respond(http.StatusCreated, h.AttachmentHandler.Upload))
+		r.Get("/", handler.Respond(http.StatusOK, h.AttachmentHandler.List))
+		r.Route("/{attachmentID}", func(r chi.Router) {
+			r.Get("/", handler.Respond(http.StatusOK, h.AttachmentHandler.Download))
+			r.Delete("/", handler.Respond(http.StatusNoContent, h.AttachmentHandler.Delete))
+			r.Post("/url", handler.Respond(http.StatusOK, h.AttachmentHandler.SignedURL))
+		})
+	})
+}
+
+// SetupFileRoutes sets up the signed download routes, which authenticate
+// through the link's signature instead of a token
+func SetupFileRoutes(r chi.Router, h *handler.Handler) {
+	r.Get("/files/{id}", handler.Respond(http.StatusOK, h.AttachmentHandler.DownloadSigned))
+}
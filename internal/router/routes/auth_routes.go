@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"net/http"
+
 	"myapp/internal/handler"
 
 	"github.com/go-chi/chi/v5"
@@ -8,6 +10,6 @@ import (
 
 // SetupAuthRoutes sets up authentication-related routes
 func SetupAuthRoutes(r chi.Router, h *handler.Handler) {
-	r.Post("/register", h.UserHandler.Register)
-	r.Post("/login", h.UserHandler.Login)
+	r.Post("/register", handler.Handle(h.Validator, http.StatusCreated, h.UserHandler.Register))
+	r.Post("/login", handler.Handle(h.Validator, http.StatusOK, h.UserHandler.Login))
 }
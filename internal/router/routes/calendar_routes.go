@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"net/http"
+
+	"myapp/internal/handler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SetupCalendarRoutes sets up the calendar feed routes, which authenticate
+// through the token in the link instead of a bearer token
+func SetupCalendarRoutes(r chi.Router, h *handler.Handler) {
+	r.Get("/calendar/{token}.ics", handler.Respond(http.StatusOK, h.CalendarHandler.Feed))
+}
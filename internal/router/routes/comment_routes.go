@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"net/http"
+
+	"myapp/internal/handler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SetupCommentRoutes sets up the routes for the comments and activity
+// timeline of a todo, below the todo's /todos/{id} route
+func SetupCommentRoutes(r chi.Router, h *handler.Handler) {
+	r.Route("/comments", func(r chi.Router) {
+		r.Post("/", handler.Handle(h.Validator, http.StatusCreated, h.CommentHandler.Create))
+		r.Get("/", handler.Respond(http.StatusOK, h.CommentHandler.List))
+		r.Route("/{commentID}", func(r chi.Router) {
+			r.Put("/", handler.Handle(h.Validator, http.StatusOK, h.CommentHandler.Update))
+			r.Delete("/", handler.Respond(http.StatusNoContent, h.CommentHandler.Delete))
+		})
+	})
+	r.Get("/activity", handler.Respond(http.StatusOK, h.CommentHandler.Activity))
+}
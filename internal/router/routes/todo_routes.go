@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"net/http"
+
 	"myapp/internal/handler"
 
 	"github.com/go-chi/chi/v5"
@@ -9,12 +11,25 @@ import (
 // SetupTodoRoutes sets up all todo-related routes
 func SetupTodoRoutes(r chi.Router, h *handler.Handler) {
 	r.Route("/todos", func(r chi.Router) {
-		r.Post("/", h.TodoHandler.Create)
-		r.Get("/", h.TodoHandler.GetByUserID)
+		r.Post("/", handler.Handle(h.Validator, http.StatusCreated, h.TodoHandler.Create))
+		r.Get("/", handler.Respond(http.StatusOK, h.TodoHandler.GetByUserID))
+		r.Post("/bulk", handler.Handle(h.Validator, http.StatusOK, h.TodoHandler.Bulk))
+		r.Get("/changes", handler.Respond(http.StatusOK, h.TodoHandler.Changes))
+		r.Post("/changes", handler.Handle(h.Validator, http.StatusOK, h.TodoHandler.Push))
+		r.Get("/export", handler.Respond(http.StatusOK, h.TodoHandler.Export))
+		r.Post("/import", handler.Respond(http.StatusOK, h.TodoHandler.Import))
+		r.Post("/import/ics", handler.Respond(http.StatusCreated, h.CalendarHandler.ImportICS))
+		r.Post("/occurrences", handler.Handle(h.Validator, http.StatusOK, h.TodoHandler.PreviewOccurrences))
+		r.Get("/stream", h.StreamHandler.SSE)
+		r.Get("/ws", h.StreamHandler.WebSocket)
 		r.Route("/{id}", func(r chi.Router) {
-			r.Get("/", h.TodoHandler.GetByID)
-			r.Put("/", h.TodoHandler.Update)
-			r.Delete("/", h.TodoHandler.Delete)
+			r.Get("/", handler.Respond(http.StatusOK, h.TodoHandler.GetByID))
+			r.Put("/", handler.Handle(h.Validator, http.StatusOK, h.TodoHandler.Update))
+			r.Patch("/", handler.Respond(http.StatusOK, h.TodoHandler.Patch))
+			r.Delete("/", handler.Respond(http.StatusNoContent, h.TodoHandler.Delete))
+			r.Get("/occurrences", handler.Respond(http.StatusOK, h.TodoHandler.Occurrences))
+			SetupAttachmentRoutes(r, h)
+			SetupCommentRoutes(r, h)
 		})
 	})
 }
@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"net/http"
+
 	"myapp/internal/handler"
 
 	"github.com/go-chi/chi/v5"
@@ -10,5 +12,10 @@ import (
 func SetupUserRoutes(r chi.Router, h *handler.Handler) {
 	r.Route("/users", func(r chi.Router) {
 		r.Get("/me", h.UserHandler.GetProfile)
+		r.Put("/me/password", handler.Handle(h.Validator, http.StatusNoContent, h.UserHandler.ChangePassword))
+		r.Get("/me/activity", handler.Respond(http.StatusOK, h.AuditHandler.Activity))
+		r.Get("/me/features", handler.Respond(http.StatusOK, h.FeatureHandler.List))
+		r.Post("/me/calendar-token", handler.Respond(http.StatusOK, h.CalendarHandler.IssueFeedToken))
+		r.Delete("/me/calendar-token", handler.Respond(http.StatusNoContent, h.CalendarHandler.RevokeFeedToken))
 	})
 }
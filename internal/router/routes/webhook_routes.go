@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"net/http"
+
+	"myapp/internal/handler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SetupWebhookRoutes sets up all webhook-related routes
+func SetupWebhookRoutes(r chi.Router, h *handler.Handler) {
+	r.Route("/webhooks", func(r chi.Router) {
+		r.Post("/", handler.Handle(h.Validator, http.StatusCreated, h.WebhookHandler.Create))
+		r.Get("/", handler.Respond(http.StatusOK, h.WebhookHandler.List))
+		r.Route("/{id}", func(r chi.Router) {
+			r.Get("/", handler.Respond(http.StatusOK, h.WebhookHandler.GetByID))
+			r.Put("/", handler.Handle(h.Validator, http.StatusOK, h.WebhookHandler.Update))
+			r.Delete("/", handler.Respond(http.StatusNoContent, h.WebhookHandler.Delete))
+			r.Get("/deliveries", handler.Respond(http.StatusOK, h.WebhookHandler.Deliveries))
+		})
+	})
+}
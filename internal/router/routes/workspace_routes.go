@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"net/http"
+
+	"myapp/internal/handler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SetupWorkspaceRoutes sets up workspace-related routes
+func SetupWorkspaceRoutes(r chi.Router, h *handler.Handler) {
+	r.Route("/workspaces", func(r chi.Router) {
+		r.Post("/", handler.Handle(h.Validator, http.StatusCreated, h.WorkspaceHandler.Create))
+		r.Get("/", handler.Respond(http.StatusOK, h.WorkspaceHandler.List))
+		r.Route("/{id}", func(r chi.Router) {
+			r.Post("/token", handler.Respond(http.StatusOK, h.WorkspaceHandler.IssueToken))
+			r.Get("/members", handler.Respond(http.StatusOK, h.WorkspaceHandler.ListMembers))
+			r.Post("/members", handler.Handle(h.Validator, http.StatusCreated, h.WorkspaceHandler.AddMember))
+			r.Delete("/members/{userID}", handler.Respond(http.StatusNoContent, h.WorkspaceHandler.RemoveMember))
+		})
+	})
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"myapp/internal/model"
+	"myapp/internal/repository"
+	"myapp/internal/storage"
+)
+
+const (
+	// sniffLen is the number of leading bytes used to detect a file's type
+	sniffLen = 512
+	// maxFilenameLen caps the length of stored file names
+	maxFilenameLen = 255
+)
+
+// AttachmentConfig configures the AttachmentService
+type AttachmentConfig struct {
+	// MaxSize is the largest accepted file, in bytes
+	MaxSize int64
+	// AllowedTypes lists the accepted media types, such as image/png. The
+	// type is detected from the file's content, not taken from the client.
+	AllowedTypes []string
+	// URLSecret keys the signatures of download links
+	URLSecret []byte
+	// URLTTL is how long a download link stays valid
+	URLTTL time.Duration
+}
+
+// AttachmentService defines the interface for todo attachment operations
+type AttachmentService interface {
+	Upload(ctx context.Context, userID uint, todoID uint, filename string, body io.Reader) (*model.Attachment, error)
+	List(ctx context.Context, userID uint, todoID uint) ([]*model.Attachment, error)
+	Open(ctx context.Context, userID uint, todoID uint, id uint) (*model.Attachment, *storage.Object, error)
+	Delete(ctx context.Context, userID uint, todoID uint, id uint) error
+	SignedURL(ctx context.Context, userID uint, todoID uint, id uint) (*model.AttachmentURL, error)
+	OpenSigned(ctx context.Context, id uint, expires int64, signature string) (*model.Attachment, *storage.Object, error)
+}
+
+type attachmentService struct {
+	attachmentRepo repository.AttachmentRepository
+	todoRepo       repository.TodoRepository
+	store          storage.BlobStore
+	config         AttachmentConfig
+}
+
+// NewAttachmentService creates a new AttachmentService instance storing
+// file contents in store
+func NewAttachmentService(attachmentRepo repository.AttachmentRepository, todoRepo repository.TodoRepository, store storage.BlobStore, config AttachmentConfig) AttachmentService {
+	return &attachmentService{
+		attachmentRepo: attachmentRepo,
+		todoRepo:       todoRepo,
+		store:          store,
+		config:         config,
+	}
+}
+
+// Upload streams body into blob storage and records it as an attachment of
+// the todo. The content type is sniffed from the first bytes and the size
+// and SHA-256 are computed while streaming, so the file is never buffered
+// whole.
+func (s *attachmentService) Upload(ctx context.Context, userID uint, todoID uint, filename string, body io.Reader) (*model.Attachment, error) {
+	if err := s.checkTodo(ctx, userID, todoID); err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(body, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if !s.allowed(contentType) {
+		return nil, model.ErrUnsupportedMediaType
+	}
+
+	hash := sha256.New()
+	content := &sizeLimitedReader{
+		r:     io.TeeReader(io.MultiReader(bytes.NewReader(head), body), hash),
+		limit: s.config.MaxSize,
+	}
+
+	key := fmt.Sprintf("todos/%d/%s", todoID, uuid.NewString())
+	if err := s.store.Put(ctx, key, content, contentType); err != nil {
+		if content.exceeded {
+			return nil, model.ErrAttachmentTooLarge
+		}
+		return nil, err
+	}
+
+	attachment := &model.Attachment{
+		TodoID:      todoID,
+		UserID:      userID,
+		Filename:    cleanFilename(filename),
+		ContentType: contentType,
+		Size:        content.read,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		StorageKey:  key,
+	}
+	if err := s.attachmentRepo.Create(ctx, attachment); err != nil {
+		s.deleteBlob(key)
+		return nil, err
+	}
+	return attachment, nil
+}
+
+func (s *attachmentService) List(ctx context.Context, userID uint, todoID uint) ([]*model.Attachment, error) {
+	if err := s.checkTodo(ctx, userID, todoID); err != nil {
+		return nil, err
+	}
+	return s.attachmentRepo.GetByTodoID(ctx, todoID)
+}
+
+// Open returns the attachment together with its opened contents, which the
+// caller must close
+func (s *attachmentService) Open(ctx context.Context, userID uint, todoID uint, id uint) (*model.Attachment, *storage.Object, error) {
+	attachment, err := s.getOwned(ctx, userID, todoID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.open(ctx, attachment)
+}
+
+// Delete removes the attachment's metadata and then its contents. A blob
+// that fails to delete is only logged; it is unreachable once the metadata
+// is gone.
+func (s *attachmentService) Delete(ctx context.Context, userID uint, todoID uint, id uint) error {
+	attachment, err := s.getOwned(ctx, userID, todoID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.attachmentRepo.Delete(ctx, attachment.ID); err != nil {
+		return err
+	}
+	s.deleteBlob(attachment.StorageKey)
+	return nil
+}
+
+// SignedURL returns a link that downloads the attachment without
+// authentication until it expires
+func (s *attachmentService) SignedURL(ctx context.Context, userID uint, todoID uint, id uint) (*model.AttachmentURL, error) {
+	attachment, err := s.getOwned(ctx, userID, todoID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.config.URLTTL).Truncate(time.Second)
+	expires := expiresAt.Unix()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {s.sign(attachment.ID, expires)},
+	}
+	return &model.AttachmentURL{
+		URL:       fmt.Sprintf("/files/%d?%s", attachment.ID, query.Encode()),
+		ExpiresAt: expiresAt.UTC(),
+	}, nil
+}
+
+// OpenSigned opens an attachment through a link created by SignedURL,
+// returning model.ErrInvalidSignature if the link was altered or expired
+func (s *attachmentService) OpenSigned(ctx context.Context, id uint, expires int64, signature string) (*model.Attachment, *storage.Object, error) {
+	if !hmac.Equal([]byte(signature), []byte(s.sign(id, expires))) || time.Now().Unix() > expires {
+		return nil, nil, model.ErrInvalidSignature
+	}
+
+	attachment, err := s.attachmentRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, model.ErrAttachmentNotFound
+		}
+		return nil, nil, err
+	}
+	return s.open(ctx, attachment)
+}
+
+// open opens the contents of an attachment
+func (s *attachmentService) open(ctx context.Context, attachment *model.Attachment) (*model.Attachment, *storage.Object, error) {
+	object, err := s.store.Open(ctx, attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil, model.ErrAttachmentNotFound
+		}
+		return nil, nil, err
+	}
+	return attachment, object, nil
+}
+
+// checkTodo verifies that the todo exists and is owned by the user
+func (s *attachmentService) checkTodo(ctx context.Context, userID uint, todoID uint) error {
+	_, err := getOwnedTodo(ctx, s.todoRepo, userID, todoID)
+	return err
+}
+
+// getOwned loads an attachment of a todo owned by the user and maps a
+// repository miss to model.ErrAttachmentNotFound
+func (s *attachmentService) getOwned(ctx context.Context, userID uint, todoID uint, id uint) (*model.Attachment, error) {
+	if err := s.checkTodo(ctx, userID, todoID); err != nil {
+		return nil, err
+	}
+	attachment, err := s.attachmentRepo.GetByIDForTodo(ctx, id, todoID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, model.ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// allowed reports whether a detected content type is in the allow list,
+// ignoring parameters such as charset
+func (s *attachmentService) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range s.config.AllowedTypes {
+		if strings.EqualFold(t, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// sign returns the URL-safe HMAC-SHA256 of an attachment ID and expiry
+func (s *attachmentService) sign(id uint, expires int64) string {
+	mac := hmac.New(sha256.New, s.config.URLSecret)
+	fmt.Fprintf(mac, "%d:%d", id, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// deleteBlob removes a blob that is no longer referenced. It is not tied to
+// the request context so that a cancelled request still cleans up.
+func (s *attachmentService) deleteBlob(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.store.Delete(ctx, key); err != nil {
+		slog.Warn("attachments: failed to delete blob", "key", key, "error", err)
+	}
+}
+
+// cleanFilename reduces a client-supplied file name to its base name
+// without control characters
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "." || name == "/" || name == "" {
+		name = "file"
+	}
+	if len(name) > maxFilenameLen {
+		name = strings.ToValidUTF8(name[:maxFilenameLen], "")
+	}
+	return name
+}
+
+// sizeLimitedReader counts the bytes read and fails once more than limit
+// bytes have been read
+type sizeLimitedReader struct {
+	r        io.Reader
+	limit    int64
+	read     int64
+	exceeded bool
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		l.exceeded = true
+		return 0, model.ErrAttachmentTooLarge
+	}
+	return n, err
+}
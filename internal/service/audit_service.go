@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"myapp/internal/audit"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+)
+
+const (
+	// defaultAuditLimit is the audit page size when none is given
+	defaultAuditLimit = 50
+	// maxAuditLimit is the largest audit page size
+	maxAuditLimit = 200
+	// auditVerifyBatch is the number of entries loaded at a time when
+	// verifying the hash chain
+	auditVerifyBatch = 1000
+)
+
+// AuditService defines the interface for reading the audit log
+type AuditService interface {
+	List(ctx context.Context, filter model.AuditFilter) (*model.AuditPage, error)
+	Activity(ctx context.Context, userID uint, beforeID uint64, limit int) (*model.AuditPage, error)
+	Verify(ctx context.Context) (*model.AuditVerification, error)
+}
+
+type auditService struct {
+	auditRepo repository.AuditRepository
+}
+
+// NewAuditService creates a new AuditService instance
+func NewAuditService(auditRepo repository.AuditRepository) AuditService {
+	return &auditService{auditRepo: auditRepo}
+}
+
+// List returns a page of audit entries matching filter, newest first
+func (s *auditService) List(ctx context.Context, filter model.AuditFilter) (*model.AuditPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	if limit > maxAuditLimit {
+		limit = maxAuditLimit
+	}
+
+	// Fetch one extra entry to learn whether another page exists
+	filter.Limit = limit + 1
+	entries, err := s.auditRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.AuditPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.NextBefore = page.Entries[limit-1].ID
+	}
+	return page, nil
+}
+
+// Activity returns a page of the actions the user performed, newest first
+func (s *auditService) Activity(ctx context.Context, userID uint, beforeID uint64, limit int) (*model.AuditPage, error) {
+	return s.List(ctx, model.AuditFilter{ActorID: &userID, BeforeID: beforeID, Limit: limit})
+}
+
+// Verify walks the whole hash chain and checks that every link follows its
+// predecessor and that every entry's hash matches its content. Entries not
+// yet linked by the sequencer are not covered.
+func (s *auditService) Verify(ctx context.Context) (*model.AuditVerification, error) {
+	result := &model.AuditVerification{Valid: true}
+
+	var afterSeq uint64
+	prevHash := ""
+	for {
+		links, err := s.auditRepo.GetChain(ctx, afterSeq, auditVerifyBatch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, link := range links {
+			result.Checked++
+			entry := link.Entry
+			switch {
+			case entry == nil:
+				result.Valid, result.BrokenAt, result.Reason = false, link.EntryID, "entry is missing"
+			case link.PrevHash != prevHash:
+				result.Valid, result.BrokenAt, result.Reason = false, entry.ID, "previous hash does not match the preceding entry"
+			case entry.ComputeHash() != entry.Hash:
+				result.Valid, result.BrokenAt, result.Reason = false, entry.ID, "hash does not match the entry's content"
+			case model.ChainHash(link.PrevHash, entry.Hash) != link.Hash:
+				result.Valid, result.BrokenAt, result.Reason = false, entry.ID, "chain hash does not match the entry"
+			}
+			if !result.Valid {
+				return result, nil
+			}
+			prevHash, afterSeq = link.Hash, link.Seq
+		}
+
+		if len(links) < auditVerifyBatch {
+			return result, nil
+		}
+	}
+}
+
+// recordAudit appends an audit entry within tx, filling in the request the
+// action was made in
+func recordAudit(ctx context.Context, tx *repository.Repositories, entry *model.AuditEntry) error {
+	req := audit.RequestFromContext(ctx)
+	entry.IP = req.IP
+	entry.UserAgent = req.UserAgent
+	entry.RequestID = req.RequestID
+	return tx.Audit.Append(ctx, entry)
+}
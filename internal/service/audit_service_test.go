@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"myapp/internal/model"
+	"myapp/internal/repository"
+)
+
+// chainRepository serves GetChain from links in memory
+type chainRepository struct {
+	repository.AuditRepository
+	links []*model.AuditLink
+}
+
+func (r *chainRepository) GetChain(ctx context.Context, afterSeq uint64, limit int) ([]*model.AuditLink, error) {
+	var links []*model.AuditLink
+	for _, link := range r.links {
+		if link.Seq > afterSeq && len(links) < limit {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+// chain links entries the way the sequencer does
+func chain(entries ...*model.AuditEntry) []*model.AuditLink {
+	links := make([]*model.AuditLink, len(entries))
+	prevHash := ""
+	for i, entry := range entries {
+		entry.Hash = entry.ComputeHash()
+		links[i] = &model.AuditLink{
+			Seq:      uint64(i + 1),
+			EntryID:  entry.ID,
+			Entry:    entry,
+			PrevHash: prevHash,
+			Hash:     model.ChainHash(prevHash, entry.Hash),
+		}
+		prevHash = links[i].Hash
+	}
+	return links
+}
+
+func TestAuditVerify(t *testing.T) {
+	entries := func() []*model.AuditEntry {
+		// Linked out of ID order, as entries commit in any order
+		return []*model.AuditEntry{
+			{ID: 2, Action: model.AuditLoginSucceeded},
+			{ID: 1, Action: model.AuditLoginFailed},
+			{ID: 3, Action: model.AuditPasswordChanged},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		tamper   func(links []*model.AuditLink) []*model.AuditLink
+		brokenAt uint64
+	}{
+		{"intact", func(links []*model.AuditLink) []*model.AuditLink { return links }, 0},
+		{"altered entry", func(links []*model.AuditLink) []*model.AuditLink {
+			links[1].Entry.Action = model.AuditLoginSucceeded
+			return links
+		}, 1},
+		{"altered entry with recomputed hash", func(links []*model.AuditLink) []*model.AuditLink {
+			links[1].Entry.Action = model.AuditLoginSucceeded
+			links[1].Entry.Hash = links[1].Entry.ComputeHash()
+			return links
+		}, 1},
+		{"removed link", func(links []*model.AuditLink) []*model.AuditLink {
+			return append(links[:1], links[2:]...)
+		}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &chainRepository{links: tt.tamper(chain(entries()...))}
+			result, err := NewAuditService(repo).Verify(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Valid != (tt.brokenAt == 0) || result.BrokenAt != tt.brokenAt {
+				t.Fatalf("Verify = %+v, want broken at %d", result, tt.brokenAt)
+			}
+		})
+	}
+}
@@ -3,9 +3,15 @@ package service
 import (
 	"context"
 	"errors"
+	"myapp/internal/audit"
+	"myapp/internal/events"
 	"myapp/internal/model"
 	"myapp/internal/pkg/jwt"
 	"myapp/internal/repository"
+	"myapp/internal/tenant"
+	"strconv"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -19,61 +25,97 @@ var (
 
 // AuthResponse contains the response after authentication
 type AuthResponse struct {
-	User         model.UserResponse `json:"user"`
-	AccessToken  string             `json:"access_token"`
-	RefreshToken string             `json:"refresh_token"`
+	User model.UserResponse `json:"user"`
+	// WorkspaceID is the workspace the tokens act in
+	WorkspaceID  uint   `json:"workspace_id"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 // RefreshResponse contains the response after refreshing tokens
 type RefreshResponse struct {
+	// WorkspaceID is the workspace the tokens act in
+	WorkspaceID  uint   `json:"workspace_id"`
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 }
 
-// AuthService defines the interface for authentication operations
+// AuthService defines the interface for authentication operations. Tokens
+// are issued for one workspace at a time.
 type AuthService interface {
-	Login(ctx context.Context, email, password string) (*AuthResponse, error)
+	Login(ctx context.Context, email, password string, workspaceID uint) (*AuthResponse, error)
 	Register(ctx context.Context, req *model.RegisterRequest) (*AuthResponse, error)
-	GetUserByToken(ctx context.Context, token string) (*model.User, error)
+	GetUserByToken(ctx context.Context, token string) (*model.User, uint, error)
 	RefreshTokens(ctx context.Context, refreshToken string) (*RefreshResponse, error)
+	SwitchWorkspace(ctx context.Context, userID uint, workspaceID uint) (*RefreshResponse, error)
+	ChangePassword(ctx context.Context, userID uint, currentPassword, newPassword string) error
+}
+
+// AuthConfig configures the AuthService
+type AuthConfig struct {
+	// BcryptCost is the bcrypt cost of new password hashes
+	BcryptCost int
+	// RegistrationEnabled reports whether new users may register. It is
+	// consulted on every registration, so the answer may change at runtime;
+	// nil means registration is always open.
+	RegistrationEnabled func() bool
 }
 
 // authService implements AuthService interface
 type authService struct {
-	userRepo repository.UserRepository
-	jwt      *jwt.Service
+	userRepo      repository.UserRepository
+	workspaceRepo repository.WorkspaceRepository
+	jwt           *jwt.Service
+	uow           repository.UnitOfWork
+	config        AuthConfig
 }
 
-// NewAuthService creates a new AuthService instance
-func NewAuthService(userRepo repository.UserRepository, jwtService *jwt.Service) AuthService {
+// NewAuthService creates a new AuthService instance. Users are registered
+// through uow, together with a personal workspace, and recorded in the
+// outbox as user.registered events. A zero bcrypt cost uses
+// bcrypt.DefaultCost.
+func NewAuthService(userRepo repository.UserRepository, workspaceRepo repository.WorkspaceRepository, jwtService *jwt.Service, uow repository.UnitOfWork, config AuthConfig) AuthService {
+	if config.BcryptCost == 0 {
+		config.BcryptCost = bcrypt.DefaultCost
+	}
 	return &authService{
-		userRepo: userRepo,
-		jwt:      jwtService,
+		userRepo:      userRepo,
+		workspaceRepo: workspaceRepo,
+		jwt:           jwtService,
+		uow:           uow,
+		config:        config,
 	}
 }
 
-// Register registers a new user
+// Register registers a new user and creates their personal workspace
 func (s *authService) Register(ctx context.Context, req *model.RegisterRequest) (*AuthResponse, error) {
-	// Check if email already exists
-	existingUser, err := s.userRepo.GetByEmail(ctx, req.Email)
-	if err != nil {
-		return nil, err
+	if s.config.RegistrationEnabled != nil && !s.config.RegistrationEnabled() {
+		return nil, model.ErrRegistrationDisabled
 	}
-	if existingUser != nil {
+
+	// Emails and usernames are unique across workspaces
+	lookup := tenant.CrossTenant(ctx)
+
+	// Check if email already exists
+	_, err := s.userRepo.GetByEmail(lookup, req.Email)
+	if err == nil {
 		return nil, model.ErrEmailAlreadyExists
 	}
-
-	// Check if username already exists
-	existingUser, err = s.userRepo.GetByUsername(ctx, req.Username)
-	if err != nil {
+	if !errors.Is(err, repository.ErrNotFound) {
 		return nil, err
 	}
-	if existingUser != nil {
+
+	// Check if username already exists
+	_, err = s.userRepo.GetByUsername(lookup, req.Username)
+	if err == nil {
 		return nil, model.ErrUsernameAlreadyExists
 	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
 
 	// Create new user
-	hashedPassword, err := HashPassword(req.Password)
+	hashedPassword, err := HashPassword(req.Password, s.config.BcryptCost)
 	if err != nil {
 		return nil, err
 	}
@@ -84,116 +126,265 @@ func (s *authService) Register(ctx context.Context, req *model.RegisterRequest)
 		Password:  hashedPassword,
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
+		Role:      model.RoleUser,
+		Timezone:  req.Timezone,
 	}
-
-	if err := s.userRepo.Create(ctx, user); err != nil {
-		return nil, err
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
 	}
 
-	// Generate tokens
-	accessToken, err := s.jwt.GenerateAccessToken(user.ID)
-	if err != nil {
-		return nil, err
-	}
+	var workspace *model.WorkspaceMembership
+	err = s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		if err := tx.User.Create(ctx, user); err != nil {
+			if errors.Is(err, repository.ErrDuplicate) {
+				// Taken by a concurrent registration or a deleted user
+				return model.ErrUserAlreadyExists
+			}
+			return err
+		}
 
-	refreshToken, err := s.jwt.GenerateRefreshToken(user.ID)
+		changes, err := audit.Diff(nil, user.ToResponse())
+		if err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, &model.AuditEntry{
+			ActorID:      &user.ID,
+			Action:       model.AuditUserRegistered,
+			ResourceType: model.AuditResourceUser,
+			ResourceID:   strconv.FormatUint(uint64(user.ID), 10),
+			Changes:      changes,
+		}); err != nil {
+			return err
+		}
+
+		if err := emit(ctx, tx, events.UserRegistered, user.ID, user.ToResponse()); err != nil {
+			return err
+		}
+
+		workspace, err = createWorkspace(ctx, tx, user.ID, user.Username+"'s workspace")
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &AuthResponse{
-		User:         user.ToResponse(),
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
+	return s.authResponse(user, workspace.ID)
 }
 
-// Login authenticates a user
-func (s *authService) Login(ctx context.Context, email, password string) (*AuthResponse, error) {
-	user, err := s.userRepo.GetByEmail(ctx, email)
+// Login authenticates a user for a workspace they are a member of, by
+// default the first one they joined
+func (s *authService) Login(ctx context.Context, email, password string, workspaceID uint) (*AuthResponse, error) {
+	user, err := s.userRepo.GetByEmail(tenant.CrossTenant(ctx), email)
 	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			if err := s.auditAuth(ctx, nil, model.AuditLoginFailed, map[string]string{"email": email, "reason": "user_not_found"}); err != nil {
+				return nil, err
+			}
+			return nil, model.ErrUserNotFound
+		}
 		return nil, err
 	}
-	if user == nil {
-		return nil, model.ErrUserNotFound
-	}
 
 	if !CheckPasswordHash(password, user.Password) {
+		if err := s.auditAuth(ctx, &user.ID, model.AuditLoginFailed, map[string]string{"email": email, "reason": "invalid_password"}); err != nil {
+			return nil, err
+		}
 		return nil, ErrInvalidCredentials
 	}
 
-	// Generate tokens
-	accessToken, err := s.jwt.GenerateAccessToken(user.ID)
+	workspaceID, err = s.loginWorkspace(ctx, user.ID, workspaceID)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := s.jwt.GenerateRefreshToken(user.ID)
-	if err != nil {
+	if err := s.auditAuth(ctx, &user.ID, model.AuditLoginSucceeded, nil); err != nil {
 		return nil, err
 	}
 
-	return &AuthResponse{
-		User:         user.ToResponse(),
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
+	return s.authResponse(user, workspaceID)
 }
 
-// GetUserByToken retrieves a user from a token
-func (s *authService) GetUserByToken(ctx context.Context, token string) (*model.User, error) {
-	userID, tokenType, err := s.jwt.ValidateToken(token)
+// GetUserByToken retrieves the user of an access token and the workspace
+// the token was issued for. Users removed from that workspace are no
+// longer found.
+func (s *authService) GetUserByToken(ctx context.Context, token string) (*model.User, uint, error) {
+	claims, err := s.jwt.ValidateToken(token)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Only access tokens should be used for authentication
-	if tokenType != jwt.AccessToken {
-		return nil, ErrUnauthorized
+	if claims.Type != jwt.AccessToken {
+		return nil, 0, ErrUnauthorized
 	}
 
-	user, err := s.userRepo.GetByID(ctx, userID)
+	user, err := s.userRepo.GetByID(tenant.WithWorkspace(ctx, claims.WorkspaceID), claims.UserID)
 	if err != nil {
-		return nil, err
-	}
-	if user == nil {
-		return nil, errors.New("user not found")
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, 0, model.ErrUserNotFound
+		}
+		return nil, 0, err
 	}
 
-	return user, nil
+	return user, claims.WorkspaceID, nil
 }
 
-// RefreshTokens refreshes the access and refresh tokens
+// RefreshTokens refreshes the access and refresh tokens for the workspace
+// the refresh token was issued for
 func (s *authService) RefreshTokens(ctx context.Context, refreshToken string) (*RefreshResponse, error) {
-	userID, tokenType, err := s.jwt.ValidateToken(refreshToken)
+	claims, err := s.jwt.ValidateToken(refreshToken)
 	if err != nil {
 		return nil, err
 	}
 
 	// Verify it's a refresh token
-	if tokenType != jwt.RefreshToken {
+	if claims.Type != jwt.RefreshToken {
 		return nil, ErrUnauthorized
 	}
 
-	// Verify user exists
-	user, err := s.userRepo.GetByID(ctx, userID)
-	if err != nil || user == nil {
+	// Verify user exists and is still a member of the workspace
+	user, err := s.userRepo.GetByID(tenant.WithWorkspace(ctx, claims.WorkspaceID), claims.UserID)
+	if err != nil {
 		return nil, ErrUnauthorized
 	}
 
-	// Generate new tokens
-	newAccessToken, err := s.jwt.GenerateAccessToken(user.ID)
+	if err := s.auditAuth(ctx, &user.ID, model.AuditTokenRefreshed, nil); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user.ID, claims.WorkspaceID)
+}
+
+// SwitchWorkspace issues tokens for another workspace the user is a member of
+func (s *authService) SwitchWorkspace(ctx context.Context, userID uint, workspaceID uint) (*RefreshResponse, error) {
+	if _, err := s.workspaceRepo.GetMembership(ctx, workspaceID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, model.ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+
+	return s.issueTokens(userID, workspaceID)
+}
+
+// ChangePassword replaces the user's password after checking the current
+// one. Successful and failed attempts are both audited.
+func (s *authService) ChangePassword(ctx context.Context, userID uint, currentPassword, newPassword string) error {
+	hashedPassword, err := HashPassword(newPassword, s.config.BcryptCost)
+	if err != nil {
+		return err
+	}
+
+	// The user is read in the transaction, as users read through the user
+	// cache carry no password
+	err = s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		user, err := tx.User.GetByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return model.ErrUserNotFound
+			}
+			return err
+		}
+		if !CheckPasswordHash(currentPassword, user.Password) {
+			return model.ErrInvalidPassword
+		}
+
+		user.Password = hashedPassword
+		if err := tx.User.Update(ctx, user); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, &model.AuditEntry{
+			ActorID:      &userID,
+			Action:       model.AuditPasswordChanged,
+			ResourceType: model.AuditResourceUser,
+			ResourceID:   strconv.FormatUint(uint64(userID), 10),
+		})
+	})
+	if errors.Is(err, model.ErrInvalidPassword) {
+		if err := s.auditAuth(ctx, &userID, model.AuditPasswordFailed, map[string]string{"reason": "invalid_password"}); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// loginWorkspace returns the workspace a login is for: the requested one if
+// the user is a member of it, or else the first one the user joined
+func (s *authService) loginWorkspace(ctx context.Context, userID uint, workspaceID uint) (uint, error) {
+	if workspaceID != 0 {
+		if _, err := s.workspaceRepo.GetMembership(ctx, workspaceID, userID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return 0, model.ErrWorkspaceNotFound
+			}
+			return 0, err
+		}
+		return workspaceID, nil
+	}
+
+	workspaces, err := s.workspaceRepo.ListForUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if len(workspaces) == 0 {
+		return 0, model.ErrWorkspaceNotFound
+	}
+	return workspaces[0].ID, nil
+}
+
+// authResponse issues tokens for the user acting in a workspace
+func (s *authService) authResponse(user *model.User, workspaceID uint) (*AuthResponse, error) {
+	tokens, err := s.issueTokens(user.ID, workspaceID)
 	if err != nil {
 		return nil, err
 	}
 
-	newRefreshToken, err := s.jwt.GenerateRefreshToken(user.ID)
+	return &AuthResponse{
+		User:         user.ToResponse(),
+		WorkspaceID:  workspaceID,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+// issueTokens generates an access and a refresh token for the user acting
+// in a workspace
+func (s *authService) issueTokens(userID uint, workspaceID uint) (*RefreshResponse, error) {
+	accessToken, err := s.jwt.GenerateAccessToken(userID, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := s.jwt.GenerateRefreshToken(userID, workspaceID)
 	if err != nil {
 		return nil, err
 	}
 
 	return &RefreshResponse{
-		AccessToken:  newAccessToken,
-		RefreshToken: newRefreshToken,
+		WorkspaceID:  workspaceID,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
 	}, nil
 }
+
+// auditAuth records an authentication event for actorID, which is nil when
+// the user could not be identified
+func (s *authService) auditAuth(ctx context.Context, actorID *uint, action string, metadata map[string]string) error {
+	entry := &model.AuditEntry{
+		ActorID:      actorID,
+		Action:       action,
+		ResourceType: model.AuditResourceUser,
+	}
+	if actorID != nil {
+		entry.ResourceID = strconv.FormatUint(uint64(*actorID), 10)
+	}
+	if metadata != nil {
+		var err error
+		if entry.Metadata, err = model.NewJSONText(metadata); err != nil {
+			return err
+		}
+	}
+
+	return s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		return recordAudit(ctx, tx, entry)
+	})
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"myapp/internal/model"
+	"myapp/internal/repository"
+	"myapp/internal/tenant"
+)
+
+// CalendarService defines the interface for calendar feed operations
+type CalendarService interface {
+	IssueFeedToken(ctx context.Context, userID uint) (*model.CalendarFeed, error)
+	RevokeFeedToken(ctx context.Context, userID uint) error
+	Feed(ctx context.Context, token string) ([]*model.Todo, error)
+}
+
+type calendarService struct {
+	userRepo repository.UserRepository
+	todoRepo repository.TodoRepository
+	uow      repository.UnitOfWork
+}
+
+// NewCalendarService creates a new CalendarService instance. Each user has
+// at most one feed link, showing the todos of the workspace it was issued
+// in; only the hash of its token is stored, so a link cannot be shown again
+// after it is issued, only replaced.
+func NewCalendarService(userRepo repository.UserRepository, todoRepo repository.TodoRepository, uow repository.UnitOfWork) CalendarService {
+	return &calendarService{
+		userRepo: userRepo,
+		todoRepo: todoRepo,
+		uow:      uow,
+	}
+}
+
+// IssueFeedToken creates a new feed link for the user, invalidating the
+// previous one
+func (s *calendarService) IssueFeedToken(ctx context.Context, userID uint) (*model.CalendarFeed, error) {
+	token, err := generateCalendarToken()
+	if err != nil {
+		return nil, err
+	}
+	hash := hashCalendarToken(token)
+
+	if err := s.setTokenHash(ctx, userID, &hash, model.AuditCalendarIssued); err != nil {
+		return nil, err
+	}
+	return &model.CalendarFeed{URL: fmt.Sprintf("/calendar/%s.ics", token)}, nil
+}
+
+// RevokeFeedToken disables the user's feed link
+func (s *calendarService) RevokeFeedToken(ctx context.Context, userID uint) error {
+	return s.setTokenHash(ctx, userID, nil, model.AuditCalendarRevoked)
+}
+
+// Feed returns the todos of the user a feed token was issued to in the
+// workspace it was issued in, returning model.ErrCalendarNotFound if the
+// token is unknown or was replaced, or the user has left the workspace
+func (s *calendarService) Feed(ctx context.Context, token string) ([]*model.Todo, error) {
+	// The token identifies both the user and the workspace
+	user, err := s.userRepo.GetByCalendarTokenHash(tenant.CrossTenant(ctx), hashCalendarToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, model.ErrCalendarNotFound
+		}
+		return nil, err
+	}
+	if user.CalendarWorkspaceID == nil {
+		return nil, model.ErrCalendarNotFound
+	}
+
+	ctx = tenant.WithWorkspace(ctx, *user.CalendarWorkspaceID)
+	if _, err := s.userRepo.GetByID(ctx, user.ID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, model.ErrCalendarNotFound
+		}
+		return nil, err
+	}
+	return s.todoRepo.GetByUserID(ctx, user.ID)
+}
+
+// setTokenHash stores the hash of the user's feed token, issued in the
+// workspace of ctx, and records action in the audit log
+func (s *calendarService) setTokenHash(ctx context.Context, userID uint, hash *string, action string) error {
+	var workspaceID *uint
+	if hash != nil {
+		id, ok := tenant.WorkspaceID(ctx)
+		if !ok {
+			return tenant.ErrNoWorkspace
+		}
+		workspaceID = &id
+	}
+
+	return s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		user, err := tx.User.GetByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return model.ErrUserNotFound
+			}
+			return err
+		}
+
+		user.CalendarTokenHash = hash
+		user.CalendarWorkspaceID = workspaceID
+		if err := tx.User.Update(ctx, user); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, &model.AuditEntry{
+			ActorID:      &userID,
+			Action:       action,
+			ResourceType: model.AuditResourceUser,
+			ResourceID:   strconv.FormatUint(uint64(userID), 10),
+		})
+	})
+}
+
+// generateCalendarToken returns a random, URL-safe feed token
+func generateCalendarToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashCalendarToken returns the hex SHA-256 of a feed token. Tokens carry
+// 256 bits of entropy, so an unsalted hash is enough to protect them at rest.
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
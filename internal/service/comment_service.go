@@ -0,0 +1,359 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"myapp/internal/events"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+)
+
+const (
+	// maxMentions caps the number of distinct usernames resolved per comment
+	maxMentions = 20
+	// defaultActivityLimit is the timeline page size when none is given
+	defaultActivityLimit = 50
+	// maxActivityLimit is the largest timeline page size
+	maxActivityLimit = 200
+)
+
+// mentionPattern matches @username where the @ does not follow a word
+// character, so that email addresses are not taken for mentions
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w+)`)
+
+// CommentService defines the interface for comments on todos and the todo
+// activity timeline
+type CommentService interface {
+	Create(ctx context.Context, userID uint, todoID uint, req *model.CommentRequest) (*model.Comment, error)
+	List(ctx context.Context, userID uint, todoID uint) ([]*model.Comment, error)
+	Update(ctx context.Context, userID uint, todoID uint, id uint, req *model.CommentRequest) (*model.Comment, error)
+	Delete(ctx context.Context, userID uint, todoID uint, id uint) error
+	Activity(ctx context.Context, userID uint, todoID uint, before string, limit int) (*model.ActivityPage, error)
+}
+
+type commentService struct {
+	commentRepo repository.CommentRepository
+	todoRepo    repository.TodoRepository
+	userRepo    repository.UserRepository
+	auditRepo   repository.AuditRepository
+	uow         repository.UnitOfWork
+}
+
+// NewCommentService creates a new CommentService instance. Comments are
+// visible to whoever can see the todo, and changes are recorded in the
+// outbox as comment.created, comment.updated and comment.deleted events.
+func NewCommentService(commentRepo repository.CommentRepository, todoRepo repository.TodoRepository, userRepo repository.UserRepository, auditRepo repository.AuditRepository, uow repository.UnitOfWork) CommentService {
+	return &commentService{
+		commentRepo: commentRepo,
+		todoRepo:    todoRepo,
+		userRepo:    userRepo,
+		auditRepo:   auditRepo,
+		uow:         uow,
+	}
+}
+
+// CommentDeletedEvent is the payload of comment.deleted events
+type CommentDeletedEvent struct {
+	ID     uint `json:"id"`
+	TodoID uint `json:"todo_id"`
+}
+
+// Create adds a comment to a todo, resolving the users it mentions
+func (s *commentService) Create(ctx context.Context, userID uint, todoID uint, req *model.CommentRequest) (*model.Comment, error) {
+	todo, err := getOwnedTodo(ctx, s.todoRepo, userID, todoID)
+	if err != nil {
+		return nil, err
+	}
+
+	mentions, err := s.resolveMentions(ctx, req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &model.Comment{
+		TodoID:   todoID,
+		UserID:   userID,
+		Body:     req.Body,
+		Mentions: mentions,
+	}
+	err = s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Comment.Create(ctx, comment); err != nil {
+			return err
+		}
+		return emit(ctx, tx, events.CommentCreated, todo.UserID, comment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// List returns the comments of a todo, oldest first
+func (s *commentService) List(ctx context.Context, userID uint, todoID uint) ([]*model.Comment, error) {
+	if _, err := getOwnedTodo(ctx, s.todoRepo, userID, todoID); err != nil {
+		return nil, err
+	}
+	return s.commentRepo.GetByTodoID(ctx, todoID)
+}
+
+// Update replaces the body of a comment written by the user and resolves
+// its mentions again
+func (s *commentService) Update(ctx context.Context, userID uint, todoID uint, id uint, req *model.CommentRequest) (*model.Comment, error) {
+	todo, comment, err := s.getAuthored(ctx, userID, todoID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	mentions, err := s.resolveMentions(ctx, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	comment.Body = req.Body
+	comment.Mentions = mentions
+
+	err = s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Comment.Update(ctx, comment); err != nil {
+			return err
+		}
+		return emit(ctx, tx, events.CommentUpdated, todo.UserID, comment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// Delete removes a comment written by the user
+func (s *commentService) Delete(ctx context.Context, userID uint, todoID uint, id uint) error {
+	todo, comment, err := s.getAuthored(ctx, userID, todoID, id)
+	if err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Comment.Delete(ctx, comment.ID); err != nil {
+			return err
+		}
+		return emit(ctx, tx, events.CommentDeleted, todo.UserID, CommentDeletedEvent{ID: comment.ID, TodoID: todoID})
+	})
+}
+
+// Activity returns a page of the todo's timeline, newest first. Comments
+// are merged with the changes to the todo recorded in the audit log; both
+// sources are read one item past the page so the merge knows whether more
+// items follow. before is the NextBefore cursor of the previous page, or
+// empty for the first page.
+func (s *commentService) Activity(ctx context.Context, userID uint, todoID uint, before string, limit int) (*model.ActivityPage, error) {
+	cursor, err := decodeActivityCursor(before)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := getOwnedTodo(ctx, s.todoRepo, userID, todoID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultActivityLimit
+	}
+	if limit > maxActivityLimit {
+		limit = maxActivityLimit
+	}
+
+	// Comments come before changes made at the same time, so a page that
+	// ends on a comment continues with all changes made at that time, and
+	// one that ends on a change with the comments made before it
+	var commentsBefore, changesBefore *time.Time
+	var commentID uint
+	var changeID uint64
+	if cursor != nil {
+		commentsBefore, changesBefore = &cursor.at, &cursor.at
+		if cursor.typ == model.ActivityComment {
+			commentID, changeID = uint(cursor.id), math.MaxInt64
+		} else {
+			changeID = cursor.id
+		}
+	}
+
+	comments, err := s.commentRepo.ListForTodo(ctx, todoID, commentsBefore, commentID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.auditRepo.List(ctx, model.AuditFilter{
+		ResourceType: model.AuditResourceTodo,
+		ResourceID:   strconv.FormatUint(uint64(todoID), 10),
+		ByTime:       true,
+		BeforeTime:   changesBefore,
+		BeforeID:     changeID,
+		Limit:        limit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*model.ActivityItem, 0, len(comments)+len(changes))
+	positions := make([]activityCursor, 0, len(comments)+len(changes))
+	for _, comment := range comments {
+		actorID := comment.UserID
+		items = append(items, &model.ActivityItem{
+			Type:       model.ActivityComment,
+			OccurredAt: comment.CreatedAt,
+			ActorID:    &actorID,
+			Comment:    comment,
+		})
+		positions = append(positions, activityCursor{comment.CreatedAt, model.ActivityComment, uint64(comment.ID)})
+	}
+	for _, entry := range changes {
+		items = append(items, &model.ActivityItem{
+			Type:       model.ActivityChange,
+			OccurredAt: entry.OccurredAt,
+			ActorID:    entry.ActorID,
+			Action:     entry.Action,
+			Changes:    entry.Changes,
+		})
+		positions = append(positions, activityCursor{entry.OccurredAt, model.ActivityChange, entry.ID})
+	}
+	sort.Sort(activityTimeline{items, positions})
+
+	page := &model.ActivityPage{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		page.NextBefore = positions[limit-1].encode()
+	}
+	return page, nil
+}
+
+// activityCursor is the position of an item in a todo's timeline
+type activityCursor struct {
+	at  time.Time
+	typ string
+	id  uint64
+}
+
+// newer reports whether c comes before other in the timeline, which is
+// ordered by time, then comments before changes, then ID, newest first
+func (c activityCursor) newer(other activityCursor) bool {
+	if !c.at.Equal(other.at) {
+		return c.at.After(other.at)
+	}
+	if c.typ != other.typ {
+		return c.typ == model.ActivityComment
+	}
+	return c.id > other.id
+}
+
+// encode turns the position into an opaque cursor. The time keeps its
+// offset, as SQLite compares times in the form they were written.
+func (c activityCursor) encode() string {
+	raw := fmt.Sprintf("%s%s:%d:%s", cursorPrefix, c.typ, c.id, c.at.Format(time.RFC3339Nano))
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeActivityCursor parses a cursor issued by encode; empty means the
+// start of the timeline
+func decodeActivityCursor(cursor string) (*activityCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return nil, model.ErrInvalidCursor
+	}
+	parts := strings.SplitN(strings.TrimPrefix(string(raw), cursorPrefix), ":", 3)
+	if len(parts) != 3 || (parts[0] != model.ActivityComment && parts[0] != model.ActivityChange) {
+		return nil, model.ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, model.ErrInvalidCursor
+	}
+	at, err := time.Parse(time.RFC3339Nano, parts[2])
+	if err != nil {
+		return nil, model.ErrInvalidCursor
+	}
+	return &activityCursor{at: at, typ: parts[0], id: id}, nil
+}
+
+// activityTimeline sorts timeline items by their positions
+type activityTimeline struct {
+	items     []*model.ActivityItem
+	positions []activityCursor
+}
+
+func (t activityTimeline) Len() int           { return len(t.items) }
+func (t activityTimeline) Less(i, j int) bool { return t.positions[i].newer(t.positions[j]) }
+func (t activityTimeline) Swap(i, j int) {
+	t.items[i], t.items[j] = t.items[j], t.items[i]
+	t.positions[i], t.positions[j] = t.positions[j], t.positions[i]
+}
+
+// getAuthored loads a comment on a todo visible to the user and checks that
+// the user wrote it
+func (s *commentService) getAuthored(ctx context.Context, userID uint, todoID uint, id uint) (*model.Todo, *model.Comment, error) {
+	todo, err := getOwnedTodo(ctx, s.todoRepo, userID, todoID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	comment, err := s.commentRepo.GetByIDForTodo(ctx, id, todoID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil, model.ErrCommentNotFound
+		}
+		return nil, nil, err
+	}
+	if comment.UserID != userID {
+		return nil, nil, model.ErrNotCommentAuthor
+	}
+	return todo, comment, nil
+}
+
+// resolveMentions looks up the users mentioned in body. Usernames that do
+// not belong to a user are left as plain text.
+func (s *commentService) resolveMentions(ctx context.Context, body string) ([]*model.CommentMention, error) {
+	mentions := []*model.CommentMention{}
+	seen := make(map[uint]bool)
+	for _, username := range parseMentions(body) {
+		user, err := s.userRepo.GetByUsername(ctx, username)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if seen[user.ID] {
+			continue
+		}
+		seen[user.ID] = true
+		mentions = append(mentions, &model.CommentMention{UserID: user.ID, Username: user.Username})
+	}
+	return mentions, nil
+}
+
+// parseMentions returns the distinct usernames mentioned in body in order
+// of first appearance, up to maxMentions
+func parseMentions(body string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		key := strings.ToLower(match[1])
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		usernames = append(usernames, match[1])
+		if len(usernames) == maxMentions {
+			break
+		}
+	}
+	return usernames
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/logger"
+
+	"myapp/internal/database"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+)
+
+// ownedTodoRepository serves the todo with ID 1 to the user with ID 1
+type ownedTodoRepository struct {
+	repository.TodoRepository
+}
+
+func (r *ownedTodoRepository) GetByIDForUser(ctx context.Context, id uint, userID uint) (*model.Todo, error) {
+	if id != 1 || userID != 1 {
+		return nil, repository.ErrNotFound
+	}
+	return &model.Todo{ID: 1, UserID: 1, Title: "todo"}, nil
+}
+
+func TestActivityPagesItemsSharingATimestamp(t *testing.T) {
+	db, err := database.Open(database.Config{
+		Driver: database.SQLite,
+		DSN:    filepath.Join(t.TempDir(), "test.db"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Logger = logger.Discard
+	t.Cleanup(func() { database.Close(db) })
+
+	if err := db.Create(&model.User{ID: 1, Email: "alice@example.com", Username: "alice", Password: "hash"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.Workspace{ID: 1, Name: "alice", CreatedBy: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.Todo{ID: 1, UserID: 1, WorkspaceID: 1, Title: "todo"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Most items share a timestamp, as they do when written by one request,
+	// and in a zone other than UTC, as on a server outside it
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	earlier := at.Add(-time.Minute)
+	for i, created := range []time.Time{at, at, at, earlier} {
+		comment := &model.Comment{ID: uint(i + 1), TodoID: 1, UserID: 1, Body: "comment", CreatedAt: created, UpdatedAt: created}
+		if err := db.Create(comment).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, occurred := range []time.Time{at, at, earlier} {
+		entry := &model.AuditEntry{
+			ID:           uint64(i + 1),
+			OccurredAt:   occurred,
+			Action:       model.AuditTodoUpdated,
+			ResourceType: model.AuditResourceTodo,
+			ResourceID:   "1",
+			Changes:      model.JSONText(fmt.Sprintf(`{"change":%d}`, i+1)),
+		}
+		if err := db.Create(entry).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"comment 3", "comment 2", "comment 1", `change {"change":2}`, `change {"change":1}`, "comment 4", `change {"change":3}`}
+
+	svc := NewCommentService(repository.NewCommentRepository(db), &ownedTodoRepository{}, nil, repository.NewAuditRepository(db), nil)
+	for _, limit := range []int{1, 2, 3, 7} {
+		var got []string
+		before := ""
+		for pages := 0; ; pages++ {
+			if pages > len(want) {
+				t.Fatalf("limit %d: paging does not end", limit)
+			}
+			page, err := svc.Activity(context.Background(), 1, 1, before, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, item := range page.Items {
+				if item.Type == model.ActivityComment {
+					got = append(got, fmt.Sprintf("comment %d", item.Comment.ID))
+				} else {
+					got = append(got, "change "+string(item.Changes))
+				}
+			}
+			if page.NextBefore == "" {
+				break
+			}
+			before = page.NextBefore
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("limit %d: items = %q, want %q", limit, got, want)
+		}
+	}
+
+	for _, cursor := range []string{"not base64!", "djE6Y29tbWVudDox", "djE6Y29tbWVudDoxOnllc3RlcmRheQ"} {
+		if _, err := svc.Activity(context.Background(), 1, 1, cursor, 10); !errors.Is(err, model.ErrInvalidCursor) {
+			t.Errorf("Activity(before=%q) error = %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
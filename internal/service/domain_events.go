@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+	"myapp/internal/events"
+	"myapp/internal/repository"
+)
+
+// emit records a domain event in the outbox of the transaction tx, so it is
+// dispatched if and only if the transaction commits
+func emit(ctx context.Context, tx *repository.Repositories, eventType string, userID uint, data interface{}) error {
+	event, err := events.NewDomainEvent(eventType, userID, data)
+	if err != nil {
+		return err
+	}
+	return tx.Outbox.Add(ctx, event)
+}
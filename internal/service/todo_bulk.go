@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+)
+
+// errBulkAborted rolls back an atomic bulk request after a failing item
+var errBulkAborted = errors.New("bulk request aborted")
+
+// Bulk applies many todo changes for a user in one transaction. Each item
+// is run in its own savepoint and ownership is checked per item. In atomic
+// mode the first failure rolls back every item; in best-effort mode failing
+// items are rolled back individually and the rest are committed.
+func (s *todoService) Bulk(ctx context.Context, userID uint, req *model.TodoBulkRequest) (*model.TodoBulkResponse, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = model.TodoBulkAtomic
+	}
+
+	results := make([]model.TodoBulkItemResult, len(req.Items))
+	for i, item := range req.Items {
+		results[i] = model.TodoBulkItemResult{Index: i, Op: item.Op, ID: item.ID, Error: model.TodoBulkErrSkipped}
+	}
+
+	err := s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		for i, item := range req.Items {
+			var todo *model.Todo
+			err := tx.UnitOfWork.Do(ctx, func(itemTx *repository.Repositories) error {
+				var err error
+				todo, err = s.inTx(itemTx).applyBulkItem(ctx, userID, item)
+				return err
+			})
+
+			results[i] = bulkItemResult(i, item, todo, err)
+			if err != nil {
+				if mode == model.TodoBulkAtomic {
+					return errBulkAborted
+				}
+				continue
+			}
+		}
+		return nil
+	})
+
+	if errors.Is(err, errBulkAborted) {
+		for i := range results {
+			if results[i].Success {
+				results[i] = model.TodoBulkItemResult{Index: i, Op: results[i].Op, ID: results[i].ID, Error: model.TodoBulkErrRolledBack}
+			}
+		}
+		return &model.TodoBulkResponse{Mode: mode, Committed: false, Results: results}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TodoBulkResponse{Mode: mode, Committed: true, Results: results}, nil
+}
+
+// applyBulkItem applies a single bulk item through the regular service
+// methods so that ownership and version checks are shared with them
+func (s *todoService) applyBulkItem(ctx context.Context, userID uint, item model.TodoBulkItem) (*model.Todo, error) {
+	switch item.Op {
+	case model.TodoBulkCreate:
+		return s.Create(ctx, userID, &model.TodoCreateRequest{
+			Title:       item.Title,
+			Description: item.Description,
+		})
+	case model.TodoBulkUpdate:
+		// Fields bulk items cannot carry, such as the due date, are kept
+		current, err := s.getOwned(ctx, userID, item.ID)
+		if err != nil {
+			return nil, err
+		}
+		req := current.UpdateRequest()
+		req.Title = item.Title
+		req.Description = item.Description
+		req.Completed = item.Completed
+		return s.Update(ctx, userID, item.ID, item.Version, &req)
+	case model.TodoBulkComplete:
+		current, err := s.getOwned(ctx, userID, item.ID)
+		if err != nil {
+			return nil, err
+		}
+		req := current.UpdateRequest()
+		req.Completed = true
+		version := item.Version
+		if version == 0 {
+			version = current.Version
+		}
+		return s.Update(ctx, userID, item.ID, version, &req)
+	case model.TodoBulkDelete:
+		if item.Version != 0 {
+			current, err := s.getOwned(ctx, userID, item.ID)
+			if err != nil {
+				return nil, err
+			}
+			if current.Version != item.Version {
+				return nil, model.ErrTodoVersionConflict
+			}
+		}
+		return nil, s.Delete(ctx, userID, item.ID)
+	default:
+		return nil, model.ErrValidation
+	}
+}
+
+// bulkItemResult builds the result of a bulk item from its outcome
+func bulkItemResult(index int, item model.TodoBulkItem, todo *model.Todo, err error) model.TodoBulkItemResult {
+	result := model.TodoBulkItemResult{Index: index, Op: item.Op, ID: item.ID}
+
+	switch {
+	case err == nil:
+		result.Success = true
+		result.Todo = todo
+		if todo != nil {
+			result.ID = todo.ID
+		}
+	case errors.Is(err, model.ErrTodoNotFound):
+		result.Error = model.TodoBulkErrNotFound
+	case errors.Is(err, model.ErrTodoVersionConflict):
+		result.Error = model.TodoBulkErrVersionConflict
+	default:
+		result.Error = model.TodoBulkErrInternal
+	}
+	return result
+}
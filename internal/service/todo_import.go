@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"myapp/internal/model"
+	"myapp/internal/repository"
+)
+
+const (
+	// importBatchSize is the number of todos inserted per statement
+	importBatchSize = 500
+	// exportBatchSize is the number of todos read per query while exporting
+	exportBatchSize = 500
+)
+
+// Import creates the todos of req for the user in one transaction, so that
+// an import either succeeds as a whole or leaves nothing behind. Todos with
+// a due date but no time zone are scheduled in the user's.
+func (s *todoService) Import(ctx context.Context, userID uint, req *model.TodoImportRequest) (*model.TodoImportResponse, error) {
+	timezone, err := s.importTimezone(ctx, userID, req.Todos)
+	if err != nil {
+		return nil, err
+	}
+
+	todos := make([]*model.Todo, 0, len(req.Todos))
+	for _, item := range req.Todos {
+		todo, err := s.importedTodo(ctx, userID, item, timezone)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+
+	if err := s.insertBatches(ctx, todos); err != nil {
+		return nil, err
+	}
+	return &model.TodoImportResponse{Imported: len(todos), Todos: todos}, nil
+}
+
+// ImportStream creates the todos of the rows of an imported file, which
+// next returns one at a time until io.EOF. Rows arrive with the problems
+// found while decoding and validating them; scheduling problems are added
+// here. Rows with problems are reported by line and skipped, while valid
+// rows are committed importBatchSize at a time, each batch in its own
+// transaction, so that files are imported in constant memory. A dry run
+// only validates.
+//
+// If next returns a *model.TodoImportError, the rest of the file cannot be
+// read: the error is returned if nothing was committed yet, and otherwise
+// the rows read so far are committed and the error is reported.
+func (s *todoService) ImportStream(ctx context.Context, userID uint, next func() (model.TodoImportRow, error), dryRun bool) (*model.TodoImportReport, error) {
+	report := &model.TodoImportReport{
+		DryRun: dryRun,
+		Errors: []model.TodoImportError{},
+	}
+
+	var (
+		timezone       string
+		timezoneLoaded bool
+	)
+	batch := make([]*model.Todo, 0, importBatchSize)
+	flush := func() error {
+		if dryRun || len(batch) == 0 {
+			batch = batch[:0]
+			return nil
+		}
+		if err := s.insertBatch(ctx, batch); err != nil {
+			return err
+		}
+		report.Committed = true
+		report.Imported += len(batch)
+		batch = make([]*model.Todo, 0, importBatchSize)
+		return nil
+	}
+
+	for {
+		row, err := next()
+		if err == io.EOF {
+			break
+		}
+		var fileErr *model.TodoImportError
+		if errors.As(err, &fileErr) && report.Committed {
+			report.Errors = append(report.Errors, *fileErr)
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		report.Rows++
+		if len(row.Errors) > 0 {
+			report.Errors = append(report.Errors, row.Errors...)
+			continue
+		}
+		// The user's time zone is looked up once, when a todo first needs it
+		if row.Todo.DueAt != nil && row.Todo.Timezone == "" && !timezoneLoaded {
+			if timezone, err = s.userTimezone(ctx, userID); err != nil {
+				return nil, err
+			}
+			timezoneLoaded = true
+		}
+		todo, err := s.importedTodo(ctx, userID, row.Todo, timezone)
+		if errors.Is(err, model.ErrInvalidRecurrence) {
+			report.Errors = append(report.Errors, model.TodoImportError{
+				Line:    row.Line,
+				Field:   "recurrence",
+				Code:    "invalid_value",
+				Message: "recurrence must be a valid RRULE with a due date",
+			})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		report.Valid++
+
+		batch = append(batch, todo)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Export calls fn with each of the user's todos in ID order. Todos are read
+// in batches, so exports of any size run in constant memory.
+func (s *todoService) Export(ctx context.Context, userID uint, fn func(todo *model.Todo) error) error {
+	return s.todoRepo.EachByUserID(ctx, userID, exportBatchSize, func(todos []*model.Todo) error {
+		for _, todo := range todos {
+			if err := fn(todo); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// importTimezone returns the user's time zone if any of items needs it as
+// a default, so that it is looked up once rather than once per todo
+func (s *todoService) importTimezone(ctx context.Context, userID uint, items []model.TodoUpdateRequest) (string, error) {
+	for _, item := range items {
+		if item.DueAt != nil && item.Timezone == "" {
+			return s.userTimezone(ctx, userID)
+		}
+	}
+	return "", nil
+}
+
+// importedTodo builds a todo of the user from an imported item, scheduling
+// it in timezone unless the item has its own
+func (s *todoService) importedTodo(ctx context.Context, userID uint, item model.TodoUpdateRequest, timezone string) (*model.Todo, error) {
+	todo := &model.Todo{
+		Title:       item.Title,
+		Description: item.Description,
+		Completed:   item.Completed,
+		UserID:      userID,
+	}
+	if item.DueAt != nil && item.Timezone == "" {
+		item.Timezone = timezone
+	}
+	if err := s.schedule(ctx, todo, item.DueAt, item.Recurrence, item.Timezone); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// insertBatch creates todos and records their events in one transaction
+func (s *todoService) insertBatch(ctx context.Context, todos []*model.Todo) error {
+	return s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Todo.CreateBatch(ctx, todos); err != nil {
+			return err
+		}
+		for _, todo := range todos {
+			if err := recordCreated(ctx, tx, todo); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// insertBatches creates todos in one transaction, importBatchSize at a time
+func (s *todoService) insertBatches(ctx context.Context, todos []*model.Todo) error {
+	return s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		for start := 0; start < len(todos); start += importBatchSize {
+			batch := todos[start:min(start+importBatchSize, len(todos))]
+			if err := tx.Todo.CreateBatch(ctx, batch); err != nil {
+				return err
+			}
+			for _, todo := range batch {
+				if err := recordCreated(ctx, tx, todo); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
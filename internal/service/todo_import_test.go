@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"myapp/internal/events"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+)
+
+// batchTodoRepository records the batches created through it
+type batchTodoRepository struct {
+	repository.TodoRepository
+	batches []int
+}
+
+func (r *batchTodoRepository) CreateBatch(ctx context.Context, todos []*model.Todo) error {
+	r.batches = append(r.batches, len(todos))
+	return nil
+}
+
+type discardOutbox struct{ repository.OutboxRepository }
+
+func (discardOutbox) Add(ctx context.Context, event events.DomainEvent) error { return nil }
+
+type discardAudit struct{ repository.AuditRepository }
+
+func (discardAudit) Append(ctx context.Context, entry *model.AuditEntry) error { return nil }
+
+// countingUnitOfWork runs every transaction against tx and counts them
+type countingUnitOfWork struct {
+	tx      *repository.Repositories
+	commits int
+}
+
+func (u *countingUnitOfWork) Do(ctx context.Context, fn func(tx *repository.Repositories) error) error {
+	if err := fn(u.tx); err != nil {
+		return err
+	}
+	u.commits++
+	return nil
+}
+
+// importRows returns a row source of n valid rows, the rows listed in
+// invalid having an error, followed by end
+func importRows(n int, invalid map[int]bool, end error) func() (model.TodoImportRow, error) {
+	line := 0
+	return func() (model.TodoImportRow, error) {
+		if line == n {
+			return model.TodoImportRow{}, end
+		}
+		line++
+		row := model.TodoImportRow{Line: line, Todo: model.TodoUpdateRequest{Title: fmt.Sprintf("Todo %d", line)}}
+		if invalid[line] {
+			row.Errors = []model.TodoImportError{{Line: line, Field: "title", Code: "required"}}
+		}
+		return row, nil
+	}
+}
+
+func newImportService() (*todoService, *batchTodoRepository, *countingUnitOfWork) {
+	todos := &batchTodoRepository{}
+	uow := &countingUnitOfWork{tx: &repository.Repositories{
+		Todo:   todos,
+		Outbox: discardOutbox{},
+		Audit:  discardAudit{},
+	}}
+	return &todoService{todoRepo: todos, uow: uow}, todos, uow
+}
+
+func TestImportStreamCommitsBatchesAsRowsArrive(t *testing.T) {
+	s, todos, uow := newImportService()
+
+	// Rows 3 and 700 are invalid, so the first batch is full after row 501
+	next := importRows(importBatchSize*2+10, map[int]bool{3: true, 700: true}, io.EOF)
+	read, readBeforeCommit := 0, 0
+	report, err := s.ImportStream(context.Background(), 1, func() (model.TodoImportRow, error) {
+		if uow.commits == 1 && readBeforeCommit == 0 {
+			readBeforeCommit = read
+		}
+		read++
+		return next()
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readBeforeCommit != importBatchSize+1 {
+		t.Fatalf("first batch committed after %d rows, want %d", readBeforeCommit, importBatchSize+1)
+	}
+	if want := []int{importBatchSize, importBatchSize, 8}; fmt.Sprint(todos.batches) != fmt.Sprint(want) {
+		t.Fatalf("batches = %v, want %v", todos.batches, want)
+	}
+	if uow.commits != 3 {
+		t.Fatalf("commits = %d, want one per batch", uow.commits)
+	}
+	if !report.Committed || report.Rows != importBatchSize*2+10 || report.Valid != importBatchSize*2+8 ||
+		report.Imported != report.Valid || len(report.Errors) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+}
+
+func TestImportStreamDryRunCommitsNothing(t *testing.T) {
+	s, todos, _ := newImportService()
+
+	report, err := s.ImportStream(context.Background(), 1, importRows(importBatchSize+1, nil, io.EOF), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos.batches) != 0 || report.Committed || report.Imported != 0 || report.Valid != importBatchSize+1 {
+		t.Fatalf("report = %+v, batches = %v", report, todos.batches)
+	}
+}
+
+func TestImportStreamUnreadableFile(t *testing.T) {
+	fileErr := &model.TodoImportError{Line: 42, Code: model.TodoImportInvalidFile, Message: "invalid JSON"}
+
+	// Before anything was committed, the file is rejected
+	s, todos, _ := newImportService()
+	if _, err := s.ImportStream(context.Background(), 1, importRows(10, nil, fileErr), false); err != fileErr {
+		t.Fatalf("ImportStream = %v, want %v", err, fileErr)
+	}
+	if len(todos.batches) != 0 {
+		t.Fatalf("batches = %v, want none", todos.batches)
+	}
+
+	// Afterwards, the rows read so far are committed and the error reported
+	s, todos, _ = newImportService()
+	report, err := s.ImportStream(context.Background(), 1, importRows(importBatchSize+10, nil, fileErr), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != importBatchSize+10 || len(todos.batches) != 2 {
+		t.Fatalf("report = %+v, batches = %v", report, todos.batches)
+	}
+	if len(report.Errors) != 1 || report.Errors[0] != *fileErr {
+		t.Fatalf("errors = %+v, want the file error", report.Errors)
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"myapp/internal/model"
+	"myapp/internal/recurrence"
+)
+
+const (
+	// defaultOccurrenceCount is the number of occurrences previewed when no
+	// count is given
+	defaultOccurrenceCount = 10
+	// maxOccurrenceCount caps the number of occurrences previewed at once
+	maxOccurrenceCount = 100
+)
+
+// Occurrences previews the occurrences that follow a recurring todo's due
+// date, i.e. the due dates completing it would produce in turn
+func (s *todoService) Occurrences(ctx context.Context, userID uint, id uint, count int) (*model.TodoOccurrencesResponse, error) {
+	todo, err := s.getOwned(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.TodoOccurrencesResponse{
+		Recurrence:  todo.Recurrence,
+		Timezone:    todo.Timezone,
+		Occurrences: []time.Time{},
+	}
+	if todo.Recurrence == "" || todo.DueAt == nil {
+		return resp, nil
+	}
+
+	rule, err := recurrence.Parse(todo.Recurrence)
+	if err != nil {
+		return nil, model.ErrInvalidRecurrence
+	}
+	loc := location(todo.Timezone)
+	resp.Occurrences = utcTimes(rule.Occurrences(seriesStart(todo).In(loc), todo.DueAt.In(loc), occurrenceCount(count)))
+	return resp, nil
+}
+
+// PreviewOccurrences expands a recurrence rule that is not saved yet,
+// starting with the given due date if it matches the rule
+func (s *todoService) PreviewOccurrences(ctx context.Context, userID uint, req *model.TodoOccurrencesRequest) (*model.TodoOccurrencesResponse, error) {
+	rule, err := recurrence.Parse(req.Recurrence)
+	if err != nil {
+		return nil, model.ErrInvalidRecurrence
+	}
+
+	timezone := req.Timezone
+	if timezone == "" {
+		if timezone, err = s.userTimezone(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	start := req.DueAt.In(location(timezone))
+	return &model.TodoOccurrencesResponse{
+		Recurrence:  rule.String(),
+		Timezone:    timezone,
+		Occurrences: utcTimes(rule.Occurrences(start, start.Add(-time.Nanosecond), occurrenceCount(req.Count))),
+	}, nil
+}
+
+// schedule sets the due date and recurrence of todo. The rule is stored in
+// canonical form, the time zone defaults to the user's, and the series
+// restarts at the due date whenever the rule or the due date changes.
+func (s *todoService) schedule(ctx context.Context, todo *model.Todo, dueAt *time.Time, rrule, timezone string) error {
+	if rrule != "" {
+		rule, err := recurrence.Parse(rrule)
+		if err != nil || dueAt == nil {
+			return model.ErrInvalidRecurrence
+		}
+		rrule = rule.String()
+	}
+
+	if dueAt != nil {
+		due := dueAt.UTC()
+		dueAt = &due
+		if timezone == "" {
+			var err error
+			if timezone, err = s.userTimezone(ctx, todo.UserID); err != nil {
+				return err
+			}
+		}
+	}
+
+	restart := rrule != todo.Recurrence || !sameTime(dueAt, todo.DueAt) || todo.RecurrenceStart == nil
+	todo.DueAt = dueAt
+	todo.Recurrence = rrule
+	todo.Timezone = timezone
+	switch {
+	case rrule == "":
+		todo.RecurrenceStart = nil
+	case restart:
+		start := *dueAt
+		todo.RecurrenceStart = &start
+	}
+	return nil
+}
+
+// nextOccurrence returns the todo that continues the series of a recurring
+// todo being completed, or nil if the todo does not recur or its series has
+// ended. Its due date is the next occurrence after the completed todo's, in
+// the todo's time zone.
+func nextOccurrence(todo *model.Todo) (*model.Todo, error) {
+	if todo.Recurrence == "" || todo.DueAt == nil {
+		return nil, nil
+	}
+	rule, err := recurrence.Parse(todo.Recurrence)
+	if err != nil {
+		return nil, model.ErrInvalidRecurrence
+	}
+
+	loc := location(todo.Timezone)
+	start := seriesStart(todo)
+	next, ok := rule.Next(start.In(loc), todo.DueAt.In(loc))
+	if !ok {
+		return nil, nil
+	}
+
+	due := next.UTC()
+	return &model.Todo{
+		Title:           todo.Title,
+		Description:     todo.Description,
+		UserID:          todo.UserID,
+		DueAt:           &due,
+		Recurrence:      todo.Recurrence,
+		Timezone:        todo.Timezone,
+		RecurrenceStart: &start,
+	}, nil
+}
+
+// userTimezone returns the time zone the user's todos are scheduled in
+func (s *todoService) userTimezone(ctx context.Context, userID uint) (string, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if user.Timezone == "" {
+		return "UTC", nil
+	}
+	return user.Timezone, nil
+}
+
+// seriesStart returns the DTSTART of a recurring todo's series
+func seriesStart(todo *model.Todo) time.Time {
+	if todo.RecurrenceStart != nil {
+		return *todo.RecurrenceStart
+	}
+	return *todo.DueAt
+}
+
+// location loads an IANA time zone, falling back to UTC for names that are
+// empty or unknown to this host
+func location(name string) *time.Location {
+	if loc, err := time.LoadLocation(name); err == nil && name != "" {
+		return loc
+	}
+	return time.UTC
+}
+
+// occurrenceCount applies the default and maximum to a requested count
+func occurrenceCount(count int) int {
+	if count <= 0 {
+		return defaultOccurrenceCount
+	}
+	if count > maxOccurrenceCount {
+		return maxOccurrenceCount
+	}
+	return count
+}
+
+// sameTime reports whether two optional times are both unset or equal
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// utcTimes converts times to UTC
+func utcTimes(ts []time.Time) []time.Time {
+	utc := make([]time.Time, len(ts))
+	for i, t := range ts {
+		utc[i] = t.UTC()
+	}
+	return utc
+}
@@ -3,26 +3,50 @@ package service
 import (
 	"context"
 	"errors"
+	"myapp/internal/audit"
+	"myapp/internal/database"
+	"myapp/internal/events"
 	"myapp/internal/model"
 	"myapp/internal/repository"
+	"strconv"
+	"time"
 )
 
 // TodoService defines the interface for todo operations
 type TodoService interface {
 	Create(ctx context.Context, userID uint, req *model.TodoCreateRequest) (*model.Todo, error)
-	GetByID(ctx context.Context, id uint) (*model.Todo, error)
+	GetByID(ctx context.Context, userID uint, id uint) (*model.Todo, error)
 	GetByUserID(ctx context.Context, userID uint) ([]*model.Todo, error)
-	Update(ctx context.Context, userID uint, id uint, req *model.TodoUpdateRequest) (*model.Todo, error)
+	LastModified(ctx context.Context, userID uint) (time.Time, error)
+	Update(ctx context.Context, userID uint, id uint, expectedVersion uint, req *model.TodoUpdateRequest) (*model.Todo, error)
 	Delete(ctx context.Context, userID uint, id uint) error
+	Bulk(ctx context.Context, userID uint, req *model.TodoBulkRequest) (*model.TodoBulkResponse, error)
+	Changes(ctx context.Context, userID uint, cursor string, limit int) (*model.TodoChangesResponse, error)
+	Push(ctx context.Context, userID uint, req *model.TodoPushRequest) (*model.TodoPushResponse, error)
+	Occurrences(ctx context.Context, userID uint, id uint, count int) (*model.TodoOccurrencesResponse, error)
+	PreviewOccurrences(ctx context.Context, userID uint, req *model.TodoOccurrencesRequest) (*model.TodoOccurrencesResponse, error)
+	Import(ctx context.Context, userID uint, req *model.TodoImportRequest) (*model.TodoImportResponse, error)
+	ImportStream(ctx context.Context, userID uint, next func() (model.TodoImportRow, error), dryRun bool) (*model.TodoImportReport, error)
+	Export(ctx context.Context, userID uint, fn func(todo *model.Todo) error) error
 }
 
 type todoService struct {
 	todoRepo repository.TodoRepository
+	userRepo repository.UserRepository
+	uow      repository.UnitOfWork
 }
 
-// NewTodoService creates a new TodoService instance
-func NewTodoService(todoRepo repository.TodoRepository) TodoService {
-	return &todoService{todoRepo: todoRepo}
+// NewTodoService creates a new TodoService instance. Changes are made
+// through uow and recorded in the outbox as todo.created, todo.updated,
+// todo.completed and todo.deleted events. The user repository supplies the
+// time zone recurring todos default to.
+func NewTodoService(todoRepo repository.TodoRepository, userRepo repository.UserRepository, uow repository.UnitOfWork) TodoService {
+	return &todoService{todoRepo: todoRepo, userRepo: userRepo, uow: uow}
+}
+
+// TodoDeletedEvent is the payload of todo.deleted events
+type TodoDeletedEvent struct {
+	ID uint `json:"id"`
 }
 
 func (s *todoService) Create(ctx context.Context, userID uint, req *model.TodoCreateRequest) (*model.Todo, error) {
@@ -31,56 +55,175 @@ func (s *todoService) Create(ctx context.Context, userID uint, req *model.TodoCr
 		Description: req.Description,
 		UserID:      userID,
 	}
+	if err := s.schedule(ctx, todo, req.DueAt, req.Recurrence, req.Timezone); err != nil {
+		return nil, err
+	}
 
-	if err := s.todoRepo.Create(ctx, todo); err != nil {
+	err := s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		return insertTodo(ctx, tx, todo)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return todo, nil
 }
 
-func (s *todoService) GetByID(ctx context.Context, id uint) (*model.Todo, error) {
-	return s.todoRepo.GetByID(ctx, id)
+// GetByID retrieves a todo owned by the user, returning model.ErrTodoNotFound
+// if it does not exist or belongs to someone else
+func (s *todoService) GetByID(ctx context.Context, userID uint, id uint) (*model.Todo, error) {
+	return getOwnedTodo(ctx, s.todoRepo, userID, id)
 }
 
 func (s *todoService) GetByUserID(ctx context.Context, userID uint) ([]*model.Todo, error) {
 	return s.todoRepo.GetByUserID(ctx, userID)
 }
 
-func (s *todoService) Update(ctx context.Context, userID uint, id uint, req *model.TodoUpdateRequest) (*model.Todo, error) {
-	todo, err := s.todoRepo.GetByID(ctx, id)
+// LastModified returns when the user's todos last changed, including when
+// one was last deleted, or the zero time if the user never had any
+func (s *todoService) LastModified(ctx context.Context, userID uint) (time.Time, error) {
+	return s.todoRepo.LastModifiedForUser(ctx, userID)
+}
+
+// Update replaces the state of a todo owned by the user with req. A non-zero
+// expectedVersion makes the update conditional on the todo still being at
+// that version; model.ErrTodoVersionConflict is returned if it is not, or if
+// the todo is changed concurrently.
+func (s *todoService) Update(ctx context.Context, userID uint, id uint, expectedVersion uint, req *model.TodoUpdateRequest) (*model.Todo, error) {
+	todo, err := s.getOwned(ctx, userID, id)
 	if err != nil {
 		return nil, err
 	}
-
-	if todo.UserID != userID {
-		return nil, errors.New("unauthorized: todo does not belong to user")
+	if expectedVersion != 0 && todo.Version != expectedVersion {
+		return nil, model.ErrTodoVersionConflict
 	}
 
-	if req.Title != "" {
-		todo.Title = req.Title
+	completed := !todo.Completed && req.Completed
+	before := todo.UpdateRequest()
+
+	todo.Title = req.Title
+	todo.Description = req.Description
+	todo.Completed = req.Completed
+	if err := s.schedule(ctx, todo, req.DueAt, req.Recurrence, req.Timezone); err != nil {
+		return nil, err
 	}
-	if req.Description != "" {
-		todo.Description = req.Description
+
+	// Completing a recurring todo hands the series on to its next occurrence
+	var next *model.Todo
+	if completed {
+		if next, err = nextOccurrence(todo); err != nil {
+			return nil, err
+		}
+		todo.Recurrence = ""
+		todo.RecurrenceStart = nil
 	}
-	todo.Completed = req.Completed
 
-	if err := s.todoRepo.Update(ctx, todo); err != nil {
+	err = s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Todo.Update(ctx, todo); err != nil {
+			return err
+		}
+		if err := auditTodo(ctx, tx, userID, model.AuditTodoUpdated, todo.ID, before, todo.UpdateRequest()); err != nil {
+			return err
+		}
+		if err := emit(ctx, tx, events.TodoUpdated, userID, todo); err != nil {
+			return err
+		}
+		if !completed {
+			return nil
+		}
+		if err := emit(ctx, tx, events.TodoCompleted, userID, todo); err != nil {
+			return err
+		}
+		if next == nil {
+			return nil
+		}
+		return insertTodo(ctx, tx, next)
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return nil, model.ErrTodoVersionConflict
+		}
 		return nil, err
 	}
 
+	todo.NextOccurrence = next
 	return todo, nil
 }
 
 func (s *todoService) Delete(ctx context.Context, userID uint, id uint) error {
-	todo, err := s.todoRepo.GetByID(ctx, id)
+	todo, err := s.getOwned(ctx, userID, id)
 	if err != nil {
 		return err
 	}
 
-	if todo.UserID != userID {
-		return errors.New("unauthorized: todo does not belong to user")
+	return s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		if err := tx.Todo.Delete(ctx, id); err != nil {
+			return err
+		}
+		if err := auditTodo(ctx, tx, userID, model.AuditTodoDeleted, id, todo.UpdateRequest(), nil); err != nil {
+			return err
+		}
+		return emit(ctx, tx, events.TodoDeleted, userID, TodoDeletedEvent{ID: id})
+	})
+}
+
+// inTx returns a copy of the service bound to the transaction of tx. Its
+// writes become savepoints of that transaction.
+func (s *todoService) inTx(tx *repository.Repositories) *todoService {
+	return &todoService{todoRepo: tx.Todo, userRepo: tx.User, uow: tx.UnitOfWork}
+}
+
+// getOwned loads a todo scoped to its owner and maps a repository miss to
+// model.ErrTodoNotFound. The todo is read from the primary, since it is
+// loaded to be changed: a replica may return a stale version.
+func (s *todoService) getOwned(ctx context.Context, userID uint, id uint) (*model.Todo, error) {
+	return getOwnedTodo(database.Primary(ctx), s.todoRepo, userID, id)
+}
+
+// getOwnedTodo loads a todo from todoRepo scoped to its owner and maps a
+// repository miss to model.ErrTodoNotFound. Services that hang data off a
+// todo use it so that they apply the same visibility rules as TodoService.
+func getOwnedTodo(ctx context.Context, todoRepo repository.TodoRepository, userID uint, id uint) (*model.Todo, error) {
+	todo, err := todoRepo.GetByIDForUser(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, model.ErrTodoNotFound
+		}
+		return nil, err
 	}
+	return todo, nil
+}
 
-	return s.todoRepo.Delete(ctx, id)
+// insertTodo creates a todo within tx, recording it in the audit log and
+// the outbox as created by its owner
+func insertTodo(ctx context.Context, tx *repository.Repositories, todo *model.Todo) error {
+	if err := tx.Todo.Create(ctx, todo); err != nil {
+		return err
+	}
+	return recordCreated(ctx, tx, todo)
+}
+
+// recordCreated records the creation of a todo in the audit log and the
+// outbox
+func recordCreated(ctx context.Context, tx *repository.Repositories, todo *model.Todo) error {
+	if err := auditTodo(ctx, tx, todo.UserID, model.AuditTodoCreated, todo.ID, nil, todo.UpdateRequest()); err != nil {
+		return err
+	}
+	return emit(ctx, tx, events.TodoCreated, todo.UserID, todo)
+}
+
+// auditTodo records a change to a todo made by userID within tx. A nil
+// before or after state records a creation or deletion.
+func auditTodo(ctx context.Context, tx *repository.Repositories, userID uint, action string, todoID uint, before, after interface{}) error {
+	changes, err := audit.Diff(before, after)
+	if err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, &model.AuditEntry{
+		ActorID:      &userID,
+		Action:       action,
+		ResourceType: model.AuditResourceTodo,
+		ResourceID:   strconv.FormatUint(uint64(todoID), 10),
+		Changes:      changes,
+	})
 }
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultChangesLimit is the change feed page size when none is given
+	DefaultChangesLimit = 100
+	// MaxChangesLimit is the largest change feed page size
+	MaxChangesLimit = 500
+
+	cursorPrefix = "v1:"
+)
+
+// Changes returns the user's todo changes after cursor, oldest first. An
+// empty cursor starts a full sync, which omits deleted todos.
+func (s *todoService) Changes(ctx context.Context, userID uint, cursor string, limit int) (*model.TodoChangesResponse, error) {
+	since, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultChangesLimit
+	}
+	if limit > MaxChangesLimit {
+		limit = MaxChangesLimit
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	todos, err := s.todoRepo.GetChangesForUser(ctx, userID, since, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.TodoChangesResponse{Changes: make([]model.TodoChange, 0, len(todos))}
+	if len(todos) > limit {
+		todos = todos[:limit]
+		resp.HasMore = true
+	}
+
+	last := since
+	for _, todo := range todos {
+		resp.Changes = append(resp.Changes, todoChange(todo, since))
+		last = todo.ChangeSeq
+	}
+	resp.Cursor = encodeCursor(last)
+
+	return resp, nil
+}
+
+// Push applies changes made offline by a client. Every change is applied in
+// its own savepoint and reconciled with the server copy according to the
+// request's strategy; conflicts report the server copy instead of failing.
+func (s *todoService) Push(ctx context.Context, userID uint, req *model.TodoPushRequest) (*model.TodoPushResponse, error) {
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = model.TodoSyncVersion
+	}
+
+	results := make([]model.TodoPushResult, len(req.Changes))
+	err := s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		for i, change := range req.Changes {
+			var result model.TodoPushResult
+			err := tx.UnitOfWork.Do(ctx, func(itemTx *repository.Repositories) error {
+				var err error
+				result, err = s.inTx(itemTx).applyPushChange(ctx, userID, strategy, change)
+				return err
+			})
+			if err != nil {
+				result = model.TodoPushResult{ID: change.ID, Status: model.TodoPushFailed}
+			}
+			result.Index = i
+			result.ClientID = change.ClientID
+			results[i] = result
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TodoPushResponse{Results: results}, nil
+}
+
+// applyPushChange reconciles a single pushed change with the server copy
+func (s *todoService) applyPushChange(ctx context.Context, userID uint, strategy model.TodoSyncStrategy, change model.TodoPushChange) (model.TodoPushResult, error) {
+	if change.Op == model.TodoSyncUpsert && change.ID == 0 {
+		todo, err := s.Create(ctx, userID, &model.TodoCreateRequest{
+			Title:       change.Title,
+			Description: change.Description,
+		})
+		if err != nil {
+			return model.TodoPushResult{}, err
+		}
+		if change.Completed {
+			req := todo.UpdateRequest()
+			req.Completed = true
+			if todo, err = s.Update(ctx, userID, todo.ID, todo.Version, &req); err != nil {
+				return model.TodoPushResult{}, err
+			}
+		}
+		return model.TodoPushResult{ID: todo.ID, Status: model.TodoPushApplied, Todo: todo}, nil
+	}
+
+	current, err := s.getOwned(ctx, userID, change.ID)
+	if errors.Is(err, model.ErrTodoNotFound) {
+		return model.TodoPushResult{ID: change.ID, Status: model.TodoPushNotFound}, nil
+	}
+	if err != nil {
+		return model.TodoPushResult{}, err
+	}
+
+	conflict := false
+	switch strategy {
+	case model.TodoSyncLastWriterWins:
+		conflict = !change.UpdatedAt.After(current.UpdatedAt)
+	default:
+		conflict = change.Version != current.Version
+	}
+	if conflict {
+		return model.TodoPushResult{ID: current.ID, Status: model.TodoPushConflict, Todo: current}, nil
+	}
+
+	if change.Op == model.TodoSyncDelete {
+		if err := s.Delete(ctx, userID, current.ID); err != nil {
+			return model.TodoPushResult{}, err
+		}
+		return model.TodoPushResult{ID: current.ID, Status: model.TodoPushApplied}, nil
+	}
+
+	// Fields pushed changes cannot carry, such as the due date, are kept
+	req := current.UpdateRequest()
+	req.Title = change.Title
+	req.Description = change.Description
+	req.Completed = change.Completed
+	todo, err := s.Update(ctx, userID, current.ID, current.Version, &req)
+	if err != nil {
+		return model.TodoPushResult{}, err
+	}
+	return model.TodoPushResult{ID: todo.ID, Status: model.TodoPushApplied, Todo: todo}, nil
+}
+
+// todoChange classifies a changed todo relative to the cursor it was
+// fetched after
+func todoChange(todo *model.Todo, since int64) model.TodoChange {
+	switch {
+	case todo.DeletedAt.Valid:
+		deletedAt := todo.DeletedAt.Time
+		return model.TodoChange{Type: model.TodoChangeDeleted, ID: todo.ID, DeletedAt: &deletedAt}
+	case todo.CreatedSeq > since:
+		return model.TodoChange{Type: model.TodoChangeCreated, ID: todo.ID, Todo: todo}
+	default:
+		return model.TodoChange{Type: model.TodoChangeUpdated, ID: todo.ID, Todo: todo}
+	}
+}
+
+// encodeCursor turns a change sequence number into an opaque cursor
+func encodeCursor(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(seq, 10)))
+}
+
+// decodeCursor parses a cursor issued by encodeCursor; empty means the start
+func decodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, model.ErrInvalidCursor
+	}
+	seq, err := strconv.ParseInt(strings.TrimPrefix(string(raw), cursorPrefix), 10, 64)
+	if err != nil || seq < 0 {
+		return 0, model.ErrInvalidCursor
+	}
+	return seq, nil
+}
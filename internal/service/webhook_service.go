@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+	"myapp/internal/webhook"
+)
+
+const (
+	// defaultDeliveryLimit is the number of deliveries listed when no limit is given
+	defaultDeliveryLimit = 50
+	// maxDeliveryLimit caps the number of deliveries listed at once
+	maxDeliveryLimit = 200
+)
+
+// WebhookService defines the interface for webhook operations
+type WebhookService interface {
+	Create(ctx context.Context, userID uint, req *model.WebhookCreateRequest) (*model.WebhookCreateResponse, error)
+	GetByID(ctx context.Context, userID uint, id uint) (*model.Webhook, error)
+	GetByUserID(ctx context.Context, userID uint) ([]*model.Webhook, error)
+	Update(ctx context.Context, userID uint, id uint, req *model.WebhookUpdateRequest) (*model.Webhook, error)
+	Delete(ctx context.Context, userID uint, id uint) error
+	Deliveries(ctx context.Context, userID uint, id uint, limit int) ([]*model.WebhookDelivery, error)
+}
+
+type webhookService struct {
+	webhookRepo repository.WebhookRepository
+}
+
+// NewWebhookService creates a new WebhookService instance
+func NewWebhookService(webhookRepo repository.WebhookRepository) WebhookService {
+	return &webhookService{webhookRepo: webhookRepo}
+}
+
+// Create registers a webhook with a newly generated signing secret
+func (s *webhookService) Create(ctx context.Context, userID uint, req *model.WebhookCreateRequest) (*model.WebhookCreateResponse, error) {
+	if err := webhook.ValidateURL(req.URL); err != nil {
+		return nil, err
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &model.Webhook{
+		UserID: userID,
+		URL:    req.URL,
+		Secret: secret,
+		Events: model.EventTypes(req.Events),
+		Active: true,
+	}
+	if err := s.webhookRepo.Create(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	return &model.WebhookCreateResponse{Webhook: *webhook, Secret: secret}, nil
+}
+
+func (s *webhookService) GetByID(ctx context.Context, userID uint, id uint) (*model.Webhook, error) {
+	return s.getOwned(ctx, userID, id)
+}
+
+func (s *webhookService) GetByUserID(ctx context.Context, userID uint) ([]*model.Webhook, error) {
+	return s.webhookRepo.GetByUserID(ctx, userID)
+}
+
+// Update replaces the URL, subscriptions and active flag of a webhook. The
+// signing secret is kept.
+func (s *webhookService) Update(ctx context.Context, userID uint, id uint, req *model.WebhookUpdateRequest) (*model.Webhook, error) {
+	if err := webhook.ValidateURL(req.URL); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.getOwned(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.URL = req.URL
+	webhook.Events = model.EventTypes(req.Events)
+	webhook.Active = req.Active
+
+	if err := s.webhookRepo.Update(ctx, webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *webhookService) Delete(ctx context.Context, userID uint, id uint) error {
+	if _, err := s.getOwned(ctx, userID, id); err != nil {
+		return err
+	}
+	return s.webhookRepo.Delete(ctx, id)
+}
+
+// Deliveries returns the most recent deliveries of a webhook owned by the user
+func (s *webhookService) Deliveries(ctx context.Context, userID uint, id uint, limit int) ([]*model.WebhookDelivery, error) {
+	if _, err := s.getOwned(ctx, userID, id); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultDeliveryLimit
+	}
+	if limit > maxDeliveryLimit {
+		limit = maxDeliveryLimit
+	}
+	return s.webhookRepo.GetDeliveries(ctx, id, limit)
+}
+
+// getOwned loads a webhook scoped to its owner and maps a repository miss to
+// model.ErrWebhookNotFound
+func (s *webhookService) getOwned(ctx context.Context, userID uint, id uint) (*model.Webhook, error) {
+	webhook, err := s.webhookRepo.GetByIDForUser(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, model.ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// generateWebhookSecret returns a random secret for signing deliveries
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
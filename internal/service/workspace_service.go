@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/audit"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+	"myapp/internal/tenant"
+	"strconv"
+)
+
+// WorkspaceService defines the interface for workspace operations
+type WorkspaceService interface {
+	Create(ctx context.Context, userID uint, req *model.WorkspaceRequest) (*model.WorkspaceMembership, error)
+	List(ctx context.Context, userID uint) ([]*model.WorkspaceMembership, error)
+	ListMembers(ctx context.Context, userID uint, workspaceID uint) ([]*model.WorkspaceMember, error)
+	AddMember(ctx context.Context, userID uint, workspaceID uint, req *model.WorkspaceMemberRequest) (*model.WorkspaceMember, error)
+	RemoveMember(ctx context.Context, userID uint, workspaceID uint, memberID uint) error
+}
+
+type workspaceService struct {
+	workspaceRepo repository.WorkspaceRepository
+	userRepo      repository.UserRepository
+	uow           repository.UnitOfWork
+}
+
+// NewWorkspaceService creates a new WorkspaceService instance. Members can
+// see a workspace and its members; only owners can add or remove others.
+func NewWorkspaceService(workspaceRepo repository.WorkspaceRepository, userRepo repository.UserRepository, uow repository.UnitOfWork) WorkspaceService {
+	return &workspaceService{
+		workspaceRepo: workspaceRepo,
+		userRepo:      userRepo,
+		uow:           uow,
+	}
+}
+
+// Create creates a workspace owned by the user
+func (s *workspaceService) Create(ctx context.Context, userID uint, req *model.WorkspaceRequest) (*model.WorkspaceMembership, error) {
+	var workspace *model.WorkspaceMembership
+	err := s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		var err error
+		workspace, err = createWorkspace(ctx, tx, userID, req.Name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return workspace, nil
+}
+
+// List returns the workspaces the user is a member of
+func (s *workspaceService) List(ctx context.Context, userID uint) ([]*model.WorkspaceMembership, error) {
+	return s.workspaceRepo.ListForUser(ctx, userID)
+}
+
+// ListMembers returns the members of a workspace the user is a member of
+func (s *workspaceService) ListMembers(ctx context.Context, userID uint, workspaceID uint) ([]*model.WorkspaceMember, error) {
+	if _, err := getMembership(ctx, s.workspaceRepo, workspaceID, userID); err != nil {
+		return nil, err
+	}
+	return s.workspaceRepo.ListMembers(ctx, workspaceID)
+}
+
+// AddMember adds the user with the requested email to a workspace the
+// caller owns, as a member unless another role is requested
+func (s *workspaceService) AddMember(ctx context.Context, userID uint, workspaceID uint, req *model.WorkspaceMemberRequest) (*model.WorkspaceMember, error) {
+	if err := s.checkOwner(ctx, workspaceID, userID); err != nil {
+		return nil, err
+	}
+
+	// The user is not a member yet, so the lookup cannot be scoped to it
+	user, err := s.userRepo.GetByEmail(tenant.CrossTenant(ctx), req.Email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, model.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	member := &model.WorkspaceMember{
+		WorkspaceID: workspaceID,
+		UserID:      user.ID,
+		Role:        req.Role,
+	}
+	if member.Role == "" {
+		member.Role = model.WorkspaceRoleMember
+	}
+
+	err = s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		_, err := tx.Workspace.GetMembership(ctx, workspaceID, user.ID)
+		if err == nil {
+			return model.ErrAlreadyWorkspaceMember
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+
+		if err := tx.Workspace.AddMember(ctx, member); err != nil {
+			return err
+		}
+		return auditMember(ctx, tx, userID, model.AuditMemberAdded, member)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	member.User = user
+	return member, nil
+}
+
+// RemoveMember removes a member from a workspace. Owners can remove anyone
+// and members can remove themselves, but a workspace always keeps at least
+// one owner.
+func (s *workspaceService) RemoveMember(ctx context.Context, userID uint, workspaceID uint, memberID uint) error {
+	if memberID == userID {
+		if _, err := getMembership(ctx, s.workspaceRepo, workspaceID, userID); err != nil {
+			return err
+		}
+	} else if err := s.checkOwner(ctx, workspaceID, userID); err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(tx *repository.Repositories) error {
+		membership, err := tx.Workspace.GetMembership(ctx, workspaceID, memberID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return model.ErrUserNotFound
+			}
+			return err
+		}
+
+		if membership.Role == model.WorkspaceRoleOwner {
+			owners, err := tx.Workspace.CountOwners(ctx, workspaceID)
+			if err != nil {
+				return err
+			}
+			if owners <= 1 {
+				return model.ErrLastWorkspaceOwner
+			}
+		}
+
+		if err := tx.Workspace.RemoveMember(ctx, workspaceID, memberID); err != nil {
+			return err
+		}
+		return auditMember(ctx, tx, userID, model.AuditMemberRemoved, &model.WorkspaceMember{
+			WorkspaceID: workspaceID,
+			UserID:      memberID,
+			Role:        membership.Role,
+		})
+	})
+}
+
+// checkOwner returns model.ErrWorkspaceNotFound if the user is not a member
+// of the workspace and model.ErrNotWorkspaceOwner if they are not an owner
+func (s *workspaceService) checkOwner(ctx context.Context, workspaceID uint, userID uint) error {
+	membership, err := getMembership(ctx, s.workspaceRepo, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if membership.Role != model.WorkspaceRoleOwner {
+		return model.ErrNotWorkspaceOwner
+	}
+	return nil
+}
+
+// getMembership retrieves a workspace the user is a member of, translating
+// a missing membership into model.ErrWorkspaceNotFound
+func getMembership(ctx context.Context, workspaceRepo repository.WorkspaceRepository, workspaceID uint, userID uint) (*model.WorkspaceMembership, error) {
+	membership, err := workspaceRepo.GetMembership(ctx, workspaceID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, model.ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+	return membership, nil
+}
+
+// createWorkspace creates a workspace owned by userID in tx and records it
+// in the audit log
+func createWorkspace(ctx context.Context, tx *repository.Repositories, userID uint, name string) (*model.WorkspaceMembership, error) {
+	workspace := &model.Workspace{Name: name, CreatedBy: userID}
+	if err := tx.Workspace.Create(ctx, workspace); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Workspace.AddMember(ctx, &model.WorkspaceMember{
+		WorkspaceID: workspace.ID,
+		UserID:      userID,
+		Role:        model.WorkspaceRoleOwner,
+	}); err != nil {
+		return nil, err
+	}
+
+	changes, err := audit.Diff(nil, workspace)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, &model.AuditEntry{
+		ActorID:      &userID,
+		Action:       model.AuditWorkspaceCreated,
+		ResourceType: model.AuditResourceWorkspace,
+		ResourceID:   strconv.FormatUint(uint64(workspace.ID), 10),
+		Changes:      changes,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &model.WorkspaceMembership{Workspace: *workspace, Role: model.WorkspaceRoleOwner}, nil
+}
+
+// auditMember records a change to a workspace's members in the audit log
+func auditMember(ctx context.Context, tx *repository.Repositories, actorID uint, action string, member *model.WorkspaceMember) error {
+	metadata, err := model.NewJSONText(map[string]interface{}{
+		"user_id": member.UserID,
+		"role":    member.Role,
+	})
+	if err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, &model.AuditEntry{
+		ActorID:      &actorID,
+		Action:       action,
+		ResourceType: model.AuditResourceWorkspace,
+		ResourceID:   strconv.FormatUint(uint64(member.WorkspaceID), 10),
+		Metadata:     metadata,
+	})
+}
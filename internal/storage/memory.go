@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps blobs in memory. It is meant for development and tests;
+// blobs are lost on restart and are not shared between instances.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	blobs map[string]memoryBlob
+}
+
+type memoryBlob struct {
+	data    []byte
+	modTime time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{blobs: make(map[string]memoryBlob)}
+}
+
+func (s *MemoryStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(contextReader{ctx: ctx, r: r})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = memoryBlob{data: data, modTime: time.Now()}
+	return nil
+}
+
+func (s *MemoryStore) Open(ctx context.Context, key string) (*Object, error) {
+	s.mu.RLock()
+	blob, ok := s.blobs[key]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	// Stored data is never modified in place, so readers can share it
+	reader := bytes.NewReader(blob.data)
+	return &Object{ReadSeekCloser: nopCloser{reader}, Size: reader.Size(), ModTime: blob.modTime}, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blobs, key)
+	return nil
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
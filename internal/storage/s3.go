@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config configures an S3Store
+type S3Config struct {
+	// Endpoint is the host and optional port of the S3-compatible service,
+	// e.g. s3.amazonaws.com or localhost:9000 for MinIO
+	Endpoint  string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Region    string
+	UseSSL    bool
+}
+
+// S3Store stores blobs as objects in a bucket of an S3-compatible service
+// such as AWS S3 or MinIO
+type S3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3Store creates an S3Store. The bucket must already exist.
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	return &S3Store{client: client, bucket: cfg.Bucket}, nil
+}
+
+// Put uploads the blob with a multipart upload, since its size is not known
+// in advance. A failed upload is aborted and leaves no object behind.
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, -1, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+// Open opens the object for ranged reads. The object is fetched lazily, so
+// seeking before reading only downloads the requested range.
+func (s *S3Store) Open(ctx context.Context, key string) (*Object, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, translateS3Error(err)
+	}
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, translateS3Error(err)
+	}
+	return &Object{ReadSeekCloser: object, Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	// S3 reports success for missing keys
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+// translateS3Error maps a missing object onto ErrNotFound
+func translateS3Error(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return err
+}
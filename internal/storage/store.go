@@ -0,0 +1,51 @@
+// Package storage keeps file contents, such as todo attachments, outside the
+// database. Metadata lives in the database and refers to blobs by key.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when no blob is stored under a key
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for keys that are empty, absolute or escape the
+// store through ".." segments
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// Object is an open blob. It supports seeking so that it can serve Range
+// requests; the caller must close it.
+type Object struct {
+	io.ReadSeekCloser
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore stores blobs under slash-separated keys
+type BlobStore interface {
+	// Put streams r into the blob at key, replacing any existing blob. If r
+	// fails, nothing is stored and its error is returned.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Open opens the blob at key, returning ErrNotFound if there is none
+	Open(ctx context.Context, key string) (*Object, error)
+	// Delete removes the blob at key. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// validateKey checks that key is a relative, slash-separated path that stays
+// inside the store
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+// Package tenant carries the workspace a request acts in through its
+// context. Repositories read it to scope every query to that workspace, so
+// code that forgets to filter by workspace cannot reach another tenant's
+// rows.
+package tenant
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoWorkspace is returned by tenant-scoped queries run with a context
+// that carries no workspace
+var ErrNoWorkspace = errors.New("tenant: no workspace in context")
+
+type contextKey int
+
+const (
+	workspaceKey contextKey = iota
+	crossTenantKey
+)
+
+// WithWorkspace returns a copy of ctx acting in the given workspace
+func WithWorkspace(ctx context.Context, workspaceID uint) context.Context {
+	return context.WithValue(ctx, workspaceKey, workspaceID)
+}
+
+// WorkspaceID returns the workspace ctx acts in
+func WorkspaceID(ctx context.Context) (uint, bool) {
+	id, ok := ctx.Value(workspaceKey).(uint)
+	return id, ok && id != 0
+}
+
+// CrossTenant returns a copy of ctx that is allowed to look up users
+// regardless of workspace, for the identity lookups that happen before a
+// workspace is known, such as logging in. It does not lift the scoping of
+// workspace-owned data such as todos.
+func CrossTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, crossTenantKey, true)
+}
+
+// IsCrossTenant reports whether ctx was marked with CrossTenant
+func IsCrossTenant(ctx context.Context) bool {
+	cross, _ := ctx.Value(crossTenantKey).(bool)
+	return cross
+}
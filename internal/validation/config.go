@@ -1,52 +1,47 @@
 package validation
 
-import (
-	"os"
-	"strconv"
-	"strings"
-)
-
-// Config holds validation configuration
+// Config holds validation configuration. Its fields are tagged with their
+// configuration keys so that the policy can be set like any other setting
+// through package config.
 type Config struct {
-	Password struct {
-		MinLength        int      `json:"min_length"`
-		RequireUppercase bool     `json:"require_uppercase"`
-		RequireLowercase bool     `json:"require_lowercase"`
-		RequireNumbers   bool     `json:"require_numbers"`
-		RequireSpecial   bool     `json:"require_special"`
-		SpecialChars     string   `json:"special_chars"`
-		Disallowed       []string `json:"disallowed"`
-	} `json:"password"`
+	Password PasswordPolicy `json:"password" key:"password"`
+	Username UsernamePolicy `json:"username" key:"username"`
+	Name     NamePolicy     `json:"name" key:"name"`
+}
+
+// PasswordPolicy holds the rules of the password tag
+type PasswordPolicy struct {
+	MinLength        int      `json:"min_length" key:"min_length" env:"VALIDATION_PASSWORD_MIN_LENGTH" doc:"Minimum password length"`
+	RequireUppercase bool     `json:"require_uppercase" key:"require_uppercase" env:"VALIDATION_PASSWORD_REQUIRE_UPPERCASE" doc:"Require an uppercase letter"`
+	RequireLowercase bool     `json:"require_lowercase" key:"require_lowercase" env:"VALIDATION_PASSWORD_REQUIRE_LOWERCASE" doc:"Require a lowercase letter"`
+	RequireNumbers   bool     `json:"require_numbers" key:"require_numbers" env:"VALIDATION_PASSWORD_REQUIRE_NUMBERS" doc:"Require a digit"`
+	RequireSpecial   bool     `json:"require_special" key:"require_special" env:"VALIDATION_PASSWORD_REQUIRE_SPECIAL" doc:"Require one of the special characters"`
+	SpecialChars     string   `json:"special_chars" key:"special_chars" env:"VALIDATION_PASSWORD_SPECIAL_CHARS" doc:"Characters that count as special"`
+	Disallowed       []string `json:"disallowed" key:"disallowed" env:"VALIDATION_PASSWORD_DISALLOWED" doc:"Passwords that are rejected outright"`
+}
 
-	Username struct {
-		MinLength    int      `json:"min_length"`
-		MaxLength    int      `json:"max_length"`
-		Reserved     []string `json:"reserved"`
-		ProfaneWords []string `json:"profane_words"`
-		AllowedChars string   `json:"allowed_chars"`
-	} `json:"username"`
+// UsernamePolicy holds the rules of the username tag
+type UsernamePolicy struct {
+	MinLength    int      `json:"min_length" key:"min_length" env:"VALIDATION_USERNAME_MIN_LENGTH" doc:"Minimum username length"`
+	MaxLength    int      `json:"max_length" key:"max_length" env:"VALIDATION_USERNAME_MAX_LENGTH" doc:"Maximum username length"`
+	Reserved     []string `json:"reserved" key:"reserved" env:"VALIDATION_USERNAME_RESERVED" doc:"Usernames that cannot be registered"`
+	ProfaneWords []string `json:"profane_words" key:"profane_words" env:"VALIDATION_USERNAME_PROFANE_WORDS" doc:"Words usernames must not contain"`
+	AllowedChars string   `json:"allowed_chars" key:"allowed_chars" env:"VALIDATION_USERNAME_ALLOWED_CHARS" doc:"Characters usernames may consist of"`
+}
 
-	Name struct {
-		MinLength      int    `json:"min_length"`
-		MaxLength      int    `json:"max_length"`
-		AllowedChars   string `json:"allowed_chars"`
-		SpecialChars   string `json:"special_chars"`
-		MaxConsecutive int    `json:"max_consecutive"`
-	} `json:"name"`
+// NamePolicy holds the rules of the name tag
+type NamePolicy struct {
+	MinLength      int    `json:"min_length" key:"min_length" env:"VALIDATION_NAME_MIN_LENGTH" doc:"Minimum length of first and last names"`
+	MaxLength      int    `json:"max_length" key:"max_length" env:"VALIDATION_NAME_MAX_LENGTH" doc:"Maximum length of first and last names"`
+	AllowedChars   string `json:"allowed_chars" key:"allowed_chars" env:"VALIDATION_NAME_ALLOWED_CHARS" doc:"Letters names may consist of"`
+	SpecialChars   string `json:"special_chars" key:"special_chars" env:"VALIDATION_NAME_SPECIAL_CHARS" doc:"Separators allowed between letters"`
+	MaxConsecutive int    `json:"max_consecutive" key:"max_consecutive" env:"VALIDATION_NAME_MAX_CONSECUTIVE" doc:"Maximum run of the same character"`
 }
 
 // DefaultConfig returns the default validation configuration
 func DefaultConfig() *Config {
 	return &Config{
-		Password: struct {
-			MinLength        int      `json:"min_length"`
-			RequireUppercase bool     `json:"require_uppercase"`
-			RequireLowercase bool     `json:"require_lowercase"`
-			RequireNumbers   bool     `json:"require_numbers"`
-			RequireSpecial   bool     `json:"require_special"`
-			SpecialChars     string   `json:"special_chars"`
-			Disallowed       []string `json:"disallowed"`
-		}{
+		Password: PasswordPolicy{
 			MinLength:        8,
 			RequireUppercase: true,
 			RequireLowercase: true,
@@ -55,26 +50,14 @@ func DefaultConfig() *Config {
 			SpecialChars:     "!@#$%^&*()_+-=[]{}|;:,.<>?",
 			Disallowed:       []string{"password", "123456", "qwerty", "admin", "welcome"},
 		},
-		Username: struct {
-			MinLength    int      `json:"min_length"`
-			MaxLength    int      `json:"max_length"`
-			Reserved     []string `json:"reserved"`
-			ProfaneWords []string `json:"profane_words"`
-			AllowedChars string   `json:"allowed_chars"`
-		}{
+		Username: UsernamePolicy{
 			MinLength:    3,
 			MaxLength:    20,
 			Reserved:     []string{"admin", "root", "system", "support", "help", "info", "contact"},
 			ProfaneWords: []string{"fuck", "shit", "ass", "bitch", "cunt"},
 			AllowedChars: "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_",
 		},
-		Name: struct {
-			MinLength      int    `json:"min_length"`
-			MaxLength      int    `json:"max_length"`
-			AllowedChars   string `json:"allowed_chars"`
-			SpecialChars   string `json:"special_chars"`
-			MaxConsecutive int    `json:"max_consecutive"`
-		}{
+		Name: NamePolicy{
 			MinLength:      2,
 			MaxLength:      50,
 			AllowedChars:   "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
@@ -83,40 +66,3 @@ func DefaultConfig() *Config {
 		},
 	}
 }
-
-// LoadConfigFromEnv loads configuration from environment variables
-func LoadConfigFromEnv() *Config {
-	config := DefaultConfig()
-
-	if v := os.Getenv("VALIDATION_PASSWORD_MIN_LENGTH"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			config.Password.MinLength = n
-		}
-	}
-
-	if v := os.Getenv("VALIDATION_PASSWORD_REQUIRE_UPPERCASE"); v != "" {
-		config.Password.RequireUppercase = strings.ToLower(v) == "true"
-	}
-
-	if v := os.Getenv("VALIDATION_PASSWORD_REQUIRE_LOWERCASE"); v != "" {
-		config.Password.RequireLowercase = strings.ToLower(v) == "true"
-	}
-
-	if v := os.Getenv("VALIDATION_PASSWORD_REQUIRE_NUMBERS"); v != "" {
-		config.Password.RequireNumbers = strings.ToLower(v) == "true"
-	}
-
-	if v := os.Getenv("VALIDATION_PASSWORD_REQUIRE_SPECIAL"); v != "" {
-		config.Password.RequireSpecial = strings.ToLower(v) == "true"
-	}
-
-	if v := os.Getenv("VALIDATION_PASSWORD_SPECIAL_CHARS"); v != "" {
-		config.Password.SpecialChars = v
-	}
-
-	if v := os.Getenv("VALIDATION_PASSWORD_DISALLOWED"); v != "" {
-		config.Password.Disallowed = strings.Split(v, ",")
-	}
-
-	return config
-}
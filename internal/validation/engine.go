@@ -0,0 +1,179 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-playground/validator/v10"
+
+	"myapp/internal/recurrence"
+)
+
+// Validator defines the interface for validation
+type Validator interface {
+	Validate(ctx context.Context, value interface{}) error
+}
+
+// Engine validates structs declaratively from their `validate` struct tags.
+// Besides the built-in go-playground tags it understands the policy tags
+// `password`, `username` and `name`, which are checked against Config, and
+// `rrule` for recurrence rules. The policy can be replaced while the engine
+// is in use.
+type Engine struct {
+	config   atomic.Pointer[Config]
+	validate *validator.Validate
+}
+
+// policyCheck is a configurable check backing a custom validation tag
+type policyCheck func(value string) *violation
+
+// NewEngine creates a validation engine using the given policy configuration.
+// A nil config falls back to DefaultConfig.
+func NewEngine(config *Config) *Engine {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	e := &Engine{
+		validate: validator.New(validator.WithRequiredStructEnabled()),
+	}
+	e.config.Store(config)
+
+	// Report fields by their JSON names so error paths match the request body
+	e.validate.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+
+	for tag := range e.policies() {
+		// Checks are looked up on every call so that they follow SetConfig.
+		// Registration only fails for empty tags or nil functions.
+		_ = e.validate.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+			return e.policies()[tag](fl.Field().String()) == nil
+		})
+	}
+
+	_ = e.validate.RegisterValidation("rrule", func(fl validator.FieldLevel) bool {
+		_, err := recurrence.Parse(fl.Field().String())
+		return err == nil
+	})
+
+	return e
+}
+
+// Config returns the policy configuration used by the engine
+func (e *Engine) Config() *Config {
+	return e.config.Load()
+}
+
+// SetConfig replaces the policy configuration for subsequent validations
+func (e *Engine) SetConfig(config *Config) {
+	e.config.Store(config)
+}
+
+// policies maps custom tags to the policy checks that implement them
+func (e *Engine) policies() map[string]policyCheck {
+	config := e.config.Load()
+	return map[string]policyCheck{
+		"password": config.checkPassword,
+		"username": config.checkUsername,
+		"name":     config.checkName,
+	}
+}
+
+// Validate validates a struct and returns ValidationErrors describing every
+// failing field, or nil if the value is valid
+func (e *Engine) Validate(ctx context.Context, value interface{}) error {
+	err := e.validate.StructCtx(ctx, value)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return NewValidationErrors(NewValidationError(ErrInvalidValue, "", err.Error(), http.StatusBadRequest))
+	}
+
+	errs := make([]ValidationError, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		errs = append(errs, e.translate(fe))
+	}
+	return NewValidationErrors(errs...)
+}
+
+// translate converts a go-playground field error into a ValidationError
+func (e *Engine) translate(fe validator.FieldError) ValidationError {
+	field := fieldPath(fe.Namespace())
+
+	if check, ok := e.policies()[fe.Tag()]; ok {
+		if v := check(fmt.Sprint(fe.Value())); v != nil {
+			return NewValidationError(v.code, field, fmt.Sprintf("%s %s", field, v.message), http.StatusUnprocessableEntity)
+		}
+	}
+
+	switch fe.Tag() {
+	case "required", "required_if", "required_unless", "required_with", "required_without":
+		return ErrRequiredField(field)
+	case "email":
+		return ErrInvalidEmail(field)
+	case "url", "http_url":
+		return NewValidationError(ErrInvalidFormat, field,
+			fmt.Sprintf("%s must be a valid URL", field), http.StatusUnprocessableEntity)
+	case "min":
+		if fe.Kind() == reflect.Slice {
+			return NewValidationError(ErrInvalidLength, field,
+				fmt.Sprintf("%s must contain at least %s items", field, fe.Param()), http.StatusUnprocessableEntity)
+		}
+		return NewValidationError(ErrInvalidLength, field,
+			fmt.Sprintf("%s must be at least %s characters long", field, fe.Param()), http.StatusUnprocessableEntity)
+	case "max":
+		if fe.Kind() == reflect.Slice {
+			return NewValidationError(ErrInvalidLength, field,
+				fmt.Sprintf("%s must contain at most %s items", field, fe.Param()), http.StatusUnprocessableEntity)
+		}
+		return NewValidationError(ErrInvalidLength, field,
+			fmt.Sprintf("%s must be at most %s characters long", field, fe.Param()), http.StatusUnprocessableEntity)
+	case "len":
+		return NewValidationError(ErrInvalidLength, field,
+			fmt.Sprintf("%s must be exactly %s characters long", field, fe.Param()), http.StatusUnprocessableEntity)
+	case "alpha", "alphanum", "ascii":
+		return NewValidationError(ErrInvalidChars, field,
+			fmt.Sprintf("%s contains invalid characters", field), http.StatusUnprocessableEntity)
+	case "rrule":
+		message := fmt.Sprintf("%s must be a valid RRULE", field)
+		if _, err := recurrence.Parse(fmt.Sprint(fe.Value())); err != nil {
+			message = fmt.Sprintf("%s is not a valid RRULE: %s", field, strings.TrimPrefix(err.Error(), recurrence.ErrInvalidRule.Error()+": "))
+		}
+		return NewValidationError(ErrInvalidFormat, field, message, http.StatusUnprocessableEntity)
+	case "timezone":
+		return NewValidationError(ErrInvalidValue, field,
+			fmt.Sprintf("%s must be an IANA time zone such as Europe/Berlin", field), http.StatusUnprocessableEntity)
+	case "oneof":
+		return NewValidationError(ErrInvalidValue, field,
+			fmt.Sprintf("%s must be one of: %s", field, fe.Param()), http.StatusUnprocessableEntity)
+	default:
+		return NewValidationError(ErrInvalidValue, field,
+			fmt.Sprintf("%s is invalid", field), http.StatusUnprocessableEntity)
+	}
+}
+
+// fieldPath strips the top-level struct name from a validator namespace,
+// turning "RegisterRequest.email" into "email" and
+// "BulkRequest.items[0].title" into "items[0].title"
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
@@ -0,0 +1,121 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type testItem struct {
+	Title string `json:"title" validate:"required,max=10"`
+}
+
+type testRequest struct {
+	Email      string     `json:"email" validate:"required,email"`
+	Username   string     `json:"username" validate:"username"`
+	Password   string     `json:"password" validate:"password"`
+	FirstName  string     `json:"first_name" validate:"omitempty,name"`
+	Recurrence string     `json:"recurrence" validate:"omitempty,rrule"`
+	Timezone   string     `json:"timezone" validate:"omitempty,timezone"`
+	Priority   string     `json:"priority" validate:"omitempty,oneof=low high"`
+	Items      []testItem `json:"items" validate:"max=2,dive"`
+	Internal   string     `json:"-" validate:"omitempty,len=3"`
+}
+
+// validRequest returns a request that passes the default policy
+func validRequest() testRequest {
+	return testRequest{
+		Email:      "alice@example.com",
+		Username:   "alice",
+		Password:   "Str0ng!Pw",
+		FirstName:  "Alice",
+		Recurrence: "FREQ=WEEKLY;BYDAY=MO",
+		Timezone:   "Europe/Berlin",
+		Priority:   "high",
+		Items:      []testItem{{Title: "one"}},
+	}
+}
+
+func TestEngineValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*testRequest)
+		field  string
+		code   ErrorCode
+	}{
+		{"required", func(r *testRequest) { r.Email = "" }, "email", ErrRequired},
+		{"email", func(r *testRequest) { r.Email = "alice" }, "email", ErrInvalidFormat},
+		{"username policy", func(r *testRequest) { r.Username = "root" }, "username", ErrReservedValue},
+		{"password policy", func(r *testRequest) { r.Password = "Str0ngPw1" }, "password", ErrMissingSpecial},
+		{"name policy", func(r *testRequest) { r.FirstName = "Al1ce" }, "first_name", ErrInvalidChars},
+		{"rrule", func(r *testRequest) { r.Recurrence = "FREQ=SOMETIMES" }, "recurrence", ErrInvalidFormat},
+		{"timezone", func(r *testRequest) { r.Timezone = "Mars/Olympus" }, "timezone", ErrInvalidValue},
+		{"oneof", func(r *testRequest) { r.Priority = "urgent" }, "priority", ErrInvalidValue},
+		{"too many items", func(r *testRequest) { r.Items = make([]testItem, 3) }, "items", ErrInvalidLength},
+		{"nested field", func(r *testRequest) { r.Items[0].Title = "a title too long" }, "items[0].title", ErrInvalidLength},
+		{"unnamed field", func(r *testRequest) { r.Internal = "ab" }, "Internal", ErrInvalidLength},
+	}
+	engine := NewEngine(nil)
+	if err := engine.Validate(context.Background(), validRequest()); err != nil {
+		t.Fatalf("Validate(valid request) = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validRequest()
+			tt.modify(&req)
+			err := engine.Validate(context.Background(), req)
+			var errs ValidationErrors
+			if !errors.As(err, &errs) || len(errs.Errors) != 1 {
+				t.Fatalf("Validate() = %v, want one ValidationError", err)
+			}
+			got := errs.Errors[0]
+			if got.Field != tt.field || got.Code != tt.code {
+				t.Fatalf("error = %s %q (%s), want %s %q", got.Field, got.Code, got.Message, tt.field, tt.code)
+			}
+		})
+	}
+}
+
+func TestEngineReportsEveryField(t *testing.T) {
+	req := validRequest()
+	req.Email, req.Username, req.Password = "", "x", "weak"
+	err := NewEngine(nil).Validate(context.Background(), req)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs.Errors) != 3 {
+		t.Fatalf("Validate() = %v, want three errors", err)
+	}
+	if errs.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", errs.StatusCode, http.StatusUnprocessableEntity)
+	}
+	if got, want := errs.Errors[2].Message, "password must be at least 8 characters long"; got != want {
+		t.Errorf("password message = %q, want %q", got, want)
+	}
+}
+
+func TestEngineSetConfig(t *testing.T) {
+	engine := NewEngine(nil)
+	req := validRequest()
+	req.Password = "simple"
+	if err := engine.Validate(context.Background(), req); err == nil {
+		t.Fatal("Validate() accepted a password breaking the default policy")
+	}
+
+	config := DefaultConfig()
+	config.Password = PasswordPolicy{MinLength: 6}
+	engine.SetConfig(config)
+	if err := engine.Validate(context.Background(), req); err != nil {
+		t.Fatalf("Validate() after relaxing the policy = %v", err)
+	}
+	if engine.Config() != config {
+		t.Error("Config() does not return the configuration set")
+	}
+}
+
+func TestEngineRejectsNonStructs(t *testing.T) {
+	err := NewEngine(nil).Validate(context.Background(), "not a struct")
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || errs.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Validate(string) = %v, want a 400 ValidationErrors", err)
+	}
+}
@@ -21,6 +21,13 @@ const (
 	ErrInvalidChars     ErrorCode = "invalid_chars"
 	ErrConsecutiveChars ErrorCode = "consecutive_chars"
 	ErrInvalidBoundary  ErrorCode = "invalid_boundary"
+
+	// Password policy errors
+	ErrMissingUppercase ErrorCode = "missing_uppercase"
+	ErrMissingLowercase ErrorCode = "missing_lowercase"
+	ErrMissingNumber    ErrorCode = "missing_number"
+	ErrMissingSpecial   ErrorCode = "missing_special"
+	ErrDisallowedValue  ErrorCode = "disallowed_value"
 )
 
 // ValidationError represents a validation error with detailed information
@@ -90,22 +97,4 @@ var (
 			http.StatusUnprocessableEntity,
 		)
 	}
-
-	ErrInvalidPassword = func(field string) ValidationError {
-		return NewValidationError(
-			ErrInvalidValue,
-			field,
-			"Password must be at least 8 characters long and contain uppercase, lowercase, number, and special character",
-			http.StatusUnprocessableEntity,
-		)
-	}
-
-	ErrInvalidUsername = func(field string) ValidationError {
-		return NewValidationError(
-			ErrInvalidValue,
-			field,
-			"Username must be 3-20 characters long, start with a letter, and contain only alphanumeric characters and underscores",
-			http.StatusUnprocessableEntity,
-		)
-	}
 )
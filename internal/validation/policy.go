@@ -0,0 +1,139 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// violation describes why a value failed a configurable policy check
+type violation struct {
+	code    ErrorCode
+	message string
+}
+
+// checkPassword validates a password against the password policy and
+// returns the first violation, or nil if the password is acceptable
+func (c *Config) checkPassword(value string) *violation {
+	p := c.Password
+
+	if utf8.RuneCountInString(value) < p.MinLength {
+		return &violation{ErrInvalidLength, fmt.Sprintf("must be at least %d characters long", p.MinLength)}
+	}
+
+	var hasUpper, hasLower, hasNumber, hasSpecial bool
+	for _, r := range value {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasNumber = true
+		case strings.ContainsRune(p.SpecialChars, r):
+			hasSpecial = true
+		}
+	}
+
+	switch {
+	case p.RequireUppercase && !hasUpper:
+		return &violation{ErrMissingUppercase, "must contain at least one uppercase letter"}
+	case p.RequireLowercase && !hasLower:
+		return &violation{ErrMissingLowercase, "must contain at least one lowercase letter"}
+	case p.RequireNumbers && !hasNumber:
+		return &violation{ErrMissingNumber, "must contain at least one number"}
+	case p.RequireSpecial && !hasSpecial:
+		return &violation{ErrMissingSpecial, fmt.Sprintf("must contain at least one of %s", p.SpecialChars)}
+	}
+
+	lower := strings.ToLower(value)
+	for _, word := range p.Disallowed {
+		if word != "" && strings.Contains(lower, strings.ToLower(word)) {
+			return &violation{ErrDisallowedValue, "must not contain common words or sequences"}
+		}
+	}
+
+	return nil
+}
+
+// checkUsername validates a username against the username policy
+func (c *Config) checkUsername(value string) *violation {
+	u := c.Username
+
+	n := utf8.RuneCountInString(value)
+	if n < u.MinLength || n > u.MaxLength {
+		return &violation{ErrInvalidLength, fmt.Sprintf("must be %d-%d characters long", u.MinLength, u.MaxLength)}
+	}
+
+	for _, r := range value {
+		if !strings.ContainsRune(u.AllowedChars, r) {
+			return &violation{ErrInvalidChars, "must contain only letters, numbers and underscores"}
+		}
+	}
+
+	if first, _ := utf8.DecodeRuneInString(value); !unicode.IsLetter(first) {
+		return &violation{ErrInvalidBoundary, "must start with a letter"}
+	}
+
+	lower := strings.ToLower(value)
+	for _, word := range u.Reserved {
+		if lower == strings.ToLower(word) {
+			return &violation{ErrReservedValue, "is reserved"}
+		}
+	}
+	for _, word := range u.ProfaneWords {
+		if word != "" && strings.Contains(lower, strings.ToLower(word)) {
+			return &violation{ErrProfaneContent, "contains inappropriate language"}
+		}
+	}
+
+	return nil
+}
+
+// checkName validates a personal name against the name policy
+func (c *Config) checkName(value string) *violation {
+	nc := c.Name
+
+	n := utf8.RuneCountInString(value)
+	if n < nc.MinLength || n > nc.MaxLength {
+		return &violation{ErrInvalidLength, fmt.Sprintf("must be %d-%d characters long", nc.MinLength, nc.MaxLength)}
+	}
+
+	for _, r := range value {
+		if !strings.ContainsRune(nc.AllowedChars, r) && !strings.ContainsRune(nc.SpecialChars, r) {
+			return &violation{ErrInvalidChars, fmt.Sprintf("must contain only letters and %q", nc.SpecialChars)}
+		}
+	}
+
+	first, _ := utf8.DecodeRuneInString(value)
+	last, _ := utf8.DecodeLastRuneInString(value)
+	if strings.ContainsRune(nc.SpecialChars, first) || strings.ContainsRune(nc.SpecialChars, last) {
+		return &violation{ErrInvalidBoundary, "must start and end with a letter"}
+	}
+
+	if nc.MaxConsecutive > 0 && maxRun(value) > nc.MaxConsecutive {
+		return &violation{ErrConsecutiveChars, fmt.Sprintf("must not repeat a character more than %d times in a row", nc.MaxConsecutive)}
+	}
+
+	return nil
+}
+
+// maxRun returns the length of the longest run of identical characters,
+// ignoring case
+func maxRun(value string) int {
+	longest, run := 0, 0
+	var prev rune = -1
+	for _, r := range strings.ToLower(value) {
+		if r == prev {
+			run++
+		} else {
+			run = 1
+			prev = r
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+	return longest
+}
@@ -0,0 +1,107 @@
+package validation
+
+import "testing"
+
+// checkCode returns the code of a violation, or "" for none
+func checkCode(v *violation) ErrorCode {
+	if v == nil {
+		return ""
+	}
+	return v.code
+}
+
+func TestCheckPassword(t *testing.T) {
+	tests := []struct {
+		password string
+		want     ErrorCode
+	}{
+		{"Str0ng!Pw", ""},
+		{"Äbc1!xyzé", ""},
+		{"Sh0rt!", ErrInvalidLength},
+		{"lower0nly!", ErrMissingUppercase},
+		{"UPPER0NLY!", ErrMissingLowercase},
+		{"NoNumbers!", ErrMissingNumber},
+		{"NoSpecial1", ErrMissingSpecial},
+		{"MyPassword1!", ErrDisallowedValue},
+		{"Qwerty12!x", ErrDisallowedValue},
+	}
+	config := DefaultConfig()
+	for _, tt := range tests {
+		if got := checkCode(config.checkPassword(tt.password)); got != tt.want {
+			t.Errorf("checkPassword(%q) = %q, want %q", tt.password, got, tt.want)
+		}
+	}
+
+	relaxed := DefaultConfig()
+	relaxed.Password = PasswordPolicy{MinLength: 4}
+	if v := relaxed.checkPassword("abcd"); v != nil {
+		t.Errorf("checkPassword with no requirements = %q, want none", v.code)
+	}
+}
+
+func TestCheckUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		want     ErrorCode
+	}{
+		{"alice", ""},
+		{"alice_99", ""},
+		{"al", ErrInvalidLength},
+		{"a_very_long_username_x", ErrInvalidLength},
+		{"alice-b", ErrInvalidChars},
+		{"alicé", ErrInvalidChars},
+		{"9lives", ErrInvalidBoundary},
+		{"_alice", ErrInvalidBoundary},
+		{"Admin", ErrReservedValue},
+		{"badass", ErrProfaneContent},
+	}
+	config := DefaultConfig()
+	for _, tt := range tests {
+		if got := checkCode(config.checkUsername(tt.username)); got != tt.want {
+			t.Errorf("checkUsername(%q) = %q, want %q", tt.username, got, tt.want)
+		}
+	}
+}
+
+func TestCheckName(t *testing.T) {
+	tests := []struct {
+		name string
+		want ErrorCode
+	}{
+		{"Alice", ""},
+		{"Mary-Jane O'Neil", ""},
+		{"Anna", ""},
+		{"A", ErrInvalidLength},
+		{"Alice2", ErrInvalidChars},
+		{"Zoë", ErrInvalidChars},
+		{"-Alice", ErrInvalidBoundary},
+		{"Alice ", ErrInvalidBoundary},
+		{"Aaaron", ErrConsecutiveChars},
+		{"Jo--Ann", ""},
+		{"Jo---Ann", ErrConsecutiveChars},
+	}
+	config := DefaultConfig()
+	for _, tt := range tests {
+		if got := checkCode(config.checkName(tt.name)); got != tt.want {
+			t.Errorf("checkName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMaxRun(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 0},
+		{"abc", 1},
+		{"aabbb", 3},
+		{"aAa", 3},
+		{"ééé", 3},
+	}
+	for _, tt := range tests {
+		if got := maxRun(tt.value); got != tt.want {
+			t.Errorf("maxRun(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"myapp/internal/model"
+)
+
+// errForbiddenAddress is returned when a delivery would connect to an address
+// that is not publicly routable
+var errForbiddenAddress = errors.New("webhook: endpoint address is not allowed")
+
+// forbiddenPrefixes lists special-purpose ranges that are not covered by the
+// net/netip predicates but must not be reached either
+var forbiddenPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, which may map to private IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, which may map to private IPv4
+	netip.MustParsePrefix("::ffff:0:0:0/96"), // IPv4-translated
+}
+
+// forbiddenIP reports whether deliveries must not be sent to ip: loopback,
+// private, link-local, unspecified, multicast and other special-purpose
+// addresses are reserved for the service's own network
+func forbiddenIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return true
+	}
+	for _, prefix := range forbiddenPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateURL checks that raw is an absolute http or https URL naming a host
+// that may receive deliveries. Host names are only resolved when a delivery
+// is sent, where the client of NewClient checks the address again.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return model.ErrInvalidWebhookURL
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.User != nil || u.Opaque != "" {
+		return model.ErrInvalidWebhookURL
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return model.ErrInvalidWebhookURL
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && forbiddenIP(ip) {
+		return model.ErrInvalidWebhookURL
+	}
+	return nil
+}
+
+// NewClient returns the HTTP client deliveries are sent with. It refuses to
+// connect to addresses rejected by forbiddenIP, checking every address a
+// host name resolves to as it is dialed, so that DNS cannot be used to reach
+// the internal network. Redirects are not followed and no proxy is used.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Control:   controlAddress,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		// A redirect is reported as the endpoint's response, as its target
+		// has not been validated
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// controlAddress is a net.Dialer Control function rejecting forbidden
+// addresses once the host name has been resolved
+func controlAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil || forbiddenIP(addrPort.Addr()) {
+		return errForbiddenAddress
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"myapp/internal/events"
+	"myapp/internal/model"
+	"myapp/internal/repository"
+)
+
+// Payload is the JSON body sent to webhook endpoints
+type Payload struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Enqueuer queues a delivery of each domain event for every active webhook
+// of the event's user that is subscribed to its type
+type Enqueuer struct {
+	repo repository.WebhookRepository
+}
+
+// NewEnqueuer creates a new Enqueuer
+func NewEnqueuer(repo repository.WebhookRepository) *Enqueuer {
+	return &Enqueuer{repo: repo}
+}
+
+// Handle is an outbox handler. Deliveries are keyed by webhook and event
+// ID, so handling the same event again does not deliver it twice.
+func (e *Enqueuer) Handle(ctx context.Context, event events.DomainEvent) error {
+	webhooks, err := e.repo.GetActiveByUserID(ctx, event.UserID)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(Payload{
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.OccurredAt,
+		Data:      event.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	var deliveries []*model.WebhookDelivery
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(event.Type) {
+			continue
+		}
+		deliveries = append(deliveries, &model.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       string(body),
+			Status:        model.WebhookDeliveryPending,
+			NextAttemptAt: time.Now(),
+		})
+	}
+
+	return e.repo.CreateDeliveries(ctx, deliveries)
+}
@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"myapp/internal/model"
+	"myapp/internal/repository"
+)
+
+// maxDrainBody is how much of a response body is read so that the
+// connection can be reused
+const maxDrainBody = 1024
+
+// Config configures delivery workers
+type Config struct {
+	// Workers is the number of deliveries sent concurrently
+	Workers int
+	// BatchSize is the number of due deliveries claimed per poll
+	BatchSize int
+	// PollInterval is how long to wait between polls when the outbox is idle
+	PollInterval time.Duration
+	// Timeout bounds each delivery request
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts before a delivery is failed
+	MaxAttempts int
+	// BackoffBase is the delay before the first retry; each further retry
+	// doubles it, up to BackoffMax
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+}
+
+// DefaultConfig returns the default worker configuration
+func DefaultConfig() Config {
+	return Config{
+		Workers:      4,
+		BatchSize:    32,
+		PollInterval: time.Second,
+		Timeout:      10 * time.Second,
+		MaxAttempts:  8,
+		BackoffBase:  30 * time.Second,
+		BackoffMax:   6 * time.Hour,
+	}
+}
+
+// Worker sends queued webhook deliveries
+type Worker struct {
+	repo   repository.WebhookRepository
+	client *http.Client
+	config Config
+}
+
+// NewWorker creates a new Worker. A nil client uses NewClient with the
+// configured timeout; zero config fields take their default values.
+func NewWorker(repo repository.WebhookRepository, client *http.Client, config Config) *Worker {
+	defaults := DefaultConfig()
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.BackoffBase <= 0 {
+		config.BackoffBase = defaults.BackoffBase
+	}
+	if config.BackoffMax <= 0 {
+		config.BackoffMax = defaults.BackoffMax
+	}
+	if client == nil {
+		client = NewClient(config.Timeout)
+	}
+	return &Worker{repo: repo, client: client, config: config}
+}
+
+// Run delivers due deliveries until ctx is cancelled, then waits for
+// in-flight deliveries to finish
+func (w *Worker) Run(ctx context.Context) {
+	for {
+		n, err := w.ProcessDue(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("webhook: failed to claim deliveries", "error", err)
+		}
+
+		// Keep draining while batches come back full
+		if err == nil && n == w.config.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.config.PollInterval):
+		}
+	}
+}
+
+// ProcessDue claims one batch of due deliveries, sends them and records the
+// outcomes. It returns the number of deliveries attempted.
+func (w *Worker) ProcessDue(ctx context.Context) (int, error) {
+	// A claim is leased for long enough to cover every request in the batch
+	lease := 2 * w.config.Timeout
+	deliveries, err := w.repo.ClaimDueDeliveries(ctx, time.Now(), lease, w.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	jobs := make(chan *model.WebhookDelivery)
+	var wg sync.WaitGroup
+	for i := 0; i < w.config.Workers && i < len(deliveries); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range jobs {
+				w.deliver(delivery)
+			}
+		}()
+	}
+	for _, delivery := range deliveries {
+		jobs <- delivery
+	}
+	close(jobs)
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+// deliver makes one attempt at a delivery and records the outcome. It is
+// not tied to the Run context so that shutdown does not abandon a request
+// halfway and count it as a failure.
+func (w *Worker) deliver(delivery *model.WebhookDelivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), w.config.Timeout)
+	defer cancel()
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+
+	status, err := w.send(ctx, delivery)
+	delivery.ResponseStatus = status
+	switch {
+	case err == nil:
+		delivery.Status = model.WebhookDeliverySucceeded
+		delivery.LastError = ""
+	case delivery.Attempts >= w.config.MaxAttempts:
+		delivery.Status = model.WebhookDeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.NextAttemptAt = now.Add(w.backoff(delivery.Attempts))
+		delivery.LastError = err.Error()
+	}
+
+	if err := w.repo.UpdateDelivery(ctx, delivery); err != nil {
+		slog.Error("webhook: failed to record delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// send posts the delivery payload and returns the response status. Any
+// status outside 2xx is an error. Only the status is recorded, as the
+// response body is under the control of whoever registered the endpoint.
+func (w *Worker) send(ctx context.Context, delivery *model.WebhookDelivery) (int, error) {
+	webhook := delivery.Webhook
+	if webhook == nil || !webhook.Active {
+		return 0, fmt.Errorf("webhook is disabled")
+	}
+
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "myapp-webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.EventID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		if errors.Is(err, errForbiddenAddress) {
+			// Do not reveal what the host name resolved to
+			return 0, errForbiddenAddress
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the attempt following the given one,
+// doubling from BackoffBase up to BackoffMax with up to 20% jitter so that
+// retries against a recovering endpoint are spread out
+func (w *Worker) backoff(attempt int) time.Duration {
+	delay := w.config.BackoffBase
+	for i := 1; i < attempt && delay < w.config.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > w.config.BackoffMax {
+		delay = w.config.BackoffMax
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
@@ -14,7 +14,10 @@ fi
 SCRIPT_DIR="$( cd "$( dirname "${BASH_SOURCE[0]}" )" && pwd )"
 PROJECT_ROOT="$(dirname "$SCRIPT_DIR")"
 
-# Run migrations
-PGPASSWORD=$DB_PASSWORD psql -h $DB_HOST -p $DB_PORT -U $DB_USER -d $DB_NAME -f "$PROJECT_ROOT/internal/db/migrations/000001_init_schema.up.sql"
+# Run migrations in order
+for migration in "$PROJECT_ROOT"/internal/db/migrations/*.up.sql; do
+    echo "Applying $(basename "$migration")"
+    PGPASSWORD=$DB_PASSWORD psql -h $DB_HOST -p $DB_PORT -U $DB_USER -d $DB_NAME -v ON_ERROR_STOP=1 -f "$migration" || exit 1
+done
 
 echo "Migrations completed" 
\ No newline at end of file